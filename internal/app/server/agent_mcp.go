@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"xiaozhi-esp32-server-golang/internal/app/server/agent_mcp"
+	"xiaozhi-esp32-server-golang/internal/app/server/chat"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// agentMCPController 为智能体 MCP 服务器提供设备查询和控制能力
+type agentMCPController struct {
+	app *App
+}
+
+func (c *agentMCPController) deviceInfo(chatManager *chat.ChatManager) agent_mcp.DeviceInfo {
+	state := chatManager.GetClientState()
+	return agent_mcp.DeviceInfo{
+		DeviceID:   state.DeviceID,
+		AgentID:    state.AgentID,
+		Activated:  state.IsActivated,
+		ListenMode: state.ListenMode,
+		SessionID:  state.SessionID,
+	}
+}
+
+// getChatManager 获取属于该智能体的在线设备
+func (c *agentMCPController) getChatManager(agentID string, deviceID string) (*chat.ChatManager, bool) {
+	chatManager, exists := c.app.GetChatManager(deviceID)
+	if !exists || chatManager == nil || chatManager.GetClientState() == nil {
+		return nil, false
+	}
+	if chatManager.GetClientState().AgentID != agentID {
+		return nil, false
+	}
+	return chatManager, true
+}
+
+func (c *agentMCPController) ListDevices(agentID string) []agent_mcp.DeviceInfo {
+	devices := make([]agent_mcp.DeviceInfo, 0)
+	for _, chatManager := range c.app.GetAllChatManagers() {
+		state := chatManager.GetClientState()
+		if state == nil || state.AgentID != agentID {
+			continue
+		}
+		devices = append(devices, c.deviceInfo(chatManager))
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].DeviceID < devices[j].DeviceID
+	})
+	return devices
+}
+
+func (c *agentMCPController) GetDevice(agentID string, deviceID string) (agent_mcp.DeviceInfo, bool) {
+	chatManager, ok := c.getChatManager(agentID, deviceID)
+	if !ok {
+		return agent_mcp.DeviceInfo{}, false
+	}
+	return c.deviceInfo(chatManager), true
+}
+
+func (c *agentMCPController) Speak(agentID string, deviceID string, text string) error {
+	chatManager, ok := c.getChatManager(agentID, deviceID)
+	if !ok {
+		return fmt.Errorf("device %s not found or offline", deviceID)
+	}
+	return chatManager.InjectMessage(text, true)
+}
+
+func (c *agentMCPController) Ask(ctx context.Context, agentID string, deviceID string, question string, speak bool) (string, error) {
+	chatManager, ok := c.getChatManager(agentID, deviceID)
+	if !ok {
+		return "", fmt.Errorf("device %s not found or offline", deviceID)
+	}
+	answer, err := chatManager.AskAgent(ctx, question)
+	if err != nil {
+		return "", err
+	}
+	if speak && answer != "" {
+		if err := chatManager.InjectMessage(answer, true); err != nil {
+			log.Warnf("智能体MCP服务器: 播报回答失败, device=%s err=%v", deviceID, err)
+		}
+	}
+	return answer, nil
+}
@@ -0,0 +1,124 @@
+package agent_mcp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	serverName    = "xiaozhi-agent"
+	serverVersion = "1.0.0"
+)
+
+// DeviceInfo 智能体下在线设备的信息
+type DeviceInfo struct {
+	DeviceID   string   `json:"device_id"`
+	AgentID    string   `json:"agent_id"`
+	Activated  bool     `json:"activated"`
+	ListenMode string   `json:"listen_mode,omitempty"`
+	SessionID  string   `json:"session_id,omitempty"`
+	Tools      []string `json:"tools,omitempty"`
+}
+
+// DeviceController 提供设备查询和控制能力，由 App 实现
+type DeviceController interface {
+	// ListDevices 返回智能体下的在线设备
+	ListDevices(agentID string) []DeviceInfo
+	// GetDevice 返回智能体下指定在线设备，设备不在线或不属于该智能体时返回 false
+	GetDevice(agentID string, deviceID string) (DeviceInfo, bool)
+	// Speak 在设备上直接播报文本
+	Speak(agentID string, deviceID string, text string) error
+	// Ask 使用设备当前智能体配置回答问题，speak 为 true 时同时在设备上播报回答
+	Ask(ctx context.Context, agentID string, deviceID string, question string, speak bool) (string, error)
+}
+
+// Server 将每个智能体暴露为一个 streamable-HTTP MCP 服务器
+type Server struct {
+	controller DeviceController
+
+	mu     sync.Mutex
+	agents map[string]*agentServer
+}
+
+// NewServer 创建智能体 MCP 服务器
+func NewServer(controller DeviceController) *Server {
+	return &Server{
+		controller: controller,
+		agents:     make(map[string]*agentServer),
+	}
+}
+
+// Enabled 是否开启智能体 MCP 服务器，默认开启
+func Enabled() bool {
+	if !viper.IsSet("agent_mcp_server.enable") {
+		return true
+	}
+	return viper.GetBool("agent_mcp_server.enable")
+}
+
+// exposeDeviceTools 是否将设备自身的 MCP 工具暴露给外部客户端，默认开启
+func exposeDeviceTools() bool {
+	if !viper.IsSet("agent_mcp_server.expose_device_tools") {
+		return true
+	}
+	return viper.GetBool("agent_mcp_server.expose_device_tools")
+}
+
+// ServeAgent 处理指定智能体的 MCP 请求，调用方负责鉴权
+func (s *Server) ServeAgent(w http.ResponseWriter, r *http.Request, agentID string) {
+	agent := s.getAgentServer(agentID)
+	if exposeDeviceTools() {
+		agent.syncDeviceTools(r.Context())
+	} else {
+		agent.clearDeviceTools()
+	}
+	agent.httpServer.ServeHTTP(w, r)
+}
+
+func (s *Server) getAgentServer(agentID string) *agentServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if agent, ok := s.agents[agentID]; ok {
+		return agent
+	}
+	agent := newAgentServer(agentID, s.controller)
+	s.agents[agentID] = agent
+	log.Infof("创建智能体MCP服务器, agent=%s", agentID)
+	return agent
+}
+
+// agentServer 单个智能体的 MCP 服务器
+type agentServer struct {
+	agentID    string
+	controller DeviceController
+	mcpServer  *server.MCPServer
+	httpServer *server.StreamableHTTPServer
+
+	syncMu      sync.Mutex
+	deviceTools map[string]deviceToolRef // 对外工具名 -> 设备工具
+}
+
+func newAgentServer(agentID string, controller DeviceController) *agentServer {
+	mcpServer := server.NewMCPServer(
+		serverName,
+		serverVersion,
+		server.WithToolCapabilities(true),
+		server.WithRecovery(),
+		server.WithInstructions("控制小智智能体下的在线设备：查询设备、在设备上播报、向智能体提问以及调用设备自身的工具。"),
+	)
+	agent := &agentServer{
+		agentID:     agentID,
+		controller:  controller,
+		mcpServer:   mcpServer,
+		deviceTools: make(map[string]deviceToolRef),
+	}
+	agent.registerBuiltinTools()
+	agent.httpServer = server.NewStreamableHTTPServer(mcpServer)
+	return agent
+}
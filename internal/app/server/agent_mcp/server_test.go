@@ -0,0 +1,128 @@
+package agent_mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type fakeController struct {
+	devices map[string]DeviceInfo
+	spoken  []string
+}
+
+func (c *fakeController) ListDevices(agentID string) []DeviceInfo {
+	var devices []DeviceInfo
+	for _, device := range c.devices {
+		if device.AgentID == agentID {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+func (c *fakeController) GetDevice(agentID string, deviceID string) (DeviceInfo, bool) {
+	device, ok := c.devices[deviceID]
+	if !ok || device.AgentID != agentID {
+		return DeviceInfo{}, false
+	}
+	return device, true
+}
+
+func (c *fakeController) Speak(agentID string, deviceID string, text string) error {
+	c.spoken = append(c.spoken, deviceID+":"+text)
+	return nil
+}
+
+func (c *fakeController) Ask(ctx context.Context, agentID string, deviceID string, question string, speak bool) (string, error) {
+	return fmt.Sprintf("answer to %s", question), nil
+}
+
+func TestDeviceToolName(t *testing.T) {
+	cases := map[[2]string]string{
+		{"ba:8f:17:de:94:94", "self.audio_speaker.set_volume"}: "ba8f17de9494__self_audio_speaker_set_volume",
+		{"dev-1", "light on"}: "dev-1__light_on",
+	}
+	for input, want := range cases {
+		if got := deviceToolName(input[0], input[1]); got != want {
+			t.Errorf("deviceToolName(%q, %q) = %q, want %q", input[0], input[1], got, want)
+		}
+	}
+	long := deviceToolName("aa:bb:cc:dd:ee:ff", "self.a_very_long_tool_name_that_goes_on_and_on_and_on_forever")
+	if len(long) != maxToolNameLength {
+		t.Errorf("expected truncated name length %d, got %d", maxToolNameLength, len(long))
+	}
+}
+
+func TestServeAgent(t *testing.T) {
+	controller := &fakeController{devices: map[string]DeviceInfo{
+		"dev1": {DeviceID: "dev1", AgentID: "agent1"},
+		"dev2": {DeviceID: "dev2", AgentID: "agent2"},
+	}}
+	s := NewServer(controller)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeAgent(w, r, "agent1")
+	}))
+	defer httpServer.Close()
+
+	ctx := context.Background()
+	mcpClient, err := client.NewStreamableHttpClient(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mcpClient.Close()
+	if err := mcpClient.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "test", Version: "1.0.0"}
+	if _, err := mcpClient.Initialize(ctx, initRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := mcpClient.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 4 {
+		t.Fatalf("expected 4 builtin tools, got %d", len(tools.Tools))
+	}
+
+	call := func(name string, args map[string]interface{}) *mcp.CallToolResult {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = name
+		request.Params.Arguments = args
+		result, err := mcpClient.CallTool(ctx, request)
+		if err != nil {
+			t.Fatalf("call %s: %v", name, err)
+		}
+		return result
+	}
+
+	// 只有一台在线设备时可省略 device_id
+	if result := call("speak_on_device", map[string]interface{}{"text": "你好"}); result.IsError {
+		t.Fatalf("speak_on_device failed: %+v", result.Content)
+	}
+	if len(controller.spoken) != 1 || controller.spoken[0] != "dev1:你好" {
+		t.Fatalf("unexpected spoken: %v", controller.spoken)
+	}
+
+	// 不能操作其他智能体的设备
+	if result := call("speak_on_device", map[string]interface{}{"text": "hi", "device_id": "dev2"}); !result.IsError {
+		t.Fatal("expected error for device of another agent")
+	}
+
+	result := call("ask_agent", map[string]interface{}{"question": "几点了"})
+	if result.IsError || len(result.Content) == 0 {
+		t.Fatalf("ask_agent failed: %+v", result.Content)
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); !ok || text.Text != "answer to 几点了" {
+		t.Fatalf("unexpected ask_agent result: %+v", result.Content[0])
+	}
+}
@@ -0,0 +1,325 @@
+package agent_mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	domain_mcp "xiaozhi-esp32-server-golang/internal/domain/mcp"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	// askTimeout ask_agent 单次调用的超时时间
+	askTimeout = 60 * time.Second
+	// deviceToolTimeout 设备工具单次调用的超时时间
+	deviceToolTimeout = 30 * time.Second
+	// maxToolNameLength MCP 工具名的最大长度
+	maxToolNameLength = 64
+	// deviceToolSeparator 设备标识与设备工具名之间的分隔符
+	deviceToolSeparator = "__"
+)
+
+// deviceToolRef 对外暴露的设备工具对应的设备和原始工具名
+type deviceToolRef struct {
+	deviceID string
+	toolName string
+}
+
+func (a *agentServer) registerBuiltinTools() {
+	a.mcpServer.AddTools(
+		server.ServerTool{
+			Tool: mcp.NewTool("list_online_devices",
+				mcp.WithDescription("列出该智能体下当前在线的设备"),
+				mcp.WithReadOnlyHintAnnotation(true),
+			),
+			Handler: a.handleListOnlineDevices,
+		},
+		server.ServerTool{
+			Tool: mcp.NewTool("get_device_status",
+				mcp.WithDescription("获取设备的在线状态、拾音模式以及设备提供的工具列表"),
+				mcp.WithString("device_id", mcp.Description("设备ID，只有一台在线设备时可省略")),
+				mcp.WithReadOnlyHintAnnotation(true),
+			),
+			Handler: a.handleGetDeviceStatus,
+		},
+		server.ServerTool{
+			Tool: mcp.NewTool("speak_on_device",
+				mcp.WithDescription("在设备上直接播报一段文本，不经过大模型"),
+				mcp.WithString("text", mcp.Required(), mcp.Description("要播报的文本")),
+				mcp.WithString("device_id", mcp.Description("设备ID，只有一台在线设备时可省略")),
+			),
+			Handler: a.handleSpeakOnDevice,
+		},
+		server.ServerTool{
+			Tool: mcp.NewTool("ask_agent",
+				mcp.WithDescription("使用智能体的角色设定和大模型回答问题，返回回答文本"),
+				mcp.WithString("question", mcp.Required(), mcp.Description("要提问的内容")),
+				mcp.WithString("device_id", mcp.Description("使用该设备当前的智能体配置，只有一台在线设备时可省略")),
+				mcp.WithBoolean("speak", mcp.Description("是否同时在设备上播报回答，默认否")),
+			),
+			Handler: a.handleAskAgent,
+		},
+	)
+}
+
+// resolveDevice 获取工具参数中的设备，未指定且只有一台在线设备时使用该设备
+func (a *agentServer) resolveDevice(request mcp.CallToolRequest) (DeviceInfo, error) {
+	deviceID := strings.TrimSpace(request.GetString("device_id", ""))
+	if deviceID != "" {
+		device, ok := a.controller.GetDevice(a.agentID, deviceID)
+		if !ok {
+			return DeviceInfo{}, fmt.Errorf("设备 %s 不在线或不属于该智能体", deviceID)
+		}
+		return device, nil
+	}
+	devices := a.controller.ListDevices(a.agentID)
+	switch len(devices) {
+	case 0:
+		return DeviceInfo{}, fmt.Errorf("该智能体下没有在线设备")
+	case 1:
+		return devices[0], nil
+	default:
+		return DeviceInfo{}, fmt.Errorf("该智能体下有 %d 台在线设备，请指定 device_id", len(devices))
+	}
+}
+
+func (a *agentServer) handleListOnlineDevices(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	devices := a.controller.ListDevices(a.agentID)
+	return jsonResult(map[string]interface{}{
+		"count":   len(devices),
+		"devices": devices,
+	})
+}
+
+func (a *agentServer) handleGetDeviceStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	device, err := a.resolveDevice(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	device.Tools = deviceToolNames(device.DeviceID)
+	return jsonResult(device)
+}
+
+func (a *agentServer) handleSpeakOnDevice(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := request.RequireString("text")
+	if err != nil || strings.TrimSpace(text) == "" {
+		return mcp.NewToolResultError("text 不能为空"), nil
+	}
+	device, err := a.resolveDevice(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := a.controller.Speak(a.agentID, device.DeviceID, text); err != nil {
+		return mcp.NewToolResultErrorf("播报失败: %v", err), nil
+	}
+	log.Infof("智能体MCP服务器: 设备播报, agent=%s device=%s text=%s", a.agentID, device.DeviceID, text)
+	return mcp.NewToolResultText(fmt.Sprintf("已在设备 %s 上播报", device.DeviceID)), nil
+}
+
+func (a *agentServer) handleAskAgent(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	question, err := request.RequireString("question")
+	if err != nil || strings.TrimSpace(question) == "" {
+		return mcp.NewToolResultError("question 不能为空"), nil
+	}
+	device, err := a.resolveDevice(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, askTimeout)
+	defer cancel()
+	answer, err := a.controller.Ask(ctx, a.agentID, device.DeviceID, question, request.GetBool("speak", false))
+	if err != nil {
+		return mcp.NewToolResultErrorf("提问失败: %v", err), nil
+	}
+	return mcp.NewToolResultText(answer), nil
+}
+
+// syncDeviceTools 将在线设备的 MCP 工具同步为本服务器的工具，工具名为 "<设备标识>__<工具名>"
+func (a *agentServer) syncDeviceTools(ctx context.Context) {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+
+	desired := make(map[string]server.ServerTool)
+	refs := make(map[string]deviceToolRef)
+	for _, device := range a.controller.ListDevices(a.agentID) {
+		session := domain_mcp.GetDeviceMcpClient(device.DeviceID)
+		if session == nil {
+			continue
+		}
+		for toolName, invokable := range session.GetTools() {
+			name := deviceToolName(device.DeviceID, toolName)
+			if _, exists := desired[name]; exists {
+				continue
+			}
+			if _, exists := a.deviceTools[name]; exists {
+				desired[name] = server.ServerTool{}
+				refs[name] = a.deviceTools[name]
+				continue
+			}
+			info, err := invokable.Info(ctx)
+			if err != nil || info == nil {
+				continue
+			}
+			ref := deviceToolRef{deviceID: device.DeviceID, toolName: toolName}
+			desired[name] = server.ServerTool{
+				Tool:    mcp.NewToolWithRawSchema(name, fmt.Sprintf("[设备 %s] %s", device.DeviceID, info.Desc), toolInputSchema(info)),
+				Handler: a.deviceToolHandler(ref),
+			}
+			refs[name] = ref
+		}
+	}
+
+	var removed []string
+	for name := range a.deviceTools {
+		if _, ok := desired[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	var added []server.ServerTool
+	for name, serverTool := range desired {
+		if _, ok := a.deviceTools[name]; !ok {
+			added = append(added, serverTool)
+		}
+	}
+	if len(removed) > 0 {
+		a.mcpServer.DeleteTools(removed...)
+	}
+	if len(added) > 0 {
+		a.mcpServer.AddTools(added...)
+	}
+	a.deviceTools = refs
+}
+
+// clearDeviceTools 移除全部设备工具
+func (a *agentServer) clearDeviceTools() {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	if len(a.deviceTools) == 0 {
+		return
+	}
+	names := make([]string, 0, len(a.deviceTools))
+	for name := range a.deviceTools {
+		names = append(names, name)
+	}
+	a.mcpServer.DeleteTools(names...)
+	a.deviceTools = make(map[string]deviceToolRef)
+}
+
+func (a *agentServer) deviceToolHandler(ref deviceToolRef) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, ok := a.controller.GetDevice(a.agentID, ref.deviceID); !ok {
+			return mcp.NewToolResultErrorf("设备 %s 不在线或不属于该智能体", ref.deviceID), nil
+		}
+		session := domain_mcp.GetDeviceMcpClient(ref.deviceID)
+		if session == nil {
+			return mcp.NewToolResultErrorf("设备 %s 没有MCP连接", ref.deviceID), nil
+		}
+		invokable, ok := session.GetToolByName(ref.toolName)
+		if !ok {
+			return mcp.NewToolResultErrorf("设备 %s 上不存在工具 %s", ref.deviceID, ref.toolName), nil
+		}
+
+		arguments := "{}"
+		if args := request.GetArguments(); len(args) > 0 {
+			argsJSON, err := json.Marshal(args)
+			if err != nil {
+				return mcp.NewToolResultErrorf("参数序列化失败: %v", err), nil
+			}
+			arguments = string(argsJSON)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, deviceToolTimeout)
+		defer cancel()
+		// 按 agent_mcp 来源执行智能体工具策略（InvokableRun 内统一调用 CheckToolCall）
+		ctx = domain_mcp.WithToolCallSource(ctx, domain_mcp.ToolSourceAgentMCP)
+		log.Infof("智能体MCP服务器: 调用设备工具, agent=%s device=%s tool=%s args=%s", a.agentID, ref.deviceID, ref.toolName, arguments)
+		result, err := invokable.InvokableRun(ctx, arguments)
+		if err != nil {
+			return mcp.NewToolResultErrorf("调用设备工具失败: %v", err), nil
+		}
+		return parseToolResult(result), nil
+	}
+}
+
+// parseToolResult 设备工具返回的是 CallToolResult JSON，解析失败时按纯文本返回
+func parseToolResult(result string) *mcp.CallToolResult {
+	raw := json.RawMessage(result)
+	if parsed, err := mcp.ParseCallToolResult(&raw); err == nil && parsed != nil && len(parsed.Content) > 0 {
+		return parsed
+	}
+	return mcp.NewToolResultText(result)
+}
+
+func jsonResult(data interface{}) (*mcp.CallToolResult, error) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(dataJSON)), nil
+}
+
+// deviceToolNames 返回设备上的 MCP 工具名列表
+func deviceToolNames(deviceID string) []string {
+	session := domain_mcp.GetDeviceMcpClient(deviceID)
+	if session == nil {
+		return nil
+	}
+	tools := session.GetTools()
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deviceToolName 生成对外工具名：设备ID与工具名中只保留字母、数字、下划线和连字符，超长时截断
+func deviceToolName(deviceID string, toolName string) string {
+	name := sanitizeToolName(deviceID, false) + deviceToolSeparator + sanitizeToolName(toolName, true)
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
+
+func sanitizeToolName(s string, keepSeparators bool) string {
+	var builder strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			builder.WriteRune(r)
+		case keepSeparators:
+			builder.WriteRune('_')
+		}
+	}
+	return builder.String()
+}
+
+// toolInputSchema 将 eino 工具参数转换为 MCP 工具的 inputSchema
+func toolInputSchema(info *schema.ToolInfo) json.RawMessage {
+	emptySchema := json.RawMessage(`{"type":"object","properties":{}}`)
+	if info.ParamsOneOf == nil {
+		return emptySchema
+	}
+	params, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil || params == nil {
+		return emptySchema
+	}
+	if params.Type == "" {
+		params.Type = "object"
+	}
+	schemaJSON, err := json.Marshal(params)
+	if err != nil {
+		return emptySchema
+	}
+	return schemaJSON
+}
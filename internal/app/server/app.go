@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 	"xiaozhi-esp32-server-golang/internal/app/mqtt_server"
+	"xiaozhi-esp32-server-golang/internal/app/server/agent_mcp"
 	"xiaozhi-esp32-server-golang/internal/app/server/chat"
 	"xiaozhi-esp32-server-golang/internal/app/server/mqtt_udp"
 	"xiaozhi-esp32-server-golang/internal/app/server/types"
@@ -158,6 +159,7 @@ func (app *App) newWebSocketServer() *websocket.WebSocketServer {
 		port,
 		websocket.WithOnNewConnection(app.OnNewConnection),
 		websocket.WithOnOpenClawResponse(app.OnOpenClawResponse),
+		websocket.WithAgentMCPServer(agent_mcp.NewServer(&agentMCPController{app: app})),
 	)
 }
 
@@ -447,7 +449,28 @@ func (a *App) registerHandler() {
 		return
 	}
 	provider.RegisterMessageEventHandler(context.Background(), config_types.EventHandleMessageInject, a.HandleInjectMsg)
-	log.Infof("registerHandler: registered paths=[%s]", config_types.EventHandleMessageInject)
+	provider.RegisterMessageEventHandler(context.Background(), config_types.EventHandleMemoryManage, a.HandleMemoryManage)
+	provider.RegisterMessageEventHandler(context.Background(), config_types.EventHandleConfigReload, a.HandleConfigReload)
+	log.Infof("registerHandler: registered paths=[%s, %s, %s]", config_types.EventHandleMessageInject, config_types.EventHandleMemoryManage, config_types.EventHandleConfigReload)
+}
+
+// HandleConfigReload 管理后台回滚智能体或角色后，重新加载本实例上在线设备的配置；不在本实例的设备忽略
+func (a *App) HandleConfigReload(ctx context.Context, eventType string, eventData map[string]interface{}) (string, error) {
+	deviceIDs, _ := eventData["device_ids"].([]interface{})
+	reloaded := 0
+	for _, item := range deviceIDs {
+		deviceID, _ := item.(string)
+		chatManager, exists := a.GetChatManager(deviceID)
+		if !exists {
+			continue
+		}
+		if err := chatManager.ReloadDeviceConfig(ctx); err != nil {
+			log.Errorf("HandleConfigReload: 设备 %s 重新加载配置失败: %v", deviceID, err)
+			continue
+		}
+		reloaded++
+	}
+	return fmt.Sprintf("reloaded %d devices", reloaded), nil
 }
 
 // 向客户端注入消息
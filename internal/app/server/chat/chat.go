@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/cloudwego/eino/schema"
 	"github.com/spf13/viper"
 
 	"xiaozhi-esp32-server-golang/constants"
@@ -13,6 +14,8 @@ import (
 	. "xiaozhi-esp32-server-golang/internal/data/client"
 	userconfig "xiaozhi-esp32-server-golang/internal/domain/config"
 	"xiaozhi-esp32-server-golang/internal/domain/eventbus"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
 	"xiaozhi-esp32-server-golang/internal/domain/openclaw"
 	log "xiaozhi-esp32-server-golang/logger"
 )
@@ -122,6 +125,7 @@ func GenClientState(pctx context.Context, deviceID string) (*ClientState, error)
 		SessionCtx: Ctx{},
 	}
 	applyOutputAudioFormatForTTS(clientState)
+	mcp.SetToolPolicy(deviceID, deviceConfig.AgentId, deviceConfig.MCPToolPolicy)
 
 	return clientState, nil
 }
@@ -160,6 +164,7 @@ func (c *ChatManager) ReloadDeviceConfig(ctx context.Context) error {
 	c.clientState.SystemPrompt = deviceConfig.SystemPrompt
 	// 切换角色后清空声纹临时TTS配置，避免旧配置污染
 	c.clientState.SpeakerTTSConfig = nil
+	mcp.SetToolPolicy(c.DeviceID, deviceConfig.AgentId, deviceConfig.MCPToolPolicy)
 	// OpenClaw模式状态由 openclaw manager 按 agent session 维护，配置刷新时主动退出模式。
 	openclaw.GetManager().ExitMode(oldAgentID, c.DeviceID)
 	openclaw.GetManager().ExitMode(c.clientState.AgentID, c.DeviceID)
@@ -229,3 +234,23 @@ func (c *ChatManager) InjectMessage(message string, skipLlm bool) error {
 		return c.session.AddAsrResultToQueue(message, nil)
 	}
 }
+
+// AskAgent 使用设备当前智能体的 LLM 和系统提示词回答问题，不写入对话历史
+func (c *ChatManager) AskAgent(ctx context.Context, question string) (string, error) {
+	llmConfig := c.clientState.DeviceConfig.Llm.Config
+	llmType, _ := llmConfig["type"].(string)
+	if llmType == "" {
+		return "", fmt.Errorf("设备 %s 未配置LLM", c.DeviceID)
+	}
+	llmProvider, err := llm.GetLLMProvider(llmType, llmConfig)
+	if err != nil {
+		return "", fmt.Errorf("创建LLM失败: %w", err)
+	}
+
+	dialogue := make([]*schema.Message, 0, 2)
+	if c.clientState.SystemPrompt != "" {
+		dialogue = append(dialogue, schema.SystemMessage(c.clientState.SystemPrompt))
+	}
+	dialogue = append(dialogue, schema.UserMessage(question))
+	return llm.ResponseText(ctx, llmProvider, "ask_agent_"+c.DeviceID, dialogue)
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	. "xiaozhi-esp32-server-golang/internal/data/client"
@@ -81,6 +82,20 @@ type LLMManager struct {
 	// key: role (user/assistant), value: MessageID
 	lastMessageID   map[string]string
 	lastMessageIDMu sync.RWMutex // 保护 lastMessageID 的并发访问
+
+	// 滑出历史窗口的消息合并进滚动摘要
+	summarizer *historySummarizer
+
+	// 已向用户询问、等待口头确认的敏感工具调用
+	pendingToolConfirm *pendingToolConfirm
+	toolConfirmMu      sync.Mutex
+
+	// MCP服务器发起、等待用户语音回答的 elicitation 请求
+	pendingElicitation *pendingElicitation
+	elicitationMu      sync.Mutex
+
+	// 当前轮次识别到的说话人，用于工具调用审计
+	speakerName atomic.Value
 }
 
 func NewLLMManager(clientState *ClientState, serverTransport *ServerTransport, ttsManager *TTSManager) *LLMManager {
@@ -90,6 +105,7 @@ func NewLLMManager(clientState *ClientState, serverTransport *ServerTransport, t
 		ttsManager:       ttsManager,
 		llmResponseQueue: util.NewQueue[LLMResponseChannelItem](10),
 		lastMessageID:    make(map[string]string),
+		summarizer:       newHistorySummarizer(clientState),
 	}
 }
 
@@ -489,6 +505,10 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 		// 在 context 中传递 chat_session_operator，供 local mcp tool 使用
 		toolCtx = context.WithValue(ctx, "chat_session_operator", chatSessionOperator)
 	}
+	// 工具执行期间 MCP 服务器发来的 sampling/elicitation 请求由本会话应答
+	toolCtx = mcp.WithServerRequestHandler(toolCtx, l)
+	// 工具策略在 McpTool.InvokableRun 中统一执行
+	toolCtx = mcp.WithToolPolicy(toolCtx, state.AgentID, state.DeviceConfig.MCPToolPolicy)
 
 	var shouldStopLLMProcessing bool
 
@@ -503,34 +523,71 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 		messageList = append(messageList, respMsg)
 	}
 
-	addMessageFunc := func(toolCall schema.ToolCall, result string) {
-		toolResultMsg := &schema.Message{
-			Role:       schema.Tool,
-			ToolCallID: toolCall.ID,
-			Content:    result,
-		}
-		messageList = append(messageList, toolResultMsg)
-	}
+	// 工具结果按调用顺序写入历史
+	toolResults := make([]string, len(tools))
 
 	var findExitTool bool
 
-	for _, toolCall := range tools {
+	// 需要用户确认的工具调用，本轮不执行
+	var pendingCalls []pendingToolCall
+
+	// 通过检查的工具调用并发执行，避免一个卡住的工具阻塞其它调用
+	var invocations []*toolInvocation
+	// 有工具执行失败或超时，仍需把失败原因交给 LLM 生成回复
+	var invokeToolFailed bool
+
+	for i, toolCall := range tools {
 		toolName := toolCall.Function.Name
-		tool, ok := mcp.GetToolByName(state.DeviceID, state.AgentID, toolName, state.DeviceConfig.MCPServiceNames)
+		tool, source, ok := mcp.LookupTool(state.DeviceID, state.AgentID, toolName, state.DeviceConfig.MCPServiceNames)
 		if !ok || tool == nil {
 			log.Errorf("未找到工具: %s", toolName)
-			addMessageFunc(toolCall, fmt.Sprintf("未找到工具: %s", toolName))
+			toolResults[i] = fmt.Sprintf("未找到工具: %s", toolName)
+			l.auditToolCall(toolCallAudit{toolCall: toolCall, status: toolCallStatusNotFound, err: fmt.Errorf("未找到工具: %s", toolName)})
 			continue
 		}
-		log.Infof("进行工具调用请求: %s, 参数: %+v", toolName, toolCall.Function.Arguments)
-		startTs := time.Now().UnixMilli()
-		fcResult, err := tool.InvokableRun(toolCtx, toolCall.Function.Arguments)
-		if err != nil {
-			log.Errorf("工具调用失败: %v", err)
-			addMessageFunc(toolCall, fmt.Sprintf("工具 %s 调用失败: %v", toolName, err))
+		serverName := l.toolServerName(tool, source)
+		policy := state.DeviceConfig.MCPToolPolicy
+		if !isToolCallConfirmed(ctx, toolCall.ID) && mcp.ToolNeedsConfirm(policy, toolName, source) && mcp.ToolAllowed(policy, toolName, source) {
+			log.Infof("工具 %s 需要用户确认, 参数: %s", toolName, toolCall.Function.Arguments)
+			pendingCalls = append(pendingCalls, pendingToolCall{
+				toolCall: toolCall,
+				label:    toolConfirmLabel(ctx, tool, toolName),
+			})
+			toolResults[i] = toolConfirmPendingResult
+			l.auditToolCall(toolCallAudit{toolCall: toolCall, source: source, server: serverName, status: toolCallStatusPendingConfirm})
+			continue
+		}
+		invocations = append(invocations, &toolInvocation{
+			index:      i,
+			toolCall:   toolCall,
+			tool:       tool,
+			source:     source,
+			serverName: serverName,
+			arguments:  toolCall.Function.Arguments,
+		})
+	}
+
+	if len(invocations) > 0 {
+		stopFiller := l.startToolFiller(ctx)
+		l.invokeToolsConcurrently(toolCtx, invocations)
+		stopFiller()
+	}
+
+	// 结果按调用顺序处理，音频播放等副作用保持串行
+	for _, invocation := range invocations {
+		i := invocation.index
+		toolCall, tool := invocation.toolCall, invocation.tool
+		toolName, source, serverName, arguments := toolCall.Function.Name, invocation.source, invocation.serverName, invocation.arguments
+		fcResult, costTs := invocation.output, invocation.costMs
+		if invocation.err != nil {
+			log.Errorf("工具调用失败: %v", invocation.err)
+			toolResults[i] = invocation.err.Error()
+			if invocation.status != toolCallStatusCanceled && invocation.status != toolCallStatusDenied {
+				invokeToolFailed = true
+			}
+			l.auditToolCall(toolCallAudit{toolCall: toolCall, source: source, server: serverName, arguments: arguments, status: invocation.status, latencyMs: costTs, err: invocation.err})
 			continue
 		}
-		costTs := time.Now().UnixMilli() - startTs
 		invokeToolSuccess = true
 		if len(fcResult) > 2048 {
 			log.Infof("工具调用结果 len: %d, 耗时: %dms", len(fcResult), costTs)
@@ -540,6 +597,7 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 
 		var result string = fcResult
 		var contentList []mcp_go.Content
+		auditStatus := toolCallStatusSuccess
 		if mcpResp, ok := l.handleLocalToolResult(fcResult); ok {
 			if mcpResp.GetType() == MCPResponseTypeAction {
 				if mcpResp.GetAction() == "exit_conversation" {
@@ -553,7 +611,8 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 			contentList = mcpResp.GetContent()
 		} else if toolCallResult, ok := l.handleToolResult(fcResult); ok {
 			if toolCallResult.IsError {
-				log.Errorf("工具调用失败: %s, 错误: %s", fcResult, toolCallResult.IsError)
+				log.Errorf("工具调用失败: %s, 结果: %+v", fcResult, toolCallResult.Content)
+				auditStatus = toolCallStatusError
 			}
 			contentList = toolCallResult.Content
 		}
@@ -575,6 +634,11 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 					break
 				} else if resourceLink, ok := content.(mcp_go.ResourceLink); ok {
 					log.Debugf("调用工具 %s 返回资源链接: %+v", toolName, resourceLink)
+					// 非音频资源读取后交给 LLM 继续处理
+					if !mcp.IsAudioResourceLink(resourceLink) {
+						mcpContent += l.readResourceLinkText(ctx, tool, resourceLink)
+						continue
+					}
 					mcpContent = "执行成功"
 					err := l.handleResourceLink(ctx, resourceLink, tool, &wg)
 					if err != nil {
@@ -587,13 +651,38 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 				} else if textContent, ok := content.(mcp_go.TextContent); ok {
 					log.Debugf("调用工具 %s 返回文本资源长度: %s", toolName, textContent.Text)
 					mcpContent += textContent.Text
+				} else if embedded, ok := content.(mcp_go.EmbeddedResource); ok {
+					contents := mcp.DecodeResourceContents([]mcp_go.ResourceContents{embedded.Resource})
+					mcpContent += l.resourceContentsText("", "", contents)
 				}
 			}
 			if mcpContent != "" {
 				result = mcpContent
 			}
 		}
-		addMessageFunc(toolCall, result)
+		toolResults[i] = result
+		l.auditToolCall(toolCallAudit{toolCall: toolCall, source: source, server: serverName, arguments: arguments, result: result, status: auditStatus, latencyMs: costTs})
+	}
+
+	for i, toolCall := range tools {
+		messageList = append(messageList, &schema.Message{
+			Role:       schema.Tool,
+			ToolCallID: toolCall.ID,
+			Content:    toolResults[i],
+		})
+	}
+
+	// 有待确认的工具时播报确认问句并结束本轮，用户回答后由 HandleToolConfirmAnswer 执行或取消
+	if len(pendingCalls) > 0 {
+		question := l.setPendingToolConfirm(pendingCalls)
+		messageList = append(messageList, schema.AssistantMessage(question, nil))
+		if err := l.ttsManager.handleTextResponse(ctx, llm_common.LLMResponseStruct{Text: question}, false); err != nil {
+			log.Errorf("播报工具确认问句失败: %v", err)
+		}
+		if fullText, ok := ctx.Value(fullTextKey).(*strings.Builder); ok && fullText != nil {
+			fullText.WriteString(question)
+		}
+		shouldStopLLMProcessing = true
 	}
 
 	if len(messageList) > 0 {
@@ -623,12 +712,18 @@ func (l *LLMManager) handleToolCallResponse(ctx context.Context, userMessage *sc
 		return invokeToolSuccess, nil
 	}
 
-	// 如果工具调用成功且没有被标记为停止处理，则继续LLM调用
-	if invokeToolSuccess && !shouldStopLLMProcessing {
+	// 用户已打断，不再继续本轮
+	if ctx.Err() != nil {
+		return invokeToolSuccess, nil
+	}
+
+	// 如果工具调用成功（或失败原因需要告知用户）且没有被标记为停止处理，则继续LLM调用
+	followUp := (invokeToolSuccess || invokeToolFailed) && !shouldStopLLMProcessing
+	if followUp {
 		l.DoLLmRequest(ctx, nil, l.einoTools, true, nil)
 	}
 
-	return invokeToolSuccess, nil
+	return invokeToolSuccess || followUp || len(pendingCalls) > 0, nil
 }
 
 func (l *LLMManager) handleResourceLink(ctx context.Context, resourceLink mcp_go.ResourceLink, toolCall tool.InvokableTool, wg *sync.WaitGroup) error {
@@ -985,6 +1080,13 @@ func (l *LLMManager) DoLLmRequest(ctx context.Context, userMessage *schema.Messa
 	clientState := l.clientState
 
 	l.einoTools = einoTools
+	if userMessage != nil {
+		speakerName := ""
+		if speakerResult != nil && speakerResult.Identified {
+			speakerName = speakerResult.SpeakerName
+		}
+		l.setSpeakerName(speakerName)
+	}
 
 	//组装历史消息和当前用户的消息
 	requestMessages := l.GetMessages(ctx, userMessage, MaxMessageCount, speakerResult)
@@ -1055,6 +1157,7 @@ func (l *LLMManager) AddMessage(ctx context.Context, msg *schema.Message) error
 			Channels:    0,
 			Timestamp:   time.Now(),
 			IsUpdate:    false, // 一次性保存
+			MemoryScope: l.clientState.GetMemoryScopeID(),
 		})
 		return nil
 	}
@@ -1078,6 +1181,7 @@ func (l *LLMManager) AddMessage(ctx context.Context, msg *schema.Message) error
 		Channels:    0,
 		Timestamp:   time.Now(),
 		IsUpdate:    false, // 新增消息
+		MemoryScope: l.clientState.GetMemoryScopeID(),
 	})
 
 	return nil
@@ -1088,28 +1192,67 @@ func (l *LLMManager) AddLlmMessage(ctx context.Context, msg *schema.Message) err
 	return l.AddMessage(ctx, msg)
 }
 
+// getMemoryContext 获取记忆分区的 memory context，非共享分区首次使用时从记忆体加载
+func (l *LLMManager) getMemoryContext(ctx context.Context, memoryScope string) string {
+	if memoryContext, ok := l.clientState.GetScopedMemoryContext(memoryScope); ok {
+		return memoryContext
+	}
+	if l.clientState.MemoryProvider == nil {
+		return ""
+	}
+	memoryContext, err := l.clientState.MemoryProvider.GetContext(ctx, memoryScope, 500)
+	if err != nil {
+		log.Warnf("获取记忆分区 %s 的 memory context 失败: %v", memoryScope, err)
+		return ""
+	}
+	l.clientState.SetScopedMemoryContext(memoryScope, memoryContext)
+	return memoryContext
+}
+
 func (l *LLMManager) GetMessages(ctx context.Context, userMessage *schema.Message, count int, speakerResult *speaker.IdentifyResult) []*schema.Message {
 	memoryMode := l.clientState.GetMemoryMode()
 	includeHistory := memoryMode != MemoryModeNone
 
-	// 从 dialogue 中获取上下文（none 模式下不加载历史）
+	// 从 dialogue 中按 token 预算获取上下文（none 模式下不加载历史），滑出窗口的消息合并进滚动摘要
 	messageList := make([]*schema.Message, 0)
+	historySummary := ""
 	if includeHistory {
-		messageList = l.clientState.GetMessages(count)
+		window, evicted, evictedEnd := l.clientState.GetHistoryWindow(historyMaxTokens(), count)
+		messageList = window
 		if userMessage != nil {
 			messageList = trimTrailingUserMessages(messageList)
 		}
+		l.summarizer.Summarize(evicted, evictedEnd)
+		historySummary = l.clientState.GetHistorySummary()
 	}
 
-	// 构建 system prompt
-	systemPrompt := l.clientState.SystemPrompt
+	// 构建 system prompt，按段落组织以便超出模型上下文时按优先级裁剪
+	sections := []promptSection{{name: "系统提示词", content: l.clientState.SystemPrompt}}
 
 	// 添加当前时间和日期信息
 	now := time.Now()
-	systemPrompt += fmt.Sprintf("\n当前时间和日期: %s %s", now.Format("2006年01月02日 15:04:05"), now.Format("Monday"))
+	sections = append(sections, promptSection{
+		name:    "当前时间",
+		content: fmt.Sprintf("\n当前时间和日期: %s %s", now.Format("2006年01月02日 15:04:05"), now.Format("Monday")),
+	})
 
-	if memoryMode == MemoryModeLong && l.clientState.MemoryContext != "" {
-		systemPrompt += fmt.Sprintf("\n用户个性化信息: \n%s", l.clientState.MemoryContext)
+	if historySummary != "" {
+		sections = append(sections, promptSection{
+			name:     "对话摘要",
+			content:  fmt.Sprintf("\n之前的对话摘要: \n%s", historySummary),
+			priority: promptPriorityHistorySum,
+		})
+	}
+
+	memoryScope := l.clientState.GetMemoryScopeID()
+	if memoryMode == MemoryModeLong {
+		if memoryContext := l.getMemoryContext(ctx, memoryScope); memoryContext != "" {
+			sections = append(sections, promptSection{
+				name:     "个性化记忆",
+				content:  fmt.Sprintf("\n用户个性化信息: \n%s", memoryContext),
+				priority: promptPriorityMemoryContext,
+			})
+		}
 	}
 
 	log.Debugf("speakerResult: %+v, voiceIdentify: %+v", speakerResult, l.clientState.DeviceConfig.VoiceIdentify)
@@ -1122,7 +1265,10 @@ func (l *LLMManager) GetMessages(ctx context.Context, userMessage *schema.Messag
 			if speakerGroupInfo, found := l.clientState.DeviceConfig.VoiceIdentify[speakerResult.SpeakerName]; found {
 				// 如果找到匹配的 speakerGroup，将描述整合到 systemPrompt
 				if speakerGroupInfo.Prompt != "" {
-					systemPrompt += fmt.Sprintf("\n基于声纹识别到对话人信息: \n%s", speakerGroupInfo.Prompt)
+					sections = append(sections, promptSection{
+						name:    "说话人信息",
+						content: fmt.Sprintf("\n基于声纹识别到对话人信息: \n%s", speakerGroupInfo.Prompt),
+					})
 				}
 			}
 		}
@@ -1130,25 +1276,28 @@ func (l *LLMManager) GetMessages(ctx context.Context, userMessage *schema.Messag
 
 	//search memory
 	if memoryMode == MemoryModeLong && l.clientState.MemoryProvider != nil && userMessage != nil {
-		memoryContext, err := l.clientState.MemoryProvider.Search(ctx, l.clientState.GetDeviceIDOrAgentID(), userMessage.Content, 10, 180)
+		memoryContext, err := l.clientState.MemoryProvider.Search(ctx, memoryScope, userMessage.Content, 10, 180)
 		if err != nil {
 			log.Errorf("搜索记忆失败: %v", err)
 		}
 		log.Debugf("搜索记忆成功, 输入内容: %s, 记忆内容: %s", userMessage.Content, memoryContext)
 		if memoryContext != "" {
-			systemPrompt += fmt.Sprintf("\n历史关联信息: \n%s", memoryContext)
+			sections = append(sections, promptSection{
+				name:     "记忆检索结果",
+				content:  fmt.Sprintf("\n历史关联信息: \n%s", memoryContext),
+				priority: promptPriorityMemorySearch,
+			})
 		}
 	}
 
-	systemPrompt += buildKnowledgeSearchRoutingPolicy(l.clientState.DeviceConfig.KnowledgeBases)
-
-	retMessage := make([]*schema.Message, 0)
-	retMessage = append(retMessage, &schema.Message{
-		Role:    schema.System,
-		Content: systemPrompt,
+	sections = append(sections, promptSection{
+		name:    "知识库策略",
+		content: buildKnowledgeSearchRoutingPolicy(l.clientState.DeviceConfig.KnowledgeBases),
 	})
+
 	// 过滤掉空的assistant消息，避免发送给LLM API时出现400错误
 	// 空的assistant消息（Content为空且ToolCalls为空）会导致API错误
+	historyMessages := make([]*schema.Message, 0, len(messageList))
 	for _, msg := range messageList {
 		if msg != nil && msg.Role == schema.Assistant && msg.Content == "" && len(msg.ToolCalls) == 0 {
 			log.Debugf("过滤掉空的assistant消息，避免发送给LLM API")
@@ -1158,8 +1307,25 @@ func (l *LLMManager) GetMessages(ctx context.Context, userMessage *schema.Messag
 		if isInterruptedMessage(msgCopy) {
 			msgCopy.Content = decorateInterruptedContent(msgCopy.Content)
 		}
-		retMessage = append(retMessage, msgCopy)
+		historyMessages = append(historyMessages, msgCopy)
 	}
+
+	// 按模型上下文长度裁剪
+	if budget := newPromptBudget(l.clientState.DeviceConfig.Llm.Config); budget != nil {
+		var dropped []string
+		sections, historyMessages, dropped = budget.fit(sections, historyMessages, userMessage, l.einoTools)
+		if len(dropped) > 0 {
+			historyMessages = AlignToolMessages(historyMessages)
+		}
+		logPromptBudget(l.clientState.SessionID, dropped)
+	}
+
+	retMessage := make([]*schema.Message, 0, len(historyMessages)+2)
+	retMessage = append(retMessage, &schema.Message{
+		Role:    schema.System,
+		Content: joinPromptSections(sections),
+	})
+	retMessage = append(retMessage, historyMessages...)
 	if userMessage != nil {
 		// 检查 retMessage 的最后一条消息是否已经是相同的用户消息，避免重复添加
 		shouldAdd := true
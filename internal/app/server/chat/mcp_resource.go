@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	mcp_go "github.com/mark3labs/mcp-go/mcp"
+
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	// mcpResourceTextMaxRunes 交给 LLM 的资源文本最大长度
+	mcpResourceTextMaxRunes = 4000
+	mcpResourceImagePrompt  = "请简要描述这张图片的内容"
+)
+
+// readResourceLinkText 读取非音频的资源链接，转换为交给 LLM 的文本
+func (l *LLMManager) readResourceLinkText(ctx context.Context, invokable tool.InvokableTool, link mcp_go.ResourceLink) string {
+	mcpTool, ok := invokable.(*mcp.McpTool)
+	if !ok {
+		return fmt.Sprintf("资源 %s 无法读取", link.Name)
+	}
+	contents, err := mcp.ReadResourceLink(ctx, mcpTool, link)
+	if err != nil {
+		log.Errorf("读取MCP资源失败, uri: %s, err: %v", link.URI, err)
+		return fmt.Sprintf("读取资源 %s 失败: %v", link.Name, err)
+	}
+	log.Infof("读取MCP资源成功, uri: %s, 内容数: %d", link.URI, len(contents))
+	return l.resourceContentsText(link.Name, link.Description, contents)
+}
+
+// resourceContentsText 文本资源直接返回，图片资源交给视觉模型识别，其他二进制资源只返回描述
+func (l *LLMManager) resourceContentsText(name string, description string, contents []mcp.ResourceContent) string {
+	parts := make([]string, 0, len(contents))
+	for _, content := range contents {
+		label := name
+		if label == "" {
+			label = content.URI
+		}
+		switch {
+		case content.IsText():
+			parts = append(parts, truncateRunes(content.Text, mcpResourceTextMaxRunes))
+		case content.IsImage():
+			prompt := mcpResourceImagePrompt
+			if description != "" {
+				prompt = fmt.Sprintf("%s（%s）", mcpResourceImagePrompt, description)
+			}
+			result, err := HandleVllm(l.clientState.DeviceID, content.Blob, prompt)
+			if err != nil {
+				log.Warnf("识别MCP图片资源失败, uri: %s, err: %v", content.URI, err)
+				parts = append(parts, fmt.Sprintf("已获取图片 %s，但无法识别图片内容", label))
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("图片 %s 的内容: %s", label, result))
+		default:
+			parts = append(parts, fmt.Sprintf("已获取资源 %s（%s，%d 字节）", label, content.MIMEType, len(content.Blob)))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,301 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	mcp_go "github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	defaultElicitationTimeout = 30 * time.Second
+	elicitationLLMTimeout     = 10 * time.Second
+)
+
+const elicitationExtractPrompt = `你是一个信息提取助手。系统向用户提出了一个问题，请根据用户的回答，按给定的 JSON Schema 提取字段：
+- 只输出一个 JSON 对象，不要输出其他内容
+- 用户拒绝回答或明确不愿提供时，只输出 decline
+- 回答中没有的可选字段不要输出`
+
+// pendingElicitation 已向用户播报、等待语音回答的 elicitation 请求
+type pendingElicitation struct {
+	question string
+	answer   chan string
+}
+
+// elicitationTimeout 等待用户回答 elicitation 的超时时间
+func elicitationTimeout() time.Duration {
+	if seconds := viper.GetInt("chat.elicitation_timeout"); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultElicitationTimeout
+}
+
+// CreateMessage 应答 MCP 服务器的 sampling 请求，使用智能体配置的 LLM，受 mcp.sampling 策略限制
+func (l *LLMManager) CreateMessage(ctx context.Context, request mcp_go.CreateMessageRequest) (*mcp_go.CreateMessageResult, error) {
+	policy := mcp.GetSamplingPolicy()
+	if !policy.Enable {
+		return nil, fmt.Errorf("sampling 未开启")
+	}
+
+	llmConfig := make(map[string]interface{}, len(l.clientState.DeviceConfig.Llm.Config)+1)
+	for key, value := range l.clientState.DeviceConfig.Llm.Config {
+		llmConfig[key] = value
+	}
+	llmType, _ := llmConfig["type"].(string)
+	if llmType == "" {
+		return nil, fmt.Errorf("智能体未配置LLM")
+	}
+	modelName, _ := llmConfig["model_name"].(string)
+	if !policy.ModelAllowed(modelName) {
+		return nil, fmt.Errorf("模型 %s 不允许用于 sampling", modelName)
+	}
+	maxTokens := policy.MaxTokens
+	if request.MaxTokens > 0 && request.MaxTokens < maxTokens {
+		maxTokens = request.MaxTokens
+	}
+	llmConfig["max_tokens"] = maxTokens
+
+	messages := make([]*schema.Message, 0, len(request.Messages)+1)
+	if systemPrompt := strings.TrimSpace(request.SystemPrompt); systemPrompt != "" {
+		messages = append(messages, schema.SystemMessage(systemPrompt))
+	}
+	for _, message := range request.Messages {
+		text := samplingMessageText(message.Content)
+		if text == "" {
+			continue
+		}
+		if message.Role == mcp_go.RoleAssistant {
+			messages = append(messages, schema.AssistantMessage(text, nil))
+		} else {
+			messages = append(messages, schema.UserMessage(text))
+		}
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("sampling 请求没有文本消息")
+	}
+
+	llmProvider, err := llm.GetLLMProvider(llmType, llmConfig)
+	if err != nil {
+		return nil, fmt.Errorf("获取LLM失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+	defer cancel()
+	log.Infof("MCP服务器请求 sampling, deviceID: %s, model: %s, max_tokens: %d, 消息数: %d", l.clientState.DeviceID, modelName, maxTokens, len(messages))
+	text, err := llm.ResponseText(ctx, llmProvider, "mcp_sampling_"+l.clientState.SessionID, messages)
+	if err != nil {
+		return nil, fmt.Errorf("sampling 调用LLM失败: %v", err)
+	}
+
+	return &mcp_go.CreateMessageResult{
+		SamplingMessage: mcp_go.SamplingMessage{
+			Role:    mcp_go.RoleAssistant,
+			Content: mcp_go.NewTextContent(text),
+		},
+		Model:      modelName,
+		StopReason: "endTurn",
+	}, nil
+}
+
+// samplingMessageText 取 sampling 消息中的文本，图片、音频等内容忽略
+func samplingMessageText(content any) string {
+	switch c := content.(type) {
+	case mcp_go.TextContent:
+		return strings.TrimSpace(c.Text)
+	case map[string]any:
+		if contentType, _ := c["type"].(string); contentType == "text" {
+			text, _ := c["text"].(string)
+			return strings.TrimSpace(text)
+		}
+	case string:
+		return strings.TrimSpace(c)
+	}
+	return ""
+}
+
+// Elicit 应答 MCP 服务器的 elicitation 请求：播报问题，等待用户语音回答后按 schema 填写结果；
+// 超时、打断或已有未回答的询问时返回 cancel
+func (l *LLMManager) Elicit(ctx context.Context, params mcp.ElicitationParams) (*mcp.ElicitationResult, error) {
+	question := strings.TrimSpace(params.Message)
+	if question == "" {
+		return nil, fmt.Errorf("elicitation 请求缺少 message")
+	}
+
+	pending := &pendingElicitation{question: question, answer: make(chan string, 1)}
+	l.elicitationMu.Lock()
+	if l.pendingElicitation != nil {
+		l.elicitationMu.Unlock()
+		log.Warnf("已有等待回答的询问, 取消新的 elicitation, deviceID: %s, message: %s", l.clientState.DeviceID, question)
+		return &mcp.ElicitationResult{Action: mcp.ElicitationCancel}, nil
+	}
+	l.pendingElicitation = pending
+	l.elicitationMu.Unlock()
+	defer func() {
+		l.elicitationMu.Lock()
+		if l.pendingElicitation == pending {
+			l.pendingElicitation = nil
+		}
+		l.elicitationMu.Unlock()
+	}()
+
+	log.Infof("MCP服务器请求 elicitation, deviceID: %s, message: %s", l.clientState.DeviceID, question)
+	if err := l.ttsManager.handleTextResponse(ctx, llm_common.LLMResponseStruct{Text: question}, true); err != nil {
+		return nil, fmt.Errorf("播报询问失败: %v", err)
+	}
+	// 结束本段播报让设备进入拾音，回答后重新开始播报后续的工具结果
+	if !l.clientState.IsRealTime() {
+		l.ttsManager.EnqueueTtsStop(ctx)
+	}
+
+	timer := time.NewTimer(elicitationTimeout())
+	defer timer.Stop()
+	select {
+	case text := <-pending.answer:
+		if !l.clientState.IsRealTime() {
+			l.ttsManager.EnqueueTtsStart(ctx)
+		}
+		result := l.buildElicitationResult(ctx, params, text)
+		log.Infof("elicitation 已回答, deviceID: %s, 回答: %s, action: %s", l.clientState.DeviceID, text, result.Action)
+		return result, nil
+	case <-timer.C:
+		log.Infof("elicitation 等待回答超时, deviceID: %s, message: %s", l.clientState.DeviceID, question)
+		return &mcp.ElicitationResult{Action: mcp.ElicitationCancel}, nil
+	case <-ctx.Done():
+		return &mcp.ElicitationResult{Action: mcp.ElicitationCancel}, nil
+	}
+}
+
+// HasPendingElicitation 是否有等待用户回答的 elicitation，此时设备开始拾音不应打断正在执行的工具
+func (l *LLMManager) HasPendingElicitation() bool {
+	l.elicitationMu.Lock()
+	defer l.elicitationMu.Unlock()
+	return l.pendingElicitation != nil
+}
+
+// HandleElicitationAnswer 有等待回答的 elicitation 时，将用户本次说的话作为回答交给 MCP 服务器
+func (l *LLMManager) HandleElicitationAnswer(text string) bool {
+	l.elicitationMu.Lock()
+	pending := l.pendingElicitation
+	l.pendingElicitation = nil
+	l.elicitationMu.Unlock()
+	if pending == nil {
+		return false
+	}
+	pending.answer <- text
+	return true
+}
+
+// buildElicitationResult 将语音回答映射为 schema 字段：单个字段直接转换类型，多个字段或转换失败时由 LLM 提取
+func (l *LLMManager) buildElicitationResult(ctx context.Context, params mcp.ElicitationParams, text string) *mcp.ElicitationResult {
+	properties, _ := params.RequestedSchema["properties"].(map[string]any)
+	if len(properties) == 1 {
+		for name, raw := range properties {
+			property, _ := raw.(map[string]any)
+			if value, ok := coerceElicitationValue(property, text); ok {
+				return &mcp.ElicitationResult{Action: mcp.ElicitationAccept, Content: map[string]any{name: value}}
+			}
+		}
+	}
+	if classifyToolConfirmAnswer(text) == toolConfirmNo {
+		return &mcp.ElicitationResult{Action: mcp.ElicitationDecline}
+	}
+	if len(properties) == 0 {
+		return &mcp.ElicitationResult{Action: mcp.ElicitationAccept}
+	}
+
+	content, declined, err := l.extractElicitationContent(ctx, params, text)
+	if err != nil {
+		log.Warnf("elicitation 提取字段失败: %v", err)
+		return &mcp.ElicitationResult{Action: mcp.ElicitationCancel}
+	}
+	if declined {
+		return &mcp.ElicitationResult{Action: mcp.ElicitationDecline}
+	}
+	return &mcp.ElicitationResult{Action: mcp.ElicitationAccept, Content: content}
+}
+
+// coerceElicitationValue 按字段类型转换回答，无法直接转换时返回 false
+func coerceElicitationValue(property map[string]any, text string) (any, bool) {
+	answer := strings.TrimSpace(text)
+	normalized := removePunctuation(answer)
+	if enum, ok := property["enum"].([]any); ok && len(enum) > 0 {
+		for _, option := range enum {
+			if s, ok := option.(string); ok && s != "" && strings.Contains(normalized, s) {
+				return s, true
+			}
+		}
+		return nil, false
+	}
+
+	propertyType, _ := property["type"].(string)
+	switch propertyType {
+	case "boolean":
+		switch classifyToolConfirmAnswer(answer) {
+		case toolConfirmYes:
+			return true, true
+		case toolConfirmNo:
+			return false, true
+		}
+	case "integer":
+		if value, err := strconv.ParseInt(normalized, 10, 64); err == nil {
+			return value, true
+		}
+	case "number":
+		if value, err := strconv.ParseFloat(normalized, 64); err == nil {
+			return value, true
+		}
+	case "string", "":
+		// 否定回答交给上层判断是否拒绝
+		if normalized != "" && classifyToolConfirmAnswer(answer) != toolConfirmNo {
+			return normalized, true
+		}
+	}
+	return nil, false
+}
+
+// extractElicitationContent 使用智能体的 LLM 从回答中提取字段
+func (l *LLMManager) extractElicitationContent(ctx context.Context, params mcp.ElicitationParams, text string) (map[string]any, bool, error) {
+	llmConfig := l.clientState.DeviceConfig.Llm.Config
+	llmType, _ := llmConfig["type"].(string)
+	if llmType == "" {
+		return nil, false, fmt.Errorf("智能体未配置LLM")
+	}
+	llmProvider, err := llm.GetLLMProvider(llmType, llmConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	schemaJSON, err := json.Marshal(params.RequestedSchema)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, elicitationLLMTimeout)
+	defer cancel()
+	result, err := llm.ResponseText(ctx, llmProvider, "elicitation_"+l.clientState.SessionID, []*schema.Message{
+		schema.SystemMessage(elicitationExtractPrompt),
+		schema.UserMessage(fmt.Sprintf("JSON Schema：%s\n系统提问：%s\n用户回答：%s", schemaJSON, params.Message, text)),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	result = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(result), "```json"), "```"))
+	if strings.EqualFold(result, "decline") {
+		return nil, true, nil
+	}
+	content := map[string]any{}
+	if err := json.Unmarshal([]byte(result), &content); err != nil {
+		return nil, false, fmt.Errorf("解析LLM结果失败: %v, 结果: %s", err, result)
+	}
+	return content, false, nil
+}
@@ -0,0 +1,37 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/util"
+)
+
+func TestElicitationAnswerFromAsr(t *testing.T) {
+	session := &ChatSession{
+		llmManager:    &LLMManager{},
+		chatTextQueue: util.NewQueue[AsrResponseChannelItem](10),
+	}
+	pending := &pendingElicitation{question: "要打开哪个房间的灯？", answer: make(chan string, 1)}
+	session.llmManager.pendingElicitation = pending
+
+	// 处理对话队列的协程此时阻塞在工具调用中，回答必须在入队前交给询问
+	if err := session.AddAsrResultToQueue("客厅", nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case answer := <-pending.answer:
+		if answer != "客厅" {
+			t.Errorf("answer = %q, want 客厅", answer)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("elicitation not answered")
+	}
+	if session.llmManager.HasPendingElicitation() {
+		t.Error("elicitation still pending after answer")
+	}
+	if _, err := session.chatTextQueue.Pop(context.Background(), -1); err == nil {
+		t.Error("answer also queued as a new chat turn")
+	}
+}
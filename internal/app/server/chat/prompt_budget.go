@@ -0,0 +1,214 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// 未配置 max_tokens 时为回复预留的 token 数
+	defaultResponseReserveTokens = 500
+	// 额外安全余量，弥补 token 估算误差
+	promptBudgetSafetyRatio = 0.1
+	// 单条工具结果的最小保留 token 数
+	minToolResultTokens = 256
+	// 可裁剪段落截断后低于该长度时直接丢弃
+	minSectionTokens = 32
+	// 系统提示词消息本身的开销
+	systemMessageTokenOverhead = 4
+
+	truncatedSuffix = "...(内容过长已截断)"
+)
+
+// 系统提示词段落的裁剪优先级，数值越小越先被裁剪；promptPriorityRequired 表示不裁剪
+const (
+	promptPriorityRequired      = 0
+	promptPriorityMemorySearch  = 1
+	promptPriorityMemoryContext = 2
+	promptPriorityHistorySum    = 3
+)
+
+// promptSection 系统提示词中的一个段落
+type promptSection struct {
+	name     string
+	content  string
+	priority int
+}
+
+// promptBudget 按模型上下文长度控制请求 token 数
+type promptBudget struct {
+	tokenizer     llm_common.Tokenizer
+	contextWindow int
+	reserveTokens int
+}
+
+// newPromptBudget 从 LLM 配置读取 context_window，未配置时返回 nil（不做裁剪）
+func newPromptBudget(llmConfig map[string]interface{}) *promptBudget {
+	contextWindow := configInt(llmConfig, "context_window")
+	if contextWindow <= 0 {
+		return nil
+	}
+	reserve := configInt(llmConfig, "max_tokens")
+	if reserve <= 0 {
+		reserve = defaultResponseReserveTokens
+	}
+	if reserve >= contextWindow/2 {
+		reserve = contextWindow / 2
+	}
+	return &promptBudget{
+		tokenizer:     llm_common.NewTokenizer(llmConfig),
+		contextWindow: contextWindow,
+		reserveTokens: reserve,
+	}
+}
+
+func configInt(config map[string]interface{}, key string) int {
+	switch v := config[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// joinPromptSections 按顺序拼接系统提示词段落
+func joinPromptSections(sections []promptSection) string {
+	var builder strings.Builder
+	for _, section := range sections {
+		builder.WriteString(section.content)
+	}
+	return builder.String()
+}
+
+// fit 将系统提示词段落和历史消息裁剪到预算内，裁剪顺序：
+// 1. 截断历史中过长的工具结果；2. 丢弃较早的历史轮次（保留最近一轮）；
+// 3. 按优先级截断记忆检索、个性化记忆、对话摘要；4. 丢弃剩余历史。
+// 返回裁剪后的段落、历史以及被裁剪内容的说明。
+func (b *promptBudget) fit(sections []promptSection, history []*schema.Message, userMessage *schema.Message, tools []*schema.ToolInfo) ([]promptSection, []*schema.Message, []string) {
+	available := b.contextWindow - b.reserveTokens
+	available -= int(float64(available) * promptBudgetSafetyRatio)
+	available -= llm_common.CountToolsTokens(b.tokenizer, tools)
+	available -= llm_common.CountMessageTokens(b.tokenizer, userMessage)
+
+	sectionTokens := func() int {
+		total := systemMessageTokenOverhead
+		for _, section := range sections {
+			total += b.tokenizer.CountTokens(section.content)
+		}
+		return total
+	}
+	overflow := func() int {
+		return sectionTokens() + llm_common.CountMessagesTokens(b.tokenizer, history) - available
+	}
+
+	if overflow() <= 0 {
+		return sections, history, nil
+	}
+
+	var dropped []string
+
+	// 1. 截断过长的工具结果
+	toolLimit := available / 8
+	if toolLimit < minToolResultTokens {
+		toolLimit = minToolResultTokens
+	}
+	for i, msg := range history {
+		if msg == nil || msg.Role != schema.Tool {
+			continue
+		}
+		tokens := b.tokenizer.CountTokens(msg.Content)
+		if tokens <= toolLimit {
+			continue
+		}
+		truncated, _ := llm_common.TruncateToTokens(b.tokenizer, msg.Content, toolLimit)
+		msgCopy := *msg
+		msgCopy.Content = truncated + truncatedSuffix
+		history[i] = &msgCopy
+		dropped = append(dropped, fmt.Sprintf("工具结果 %s 截断 %d->%d tokens", msg.ToolCallID, tokens, toolLimit))
+	}
+
+	// 2. 按轮次丢弃较早的历史，保留最近一轮
+	droppedMessages := 0
+	for overflow() > 0 {
+		next := nextTurnStart(history)
+		if next <= 0 || next >= len(history) {
+			break
+		}
+		droppedMessages += next
+		history = history[next:]
+	}
+	if droppedMessages > 0 {
+		dropped = append(dropped, fmt.Sprintf("丢弃较早历史 %d 条", droppedMessages))
+	}
+
+	// 3. 按优先级截断可裁剪的系统提示词段落
+	order := make([]int, 0, len(sections))
+	for i, section := range sections {
+		if section.priority != promptPriorityRequired {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, c int) bool {
+		return sections[order[a]].priority < sections[order[c]].priority
+	})
+	for _, idx := range order {
+		over := overflow()
+		if over <= 0 {
+			break
+		}
+		section := &sections[idx]
+		tokens := b.tokenizer.CountTokens(section.content)
+		keep := tokens - over
+		if keep < minSectionTokens {
+			section.content = ""
+			dropped = append(dropped, fmt.Sprintf("丢弃%s %d tokens", section.name, tokens))
+			continue
+		}
+		truncated, _ := llm_common.TruncateToTokens(b.tokenizer, section.content, keep)
+		section.content = truncated + truncatedSuffix
+		dropped = append(dropped, fmt.Sprintf("截断%s %d->%d tokens", section.name, tokens, keep))
+	}
+
+	// 4. 仍然超出时丢弃全部历史
+	if overflow() > 0 && len(history) > 0 {
+		dropped = append(dropped, fmt.Sprintf("丢弃全部剩余历史 %d 条", len(history)))
+		history = history[:0]
+	}
+
+	if over := overflow(); over > 0 {
+		dropped = append(dropped, fmt.Sprintf("裁剪后仍超出预算 %d tokens", over))
+	}
+	return sections, history, dropped
+}
+
+// nextTurnStart 返回第二个 user 消息的位置，即丢弃最早一轮后历史的起点；只剩一轮时返回 0
+func nextTurnStart(history []*schema.Message) int {
+	seenUser := false
+	for i, msg := range history {
+		if msg == nil || msg.Role != schema.User {
+			continue
+		}
+		if seenUser {
+			return i
+		}
+		seenUser = true
+	}
+	return 0
+}
+
+// logPromptBudget 记录裁剪结果
+func logPromptBudget(sessionID string, dropped []string) {
+	if len(dropped) == 0 {
+		return
+	}
+	log.Warnf("prompt 超出模型上下文预算, sessionID: %s, 已裁剪: %s", sessionID, strings.Join(dropped, "; "))
+}
@@ -257,6 +257,7 @@ func (s *ChatSession) initHistoryMessages() error {
 			return err
 		}
 		log.Infof("从 Redis 加载了 %d 条历史消息", len(historyMessages))
+
 	} else if useManager {
 		// 从 Manager 加载
 		historyMessages, err = s.loadFromManager()
@@ -278,6 +279,16 @@ func (s *ChatSession) initHistoryMessages() error {
 		log.Debugf("未加载到历史消息（可能没有历史记录）")
 	}
 
+	if useRedis {
+		// 摘要需在 InitMessages 之后恢复，按边界指纹跳过已并入摘要的消息，避免重复总结
+		summary, boundary, err := llm_memory.Get().GetSummary(s.ctx, s.clientState.DeviceID)
+		if err != nil {
+			log.Warnf("从 Redis 加载滚动摘要失败: %v", err)
+		} else if summary != "" {
+			s.clientState.RestoreHistorySummary(summary, boundary)
+		}
+	}
+
 	return nil
 }
 
@@ -363,7 +374,17 @@ func (c *ChatSession) InitAsrLlmTts() error {
 		memoryType = memory.MemoryTypeNone
 	}
 
-	memoryProvider, err := memory.GetProvider(memoryType, memoryConfig.Config)
+	providerConfig := memoryConfig.Config
+	if memoryType == memory.MemoryTypeLocal {
+		// 本地记忆使用 agent 自身的 LLM 抽取事实
+		providerConfig = make(map[string]interface{}, len(memoryConfig.Config)+1)
+		for k, v := range memoryConfig.Config {
+			providerConfig[k] = v
+		}
+		providerConfig["llm"] = c.clientState.DeviceConfig.Llm.Config
+	}
+
+	memoryProvider, err := memory.GetProvider(memoryType, providerConfig)
 	if err != nil {
 		return fmt.Errorf("创建 Memory 提供者失败: %v", err)
 	}
@@ -608,6 +629,7 @@ func (s *ChatSession) refreshDeviceConfigOnHello() error {
 	// 角色可能已切换，清空声纹临时TTS配置，避免旧配置污染
 	s.clientState.SpeakerTTSConfig = nil
 	applyOutputAudioFormatForTTS(s.clientState)
+	mcp.SetToolPolicy(s.clientState.DeviceID, deviceConfig.AgentId, deviceConfig.MCPToolPolicy)
 
 	log.Infof("设备 %s hello 刷新配置成功，agent: %s -> %s", s.clientState.DeviceID, prevAgentID, deviceConfig.AgentId)
 	return nil
@@ -907,7 +929,10 @@ func (s *ChatSession) HandleListenStart(msg *ClientMessage) error {
 		log.Infof("设备 %s 拾音模式: %s", msg.DeviceID, msg.Mode)
 	}
 	//if s.clientState.ListenMode == "manual" {
-	s.StopSpeaking(false)
+	// 等待用户回答 MCP 服务器的询问时不打断，否则正在执行的工具会被取消
+	if !s.llmManager.HasPendingElicitation() {
+		s.StopSpeaking(false)
+	}
 	//}
 
 	return s.OnListenStart()
@@ -956,6 +981,11 @@ func (s *ChatSession) OnListenStart() error {
 
 	// 定义消息保存回调
 	onMessageSave := func(userMsg *schema.Message, messageID string, audioData []float32) {
+		// 记忆按说话人分区时，此时声纹结果尚未返回，先暂存用户消息，确定分区后再写入长期记忆
+		skipMemory := s.clientState.IsMemoryPartitioned()
+		if skipMemory {
+			s.clientState.SetPendingMemoryMessage(userMsg)
+		}
 		// ASR 文本和音频同时获取，一次性保存（不需要两阶段）
 		eventbus.Get().Publish(eventbus.TopicAddMessage, &eventbus.AddMessageEvent{
 			ClientState: s.clientState,
@@ -967,6 +997,7 @@ func (s *ChatSession) OnListenStart() error {
 			Channels:    s.clientState.InputAudioFormat.Channels,
 			IsUpdate:    false, // 一次性保存（文本+音频）
 			Timestamp:   time.Now(),
+			SkipMemory:  skipMemory,
 		})
 	}
 
@@ -988,6 +1019,11 @@ func (s *ChatSession) AddAsrResultToQueue(text string, speakerResult *speaker.Id
 	if speakerResult != nil && speakerResult.Identified {
 		log.Debugf("AddAsrResultToQueue speaker: %s (confidence: %.2f)", speakerResult.SpeakerName, speakerResult.Confidence)
 	}
+	// MCP服务器正在等待用户回答时，本次说的话直接作为回答交给服务器。
+	// 发起询问的工具调用正阻塞在处理对话队列的协程中，不能再入队等待
+	if s.llmManager.HandleElicitationAnswer(text) {
+		return nil
+	}
 	sessionCtx := s.clientState.SessionCtx.Get(s.clientState.Ctx)
 	item := AsrResponseChannelItem{
 		ctx:           s.clientState.AfterAsrSessionCtx.Get(sessionCtx),
@@ -1107,6 +1143,13 @@ func (s *ChatSession) actionDoChat(ctx context.Context, text string, speakerResu
 	default:
 	}
 
+	s.switchMemoryForSpeaker(speakerResult)
+
+	// 有待确认的敏感工具调用时，本次回答优先作为确认结果处理
+	if s.llmManager.HandleToolConfirmAnswer(ctx, text) {
+		return nil
+	}
+
 	agentID := strings.TrimSpace(s.clientState.AgentID)
 	deviceID := strings.TrimSpace(s.clientState.DeviceID)
 	openclawSessionID := strings.TrimSpace(s.clientState.SessionID)
@@ -1225,7 +1268,7 @@ func (s *ChatSession) actionDoChat(ctx context.Context, text string, speakerResu
 	}
 
 	// 获取全局MCP工具列表
-	mcpTools, err := mcp.GetToolsByDeviceId(clientState.DeviceID, clientState.AgentID, clientState.DeviceConfig.MCPServiceNames)
+	mcpTools, err := mcp.GetAllowedToolsByDeviceId(clientState.DeviceID, clientState.AgentID, clientState.DeviceConfig.MCPServiceNames, clientState.DeviceConfig.MCPToolPolicy)
 	if err != nil {
 		log.Errorf("获取设备 %s 的工具失败: %v", clientState.DeviceID, err)
 		mcpTools = make(map[string]tool.InvokableTool)
@@ -1279,6 +1322,23 @@ func hasAvailableKnowledgeBase(knowledgeBases []types.KnowledgeBaseRef) bool {
 	return false
 }
 
+// switchMemoryForSpeaker 为识别的说话人切换长期记忆分区，并补写 ASR 阶段暂存的用户消息
+func (s *ChatSession) switchMemoryForSpeaker(speakerResult *speaker.IdentifyResult) {
+	memoryScope := s.clientState.SetMemorySpeaker(speakerResult)
+	pendingMsg := s.clientState.TakePendingMemoryMessage()
+	if pendingMsg == nil {
+		return
+	}
+	log.Debugf("用户消息写入记忆分区: %s", memoryScope)
+	eventbus.Get().Publish(eventbus.TopicAddMessage, &eventbus.AddMessageEvent{
+		ClientState: s.clientState,
+		Msg:         *pendingMsg,
+		Timestamp:   time.Now(),
+		MemoryScope: memoryScope,
+		MemoryOnly:  true,
+	})
+}
+
 // switchTTSForSpeaker 为识别的说话人切换TTS
 func (s *ChatSession) switchTTSForSpeaker(speakerResult *speaker.IdentifyResult) error {
 	s.clientState.SpeakerTTSConfig = nil
@@ -0,0 +1,153 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "xiaozhi-esp32-server-golang/internal/data/client"
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	"xiaozhi-esp32-server-golang/internal/domain/memory/llm_memory"
+	log "xiaozhi-esp32-server-golang/logger"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 历史窗口默认 token 预算，超出部分滚动总结
+	defaultHistoryMaxTokens = 1500
+	// 摘要默认长度上限（字）
+	defaultSummaryMaxLength = 300
+	// 单次总结超时时间
+	summaryTimeout = 30 * time.Second
+)
+
+// historySummaryPrompt 滚动摘要提示词，将已有摘要与新滑出窗口的对话合并
+const historySummaryPrompt = `你是对话摘要助手。请把"已有摘要"与"新增对话"合并成一份新的摘要，供后续对话参考。
+
+## 要求
+1. 保留用户提到的事实、偏好、正在进行的话题、未完成的请求和助手做出的承诺
+2. 省略寒暄、重复内容和无关细节
+3. 使用第三人称（"用户"、"助手"），按时间顺序简洁陈述
+4. 不超过 %d 字，直接输出摘要正文，不要解释`
+
+// historySummarizer 将滑出历史窗口的消息总结进滚动摘要
+type historySummarizer struct {
+	clientState *ClientState
+	running     atomic.Bool
+
+	llmOnce     sync.Once
+	llmProvider llm.LLMProvider
+	llmErr      error
+}
+
+func newHistorySummarizer(clientState *ClientState) *historySummarizer {
+	return &historySummarizer{clientState: clientState}
+}
+
+// historyMaxTokens 历史窗口 token 预算，配置为 0 时按条数截取
+func historyMaxTokens() int {
+	if !viper.IsSet("chat.history.max_tokens") {
+		return defaultHistoryMaxTokens
+	}
+	return viper.GetInt("chat.history.max_tokens")
+}
+
+func summaryEnabled() bool {
+	if !viper.IsSet("chat.history.summary.enable") {
+		return true
+	}
+	return viper.GetBool("chat.history.summary.enable")
+}
+
+func summaryMaxLength() int {
+	if length := viper.GetInt("chat.history.summary.max_length"); length > 0 {
+		return length
+	}
+	return defaultSummaryMaxLength
+}
+
+// getLLM 优先使用 chat.history.summary.llm 指定的（通常更便宜的）模型，未配置时使用 agent 自身的 LLM
+func (s *historySummarizer) getLLM() (llm.LLMProvider, error) {
+	s.llmOnce.Do(func() {
+		llmConfig := s.clientState.DeviceConfig.Llm.Config
+		if name := strings.TrimSpace(viper.GetString("chat.history.summary.llm")); name != "" {
+			if cfg := viper.GetStringMap("llm." + name); len(cfg) > 0 {
+				llmConfig = cfg
+			} else {
+				log.Warnf("摘要模型 %s 未在 llm 配置中找到，使用 agent 的 LLM", name)
+			}
+		}
+		llmType, _ := llmConfig["type"].(string)
+		if llmType == "" {
+			s.llmErr = fmt.Errorf("summary llm type not found")
+			return
+		}
+		s.llmProvider, s.llmErr = llm.GetLLMProvider(llmType, llmConfig)
+	})
+	return s.llmProvider, s.llmErr
+}
+
+// Summarize 异步将 evicted 合并进滚动摘要；同一时间只运行一次，未处理的消息留到下次
+func (s *historySummarizer) Summarize(evicted []*schema.Message, evictedEnd int) {
+	if len(evicted) == 0 || !summaryEnabled() {
+		return
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer s.running.Store(false)
+
+		ctx, cancel := context.WithTimeout(s.clientState.Ctx, summaryTimeout)
+		defer cancel()
+
+		summary, err := s.summarize(ctx, s.clientState.GetHistorySummary(), evicted)
+		if err != nil {
+			log.Warnf("滚动摘要失败, deviceID: %s, err: %v", s.clientState.DeviceID, err)
+			return
+		}
+		s.clientState.SetHistorySummary(summary, evictedEnd)
+		log.Debugf("滚动摘要已更新, deviceID: %s, 合并 %d 条消息, 摘要: %s", s.clientState.DeviceID, len(evicted), summary)
+
+		if viper.GetString("config_provider.type") == "redis" {
+			if err := llm_memory.Get().SetSummary(ctx, s.clientState.DeviceID, summary, SummaryBoundary(evicted)); err != nil {
+				log.Warnf("保存滚动摘要失败: %v", err)
+			}
+		}
+	}()
+}
+
+func (s *historySummarizer) summarize(ctx context.Context, previous string, evicted []*schema.Message) (string, error) {
+	dialogue := llm_common.BuildPromptFromDialogue(evicted)
+	if dialogue == "" {
+		return previous, nil
+	}
+
+	llmProvider, err := s.getLLM()
+	if err != nil {
+		return "", err
+	}
+
+	if previous == "" {
+		previous = "（无）"
+	}
+	content := fmt.Sprintf("## 已有摘要\n%s\n\n## 新增对话\n%s", previous, dialogue)
+	summary, err := llm.ResponseText(ctx, llmProvider, "history_summary_"+s.clientState.SessionID, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(historySummaryPrompt, summaryMaxLength())),
+		schema.UserMessage(content),
+	})
+	if err != nil {
+		return "", err
+	}
+	if summary == "" {
+		return "", fmt.Errorf("summary llm returned empty text")
+	}
+	return summary, nil
+}
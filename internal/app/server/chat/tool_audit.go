@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+
+	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
+	config_types "xiaozhi-esp32-server-golang/internal/domain/config/types"
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// 工具调用审计状态
+const (
+	toolCallStatusSuccess        = "success"
+	toolCallStatusError          = "error"
+	toolCallStatusDenied         = "denied"
+	toolCallStatusNotFound       = "not_found"
+	toolCallStatusPendingConfirm = "pending_confirm"
+	toolCallStatusTimeout        = "timeout"
+	toolCallStatusCanceled       = "canceled"
+)
+
+const (
+	toolAuditReportTimeout = 5 * time.Second
+	// toolAuditResultMaxRunes 结果摘要的最大长度
+	toolAuditResultMaxRunes = 500
+	// toolAuditArgumentsMaxBytes 参数的最大长度，超出部分截断
+	toolAuditArgumentsMaxBytes = 8 * 1024
+)
+
+// toolCallAudit 一次工具调用的审计记录
+type toolCallAudit struct {
+	toolCall  schema.ToolCall
+	source    string
+	server    string
+	arguments string
+	result    string
+	status    string
+	latencyMs int64
+	err       error
+}
+
+// toolCallAuditEnabled 是否上报工具调用审计日志，默认开启
+func toolCallAuditEnabled() bool {
+	if !viper.IsSet("mcp.tool_audit.enable") {
+		return true
+	}
+	return viper.GetBool("mcp.tool_audit.enable")
+}
+
+// toolServerName 工具所属的服务器：全局工具为 MCP 服务器名，设备工具为设备ID，本地工具为 local
+func (l *LLMManager) toolServerName(invokable tool.InvokableTool, source string) string {
+	switch source {
+	case mcp.ToolSourceLocal:
+		return mcp.ToolSourceLocal
+	case mcp.ToolSourceDevice:
+		return l.clientState.DeviceID
+	}
+	if mcpTool, ok := invokable.(*mcp.McpTool); ok {
+		return mcpTool.GetServerName()
+	}
+	return ""
+}
+
+func truncateRunes(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	return string([]rune(s)[:maxRunes]) + "..."
+}
+
+// setSpeakerName 记录当前轮次的说话人，工具调用审计时使用
+func (l *LLMManager) setSpeakerName(name string) {
+	l.speakerName.Store(name)
+}
+
+func (l *LLMManager) getSpeakerName() string {
+	name, _ := l.speakerName.Load().(string)
+	return name
+}
+
+// auditToolCall 通过管理后台的 websocket 通道异步上报工具调用记录，上报失败只记录日志
+func (l *LLMManager) auditToolCall(record toolCallAudit) {
+	if !toolCallAuditEnabled() {
+		return
+	}
+	state := l.clientState
+
+	arguments := record.arguments
+	if arguments == "" {
+		arguments = record.toolCall.Function.Arguments
+	}
+	if len(arguments) > toolAuditArgumentsMaxBytes {
+		arguments = arguments[:toolAuditArgumentsMaxBytes]
+	}
+	errMsg := ""
+	if record.err != nil {
+		errMsg = record.err.Error()
+	}
+	data := map[string]interface{}{
+		"agent_id":       state.AgentID,
+		"device_id":      state.DeviceID,
+		"session_id":     state.SessionID,
+		"speaker":        l.getSpeakerName(),
+		"tool_call_id":   record.toolCall.ID,
+		"tool_name":      record.toolCall.Function.Name,
+		"source":         record.source,
+		"server_name":    record.server,
+		"arguments":      arguments,
+		"result_summary": truncateRunes(record.result, toolAuditResultMaxRunes),
+		"status":         record.status,
+		"latency_ms":     record.latencyMs,
+		"error":          errMsg,
+		"called_at":      time.Now().Format(time.RFC3339),
+	}
+
+	go func() {
+		provider, err := user_config.GetProvider(viper.GetString("config_provider.type"))
+		if err != nil {
+			log.Warnf("上报工具调用审计失败, 获取配置提供者失败: %v", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), toolAuditReportTimeout)
+		defer cancel()
+		provider.NotifyDeviceEvent(ctx, config_types.EventToolCallAudit, data)
+	}()
+}
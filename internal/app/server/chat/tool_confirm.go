@@ -0,0 +1,270 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/viper"
+
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	defaultToolConfirmTimeout = 20 * time.Second
+	toolConfirmLLMTimeout     = 5 * time.Second
+	// toolConfirmLabelMaxRunes 确认问句中工具描述的最大长度
+	toolConfirmLabelMaxRunes = 30
+	// toolConfirmPendingResult 等待确认期间写入历史的工具结果
+	toolConfirmPendingResult = "该操作需要用户确认，已向用户询问，等待用户回答"
+	toolConfirmCancelText    = "好的，已取消。"
+)
+
+type toolConfirmContextKey struct{}
+
+type toolConfirmAnswer int
+
+const (
+	toolConfirmUnknown toolConfirmAnswer = iota
+	toolConfirmYes
+	toolConfirmNo
+)
+
+// 确认回答按整句匹配，"好，别忘了开灯"、"不错，执行吧"这类混合回答不会被单字误判，交给 LLM 判断
+var (
+	toolConfirmNoAnswers = map[string]struct{}{
+		"不": {}, "不要": {}, "不用": {}, "不行": {}, "不可以": {}, "不是": {}, "不对": {}, "不确认": {}, "不执行": {},
+		"不要执行": {}, "别": {}, "别执行": {}, "停": {}, "停止": {}, "否": {}, "取消": {}, "取消执行": {}, "算了": {}, "no": {},
+	}
+	toolConfirmYesAnswers = map[string]struct{}{
+		"确认": {}, "确定": {}, "确认执行": {}, "是": {}, "是的": {}, "好": {}, "好的": {}, "可以": {}, "执行": {}, "对": {},
+		"没问题": {}, "行": {}, "嗯": {}, "嗯嗯": {}, "继续": {}, "同意": {}, "yes": {}, "ok": {}, "okay": {},
+	}
+	// toolConfirmAnswerSuffixes 回答末尾可省略的语气词，如"好吧"、"不用了"
+	toolConfirmAnswerSuffixes = []string{"吧", "了", "呀", "啊", "呢", "的"}
+)
+
+const toolConfirmClassifyPrompt = `你是一个意图判断助手。系统刚刚询问用户是否确认执行一个操作，请判断用户的回答：
+- 同意执行，只输出 yes
+- 拒绝或取消，只输出 no
+- 与确认无关或无法判断，只输出 other
+不要输出其他内容。`
+
+// pendingToolCall 等待确认的单个工具调用
+type pendingToolCall struct {
+	toolCall schema.ToolCall
+	label    string
+}
+
+// pendingToolConfirm 已向用户询问、等待回答的工具调用
+type pendingToolConfirm struct {
+	calls    []pendingToolCall
+	question string
+	deadline time.Time
+}
+
+// toolConfirmTimeout 等待用户确认的超时时间，超时后的回答按普通对话处理
+func toolConfirmTimeout() time.Duration {
+	if seconds := viper.GetInt("chat.tool_confirm_timeout"); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultToolConfirmTimeout
+}
+
+// toolConfirmLabel 用工具描述的第一句作为播报内容，没有描述时使用工具名
+func toolConfirmLabel(ctx context.Context, invokable tool.InvokableTool, toolName string) string {
+	info, err := invokable.Info(ctx)
+	if err != nil || info == nil {
+		return toolName
+	}
+	desc := strings.TrimSpace(info.Desc)
+	if idx := strings.IndexAny(desc, "。.！!？?\n"); idx > 0 {
+		desc = desc[:idx]
+	}
+	if desc == "" {
+		return toolName
+	}
+	if utf8.RuneCountInString(desc) > toolConfirmLabelMaxRunes {
+		desc = string([]rune(desc)[:toolConfirmLabelMaxRunes])
+	}
+	return desc
+}
+
+func buildToolConfirmQuestion(calls []pendingToolCall) string {
+	labels := make([]string, 0, len(calls))
+	for _, call := range calls {
+		labels = append(labels, call.label)
+	}
+	return fmt.Sprintf("即将执行「%s」，确认执行吗？", strings.Join(labels, "、"))
+}
+
+// withConfirmedToolCalls 标记 ctx 中已确认的工具调用，handleToolCallResponse 不再重复询问
+func withConfirmedToolCalls(ctx context.Context, calls []schema.ToolCall) context.Context {
+	confirmed := make(map[string]struct{}, len(calls))
+	for _, call := range calls {
+		confirmed[call.ID] = struct{}{}
+	}
+	return context.WithValue(ctx, toolConfirmContextKey{}, confirmed)
+}
+
+func isToolCallConfirmed(ctx context.Context, toolCallID string) bool {
+	confirmed, ok := ctx.Value(toolConfirmContextKey{}).(map[string]struct{})
+	if !ok {
+		return false
+	}
+	_, exists := confirmed[toolCallID]
+	return exists
+}
+
+// setPendingToolConfirm 保存待确认的工具调用，返回播报给用户的确认问句
+func (l *LLMManager) setPendingToolConfirm(calls []pendingToolCall) string {
+	question := buildToolConfirmQuestion(calls)
+	l.toolConfirmMu.Lock()
+	l.pendingToolConfirm = &pendingToolConfirm{
+		calls:    calls,
+		question: question,
+		deadline: time.Now().Add(toolConfirmTimeout()),
+	}
+	l.toolConfirmMu.Unlock()
+	return question
+}
+
+func (l *LLMManager) takePendingToolConfirm() *pendingToolConfirm {
+	l.toolConfirmMu.Lock()
+	defer l.toolConfirmMu.Unlock()
+	pending := l.pendingToolConfirm
+	l.pendingToolConfirm = nil
+	return pending
+}
+
+// HandleToolConfirmAnswer 有待确认的工具调用时，将用户回答作为确认结果处理：
+// 确认则执行工具并继续对话，拒绝则取消并播报；超时或回答与确认无关时返回 false，按普通对话处理
+func (l *LLMManager) HandleToolConfirmAnswer(ctx context.Context, text string) bool {
+	pending := l.takePendingToolConfirm()
+	if pending == nil {
+		return false
+	}
+	if time.Now().After(pending.deadline) {
+		log.Infof("工具确认已超时, 取消执行, deviceID: %s, question: %s", l.clientState.DeviceID, pending.question)
+		return false
+	}
+
+	answer := classifyToolConfirmAnswer(text)
+	if answer == toolConfirmUnknown {
+		answer = l.classifyToolConfirmAnswerByLLM(ctx, pending.question, text)
+	}
+
+	switch answer {
+	case toolConfirmYes:
+		log.Infof("用户确认执行工具, deviceID: %s, 回答: %s", l.clientState.DeviceID, text)
+		if err := l.runConfirmedToolCalls(ctx, pending); err != nil {
+			log.Errorf("执行已确认的工具失败: %v", err)
+		}
+		return true
+	case toolConfirmNo:
+		log.Infof("用户取消执行工具, deviceID: %s, 回答: %s", l.clientState.DeviceID, text)
+		if err := l.AddTextToTTSQueue(toolConfirmCancelText); err != nil {
+			log.Warnf("播报取消提示失败: %v", err)
+		}
+		return true
+	default:
+		log.Infof("用户回答与确认无关, 取消执行并按普通对话处理, deviceID: %s, 回答: %s", l.clientState.DeviceID, text)
+		return false
+	}
+}
+
+// runConfirmedToolCalls 以新的工具调用 ID 重新发起已确认的调用，复用工具调用流程执行并继续 LLM 对话
+func (l *LLMManager) runConfirmedToolCalls(ctx context.Context, pending *pendingToolConfirm) error {
+	toolCalls := make([]schema.ToolCall, 0, len(pending.calls))
+	for _, call := range pending.calls {
+		toolCall := call.toolCall
+		toolCall.ID = toolCall.ID + "_confirmed"
+		toolCalls = append(toolCalls, toolCall)
+	}
+
+	responseChan := make(chan llm_common.LLMResponseStruct, 1)
+	responseChan <- llm_common.LLMResponseStruct{
+		ToolCalls: toolCalls,
+		IsStart:   true,
+		IsEnd:     true,
+	}
+	close(responseChan)
+
+	return l.HandleLLMResponseChannelAsync(withConfirmedToolCalls(ctx, toolCalls), nil, responseChan)
+}
+
+// classifyToolConfirmAnswer 按整句判断确认回答：按标点拆分后每一段都是同一种简短回答时才返回结果，
+// 例如"好的，执行吧"、"不用了，取消"；包含其他内容的回答返回 toolConfirmUnknown
+func classifyToolConfirmAnswer(text string) toolConfirmAnswer {
+	segments := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+	answer := toolConfirmUnknown
+	for _, segment := range segments {
+		current := classifyToolConfirmSegment(segment)
+		if current == toolConfirmUnknown || (answer != toolConfirmUnknown && current != answer) {
+			return toolConfirmUnknown
+		}
+		answer = current
+	}
+	return answer
+}
+
+func classifyToolConfirmSegment(segment string) toolConfirmAnswer {
+	for {
+		if _, ok := toolConfirmNoAnswers[segment]; ok {
+			return toolConfirmNo
+		}
+		if _, ok := toolConfirmYesAnswers[segment]; ok {
+			return toolConfirmYes
+		}
+		trimmed := segment
+		for _, suffix := range toolConfirmAnswerSuffixes {
+			trimmed = strings.TrimSuffix(trimmed, suffix)
+		}
+		if trimmed == segment || trimmed == "" {
+			return toolConfirmUnknown
+		}
+		segment = trimmed
+	}
+}
+
+// classifyToolConfirmAnswerByLLM 关键词无法判断时使用 agent 的 LLM 判断，失败时视为无关
+func (l *LLMManager) classifyToolConfirmAnswerByLLM(ctx context.Context, question string, text string) toolConfirmAnswer {
+	llmConfig := l.clientState.DeviceConfig.Llm.Config
+	llmType, _ := llmConfig["type"].(string)
+	if llmType == "" {
+		return toolConfirmUnknown
+	}
+	llmProvider, err := llm.GetLLMProvider(llmType, llmConfig)
+	if err != nil {
+		log.Warnf("工具确认判断获取LLM失败: %v", err)
+		return toolConfirmUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolConfirmLLMTimeout)
+	defer cancel()
+	result, err := llm.ResponseText(ctx, llmProvider, "tool_confirm_"+l.clientState.SessionID, []*schema.Message{
+		schema.SystemMessage(toolConfirmClassifyPrompt),
+		schema.UserMessage(fmt.Sprintf("系统询问：%s\n用户回答：%s", question, text)),
+	})
+	if err != nil {
+		log.Warnf("工具确认判断失败: %v", err)
+		return toolConfirmUnknown
+	}
+	switch strings.ToLower(strings.TrimSpace(result)) {
+	case "yes":
+		return toolConfirmYes
+	case "no":
+		return toolConfirmNo
+	default:
+		return toolConfirmUnknown
+	}
+}
@@ -0,0 +1,35 @@
+package chat
+
+import "testing"
+
+func TestClassifyToolConfirmAnswer(t *testing.T) {
+	tests := []struct {
+		text string
+		want toolConfirmAnswer
+	}{
+		{"好", toolConfirmYes},
+		{"好的。", toolConfirmYes},
+		{"好吧", toolConfirmYes},
+		{"嗯，可以", toolConfirmYes},
+		{"好的，执行吧", toolConfirmYes},
+		{"OK", toolConfirmYes},
+		{"不", toolConfirmNo},
+		{"不用了", toolConfirmNo},
+		{"别执行", toolConfirmNo},
+		{"不要，取消吧！", toolConfirmNo},
+		{"算了", toolConfirmNo},
+		// 混合回答交给 LLM 判断
+		{"好，别忘了开灯", toolConfirmUnknown},
+		{"不错，执行吧", toolConfirmUnknown},
+		{"好，不要", toolConfirmUnknown},
+		{"停车场在哪", toolConfirmUnknown},
+		{"今天天气怎么样", toolConfirmUnknown},
+		{"", toolConfirmUnknown},
+		{"。", toolConfirmUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyToolConfirmAnswer(tt.text); got != tt.want {
+			t.Errorf("classifyToolConfirmAnswer(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package chat
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+
+	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	"xiaozhi-esp32-server-golang/internal/domain/play_music"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const defaultToolFillerDelay = 1500 * time.Millisecond
+
+var defaultToolFillerTexts = []string{"稍等，我查一下", "好的，请稍等一下"}
+
+// toolFillerConfig 工具调用较慢时的等待提示配置
+type toolFillerConfig struct {
+	Enable bool
+	Delay  time.Duration
+	Texts  []string
+	Earcon string // 提示音文件（mp3），配置后播放提示音而不是语音
+}
+
+func getToolFillerConfig() toolFillerConfig {
+	cfg := toolFillerConfig{
+		Enable: true,
+		Delay:  defaultToolFillerDelay,
+		Texts:  viper.GetStringSlice("chat.tool_filler.texts"),
+		Earcon: viper.GetString("chat.tool_filler.earcon"),
+	}
+	if viper.IsSet("chat.tool_filler.enable") {
+		cfg.Enable = viper.GetBool("chat.tool_filler.enable")
+	}
+	// delay_ms 未配置或不是正整数时使用默认值，避免工具刚开始调用就播放提示
+	if delayMs := viper.GetInt("chat.tool_filler.delay_ms"); delayMs > 0 {
+		cfg.Delay = time.Duration(delayMs) * time.Millisecond
+	}
+	if len(cfg.Texts) == 0 {
+		cfg.Texts = defaultToolFillerTexts
+	}
+	return cfg
+}
+
+// toolFiller 一次等待提示，工具返回后取消尚未开始播放的提示
+type toolFiller struct {
+	text     string
+	earcon   []byte
+	canceled atomic.Bool
+}
+
+var (
+	earconCacheMu sync.Mutex
+	earconCache   = make(map[string][]byte)
+)
+
+// loadEarcon 读取提示音文件，按路径缓存
+func loadEarcon(path string) ([]byte, error) {
+	earconCacheMu.Lock()
+	defer earconCacheMu.Unlock()
+	if data, ok := earconCache[path]; ok {
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	earconCache[path] = data
+	return data, nil
+}
+
+// newToolFiller 按配置选择提示音或随机一句提示语，提示音读取失败时改用语音
+func newToolFiller(cfg toolFillerConfig) *toolFiller {
+	filler := &toolFiller{}
+	if cfg.Earcon != "" {
+		data, err := loadEarcon(cfg.Earcon)
+		if err != nil {
+			log.Warnf("读取工具等待提示音失败: %s, %v，改用语音提示", cfg.Earcon, err)
+		} else {
+			filler.earcon = data
+		}
+	}
+	if filler.earcon == nil {
+		texts := cfg.Texts
+		if len(texts) == 0 {
+			texts = defaultToolFillerTexts
+		}
+		filler.text = texts[rand.Intn(len(texts))]
+	}
+	return filler
+}
+
+// scheduleToolFiller 超过 delay 工具仍未返回且对话未取消时调用 play，返回的 stop 在工具返回后调用
+func scheduleToolFiller(ctx context.Context, delay time.Duration, filler *toolFiller, play func(*toolFiller)) (stop func()) {
+	timer := time.AfterFunc(delay, func() {
+		if ctx.Err() != nil || filler.canceled.Load() {
+			return
+		}
+		play(filler)
+	})
+	return func() {
+		timer.Stop()
+		filler.canceled.Store(true)
+	}
+}
+
+// startToolFiller 工具调用超过 delay 仍未返回时播报等待提示，返回的函数在工具全部返回后调用
+// 提示只进入 TTS 队列，不写入对话历史和音频历史；真正的回复排在提示之后播放
+func (l *LLMManager) startToolFiller(ctx context.Context) (stop func()) {
+	cfg := getToolFillerConfig()
+	if !cfg.Enable {
+		return func() {}
+	}
+
+	return scheduleToolFiller(ctx, cfg.Delay, newToolFiller(cfg), func(filler *toolFiller) {
+		log.Debugf("%s 工具调用超过 %s 未返回，播放等待提示", l.clientState.DeviceID, cfg.Delay)
+		l.ttsManager.ttsQueue.Push(TTSQueueItem{
+			ctx:        ctx,
+			generation: l.ttsManager.currentAudioGeneration(),
+			filler:     filler,
+		})
+	})
+}
+
+// handleToolFiller 播放等待提示；工具已返回时跳过，正在播放的提示播完后再切到真正的回复
+func (t *TTSManager) handleToolFiller(item TTSQueueItem) {
+	filler := item.filler
+	if filler.canceled.Load() || item.ctx.Err() != nil {
+		return
+	}
+
+	var (
+		outChan <-chan []byte
+		release func()
+	)
+	if filler.earcon != nil {
+		playCtx, cancel := context.WithCancel(item.ctx)
+		audioChan, err := play_music.PlayMusicFromAudioData(playCtx, filler.earcon, t.clientState.OutputAudioFormat.SampleRate, t.clientState.OutputAudioFormat.FrameDuration, "mp3")
+		if err != nil {
+			cancel()
+			log.Errorf("播放工具等待提示音失败: %v", err)
+			return
+		}
+		outChan, release = audioChan, cancel
+	} else {
+		ttsChan, releaseFunc, err := t.generateTtsOnly(item.ctx, llm_common.LLMResponseStruct{Text: filler.text})
+		if err != nil || ttsChan == nil {
+			if releaseFunc != nil {
+				releaseFunc()
+			}
+			log.Errorf("生成工具等待提示语音失败: %s, %v", filler.text, err)
+			return
+		}
+		outChan, release = ttsChan, releaseFunc
+	}
+	// 语音生成期间工具已返回，不再播放
+	if filler.canceled.Load() {
+		if release != nil {
+			release()
+		}
+		return
+	}
+	t.enqueueAudioFrames(item.ctx, item.generation, filler.text, false, outChan, release, nil, nil, true)
+}
@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetToolFillerConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   map[string]interface{}
+		wantEnable bool
+		wantDelay  time.Duration
+		wantTexts  []string
+	}{
+		{
+			name:       "未配置使用默认值",
+			wantEnable: true,
+			wantDelay:  defaultToolFillerDelay,
+			wantTexts:  defaultToolFillerTexts,
+		},
+		{
+			name:       "按配置填充",
+			settings:   map[string]interface{}{"chat.tool_filler.delay_ms": 800, "chat.tool_filler.texts": []string{"请稍候"}},
+			wantEnable: true,
+			wantDelay:  800 * time.Millisecond,
+			wantTexts:  []string{"请稍候"},
+		},
+		{
+			name:       "关闭",
+			settings:   map[string]interface{}{"chat.tool_filler.enable": false},
+			wantEnable: false,
+			wantDelay:  defaultToolFillerDelay,
+			wantTexts:  defaultToolFillerTexts,
+		},
+		{
+			name:       "delay_ms 类型错误使用默认值",
+			settings:   map[string]interface{}{"chat.tool_filler.delay_ms": "abc"},
+			wantEnable: true,
+			wantDelay:  defaultToolFillerDelay,
+			wantTexts:  defaultToolFillerTexts,
+		},
+		{
+			name:       "delay_ms 为负数使用默认值",
+			settings:   map[string]interface{}{"chat.tool_filler.delay_ms": -5},
+			wantEnable: true,
+			wantDelay:  defaultToolFillerDelay,
+			wantTexts:  defaultToolFillerTexts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			for key, value := range tt.settings {
+				viper.Set(key, value)
+			}
+			cfg := getToolFillerConfig()
+			if cfg.Enable != tt.wantEnable || cfg.Delay != tt.wantDelay {
+				t.Errorf("enable=%v delay=%s, want enable=%v delay=%s", cfg.Enable, cfg.Delay, tt.wantEnable, tt.wantDelay)
+			}
+			if len(cfg.Texts) != len(tt.wantTexts) || cfg.Texts[0] != tt.wantTexts[0] {
+				t.Errorf("texts = %v, want %v", cfg.Texts, tt.wantTexts)
+			}
+		})
+	}
+}
+
+func TestNewToolFiller(t *testing.T) {
+	earcon := filepath.Join(t.TempDir(), "earcon.mp3")
+	if err := os.WriteFile(earcon, []byte("ID3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		cfg        toolFillerConfig
+		wantEarcon bool
+		wantText   string
+	}{
+		{name: "提示音", cfg: toolFillerConfig{Earcon: earcon, Texts: []string{"请稍候"}}, wantEarcon: true},
+		{name: "提示音文件缺失改用语音", cfg: toolFillerConfig{Earcon: earcon + ".missing", Texts: []string{"请稍候"}}, wantText: "请稍候"},
+		{name: "语音", cfg: toolFillerConfig{Texts: []string{"请稍候"}}, wantText: "请稍候"},
+		{name: "未配置提示语使用默认", cfg: toolFillerConfig{}, wantText: defaultToolFillerTexts[0]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filler := newToolFiller(tt.cfg)
+			if (filler.earcon != nil) != tt.wantEarcon {
+				t.Errorf("earcon set = %v, want %v", filler.earcon != nil, tt.wantEarcon)
+			}
+			if tt.wantEarcon {
+				return
+			}
+			if tt.cfg.Texts == nil {
+				found := false
+				for _, text := range defaultToolFillerTexts {
+					found = found || filler.text == text
+				}
+				if !found {
+					t.Errorf("text = %q, want one of %v", filler.text, defaultToolFillerTexts)
+				}
+				return
+			}
+			if filler.text != tt.wantText {
+				t.Errorf("text = %q, want %q", filler.text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestScheduleToolFiller(t *testing.T) {
+	tests := []struct {
+		name     string
+		toolTime time.Duration // 工具调用耗时，为 0 表示立即返回
+		canceled bool
+		wantPlay bool
+	}{
+		{name: "工具超时播放提示", toolTime: 100 * time.Millisecond, wantPlay: true},
+		{name: "工具及时返回不播放", wantPlay: false},
+		{name: "对话已取消不播放", toolTime: 100 * time.Millisecond, canceled: true, wantPlay: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			played := make(chan *toolFiller, 1)
+			filler := &toolFiller{text: "请稍候"}
+			stop := scheduleToolFiller(ctx, 20*time.Millisecond, filler, func(f *toolFiller) { played <- f })
+			time.Sleep(tt.toolTime)
+			stop()
+			if !filler.canceled.Load() {
+				t.Error("filler not canceled after stop")
+			}
+
+			select {
+			case got := <-played:
+				if !tt.wantPlay {
+					t.Error("filler played")
+				} else if got != filler {
+					t.Error("played another filler")
+				}
+			case <-time.After(60 * time.Millisecond):
+				if tt.wantPlay {
+					t.Error("filler not played")
+				}
+			}
+		})
+	}
+}
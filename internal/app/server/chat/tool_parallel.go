@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// toolInvocation 一次需要实际执行的工具调用及其结果
+type toolInvocation struct {
+	index      int // 在本轮工具调用中的位置
+	toolCall   schema.ToolCall
+	tool       tool.InvokableTool
+	source     string
+	serverName string
+	arguments  string
+	// timeout 单次调用的超时时间，为 0 时使用所属服务器的配置
+	timeout time.Duration
+	// suspended 返回 true 时（如工具正在等待用户回答 MCP 服务器的询问）不计超时
+	suspended func() bool
+
+	output string
+	err    error
+	status string // 执行失败时的审计状态：error/timeout/canceled
+	costMs int64
+}
+
+// invokeToolsConcurrently 并发执行工具调用，每个调用使用所属服务器的超时时间
+// ctx 随会话打断取消，此时所有未完成的调用立即返回 canceled
+func (l *LLMManager) invokeToolsConcurrently(ctx context.Context, invocations []*toolInvocation) {
+	var wg sync.WaitGroup
+	for _, invocation := range invocations {
+		if invocation.suspended == nil {
+			invocation.suspended = l.HasPendingElicitation
+		}
+		wg.Add(1)
+		go func(invocation *toolInvocation) {
+			defer wg.Done()
+			invocation.invoke(ctx)
+		}(invocation)
+	}
+	wg.Wait()
+}
+
+func (t *toolInvocation) invoke(ctx context.Context) {
+	timeout := t.timeout
+	if timeout <= 0 {
+		timeout = mcp.ToolCallTimeout(t.serverName)
+	}
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log.Infof("进行工具调用请求: %s, 参数: %+v, 超时: %s", t.toolCall.Function.Name, t.arguments, timeout)
+
+	type runResult struct {
+		output string
+		err    error
+	}
+	resultChan := make(chan runResult, 1)
+	startTs := time.Now()
+	// 不响应 ctx 的工具（如卡住的远端服务）不能阻塞本轮对话，超时后直接放弃等待
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				resultChan <- runResult{err: fmt.Errorf("工具执行异常: %v", r)}
+			}
+		}()
+		output, err := t.tool.InvokableRun(callCtx, t.arguments)
+		resultChan <- runResult{output: output, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	received, timedOut := false, false
+	for !received && !timedOut && ctx.Err() == nil {
+		select {
+		case result := <-resultChan:
+			t.output, t.err = result.output, result.err
+			received = true
+		case <-timer.C:
+			// 等待用户回答询问期间不计时，回答后重新计算超时
+			if t.suspended != nil && t.suspended() {
+				timer.Reset(timeout)
+				continue
+			}
+			timedOut = true
+			cancel()
+		case <-ctx.Done():
+		}
+	}
+	t.costMs = time.Since(startTs).Milliseconds()
+
+	if received && t.err == nil {
+		return
+	}
+	var policyErr *mcp.ToolPolicyError
+	switch {
+	case received && errors.As(t.err, &policyErr):
+		// 被工具策略拒绝，原因直接交给 LLM
+		t.status = toolCallStatusDenied
+	case ctx.Err() != nil:
+		t.status = toolCallStatusCanceled
+		t.err = fmt.Errorf("工具 %s 调用已取消: 用户打断了对话", t.toolCall.Function.Name)
+	case timedOut || errors.Is(t.err, context.DeadlineExceeded):
+		t.status = toolCallStatusTimeout
+		t.err = fmt.Errorf("工具 %s 调用超时（超过 %d 秒未返回），请告知用户该操作暂时无法完成，不要重复调用", t.toolCall.Function.Name, int(timeout.Seconds()))
+	default:
+		t.status = toolCallStatusError
+		t.err = fmt.Errorf("工具 %s 调用失败: %v", t.toolCall.Function.Name, t.err)
+	}
+	t.output = ""
+}
@@ -0,0 +1,126 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// funcTool 测试用工具，调用时执行 run
+type funcTool struct {
+	run func(ctx context.Context) (string, error)
+}
+
+func (f funcTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "test_tool"}, nil
+}
+
+func (f funcTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return f.run(ctx)
+}
+
+func newTestInvocation(index int, timeout time.Duration, run func(ctx context.Context) (string, error)) *toolInvocation {
+	return &toolInvocation{
+		index:    index,
+		toolCall: schema.ToolCall{ID: "call", Function: schema.FunctionCall{Name: "test_tool"}},
+		tool:     funcTool{run: run},
+		timeout:  timeout,
+	}
+}
+
+func TestToolInvocationSuspendedByElicitation(t *testing.T) {
+	llmManager := &LLMManager{}
+	pending := &pendingElicitation{answer: make(chan string, 1)}
+	llmManager.pendingElicitation = pending
+
+	// 工具等待用户回答询问，耗时超过单次超时，回答后返回
+	invocation := newTestInvocation(0, 30*time.Millisecond, func(ctx context.Context) (string, error) {
+		select {
+		case answer := <-pending.answer:
+			return "已打开" + answer + "的灯", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		llmManager.HandleElicitationAnswer("客厅")
+	}()
+	llmManager.invokeToolsConcurrently(context.Background(), []*toolInvocation{invocation})
+	if invocation.err != nil || invocation.output != "已打开客厅的灯" {
+		t.Fatalf("output=%q err=%v status=%s", invocation.output, invocation.err, invocation.status)
+	}
+}
+
+func TestToolInvocationTimeout(t *testing.T) {
+	// 工具不响应 ctx，超时后不再等待
+	block := make(chan struct{})
+	defer close(block)
+	invocation := newTestInvocation(0, 50*time.Millisecond, func(ctx context.Context) (string, error) {
+		<-block
+		return "late", nil
+	})
+	start := time.Now()
+	(&LLMManager{}).invokeToolsConcurrently(context.Background(), []*toolInvocation{invocation})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("waited %s for a stuck tool", elapsed)
+	}
+	if invocation.status != toolCallStatusTimeout || invocation.err == nil || invocation.output != "" {
+		t.Fatalf("output=%q err=%v status=%s", invocation.output, invocation.err, invocation.status)
+	}
+}
+
+func TestToolInvocationCanceledByBargeIn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	toolCtxDone := make(chan struct{})
+	invocation := newTestInvocation(0, time.Minute, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		close(toolCtxDone)
+		return "", ctx.Err()
+	})
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+	}()
+	(&LLMManager{}).invokeToolsConcurrently(ctx, []*toolInvocation{invocation})
+	if invocation.status != toolCallStatusCanceled || invocation.err == nil {
+		t.Fatalf("output=%q err=%v status=%s", invocation.output, invocation.err, invocation.status)
+	}
+	select {
+	case <-toolCtxDone:
+	case <-time.After(time.Second):
+		t.Fatal("tool context not canceled")
+	}
+}
+
+func TestToolInvocationsPartialResults(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	invocations := []*toolInvocation{
+		newTestInvocation(0, time.Second, func(ctx context.Context) (string, error) {
+			return "客厅灯已打开", nil
+		}),
+		newTestInvocation(1, 50*time.Millisecond, func(ctx context.Context) (string, error) {
+			<-block
+			return "", nil
+		}),
+		newTestInvocation(2, time.Second, func(ctx context.Context) (string, error) {
+			return "", errors.New("设备离线")
+		}),
+	}
+	(&LLMManager{}).invokeToolsConcurrently(context.Background(), invocations)
+
+	if invocations[0].err != nil || invocations[0].output != "客厅灯已打开" {
+		t.Errorf("ok tool: output=%q err=%v", invocations[0].output, invocations[0].err)
+	}
+	if invocations[1].status != toolCallStatusTimeout {
+		t.Errorf("stuck tool status = %q, want %q", invocations[1].status, toolCallStatusTimeout)
+	}
+	if invocations[2].status != toolCallStatusError || invocations[2].err == nil {
+		t.Errorf("failed tool: status=%q err=%v", invocations[2].status, invocations[2].err)
+	}
+}
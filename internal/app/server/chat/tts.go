@@ -32,6 +32,7 @@ type AudioQueueElem struct {
 	Err        error  // SentenceEnd 时可选，表示本段错误
 	IsStart    bool   // SentenceStart 时：是否为首包（用于统计）
 	Generation uint64 // 代际标识，打断后旧代际元素将被丢弃
+	NoHistory  bool   // Kind==Frame 时：不写入音频历史（如工具等待提示）
 	OnStart    func()
 	OnEnd      func(error)
 }
@@ -46,6 +47,8 @@ type TTSQueueItem struct {
 	generation  uint64
 	onStartFunc func()
 	onEndFunc   func(err error)
+
+	filler *toolFiller // 非 nil 时为工具等待提示，不写入音频历史
 }
 
 // TTSManager 负责TTS相关的处理
@@ -150,11 +153,13 @@ func (t *TTSManager) runSenderLoop(ctx context.Context) {
 					log.Errorf("发送 TTS 音频失败: len: %d, %v", len(elem.Data), err)
 					continue
 				}
-				t.audioMutex.Lock()
-				frameCopy := make([]byte, len(elem.Data))
-				copy(frameCopy, elem.Data)
-				t.audioHistoryBuffer = append(t.audioHistoryBuffer, frameCopy)
-				t.audioMutex.Unlock()
+				if !elem.NoHistory {
+					t.audioMutex.Lock()
+					frameCopy := make([]byte, len(elem.Data))
+					copy(frameCopy, elem.Data)
+					t.audioHistoryBuffer = append(t.audioHistoryBuffer, frameCopy)
+					t.audioMutex.Unlock()
+				}
 				totalFrames++
 				if needReportFirstFrame && totalFrames == 1 {
 					log.Debugf("从接收音频结束 asr->llm->tts首帧 整体 耗时: %d ms", t.clientState.GetAsrLlmTtsDuration())
@@ -268,6 +273,11 @@ func (t *TTSManager) processTTSQueue(ctx context.Context) {
 			continue
 		}
 
+		if item.filler != nil {
+			t.handleToolFiller(item)
+			continue
+		}
+
 		if item.StreamChan != nil {
 			log.Debugf("processTTSQueue start, stream mode")
 			t.handleStreamTts(item)
@@ -311,10 +321,15 @@ func (t *TTSManager) handleTts(ctx context.Context, generation uint64, llmRespon
 		}
 		return
 	}
+	t.enqueueAudioFrames(ctx, generation, llmResponse.Text, llmResponse.IsStart, outChan, release, onStartFunc, onEndFunc, false)
+}
+
+// enqueueAudioFrames 向 sessionAudioQueue 推送 SentenceStart → Frame… → SentenceEnd，outChan 关闭或 ctx 取消时结束
+func (t *TTSManager) enqueueAudioFrames(ctx context.Context, generation uint64, text string, isStart bool, outChan <-chan []byte, release func(), onStartFunc func(), onEndFunc func(error), noHistory bool) {
 	if !t.enqueueSessionElem(ctx, generation, AudioQueueElem{
 		Kind:    AudioQueueKindSentenceStart,
-		Text:    llmResponse.Text,
-		IsStart: llmResponse.IsStart,
+		Text:    text,
+		IsStart: isStart,
 		OnStart: onStartFunc,
 	}) {
 		if release != nil {
@@ -342,7 +357,7 @@ func (t *TTSManager) handleTts(ctx context.Context, generation uint64, llmRespon
 				}
 				if !t.enqueueSessionElem(ctx, generation, AudioQueueElem{
 					Kind:  AudioQueueKindSentenceEnd,
-					Text:  llmResponse.Text,
+					Text:  text,
 					OnEnd: onEndFunc,
 				}) && onEndFunc != nil {
 					onEndFunc(ctx.Err())
@@ -351,7 +366,7 @@ func (t *TTSManager) handleTts(ctx context.Context, generation uint64, llmRespon
 			}
 			frameCopy := make([]byte, len(frame))
 			copy(frameCopy, frame)
-			if !t.enqueueSessionElem(ctx, generation, AudioQueueElem{Kind: AudioQueueKindFrame, Data: frameCopy}) {
+			if !t.enqueueSessionElem(ctx, generation, AudioQueueElem{Kind: AudioQueueKindFrame, Data: frameCopy, NoHistory: noHistory}) {
 				if release != nil {
 					release()
 				}
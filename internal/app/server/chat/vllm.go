@@ -17,7 +17,8 @@ func HandleVllm(deviceId string, file []byte, text string) (string, error) {
 	provider := viper.GetString("vision.vllm.provider")
 	vllmConfig := viper.GetStringMap(fmt.Sprintf("vision.vllm.%s", provider))
 
-	mimeType := http.DetectContentType(file[:512])
+	// DetectContentType 最多只检查前512字节
+	mimeType := http.DetectContentType(file)
 
 	llmProvider, err := llm.GetLLMProvider(provider, vllmConfig)
 	if err != nil {
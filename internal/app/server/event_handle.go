@@ -200,15 +200,16 @@ func (h *SessionEndHandler) Process(ctx context.Context, data interface{}) error
 
 	log.Debugf("HandleSessionEnd: deviceId: %s", clientState.DeviceID)
 
-	// 将消息加到长期记忆体中
-	err := clientState.MemoryProvider.Flush(
-		clientState.Ctx,
-		clientState.GetDeviceIDOrAgentID())
-	if err != nil {
-		log.Errorf("flush message to memory provider failed: %v", err)
-		return err
+	// 将消息加到长期记忆体中（按说话人分区时逐个分区刷新）
+	var flushErr error
+	for _, memoryScope := range clientState.GetMemoryScopeIDs() {
+		err := clientState.MemoryProvider.Flush(clientState.Ctx, memoryScope)
+		if err != nil {
+			log.Errorf("flush message to memory provider failed, scope: %s, err: %v", memoryScope, err)
+			flushErr = err
+		}
 	}
-	return nil
+	return flushErr
 }
 
 func (h *SessionEndHandler) GetRoutingKey(data interface{}) string {
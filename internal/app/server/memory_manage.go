@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"xiaozhi-esp32-server-golang/internal/domain/memory"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	MemoryActionContext = "context" // 获取记忆分区的上下文摘要
+	MemoryActionList    = "list"    // 列出记忆条目
+	MemoryActionSearch  = "search"  // 在记忆分区内检索条目
+	MemoryActionUpdate  = "update"  // 修改单条记忆
+	MemoryActionDelete  = "delete"  // 删除单条记忆
+	MemoryActionReset   = "reset"   // 清空记忆分区
+)
+
+// memoryManageRequest 管理后台下发的长期记忆请求
+type memoryManageRequest struct {
+	Action         string `json:"action"`
+	AgentID        string `json:"agent_id"`
+	SpeakerGroupID uint   `json:"speaker_group_id"` // 0 表示共享分区（未识别的说话人）
+	Query          string `json:"query"`
+	TopK           int    `json:"top_k"`
+	ItemID         string `json:"item_id"`
+	Content        string `json:"content"`
+	Memory         struct {
+		Provider string                 `json:"provider"`
+		Config   map[string]interface{} `json:"config"`
+	} `json:"memory"`
+}
+
+// HandleMemoryManage 处理管理后台的长期记忆请求，按 agent + 声纹组定位记忆分区
+func (a *App) HandleMemoryManage(ctx context.Context, eventType string, eventData map[string]interface{}) (string, error) {
+	bodyBytes, _ := json.Marshal(eventData)
+	var req memoryManageRequest
+	if err := json.Unmarshal(bodyBytes, &req); err != nil {
+		log.Errorf("HandleMemoryManage error: %+v", err)
+		return "", fmt.Errorf("HandleMemoryManage error")
+	}
+	req.AgentID = strings.TrimSpace(req.AgentID)
+	if req.AgentID == "" {
+		return "", fmt.Errorf("agent_id is required")
+	}
+
+	provider, err := memory.GetProvider(memory.MemoryType(req.Memory.Provider), req.Memory.Config)
+	if err != nil {
+		log.Errorf("HandleMemoryManage: 创建 Memory 提供者失败: %v", err)
+		return "", fmt.Errorf("创建 Memory 提供者失败: %v", err)
+	}
+
+	scopeID := memory.SpeakerScopeID(req.AgentID, req.SpeakerGroupID)
+	result := map[string]interface{}{
+		"scope":  scopeID,
+		"action": req.Action,
+	}
+
+	itemManager, supportsItems := memory.AsItemManager(provider)
+	result["supports_items"] = supportsItems
+
+	switch req.Action {
+	case MemoryActionContext, "":
+		content, err := provider.GetContext(ctx, scopeID, 2000)
+		if err != nil {
+			return "", fmt.Errorf("获取记忆失败: %v", err)
+		}
+		result["action"] = MemoryActionContext
+		result["content"] = content
+	case MemoryActionList, MemoryActionSearch:
+		items := make([]memory.MemoryItem, 0)
+		if supportsItems {
+			if req.Action == MemoryActionSearch && strings.TrimSpace(req.Query) != "" {
+				items, err = itemManager.SearchItems(ctx, scopeID, req.Query, req.TopK)
+			} else {
+				items, err = itemManager.ListItems(ctx, scopeID)
+			}
+			if err != nil {
+				return "", fmt.Errorf("查询记忆失败: %v", err)
+			}
+		}
+		result["items"] = items
+	case MemoryActionUpdate, MemoryActionDelete:
+		if !supportsItems {
+			return "", memory.ErrNotSupported
+		}
+		if strings.TrimSpace(req.ItemID) == "" {
+			return "", fmt.Errorf("item_id is required")
+		}
+		if req.Action == MemoryActionUpdate {
+			if strings.TrimSpace(req.Content) == "" {
+				return "", fmt.Errorf("content is required")
+			}
+			err = itemManager.UpdateItem(ctx, scopeID, req.ItemID, req.Content)
+		} else {
+			err = itemManager.DeleteItem(ctx, scopeID, req.ItemID)
+		}
+		if err != nil {
+			return "", err
+		}
+		log.Infof("HandleMemoryManage: %s memory item, scope: %s, item: %s", req.Action, scopeID, req.ItemID)
+	case MemoryActionReset:
+		if err := provider.ResetMemory(ctx, scopeID); err != nil {
+			return "", fmt.Errorf("清空记忆失败: %v", err)
+		}
+		log.Infof("HandleMemoryManage: reset memory, scope: %s", scopeID)
+	default:
+		return "", fmt.Errorf("unsupported memory action: %s", req.Action)
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(resultBytes), nil
+}
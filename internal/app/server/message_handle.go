@@ -117,6 +117,12 @@ func (w *MessageWorker) workerLoop(index int) {
 // processMessage 处理消息（在worker goroutine中顺序执行）
 // 统一处理Redis、MemoryProvider和History，保证同一设备/会话的消息顺序处理
 func (w *MessageWorker) processMessage(event *eventbus.AddMessageEvent) {
+	// 补写长期记忆的事件，历史消息已在之前保存过
+	if event.MemoryOnly {
+		w.processMemoryProvider(event)
+		return
+	}
+
 	// 1. 处理 History（所有消息）
 	// 使用独立的 context，不受 event.ClientState.Ctx 影响，确保历史消息保存不受对话取消影响
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -148,10 +154,17 @@ func (w *MessageWorker) processMemoryProvider(event *eventbus.AddMessageEvent) {
 	if clientState.GetMemoryMode() != data_client.MemoryModeLong {
 		return
 	}
+	if event.SkipMemory {
+		return
+	}
 
+	memoryScope := event.MemoryScope
+	if memoryScope == "" {
+		memoryScope = clientState.GetDeviceIDOrAgentID()
+	}
 	err := clientState.MemoryProvider.AddMessage(
 		clientState.Ctx,
-		clientState.GetDeviceIDOrAgentID(),
+		memoryScope,
 		event.Msg)
 	if err != nil {
 		log.Errorf("add message to memory provider failed: %v", err)
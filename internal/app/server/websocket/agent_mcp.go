@@ -0,0 +1,31 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+
+	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+// handleAgentMCP 处理外部MCP客户端对智能体MCP服务器的请求（streamable-HTTP）
+// token 通过 Authorization: Bearer 请求头或 token 查询参数传入，由管理后台校验签名、有效期和是否已重置
+func (s *WebSocketServer) handleAgentMCP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.Header.Get("Authorization"))
+	if token == "" {
+		token = strings.TrimSpace(r.URL.Query().Get("token"))
+	}
+	if token == "" {
+		http.Error(w, "missing token", http.StatusUnauthorized)
+		return
+	}
+
+	endpoint, err := verifyEndpointToken(r, user_config.EndpointPurposeAgentMCPServer, token)
+	if err != nil {
+		log.Warnf("智能体MCP服务器 token 校验失败: %v", err)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	s.agentMCPServer.ServeAgent(w, r, endpoint.AgentID)
+}
@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
+	"xiaozhi-esp32-server-golang/internal/domain/config/manager"
+)
+
+// endpointTokenVerifyTimeout 向管理后台校验接入点 token 的超时时间
+const endpointTokenVerifyTimeout = 5 * time.Second
+
+// verifyEndpointToken 校验设备MCP、OpenClaw、智能体MCP服务器的接入点 token，
+// token 由管理后台签发，签名、有效期和是否已重置都由管理后台校验
+func verifyEndpointToken(r *http.Request, purpose string, token string) (*manager.EndpointToken, error) {
+	token = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(token), "Bearer "))
+	if token == "" {
+		return nil, fmt.Errorf("missing token")
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), endpointTokenVerifyTimeout)
+	defer cancel()
+	endpoint, err := user_config.VerifyEndpointToken(ctx, purpose, token)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(endpoint.AgentID) == "" {
+		return nil, fmt.Errorf("missing agent_id")
+	}
+	return endpoint, nil
+}
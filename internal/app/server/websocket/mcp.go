@@ -3,21 +3,11 @@ package websocket
 import (
 	"net/http"
 	"strings"
+	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
 	"xiaozhi-esp32-server-golang/internal/domain/mcp"
 	log "xiaozhi-esp32-server-golang/logger"
-
-	"github.com/golang-jwt/jwt/v4"
 )
 
-// MCPClaims JWT claims结构
-type MCPClaims struct {
-	UserID     uint   `json:"userId"`
-	AgentID    string `json:"agentId"`
-	EndpointID string `json:"endpointId"`
-	Purpose    string `json:"purpose"`
-	jwt.RegisteredClaims
-}
-
 // handleMCPWebSocket 处理MCP WebSocket连接
 func (s *WebSocketServer) handleMCPWebSocket(w http.ResponseWriter, r *http.Request) {
 	var agentId string
@@ -25,16 +15,16 @@ func (s *WebSocketServer) handleMCPWebSocket(w http.ResponseWriter, r *http.Requ
 	// 首先尝试从URL参数中获取token
 	token := r.URL.Query().Get("token")
 	if token != "" {
-		// 从token中解析设备ID
-		claims, err := s.parseMCPToken(token)
+		// 由管理后台校验token并返回智能体ID
+		endpoint, err := verifyEndpointToken(r, user_config.EndpointPurposeMCP, token)
 		if err != nil {
-			log.Warnf("解析token失败: %v", err)
+			log.Warnf("校验token失败: %v", err)
 			http.Error(w, "无效的token", http.StatusUnauthorized)
 			return
 		}
-		log.Infof("解析token成功: %v", claims)
+		log.Infof("校验token成功: agent=%s endpoint=%s", endpoint.AgentID, endpoint.EndpointID)
 
-		agentId = claims.AgentID
+		agentId = endpoint.AgentID
 	} else {
 		log.Errorf("缺少token")
 		return
@@ -73,31 +63,6 @@ func (s *WebSocketServer) handleMCPWebSocket(w http.ResponseWriter, r *http.Requ
 	log.Infof("server %s 的MCP连接已建立", mcpClient.GetServerName()) // todo
 }
 
-// parseMCPToken 解析MCP JWT token
-func (s *WebSocketServer) parseMCPToken(tokenString string) (*MCPClaims, error) {
-	// 移除 "Bearer " 前缀
-	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-		tokenString = tokenString[7:]
-	}
-
-	// 使用与生成token相同的密钥
-	jwtSecret := []byte("xiaozhi_admin_secret_key")
-
-	token, err := jwt.ParseWithClaims(tokenString, &MCPClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(*MCPClaims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, jwt.ErrInvalidKey
-}
-
 // handleMCPAPI 处理MCP REST API请求
 func (s *WebSocketServer) handleMCPAPI(w http.ResponseWriter, r *http.Request) {
 	// 从URL路径中提取deviceId
@@ -5,22 +5,14 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	user_config "xiaozhi-esp32-server-golang/internal/domain/config"
 	"xiaozhi-esp32-server-golang/internal/domain/openclaw"
 	log "xiaozhi-esp32-server-golang/logger"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	gws "github.com/gorilla/websocket"
 )
 
-type OpenClawClaims struct {
-	UserID     uint   `json:"user_id"`
-	AgentID    string `json:"agent_id"`
-	EndpointID string `json:"endpoint_id"`
-	Purpose    string `json:"purpose"`
-	jwt.RegisteredClaims
-}
-
 func openClawSnippet(text string, maxRunes int) string {
 	if maxRunes <= 0 {
 		return ""
@@ -43,22 +35,13 @@ func (s *WebSocketServer) handleOpenClawWebSocket(w http.ResponseWriter, r *http
 		return
 	}
 
-	claims, err := s.parseOpenClawToken(token)
+	endpoint, err := verifyEndpointToken(r, user_config.EndpointPurposeOpenClaw, token)
 	if err != nil {
-		log.Warnf("OpenClaw token parse failed: %v", err)
+		log.Warnf("OpenClaw token verify failed: %v", err)
 		http.Error(w, "invalid token", http.StatusUnauthorized)
 		return
 	}
-
-	agentID := strings.TrimSpace(claims.AgentID)
-	if agentID == "" {
-		http.Error(w, "invalid token: missing agent_id", http.StatusUnauthorized)
-		return
-	}
-	if claims.Purpose != "" && claims.Purpose != "openclaw-endpoint" {
-		http.Error(w, "invalid token purpose", http.StatusUnauthorized)
-		return
-	}
+	agentID := strings.TrimSpace(endpoint.AgentID)
 
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -79,7 +62,7 @@ func (s *WebSocketServer) handleOpenClawWebSocket(w http.ResponseWriter, r *http
 		log.Errorf("Send OpenClaw handshake_ack failed, agent=%s err=%v", agentID, err)
 		return
 	}
-	log.Infof("OpenClaw connected, agent=%s endpoint=%s", agentID, claims.EndpointID)
+	log.Infof("OpenClaw connected, agent=%s endpoint=%s", agentID, endpoint.EndpointID)
 
 	for {
 		msgType, data, err := conn.ReadMessage()
@@ -193,23 +176,3 @@ func replyOpenClawPong(session *openclaw.AgentSession, ping openclaw.WSMessage)
 		Payload:       pongPayload,
 	})
 }
-
-func (s *WebSocketServer) parseOpenClawToken(tokenString string) (*OpenClawClaims, error) {
-	if strings.HasPrefix(tokenString, "Bearer ") {
-		tokenString = strings.TrimSpace(strings.TrimPrefix(tokenString, "Bearer "))
-	}
-
-	jwtSecret := []byte("xiaozhi_admin_secret_key")
-	token, err := jwt.ParseWithClaims(tokenString, &OpenClawClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(*OpenClawClaims)
-	if !ok || !token.Valid {
-		return nil, jwt.ErrInvalidKey
-	}
-	return claims, nil
-}
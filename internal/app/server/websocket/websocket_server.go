@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"xiaozhi-esp32-server-golang/internal/app/server/agent_mcp"
 	"xiaozhi-esp32-server-golang/internal/app/server/auth"
 	"xiaozhi-esp32-server-golang/internal/app/server/types"
 	"xiaozhi-esp32-server-golang/internal/domain/mcp"
@@ -29,6 +30,8 @@ type WebSocketServer struct {
 
 	onNewConnection    types.OnNewConnection
 	onOpenClawResponse func(deviceID string, text string) bool
+	// 智能体MCP服务器
+	agentMCPServer *agent_mcp.Server
 }
 
 // Option 类型定义
@@ -61,6 +64,13 @@ func WithOnOpenClawResponse(handler func(deviceID string, text string) bool) Web
 	}
 }
 
+// WithAgentMCPServer 设置智能体 MCP 服务器
+func WithAgentMCPServer(agentMCPServer *agent_mcp.Server) WebSocketServerOption {
+	return func(s *WebSocketServer) {
+		s.agentMCPServer = agentMCPServer
+	}
+}
+
 // NewWebSocketServer 创建新的 WebSocket 服务器（WithOption 方式）
 func NewWebSocketServer(port int, opts ...WebSocketServerOption) *WebSocketServer {
 	s := &WebSocketServer{
@@ -102,6 +112,9 @@ func (s *WebSocketServer) Start() error {
 	http.HandleFunc("/ws/openclaw", s.handleOpenClawWebSocket)
 	http.HandleFunc("/xiaozhi/api/mcp/tools/", s.handleMCPAPI)
 	http.HandleFunc("/xiaozhi/api/vision", s.handleVisionAPI) //图片识别API
+	if s.agentMCPServer != nil && agent_mcp.Enabled() {
+		http.HandleFunc("/xiaozhi/agent/mcp", s.handleAgentMCP)
+	}
 
 	http.HandleFunc("/admin/inject_msg", s.handleInjectMsg)
 
@@ -110,6 +123,9 @@ func (s *WebSocketServer) Start() error {
 	log.Infof("MCP WebSocket 端点: ws://%s/mcp?token=xxx", listenAddr)
 	log.Infof("OpenClaw WebSocket 端点: ws://%s/ws/openclaw?token=xxx", listenAddr)
 	log.Infof("MCP API 端点: http://%s/xiaozhi/api/mcp/tools/{deviceId}", listenAddr)
+	if s.agentMCPServer != nil && agent_mcp.Enabled() {
+		log.Infof("智能体 MCP 服务器端点: http://%s/xiaozhi/agent/mcp?token=xxx", listenAddr)
+	}
 
 	if err := http.ListenAndServe(listenAddr, nil); err != nil {
 		log.Log().Fatalf("WebSocket 服务器启动失败: %v", err)
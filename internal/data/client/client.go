@@ -3,9 +3,12 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,6 +33,10 @@ import (
 type Dialogue struct {
 	mu       sync.RWMutex // 保护 Messages 的读写锁
 	Messages []*schema.Message
+
+	// 滚动摘要：滑出历史窗口的消息被总结进 Summary
+	Summary         string
+	summarizedIndex int // Messages[:summarizedIndex] 已并入 Summary
 }
 
 const (
@@ -86,6 +93,13 @@ type ClientState struct {
 	MemoryProvider memory.MemoryProvider
 	MemoryContext  string //memory context
 
+	// 按声纹识别的说话人划分的记忆分区
+	memoryScopeMu    sync.RWMutex
+	memoryScopeID    string              // 当前说话人的记忆分区，为空时使用共享分区
+	memoryScopes     map[string]struct{} // 本次连接用过的分区
+	memoryContexts   map[string]string   // 各分区的 memory context 缓存
+	pendingMemoryMsg *schema.Message     // ASR 已保存但尚未确定说话人的用户消息
+
 	// 上下文控制
 	Ctx    context.Context
 	Cancel context.CancelFunc
@@ -140,6 +154,105 @@ func (c *ClientState) HasSpeakerGroups() bool {
 	return len(c.DeviceConfig.VoiceIdentify) > 0
 }
 
+// IsMemoryPartitioned 长期记忆是否按说话人分区
+// 需要启用声纹识别且 agent 配置了声纹组，可通过 voice_identify.memory_partition=false 关闭
+func (c *ClientState) IsMemoryPartitioned() bool {
+	if !c.IsSpeakerEnabled() || !c.HasSpeakerGroups() {
+		return false
+	}
+	if viper.IsSet("voice_identify.memory_partition") {
+		return viper.GetBool("voice_identify.memory_partition")
+	}
+	return true
+}
+
+// SetMemorySpeaker 根据声纹识别结果切换记忆分区，返回切换后的分区 ID
+// 未识别到说话人或未启用分区时回落到共享分区
+func (c *ClientState) SetMemorySpeaker(speakerResult *speaker.IdentifyResult) string {
+	var speakerGroupID uint
+	if c.IsMemoryPartitioned() && speakerResult != nil && speakerResult.Identified {
+		if groupInfo, ok := c.DeviceConfig.VoiceIdentify[speakerResult.SpeakerName]; ok {
+			speakerGroupID = groupInfo.ID
+		}
+	}
+	scopeID := memory.SpeakerScopeID(c.GetDeviceIDOrAgentID(), speakerGroupID)
+
+	c.memoryScopeMu.Lock()
+	defer c.memoryScopeMu.Unlock()
+	c.memoryScopeID = scopeID
+	if c.memoryScopes == nil {
+		c.memoryScopes = make(map[string]struct{})
+	}
+	c.memoryScopes[scopeID] = struct{}{}
+	return scopeID
+}
+
+// GetMemoryScopeID 当前说话人的记忆分区 ID
+func (c *ClientState) GetMemoryScopeID() string {
+	c.memoryScopeMu.RLock()
+	defer c.memoryScopeMu.RUnlock()
+	if c.memoryScopeID == "" {
+		return c.GetDeviceIDOrAgentID()
+	}
+	return c.memoryScopeID
+}
+
+// GetMemoryScopeIDs 本次连接用过的全部记忆分区（包含共享分区），用于会话结束时刷新
+func (c *ClientState) GetMemoryScopeIDs() []string {
+	shared := c.GetDeviceIDOrAgentID()
+	scopeIDs := []string{shared}
+
+	c.memoryScopeMu.RLock()
+	defer c.memoryScopeMu.RUnlock()
+	for scopeID := range c.memoryScopes {
+		if scopeID != shared {
+			scopeIDs = append(scopeIDs, scopeID)
+		}
+	}
+	return scopeIDs
+}
+
+// GetScopedMemoryContext 获取分区对应的 memory context，共享分区直接使用 MemoryContext
+func (c *ClientState) GetScopedMemoryContext(scopeID string) (string, bool) {
+	if scopeID == c.GetDeviceIDOrAgentID() {
+		return c.MemoryContext, true
+	}
+	c.memoryScopeMu.RLock()
+	defer c.memoryScopeMu.RUnlock()
+	memoryContext, ok := c.memoryContexts[scopeID]
+	return memoryContext, ok
+}
+
+// SetScopedMemoryContext 缓存分区对应的 memory context
+func (c *ClientState) SetScopedMemoryContext(scopeID string, memoryContext string) {
+	if scopeID == c.GetDeviceIDOrAgentID() {
+		c.MemoryContext = memoryContext
+		return
+	}
+	c.memoryScopeMu.Lock()
+	defer c.memoryScopeMu.Unlock()
+	if c.memoryContexts == nil {
+		c.memoryContexts = make(map[string]string)
+	}
+	c.memoryContexts[scopeID] = memoryContext
+}
+
+// SetPendingMemoryMessage 暂存尚未确定说话人的用户消息，确定分区后再写入长期记忆
+func (c *ClientState) SetPendingMemoryMessage(msg *schema.Message) {
+	c.memoryScopeMu.Lock()
+	defer c.memoryScopeMu.Unlock()
+	c.pendingMemoryMsg = msg
+}
+
+// TakePendingMemoryMessage 取出并清空暂存的用户消息
+func (c *ClientState) TakePendingMemoryMessage() *schema.Message {
+	c.memoryScopeMu.Lock()
+	defer c.memoryScopeMu.Unlock()
+	msg := c.pendingMemoryMsg
+	c.pendingMemoryMsg = nil
+	return msg
+}
+
 func (c *ClientState) IsRealTime() bool {
 	return c.ListenMode == "realtime"
 }
@@ -270,9 +383,123 @@ func (c *ClientState) InitMessages(messages []*schema.Message) error {
 	c.Dialogue.mu.Lock()
 	defer c.Dialogue.mu.Unlock()
 	c.Dialogue.Messages = AlignToolMessages(messages)
+	c.Dialogue.summarizedIndex = 0
 	return nil
 }
 
+// GetHistoryWindow 按 token 预算从最新消息向前截取历史窗口，窗口从 user 消息开始。
+// 同时返回滑出窗口且尚未并入摘要的消息，以及这些消息的结束位置（用于 SetHistorySummary）。
+// maxTokens > 0 时只按 token 预算截取，count 不生效；maxTokens <= 0 时按条数截取最近 count 条。
+func (c *ClientState) GetHistoryWindow(maxTokens int, count int) (window []*schema.Message, evicted []*schema.Message, evictedEnd int) {
+	c.Dialogue.mu.RLock()
+	defer c.Dialogue.mu.RUnlock()
+
+	messages := c.Dialogue.Messages
+	if len(messages) == 0 {
+		return []*schema.Message{}, nil, 0
+	}
+
+	start := len(messages)
+	if maxTokens <= 0 {
+		start = len(messages) - count
+		if start < 0 {
+			start = 0
+		}
+	} else {
+		used := 0
+		for start > 0 {
+			tokens := llm_common.EstimateMessageTokens(messages[start-1])
+			// 至少保留最近一条消息
+			if used+tokens > maxTokens && start < len(messages) {
+				break
+			}
+			used += tokens
+			start--
+		}
+	}
+	// 窗口从 user 消息开始，避免以孤立的 assistant/tool 消息开头
+	for start > 0 && start < len(messages) && (messages[start] == nil || messages[start].Role != schema.User) {
+		start++
+	}
+	if start >= len(messages) {
+		start = len(messages) - 1
+	}
+
+	if c.Dialogue.summarizedIndex < start {
+		evicted = append(evicted, messages[c.Dialogue.summarizedIndex:start]...)
+	}
+	return AlignToolMessages(messages[start:]), evicted, start
+}
+
+// GetHistorySummary 获取滚动摘要
+func (c *ClientState) GetHistorySummary() string {
+	c.Dialogue.mu.RLock()
+	defer c.Dialogue.mu.RUnlock()
+	return c.Dialogue.Summary
+}
+
+// SetHistorySummary 更新滚动摘要，summarizedEnd 为已并入摘要的消息结束位置
+func (c *ClientState) SetHistorySummary(summary string, summarizedEnd int) {
+	c.Dialogue.mu.Lock()
+	defer c.Dialogue.mu.Unlock()
+	c.Dialogue.Summary = summary
+	if summarizedEnd > len(c.Dialogue.Messages) {
+		summarizedEnd = len(c.Dialogue.Messages)
+	}
+	if summarizedEnd > c.Dialogue.summarizedIndex {
+		c.Dialogue.summarizedIndex = summarizedEnd
+	}
+}
+
+// RestoreHistorySummary 恢复持久化的滚动摘要，需在 InitMessages 之后调用。
+// boundary 为 SummaryBoundary 计算的已并入摘要的最后几条消息指纹：在历史中找到时其之前的消息视为已总结，
+// 找不到说明这些消息都早于加载的历史，全部历史都尚未总结
+func (c *ClientState) RestoreHistorySummary(summary string, boundary string) {
+	c.Dialogue.mu.Lock()
+	defer c.Dialogue.mu.Unlock()
+	c.Dialogue.Summary = summary
+	c.Dialogue.summarizedIndex = 0
+
+	n, _, ok := strings.Cut(boundary, ":")
+	size, err := strconv.Atoi(n)
+	if !ok || err != nil || size <= 0 {
+		return
+	}
+	// 从最新的位置向前找，内容重复时取最靠后的一处
+	for end := len(c.Dialogue.Messages); end >= size; end-- {
+		if SummaryBoundary(c.Dialogue.Messages[end-size:end]) == boundary {
+			c.Dialogue.summarizedIndex = end
+			return
+		}
+	}
+}
+
+// summaryBoundarySize 摘要边界指纹包含的消息条数，多条消息一起计算以降低内容重复导致的误匹配
+const summaryBoundarySize = 3
+
+// SummaryBoundary 计算已并入摘要的消息（取最后几条）的指纹，与摘要一起持久化，
+// 重新加载历史后由 RestoreHistorySummary 据此恢复摘要覆盖到的位置
+func SummaryBoundary(summarized []*schema.Message) string {
+	if len(summarized) > summaryBoundarySize {
+		summarized = summarized[len(summarized)-summaryBoundarySize:]
+	}
+	if len(summarized) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, msg := range summarized {
+		if msg != nil {
+			h.Write([]byte(msg.Role))
+			h.Write([]byte{0})
+			h.Write([]byte(msg.Content))
+			h.Write([]byte{0})
+			h.Write([]byte(msg.ToolCallID))
+		}
+		h.Write([]byte{0})
+	}
+	return strconv.Itoa(len(summarized)) + ":" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
 //历史消息相关的方法结束
 
 func (c *ClientState) SetTtsStart(isStart bool) {
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func testDialogue(n int) []*schema.Message {
+	messages := make([]*schema.Message, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			messages = append(messages, schema.UserMessage(string(rune('a'+i))))
+		} else {
+			messages = append(messages, schema.AssistantMessage(string(rune('a'+i)), nil))
+		}
+	}
+	return messages
+}
+
+// TestRestoreHistorySummary 重新加载最近的历史后，已并入摘要的消息不再作为待总结消息返回
+func TestRestoreHistorySummary(t *testing.T) {
+	all := testDialogue(12)
+	boundary := SummaryBoundary(all[2:6])
+
+	c := &ClientState{Dialogue: &Dialogue{}}
+	// 模拟只加载了最近 9 条：已总结的 all[:6] 中只剩 all[3:6]
+	c.InitMessages(all[3:])
+	c.RestoreHistorySummary("摘要", boundary)
+	if c.Dialogue.summarizedIndex != 3 {
+		t.Fatalf("summarizedIndex = %d, want 3", c.Dialogue.summarizedIndex)
+	}
+	_, evicted, end := c.GetHistoryWindow(0, 4)
+	if len(evicted) != 2 || evicted[0] != all[6] || end != 5 {
+		t.Fatalf("evicted = %v (end %d), want all[6:8]", evicted, end)
+	}
+	if c.GetHistorySummary() != "摘要" {
+		t.Fatalf("summary = %q", c.GetHistorySummary())
+	}
+
+	// 边界消息已不在加载的历史中：全部历史都尚未总结
+	c.InitMessages(all[7:])
+	c.RestoreHistorySummary("摘要", boundary)
+	if c.Dialogue.summarizedIndex != 0 {
+		t.Fatalf("summarizedIndex = %d, want 0", c.Dialogue.summarizedIndex)
+	}
+	// 旧版本没有边界的摘要
+	c.RestoreHistorySummary("摘要", "")
+	if c.Dialogue.summarizedIndex != 0 {
+		t.Fatalf("summarizedIndex = %d, want 0", c.Dialogue.summarizedIndex)
+	}
+}
@@ -0,0 +1,25 @@
+package user_config
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-esp32-server-golang/internal/domain/config/manager"
+
+	"github.com/spf13/viper"
+)
+
+// 接入点令牌的用途，与管理后台签发时一致
+const (
+	EndpointPurposeMCP            = "mcp-endpoint"
+	EndpointPurposeOpenClaw       = "openclaw-endpoint"
+	EndpointPurposeAgentMCPServer = "agent-mcp-server"
+)
+
+// VerifyEndpointToken 校验管理后台签发的接入点令牌，只有 manager 配置提供者下可用
+func VerifyEndpointToken(ctx context.Context, purpose string, token string) (*manager.EndpointToken, error) {
+	if providerType := viper.GetString("config_provider.type"); providerType != "manager" {
+		return nil, fmt.Errorf("接入点令牌由管理后台签发，当前配置提供者 %q 不支持", providerType)
+	}
+	return manager.VerifyEndpointToken(ctx, purpose, token)
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// endpointTokenCacheTTL 校验通过的接入点令牌在本地缓存的时间，管理后台重置令牌后最多这么久生效
+const endpointTokenCacheTTL = 30 * time.Second
+
+// EndpointToken 管理后台校验通过的接入点令牌信息
+type EndpointToken struct {
+	UserID     uint
+	AgentID    string
+	EndpointID string
+}
+
+type cachedEndpointToken struct {
+	token    *EndpointToken
+	expireAt time.Time
+}
+
+var (
+	endpointTokenCacheMu sync.Mutex
+	endpointTokenCache   = make(map[string]cachedEndpointToken)
+)
+
+// VerifyEndpointToken 通过 WebSocket 向管理后台校验接入点令牌，签名密钥和令牌版本只保存在管理后台
+func VerifyEndpointToken(ctx context.Context, purpose string, token string) (*EndpointToken, error) {
+	cacheKey := purpose + "|" + token
+	now := time.Now()
+	endpointTokenCacheMu.Lock()
+	if cached, ok := endpointTokenCache[cacheKey]; ok && now.Before(cached.expireAt) {
+		endpointTokenCacheMu.Unlock()
+		return cached.token, nil
+	}
+	endpointTokenCacheMu.Unlock()
+
+	resp, err := SendManagerRequest(ctx, "POST", "/api/endpoint_token/verify", map[string]interface{}{
+		"purpose": purpose,
+		"token":   token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("校验接入点令牌失败: %v", err)
+	}
+	if resp.Status != 200 {
+		return nil, fmt.Errorf("接入点令牌无效: status=%d, error=%s", resp.Status, resp.Error)
+	}
+
+	result := &EndpointToken{}
+	if userID, ok := resp.Body["user_id"].(float64); ok {
+		result.UserID = uint(userID)
+	}
+	result.AgentID, _ = resp.Body["agent_id"].(string)
+	result.EndpointID, _ = resp.Body["endpoint_id"].(string)
+	if result.AgentID == "" {
+		return nil, fmt.Errorf("管理后台未返回 agent_id")
+	}
+
+	expireAt := now.Add(endpointTokenCacheTTL)
+	if expiresAt, ok := resp.Body["expires_at"].(float64); ok && expiresAt > 0 && time.Unix(int64(expiresAt), 0).Before(expireAt) {
+		expireAt = time.Unix(int64(expiresAt), 0)
+	}
+	endpointTokenCacheMu.Lock()
+	for key, cached := range endpointTokenCache {
+		if !now.Before(cached.expireAt) {
+			delete(endpointTokenCache, key)
+		}
+	}
+	endpointTokenCache[cacheKey] = cachedEndpointToken{token: result, expireAt: expireAt}
+	endpointTokenCacheMu.Unlock()
+	return result, nil
+}
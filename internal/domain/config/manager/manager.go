@@ -104,6 +104,7 @@ func (c *ConfigManager) GetUserConfig(ctx context.Context, deviceID string) (typ
 				EnterKeywords []string `json:"enter_keywords"`
 				ExitKeywords  []string `json:"exit_keywords"`
 			} `json:"openclaw"`
+			MCPToolPolicy types.MCPToolPolicy `json:"mcp_tool_policy"`
 		} `json:"data"`
 	}
 
@@ -195,6 +196,7 @@ func (c *ConfigManager) GetUserConfig(ctx context.Context, deviceID string) (typ
 			EnterKeywords: enterKeywords,
 			ExitKeywords:  exitKeywords,
 		},
+		MCPToolPolicy: response.Data.MCPToolPolicy,
 	}
 	if strings.TrimSpace(config.MemoryMode) == "" {
 		config.MemoryMode = "short"
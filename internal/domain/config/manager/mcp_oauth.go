@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/domain/mcp"
+)
+
+// fetchMCPOAuthToken 通过 WebSocket 向管理后台获取 MCP 服务的访问令牌，刷新令牌只保存在管理后台
+func fetchMCPOAuthToken(ctx context.Context, authRef string) (*mcp.OAuthToken, error) {
+	resp, err := SendManagerRequest(ctx, "GET", "/api/mcp/oauth/token", map[string]interface{}{
+		"auth_ref": authRef,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("请求 OAuth 令牌失败: %v", err)
+	}
+	if resp.Status != 200 {
+		return nil, fmt.Errorf("请求 OAuth 令牌失败: status=%d, error=%s", resp.Status, resp.Error)
+	}
+
+	accessToken, _ := resp.Body["access_token"].(string)
+	if accessToken == "" {
+		return nil, fmt.Errorf("管理后台未返回 access_token")
+	}
+	token := &mcp.OAuthToken{AccessToken: accessToken}
+	token.TokenType, _ = resp.Body["token_type"].(string)
+	if expiresAt, ok := resp.Body["expires_at"].(float64); ok && expiresAt > 0 {
+		token.ExpiresAt = time.Unix(int64(expiresAt), 0)
+	}
+	return token, nil
+}
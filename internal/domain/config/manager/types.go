@@ -7,12 +7,14 @@ import (
 const (
 	EventDeviceOnlinePath  = "/api/device/active"
 	EventDeviceOfflinePath = "/api/device/inactive"
+	EventToolCallAuditPath = "/api/mcp/tool_call_log"
 	EventInjectMessagePath = "/api/device/message"
 )
 
 var event2Path = map[string]string{
 	types.EventDeviceOnline:  EventDeviceOnlinePath,
 	types.EventDeviceOffline: EventDeviceOfflinePath,
+	types.EventToolCallAudit: EventToolCallAuditPath,
 }
 
 var path2Event = map[string]string{
@@ -727,6 +727,14 @@ func (c *WebSocketClient) handleDefaultRequest(request *WebSocketRequest) {
 		// 处理MCP工具调用请求
 		c.handleMcpToolCallRequest(request)
 
+	case "/api/mcp/prompts":
+		// 处理MCP提示词列表请求
+		c.handleMcpPromptListRequest(request)
+
+	case "/api/mcp/prompt":
+		// 处理MCP提示词获取请求
+		c.handleMcpPromptGetRequest(request)
+
 	case "/api/openclaw/status":
 		c.handleOpenClawStatusRequest(request)
 
@@ -1103,6 +1111,75 @@ func (c *WebSocketClient) handleMcpToolListRequest(request *WebSocketRequest) {
 	}
 }
 
+// handleMcpPromptListRequest 处理MCP提示词列表请求，返回全局服务器（按智能体选择的服务过滤）与智能体接入点上报的提示词
+func (c *WebSocketClient) handleMcpPromptListRequest(request *WebSocketRequest) {
+	agentID, _ := request.Body["agent_id"].(string)
+	deviceID, _ := request.Body["device_id"].(string)
+	serviceNames, _ := request.Body["mcp_service_names"].(string)
+
+	prompts := mcp.GetPrompts(deviceID, agentID, serviceNames)
+	promptList := make([]map[string]interface{}, 0, len(prompts))
+	for _, info := range prompts {
+		promptList = append(promptList, map[string]interface{}{
+			"server_name": info.ServerName,
+			"source":      info.Source,
+			"name":        info.Prompt.Name,
+			"description": info.Prompt.Description,
+			"arguments":   info.Prompt.Arguments,
+		})
+	}
+
+	response := map[string]interface{}{
+		"agent_id":  agentID,
+		"device_id": deviceID,
+		"prompts":   promptList,
+		"count":     len(promptList),
+	}
+	if err := c.SendResponse(request.ID, 200, response, ""); err != nil {
+		log.Errorf("发送MCP提示词列表响应失败: %v", err)
+	}
+}
+
+// handleMcpPromptGetRequest 处理MCP提示词获取请求，返回按参数渲染后的提示词文本
+func (c *WebSocketClient) handleMcpPromptGetRequest(request *WebSocketRequest) {
+	agentID, _ := request.Body["agent_id"].(string)
+	deviceID, _ := request.Body["device_id"].(string)
+	serverName, _ := request.Body["server_name"].(string)
+	name, _ := request.Body["name"].(string)
+	if serverName == "" || name == "" {
+		if err := c.SendResponse(request.ID, 400, nil, "缺少server_name或name参数"); err != nil {
+			log.Errorf("发送错误响应失败: %v", err)
+		}
+		return
+	}
+
+	arguments := make(map[string]string)
+	if rawArgs, ok := request.Body["arguments"].(map[string]interface{}); ok {
+		for key, value := range rawArgs {
+			arguments[key] = fmt.Sprint(value)
+		}
+	}
+
+	result, err := mcp.GetPrompt(context.Background(), deviceID, agentID, serverName, name, arguments)
+	if err != nil {
+		log.Errorf("获取MCP提示词失败: server=%s name=%s err=%v", serverName, name, err)
+		if err := c.SendResponse(request.ID, 500, nil, fmt.Sprintf("获取提示词失败: %v", err)); err != nil {
+			log.Errorf("发送错误响应失败: %v", err)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"server_name": serverName,
+		"name":        name,
+		"description": result.Description,
+		"text":        mcp.PromptResultText(result),
+	}
+	if err := c.SendResponse(request.ID, 200, response, ""); err != nil {
+		log.Errorf("发送MCP提示词响应失败: %v", err)
+	}
+}
+
 // 全局便捷方法（异步版本）
 func SendManagerRequestAsync(ctx context.Context, method, path string, body map[string]interface{}) (string, error) {
 	return GetDefaultClient().SendRequestAsync(ctx, method, path, body)
@@ -1162,6 +1239,9 @@ func Init(ctx context.Context) error {
 	// 创建WebSocket客户端
 	client := GetDefaultClient()
 
+	// 需要 OAuth 授权的全局MCP服务从管理后台获取访问令牌
+	mcp.SetOAuthTokenSource(fetchMCPOAuthToken)
+
 	// 尝试连接到WebSocket服务器
 	if err := client.Connect(ctx); err != nil {
 		log.Warnf("初始连接Manager WebSocket失败: %v，将启动重连机制", err)
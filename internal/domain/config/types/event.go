@@ -6,11 +6,14 @@ type EventHandler func(ctx context.Context, eventType string, eventData map[stri
 
 // 上行push事件 主程序 => 管理内控
 const (
-	EventDeviceOnline  = "/api/device/active"   //设备上线
-	EventDeviceOffline = "/api/device/inactive" //设备下线
+	EventDeviceOnline  = "/api/device/active"     //设备上线
+	EventDeviceOffline = "/api/device/inactive"   //设备下线
+	EventToolCallAudit = "/api/mcp/tool_call_log" //工具调用审计日志
 )
 
 // 下行pull事件 管理内控 => 主程序
 const (
-	EventHandleMessageInject = "/api/device/inject_msg" //处理消息注入
+	EventHandleMessageInject = "/api/device/inject_msg"    //处理消息注入
+	EventHandleMemoryManage  = "/api/memory/manage"        //长期记忆查询与管理
+	EventHandleConfigReload  = "/api/device/config_reload" //重新加载设备配置（智能体、角色回滚后）
 )
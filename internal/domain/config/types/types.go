@@ -59,6 +59,42 @@ type OpenClawConfig struct {
 	ExitKeywords  []string `json:"exit_keywords"`
 }
 
+// MCPToolPolicy 智能体的工具调用策略，作用于本地、全局和设备工具
+type MCPToolPolicy struct {
+	Allow []string      `json:"allow"` // 允许的工具名 glob，可加 local:/global:/device:/agent_mcp: 前缀限定来源；为空表示全部允许
+	Deny  []string      `json:"deny"`  // 拒绝的工具名 glob，优先于 allow
+	Rules []MCPToolRule `json:"rules"` // 按工具的参数约束和限流
+	// Confirm 需要用户口头确认后才执行的工具名 glob，如开门、下单等敏感操作
+	Confirm []string `json:"confirm"`
+}
+
+// MCPToolRule 匹配 Tool 的工具调用时生效的参数约束和限流
+type MCPToolRule struct {
+	Tool      string                     `json:"tool"`
+	Args      map[string]MCPToolArgLimit `json:"args,omitempty"`
+	RateLimit *MCPToolRateLimit          `json:"rate_limit,omitempty"`
+}
+
+// MCPToolArgLimit 单个参数的约束
+type MCPToolArgLimit struct {
+	Min     *float64      `json:"min,omitempty"`
+	Max     *float64      `json:"max,omitempty"`
+	Enum    []interface{} `json:"enum,omitempty"`
+	Pattern string        `json:"pattern,omitempty"` // 字符串参数需匹配的正则
+	Clamp   bool          `json:"clamp,omitempty"`   // 数值超出范围时截断到 min/max，而不是拒绝调用
+}
+
+// MCPToolRateLimit 在 WindowSeconds 秒内最多调用 Max 次
+type MCPToolRateLimit struct {
+	Max           int `json:"max"`
+	WindowSeconds int `json:"window_seconds"`
+}
+
+// IsEmpty 是否未配置任何策略
+func (p MCPToolPolicy) IsEmpty() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0 && len(p.Rules) == 0 && len(p.Confirm) == 0
+}
+
 type UConfig struct {
 	SystemPrompt    string                      `json:"system_prompt"`
 	Asr             AsrConfig                   `json:"asr"`
@@ -71,6 +107,7 @@ type UConfig struct {
 	AgentId         string                      `json:"agent_id"`          // 所属agent_id
 	MCPServiceNames string                      `json:"mcp_service_names"` // 逗号分隔的MCP服务名，空=使用全部已启用全局MCP服务
 	OpenClaw        OpenClawConfig              `json:"openclaw"`          // OpenClaw 配置
+	MCPToolPolicy   MCPToolPolicy               `json:"mcp_tool_policy"`   // 工具调用策略
 	KnowledgeBases  []KnowledgeBaseRef          `json:"knowledge_bases"`
 }
 
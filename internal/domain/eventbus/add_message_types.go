@@ -39,4 +39,9 @@ type AddMessageEvent struct {
 
 	// 阶段标识
 	IsUpdate bool // true=更新音频，false=新增消息
+
+	// 长期记忆相关
+	MemoryScope string // 记忆分区ID（按说话人分区），为空时使用 agentID/deviceID
+	SkipMemory  bool   // true=不写入长期记忆（说话人尚未确定，稍后通过 MemoryOnly 事件补写）
+	MemoryOnly  bool   // true=仅写入长期记忆，不保存历史消息
 }
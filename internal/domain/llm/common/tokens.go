@@ -0,0 +1,224 @@
+package common
+
+import (
+	"encoding/json"
+	"sync"
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// messageTokenOverhead 每条消息的角色、分隔符等固定开销
+const messageTokenOverhead = 4
+
+// defaultEncoding 模型词表未知时使用的编码。国产模型的中文词表通常比 cl100k_base 更紧凑，
+// 按 cl100k_base 计数会略微高估，预算控制偏保守
+const defaultEncoding = tokenizer.Cl100kBase
+
+// Tokenizer 文本 token 计数，用于历史窗口和 prompt 预算控制
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// bpeTokenizer 使用 tiktoken BPE 词表计数，编码失败时退回估算
+type bpeTokenizer struct {
+	codec tokenizer.Codec
+	ratio float64
+}
+
+func (t bpeTokenizer) CountTokens(text string) int {
+	tokens, err := t.codec.Count(text)
+	if err != nil {
+		tokens = estimateTokenizer{ratio: 1}.CountTokens(text)
+	}
+	return applyRatio(tokens, t.ratio)
+}
+
+// estimateTokenizer 不依赖具体模型词表的估算实现：CJK 字符按 1 个 token，其余字符约 4 个计 1 个 token，
+// 再乘以校准系数 ratio。词表加载失败或配置 tokenizer: estimate 时使用
+type estimateTokenizer struct {
+	ratio float64
+}
+
+var (
+	codecsMu sync.Mutex
+	codecs   = make(map[tokenizer.Encoding]tokenizer.Codec)
+	// modelEncodings 缓存模型名到编码的映射
+	modelEncodings = make(map[string]tokenizer.Encoding)
+
+	defaultTokenizerOnce sync.Once
+	defaultTokenizer     Tokenizer
+)
+
+// getCodec 加载编码词表，同一编码只加载一次
+func getCodec(encoding tokenizer.Encoding) (tokenizer.Codec, error) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if codec, ok := codecs[encoding]; ok {
+		return codec, nil
+	}
+	codec, err := tokenizer.Get(encoding)
+	if err != nil {
+		return nil, err
+	}
+	codecs[encoding] = codec
+	return codec, nil
+}
+
+func getDefaultTokenizer() Tokenizer {
+	defaultTokenizerOnce.Do(func() {
+		defaultTokenizer = newTokenizer(defaultEncoding, 1)
+	})
+	return defaultTokenizer
+}
+
+func newTokenizer(encoding tokenizer.Encoding, ratio float64) Tokenizer {
+	codec, err := getCodec(encoding)
+	if err != nil {
+		return estimateTokenizer{ratio: ratio}
+	}
+	return bpeTokenizer{codec: codec, ratio: ratio}
+}
+
+// NewTokenizer 根据 LLM 配置创建 Tokenizer：
+// tokenizer 指定编码（cl100k_base、o200k_base 等，estimate 表示按字符估算）；
+// 未指定时按 model_name 选择 OpenAI 模型对应的编码，其他模型使用 cl100k_base。
+// token_ratio 用于按模型实际词表校准计数
+func NewTokenizer(llmConfig map[string]interface{}) Tokenizer {
+	ratio := 1.0
+	switch v := llmConfig["token_ratio"].(type) {
+	case float64:
+		ratio = v
+	case int:
+		ratio = float64(v)
+	}
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	if name, _ := llmConfig["tokenizer"].(string); name != "" {
+		if name == "estimate" {
+			return estimateTokenizer{ratio: ratio}
+		}
+		return newTokenizer(tokenizer.Encoding(name), ratio)
+	}
+	model, _ := llmConfig["model_name"].(string)
+	return newTokenizer(modelEncoding(model), ratio)
+}
+
+// modelEncoding 返回模型对应的编码，非 OpenAI 模型返回 defaultEncoding
+func modelEncoding(model string) tokenizer.Encoding {
+	if model == "" {
+		return defaultEncoding
+	}
+	codecsMu.Lock()
+	encoding, ok := modelEncodings[model]
+	codecsMu.Unlock()
+	if ok {
+		return encoding
+	}
+	encoding = defaultEncoding
+	if codec, err := tokenizer.ForModel(tokenizer.Model(model)); err == nil {
+		encoding = tokenizer.Encoding(codec.GetName())
+	}
+	codecsMu.Lock()
+	modelEncodings[model] = encoding
+	codecsMu.Unlock()
+	return encoding
+}
+
+func (t estimateTokenizer) CountTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return applyRatio(cjk+(other+3)/4, t.ratio)
+}
+
+func applyRatio(tokens int, ratio float64) int {
+	if ratio != 1 {
+		return int(float64(tokens)*ratio + 0.5)
+	}
+	return tokens
+}
+
+// EstimateTokens 按默认编码计算文本 token 数，用于不区分模型的场景
+func EstimateTokens(text string) int {
+	return getDefaultTokenizer().CountTokens(text)
+}
+
+// CountMessageTokens 计算单条消息的 token 数，包含工具调用参数
+func CountMessageTokens(t Tokenizer, msg *schema.Message) int {
+	if msg == nil {
+		return 0
+	}
+	tokens := messageTokenOverhead + t.CountTokens(msg.Content)
+	for _, part := range msg.MultiContent {
+		tokens += t.CountTokens(part.Text)
+	}
+	for _, toolCall := range msg.ToolCalls {
+		tokens += t.CountTokens(toolCall.Function.Name) + t.CountTokens(toolCall.Function.Arguments)
+	}
+	return tokens
+}
+
+// EstimateMessageTokens 按默认编码计算单条消息的 token 数
+func EstimateMessageTokens(msg *schema.Message) int {
+	return CountMessageTokens(getDefaultTokenizer(), msg)
+}
+
+// CountMessagesTokens 计算消息列表的 token 总数
+func CountMessagesTokens(t Tokenizer, messages []*schema.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += CountMessageTokens(t, msg)
+	}
+	return total
+}
+
+// CountToolsTokens 计算工具定义（名称、描述、参数 schema）占用的 token 数
+func CountToolsTokens(t Tokenizer, tools []*schema.ToolInfo) int {
+	total := 0
+	for _, tool := range tools {
+		if tool == nil {
+			continue
+		}
+		total += messageTokenOverhead + t.CountTokens(tool.Name) + t.CountTokens(tool.Desc)
+		if tool.ParamsOneOf == nil {
+			continue
+		}
+		if params, err := tool.ParamsOneOf.ToOpenAPIV3(); err == nil && params != nil {
+			if paramsJSON, err := json.Marshal(params); err == nil {
+				total += t.CountTokens(string(paramsJSON))
+			}
+		}
+	}
+	return total
+}
+
+// TruncateToTokens 截断文本使其不超过 maxTokens，返回截断后的文本和是否发生截断
+func TruncateToTokens(t Tokenizer, text string, maxTokens int) (string, bool) {
+	if t.CountTokens(text) <= maxTokens {
+		return text, false
+	}
+	if maxTokens <= 0 {
+		return "", true
+	}
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if t.CountTokens(string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]), true
+}
@@ -0,0 +1,53 @@
+package common
+
+import "testing"
+
+// TestTokenizerKnownCounts 与 OpenAI tiktoken 的计数结果对照
+func TestTokenizerKnownCounts(t *testing.T) {
+	cases := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4", "hello world", 2},
+		{"gpt-4", "tiktoken is great!", 6},
+		{"gpt-4", "antidisestablishmentarianism", 6},
+		{"gpt-4", "你好，世界", 6},
+		{"gpt-4o", "hello world", 2},
+		{"gpt-4o", "tiktoken is great!", 6},
+		{"gpt-4o", "你好，世界", 3},
+		{"gpt-4o-mini", "今天天气怎么样？", 5},
+		// 非 OpenAI 模型使用 cl100k_base
+		{"Qwen/Qwen2.5-72B-Instruct", "今天天气怎么样？", 10},
+	}
+	for _, c := range cases {
+		tk := NewTokenizer(map[string]interface{}{"model_name": c.model})
+		if got := tk.CountTokens(c.text); got != c.want {
+			t.Errorf("%s: CountTokens(%q) = %d, want %d", c.model, c.text, got, c.want)
+		}
+	}
+}
+
+func TestTokenizerConfig(t *testing.T) {
+	tk := NewTokenizer(map[string]interface{}{"model_name": "glm-4-flash", "tokenizer": "o200k_base"})
+	if got := tk.CountTokens("你好，世界"); got != 3 {
+		t.Errorf("o200k_base: got %d, want 3", got)
+	}
+	tk = NewTokenizer(map[string]interface{}{"tokenizer": "o200k_base", "token_ratio": 2.0})
+	if got := tk.CountTokens("你好，世界"); got != 6 {
+		t.Errorf("token_ratio 2: got %d, want 6", got)
+	}
+	// 估算：4 个汉字 + 1 个标点
+	tk = NewTokenizer(map[string]interface{}{"tokenizer": "estimate"})
+	if got := tk.CountTokens("你好，世界"); got != 5 {
+		t.Errorf("estimate: got %d, want 5", got)
+	}
+	// 未知编码退回估算
+	tk = NewTokenizer(map[string]interface{}{"tokenizer": "unknown"})
+	if _, ok := tk.(estimateTokenizer); !ok {
+		t.Errorf("unknown encoding: got %T, want estimateTokenizer", tk)
+	}
+	if got := EstimateTokens("hello world"); got != 2 {
+		t.Errorf("EstimateTokens = %d, want 2", got)
+	}
+}
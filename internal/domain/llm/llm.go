@@ -2,6 +2,8 @@ package llm
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	log "xiaozhi-esp32-server-golang/logger"
 
@@ -31,3 +33,24 @@ func ConvertMCPToolsToEinoTools(ctx context.Context, mcpTools map[string]interfa
 	log.Infof("成功转换了 %d 个MCP工具为Eino工具", len(einoTools))
 	return einoTools, nil
 }
+
+// ResponseText 调用 LLM 并将流式返回拼接为完整文本，适用于摘要、抽取等非对话场景
+func ResponseText(ctx context.Context, provider LLMProvider, sessionID string, dialogue []*schema.Message) (string, error) {
+	if provider == nil {
+		return "", fmt.Errorf("llm provider is nil")
+	}
+	var builder strings.Builder
+	for msg := range provider.ResponseWithContext(ctx, sessionID, dialogue, nil) {
+		if msg == nil {
+			continue
+		}
+		if IsLLMErrorMessage(msg) {
+			return "", fmt.Errorf("llm response error: %s", LLMErrorMessage(msg))
+		}
+		builder.WriteString(msg.Content)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(builder.String()), nil
+}
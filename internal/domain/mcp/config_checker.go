@@ -61,7 +61,7 @@ func CheckMCPConfig() {
 			status = "❌"
 			issues = append(issues, err.Error())
 			problemCount++
-		} else {
+		} else if transportType != "stdio" {
 			if _, parseErr := url.ParseRequestURI(endpoint); parseErr != nil {
 				status = "❌"
 				issues = append(issues, "URL格式不正确")
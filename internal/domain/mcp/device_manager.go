@@ -12,6 +12,7 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/gorilla/websocket"
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
@@ -26,12 +27,14 @@ type DeviceMcpSession struct {
 }
 
 func (dcs *DeviceMcpSession) AddWsEndPointMcp(mcpClient *McpClientInstance) {
+	mcpClient.ownerID = dcs.deviceID
 	dcs.wsEndPointMcp.Store(mcpClient.serverName, mcpClient)
 
 	// 设置关闭回调
 	mcpClient.SetOnCloseHandler(dcs.handleMcpClientClose)
 
 	mcpClient.refreshTools()
+	mcpClient.refreshResourcesAndPrompts()
 }
 
 // todo
@@ -42,12 +45,14 @@ func (dcs *DeviceMcpSession) SetIotOverMcp(mcpClient *McpClientInstance) {
 	/*if dcs.iotOverMcp != nil {
 		dcs.iotOverMcp.Close()
 	}*/
+	mcpClient.ownerID = dcs.deviceID
 	dcs.iotOverMcp = mcpClient
 
 	// 设置关闭回调
 	mcpClient.SetOnCloseHandler(dcs.handleMcpClientClose)
 
 	mcpClient.refreshTools()
+	mcpClient.refreshResourcesAndPrompts()
 }
 
 func (dcs *DeviceMcpSession) RemoveWsEndPointMcp(mcpClient *McpClientInstance) {
@@ -76,9 +81,12 @@ func (dcs *DeviceMcpSession) handleMcpClientClose(instance *McpClientInstance, r
 // McpClientInstance 代表一个具体的MCP客户端连接
 type McpClientInstance struct {
 	serverName string
+	ownerID    string         // 所属设备会话的ID（设备ID或智能体ID）
 	mcpClient  *client.Client // 是从ws endpoint连上来的mcp server
 	tools      map[string]tool.InvokableTool
-	toolsMux   sync.RWMutex // 保护工具列表的互斥锁
+	resources  []mcp.Resource
+	prompts    []mcp.Prompt
+	toolsMux   sync.RWMutex // 保护工具、资源、提示词列表的互斥锁
 	serverInfo *mcp.InitializeResult
 	lastPing   time.Time
 	Ctx        context.Context
@@ -133,6 +141,7 @@ func NewWsEndPointMcpClient(ctx context.Context, deviceID string, conn *websocke
 
 	wsEndPointMcp.sendInitlize(ctx)
 	wsEndPointMcp.mcpClient.Start(ctx)
+	installServerRequestHandler(wsEndPointMcp.serverName, wsTransport)
 	return wsEndPointMcp
 }
 
@@ -176,13 +185,34 @@ func (dc *McpClientInstance) refreshTools() error {
 
 	// 使用互斥锁保护工具列表的更新
 	dc.toolsMux.Lock()
-	dc.tools = ConvertMcpToolListToInvokableToolList(tools.Tools, dc.serverName, dc.mcpClient)
+	dc.tools = ConvertMcpToolListToInvokableToolList(tools.Tools, dc.serverName, dc.mcpClient, ToolSourceDevice, dc.ownerID)
 	dc.toolsMux.Unlock()
 
 	logger.Infof("刷新工具列表成功: %s 获取到 %d 个工具", dc.serverName, len(dc.tools))
 	return nil
 }
 
+// refreshResourcesAndPrompts 刷新资源与提示词列表，服务器未声明对应能力时跳过
+func (dc *McpClientInstance) refreshResourcesAndPrompts() {
+	resources, err := listServerResources(dc.Ctx, dc.mcpClient)
+	if err != nil {
+		logger.Warnf("获取资源列表失败: %s, %v", dc.serverName, err)
+	}
+	prompts, err := listServerPrompts(dc.Ctx, dc.mcpClient)
+	if err != nil {
+		logger.Warnf("获取提示词列表失败: %s, %v", dc.serverName, err)
+	}
+
+	dc.toolsMux.Lock()
+	dc.resources = resources
+	dc.prompts = prompts
+	dc.toolsMux.Unlock()
+
+	if len(resources) > 0 || len(prompts) > 0 {
+		logger.Infof("刷新资源与提示词成功: %s 获取到 %d 个资源, %d 个提示词", dc.serverName, len(resources), len(prompts))
+	}
+}
+
 func (dc *McpClientInstance) GetServerName() string {
 	return dc.serverName
 }
@@ -244,9 +274,12 @@ func (dc *McpClientInstance) sendInitlize(ctx context.Context) error {
 				Name:    "mcp-go",
 				Version: "0.1.0",
 			},
-			Capabilities: mcp.ClientCapabilities{},
 		},
 	}
+	// 只有能接收服务器请求的接入点才声明 sampling/elicitation 能力
+	if _, ok := dc.mcpClient.GetTransport().(transport.BidirectionalInterface); ok {
+		initRequest.Params.Capabilities = clientCapabilities()
+	}
 
 	serverInfo, err := dc.mcpClient.Initialize(ctx, initRequest)
 	if err != nil {
@@ -273,9 +306,12 @@ func (dc *McpClientInstance) handleJSONRPCNotification(notification mcp.JSONRPCN
 		//handleProgressNotification(notification)
 	case "notifications/message":
 		//handleMessageNotification(notification)
-	case "notifications/resources/updated":
-		//handleResourceUpdateNotification(notification)
-	case "notifications/tools/updated":
+	case mcp.MethodNotificationResourceUpdated:
+		invalidateResource(dc.serverName, notification)
+	case mcp.MethodNotificationResourcesListChanged, mcp.MethodNotificationPromptsListChanged:
+		logger.Infof("收到资源或提示词列表变化通知，刷新列表: %s", dc.serverName)
+		go dc.refreshResourcesAndPrompts()
+	case "notifications/tools/updated", mcp.MethodNotificationToolsListChanged:
 		// 收到工具更新通知，刷新工具列表
 		logger.Infof("收到工具更新通知，刷新工具列表")
 		go dc.refreshToolsOnNotification()
@@ -303,6 +339,7 @@ func (dc *McpClientInstance) handleTransportClose(reason string) {
 
 	// 标记连接已断开
 	dc.connected = false
+	forgetServerResources(dc.serverName)
 
 	// 取消上下文
 	dc.cancel()
@@ -403,3 +440,57 @@ func (dc *DeviceMcpSession) GetToolByName(toolName string) (tool tool.InvokableT
 	}
 	return nil, false
 }
+
+// getInstance 按服务器名查找设备会话中的 MCP 连接
+func (dc *DeviceMcpSession) getInstance(serverName string) *McpClientInstance {
+	if value, ok := dc.wsEndPointMcp.Load(serverName); ok {
+		return value.(*McpClientInstance)
+	}
+	dc.iotMux.RLock()
+	defer dc.iotMux.RUnlock()
+	if dc.iotOverMcp != nil && dc.iotOverMcp.serverName == serverName {
+		return dc.iotOverMcp
+	}
+	return nil
+}
+
+// instances 设备会话中的全部 MCP 连接
+func (dc *DeviceMcpSession) instances() []*McpClientInstance {
+	var result []*McpClientInstance
+	dc.wsEndPointMcp.Range(func(_, value interface{}) bool {
+		result = append(result, value.(*McpClientInstance))
+		return true
+	})
+	dc.iotMux.RLock()
+	if dc.iotOverMcp != nil {
+		result = append(result, dc.iotOverMcp)
+	}
+	dc.iotMux.RUnlock()
+	return result
+}
+
+// GetResources 获取设备上报的资源列表
+func (dc *DeviceMcpSession) GetResources() []ResourceInfo {
+	var resources []ResourceInfo
+	for _, instance := range dc.instances() {
+		instance.toolsMux.RLock()
+		for _, resource := range instance.resources {
+			resources = append(resources, ResourceInfo{ServerName: instance.serverName, Source: ToolSourceDevice, Resource: resource})
+		}
+		instance.toolsMux.RUnlock()
+	}
+	return resources
+}
+
+// GetPrompts 获取设备上报的提示词列表
+func (dc *DeviceMcpSession) GetPrompts() []PromptInfo {
+	var prompts []PromptInfo
+	for _, instance := range dc.instances() {
+		instance.toolsMux.RLock()
+		for _, prompt := range instance.prompts {
+			prompts = append(prompts, PromptInfo{ServerName: instance.serverName, Source: ToolSourceDevice, Prompt: prompt})
+		}
+		instance.toolsMux.RUnlock()
+	}
+	return prompts
+}
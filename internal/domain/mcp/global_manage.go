@@ -31,6 +31,14 @@ type MCPServerConfig struct {
 	ServiceID string            `json:"service_id,omitempty" mapstructure:"service_id"`
 	AuthRef   string            `json:"auth_ref,omitempty" mapstructure:"auth_ref"`
 	Headers   map[string]string `json:"headers,omitempty" mapstructure:"headers"`
+	// ToolTimeout 工具调用超时（秒），0 使用 mcp.tool_timeout
+	ToolTimeout int `json:"tool_timeout,omitempty" mapstructure:"tool_timeout"`
+
+	// stdio 类型：以子进程方式启动 MCP 服务器
+	Command string            `json:"command,omitempty" mapstructure:"command"`
+	Args    []string          `json:"args,omitempty" mapstructure:"args"`
+	Env     map[string]string `json:"env,omitempty" mapstructure:"env"`
+	WorkDir string            `json:"work_dir,omitempty" mapstructure:"work_dir"`
 }
 
 // GlobalMCPManager 全局MCP管理器
@@ -55,13 +63,26 @@ type MCPServerConnection struct {
 	config     MCPServerConfig
 	client     *client.Client
 	tools      map[string]tool.InvokableTool
+	resources  []mcp.Resource
+	prompts    []mcp.Prompt
 	connected  bool
 	mu         sync.RWMutex
 	lastError  error
 	retryCount int
 	lastPing   time.Time
+
+	runCancel    context.CancelFunc // 结束本次连接的上下文，stdio 类型会随之终止子进程
+	closing      bool               // 正在主动断开，子进程退出不触发重启
+	reconnecting bool
+	nextRetry    time.Time // 退避期内不再重连
 }
 
+const (
+	// 重连退避的初始间隔，之后每次失败翻倍，最大不超过 reconnect_interval
+	reconnectBackoffBase = 2 * time.Second
+	defaultReconnectMax  = 5 * time.Minute
+)
+
 var (
 	globalManager *GlobalMCPManager
 	once          sync.Once
@@ -91,6 +112,7 @@ func GetGlobalMCPManager() *GlobalMCPManager {
 // Start 启动全局MCP管理器
 func (g *GlobalMCPManager) Start() error {
 	// 热更场景：Stop 后 ctx 已取消，需重建以便重启后监控与重连正常
+	g.mu.Lock()
 	if g.ctx != nil && g.ctx.Err() != nil {
 		g.ctx, g.cancel = context.WithCancel(context.Background())
 		g.reconnectConf = ReconnectConfig{
@@ -98,6 +120,7 @@ func (g *GlobalMCPManager) Start() error {
 			MaxAttempts: viper.GetInt("mcp.global.max_reconnect_attempts"),
 		}
 	}
+	g.mu.Unlock()
 
 	// 首先检查配置
 	CheckMCPConfig()
@@ -151,11 +174,18 @@ func (g *GlobalMCPManager) Stop() error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// 并行断开，stdio 子进程各自等待优雅退出
+	var wg sync.WaitGroup
 	for name, conn := range g.servers {
-		if err := conn.disconnect(); err != nil {
-			log.Errorf("断开MCP服务器 %s 连接失败: %v", name, err)
-		}
+		wg.Add(1)
+		go func(name string, conn *MCPServerConnection) {
+			defer wg.Done()
+			if err := conn.disconnect(); err != nil {
+				log.Errorf("断开MCP服务器 %s 连接失败: %v", name, err)
+			}
+		}(name, conn)
 	}
+	wg.Wait()
 
 	g.servers = make(map[string]*MCPServerConnection)
 	g.tools = make(map[string]tool.InvokableTool)
@@ -197,7 +227,7 @@ func (g *GlobalMCPManager) connectToServer(config MCPServerConfig) error {
 	if endpointErr != nil {
 		return endpointErr
 	}
-	log.Infof("正在连接MCP服务器: %s (URL: %s)", config.Name, endpoint)
+	log.Infof("正在连接MCP服务器: %s (%s)", config.Name, endpoint)
 
 	conn := &MCPServerConnection{
 		config: config,
@@ -219,27 +249,45 @@ func (g *GlobalMCPManager) connectToServer(config MCPServerConfig) error {
 
 // connect 连接到MCP服务器
 func (conn *MCPServerConnection) connect() error {
-	// 使用背景上下文，不设置超时，让SSE连接长期保持
-	ctx := context.Background()
+	// 不设置超时，让SSE连接长期保持；断开时取消，stdio 子进程随之退出
+	ctx, cancel := context.WithCancel(context.Background())
 
 	transportInstance, endpoint, err := buildMCPTransport(conn.config)
 	if err != nil {
+		cancel()
 		return err
 	}
 
 	// 使用 client.NewClient 创建 MCP 客户端
 	mcpClient := client.NewClient(transportInstance)
+	mcpClient.OnNotification(conn.handleNotification)
 
+	conn.mu.Lock()
 	conn.client = mcpClient
+	conn.runCancel = cancel
+	conn.closing = false
+	conn.mu.Unlock()
 
-	log.Infof("开始连接MCP服务器: %s, %s URL: %s", conn.config.Name, conn.config.Type, endpoint)
+	log.Infof("开始连接MCP服务器: %s, %s: %s", conn.config.Name, conn.config.Type, endpoint)
 
-	// 启动客户端
-	if err := conn.client.Start(ctx); err != nil {
+	// 启动客户端；断开可能与连接并发进行，之后只使用本地的 mcpClient
+	if err := mcpClient.Start(ctx); err != nil {
 		log.Errorf("启动MCP客户端失败，服务器: %s, 错误: %v", conn.config.Name, err)
+		if isOAuthAuthRef(conn.config.AuthRef) {
+			invalidateOAuthToken(conn.config.AuthRef)
+		}
+		conn.disconnect()
 		return fmt.Errorf("启动客户端失败: %v", err)
 	}
 
+	installServerRequestHandler(conn.config.Name, transportInstance)
+
+	if _, isStdio := transportInstance.(*transport.Stdio); isStdio {
+		watchStdioProcess(conn.config.Name, mcpClient, func(exitErr error) {
+			conn.handleProcessExit(mcpClient, exitErr)
+		})
+	}
+
 	log.Infof("MCP客户端启动成功: %s", conn.config.Name)
 
 	// 初始化客户端
@@ -250,16 +298,20 @@ func (conn *MCPServerConnection) connect() error {
 				Name:    "xiaozhi-esp32-server",
 				Version: "1.0.0",
 			},
-			Capabilities: mcp.ClientCapabilities{
-				Experimental: make(map[string]any),
-			},
+			Capabilities: clientCapabilities(),
 		},
 	}
 
 	log.Infof("正在初始化MCP服务器: %s", conn.config.Name)
-	initResult, err := conn.client.Initialize(ctx, initRequest)
+	initCtx, initCancel := context.WithTimeout(ctx, 30*time.Second)
+	initResult, err := mcpClient.Initialize(initCtx, initRequest)
+	initCancel()
 	if err != nil {
 		log.Errorf("初始化MCP服务器失败，服务器: %s, 错误: %v", conn.config.Name, err)
+		if isOAuthAuthRef(conn.config.AuthRef) {
+			invalidateOAuthToken(conn.config.AuthRef)
+		}
+		conn.disconnect()
 		return fmt.Errorf("初始化失败: %v", err)
 	}
 
@@ -270,11 +322,19 @@ func (conn *MCPServerConnection) connect() error {
 		log.Errorf("获取工具列表失败: %v", err)
 		// 不直接返回错误，因为工具列表获取失败不应该阻止连接建立
 	}
+	conn.refreshResources(ctx)
+	conn.refreshPrompts(ctx)
 
 	conn.mu.Lock()
+	if conn.client != mcpClient {
+		// 连接过程中被断开
+		conn.mu.Unlock()
+		return fmt.Errorf("连接过程中已断开")
+	}
 	conn.connected = true
 	conn.lastError = nil
 	conn.retryCount = 0
+	conn.nextRetry = time.Time{}
 	conn.mu.Unlock()
 
 	log.Infof("MCP服务器连接建立完成: %s", conn.config.Name)
@@ -287,6 +347,8 @@ func normalizeMCPTransportType(t string) string {
 		return "sse"
 	case "streamable_http", "streamable-http", "http":
 		return "streamablehttp"
+	case "stdio", "command":
+		return "stdio"
 	default:
 		return strings.ToLower(strings.TrimSpace(t))
 	}
@@ -295,7 +357,9 @@ func normalizeMCPTransportType(t string) string {
 func endpointForConfig(config MCPServerConfig) (string, string, error) {
 	transportType := normalizeMCPTransportType(config.Type)
 	if transportType == "" {
-		if strings.TrimSpace(config.SSEUrl) != "" {
+		if strings.TrimSpace(config.Command) != "" {
+			transportType = "stdio"
+		} else if strings.TrimSpace(config.SSEUrl) != "" {
 			transportType = "sse"
 		} else if strings.TrimSpace(config.Url) != "" {
 			transportType = "streamablehttp"
@@ -319,6 +383,11 @@ func endpointForConfig(config MCPServerConfig) (string, string, error) {
 			return transportType, strings.TrimSpace(config.SSEUrl), nil
 		}
 		return "", "", fmt.Errorf("MCP服务器 %s 缺少StreamableHTTP URL", config.Name)
+	case "stdio":
+		if strings.TrimSpace(config.Command) == "" {
+			return "", "", fmt.Errorf("MCP服务器 %s 缺少启动命令 command", config.Name)
+		}
+		return transportType, stdioCommandLine(config), nil
 	default:
 		return "", "", fmt.Errorf("MCP服务器 %s 类型不支持: %s", config.Name, config.Type)
 	}
@@ -339,11 +408,16 @@ func buildMCPTransport(config MCPServerConfig) (transport.Interface, string, err
 	}
 
 	switch transportType {
+	case "stdio":
+		return newStdioTransport(config), endpoint, nil
 	case "sse":
 		opts := make([]transport.ClientOption, 0)
 		if len(headers) > 0 {
 			opts = append(opts, transport.WithHeaders(headers))
 		}
+		if isOAuthAuthRef(config.AuthRef) {
+			opts = append(opts, transport.WithHeaderFunc(oauthHeaderFunc(config.Name, config.AuthRef)))
+		}
 		sseTransport, err := transport.NewSSE(endpoint, opts...)
 		if err != nil {
 			return nil, "", fmt.Errorf("创建SSE传输层失败: %v", err)
@@ -354,6 +428,9 @@ func buildMCPTransport(config MCPServerConfig) (transport.Interface, string, err
 		if len(headers) > 0 {
 			opts = append(opts, transport.WithHTTPHeaders(headers))
 		}
+		if isOAuthAuthRef(config.AuthRef) {
+			opts = append(opts, transport.WithHTTPHeaderFunc(oauthHeaderFunc(config.Name, config.AuthRef)))
+		}
 		httpTransport, err := transport.NewStreamableHTTP(endpoint, opts...)
 		if err != nil {
 			return nil, "", fmt.Errorf("创建StreamableHTTP传输层失败: %v", err)
@@ -367,16 +444,27 @@ func buildMCPTransport(config MCPServerConfig) (transport.Interface, string, err
 // refreshTools 刷新工具列表
 func (conn *MCPServerConnection) refreshTools(ctx context.Context) error {
 	// 获取工具列表
+	conn.mu.RLock()
+	mcpClient := conn.client
+	conn.mu.RUnlock()
+	if mcpClient == nil {
+		return fmt.Errorf("client未初始化")
+	}
+
 	listRequest := mcp.ListToolsRequest{}
-	toolsResult, err := conn.client.ListTools(ctx, listRequest)
+	toolsResult, err := mcpClient.ListTools(ctx, listRequest)
 	if err != nil {
 		return fmt.Errorf("获取工具列表失败: %v", err)
 	}
 
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
+	if conn.client != mcpClient {
+		// 获取期间连接已断开或重建
+		return nil
+	}
 
-	conn.tools = ConvertMcpToolListToInvokableToolList(toolsResult.Tools, conn.config.Name, conn.client)
+	conn.tools = ConvertMcpToolListToInvokableToolList(toolsResult.Tools, conn.config.Name, mcpClient, ToolSourceGlobal, "")
 
 	// 更新全局工具列表
 	globalManager.updateGlobalTools(conn.config.Name, conn.tools)
@@ -385,7 +473,9 @@ func (conn *MCPServerConnection) refreshTools(ctx context.Context) error {
 	return nil
 }
 
-func ConvertMcpToolListToInvokableToolList(tools []mcp.Tool, serverName string, client *client.Client) map[string]tool.InvokableTool {
+// ConvertMcpToolListToInvokableToolList 将 MCP 工具列表转换为可调用工具，source 为工具来源，
+// ownerID 为设备工具所属的设备（或智能体）ID，调用时据此查找工具策略
+func ConvertMcpToolListToInvokableToolList(tools []mcp.Tool, serverName string, client *client.Client, source string, ownerID string) map[string]tool.InvokableTool {
 	invokeTools := make(map[string]tool.InvokableTool)
 	for _, tool := range tools {
 
@@ -409,31 +499,121 @@ func ConvertMcpToolListToInvokableToolList(tools []mcp.Tool, serverName string,
 			},
 			serverName: serverName,
 			client:     client,
+			source:     source,
+			ownerID:    ownerID,
 		}
 		invokeTools[tool.Name] = mcpToolInstance
 	}
 	return invokeTools
 }
 
-// disconnect 断开连接
+// disconnect 断开连接。stdio 子进程先关闭 stdin 等待自行退出，
+// 超过 stdioShutdownTimeout 后取消上下文发送中断信号，仍未退出则强制结束
 func (conn *MCPServerConnection) disconnect() error {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 
+	conn.closing = true
 	if conn.client != nil {
 		// 关闭客户端
-		if err := conn.client.Close(); err != nil {
-			log.Errorf("关闭MCP客户端失败: %v", err)
+		done := make(chan error, 1)
+		go func(c *client.Client) {
+			done <- c.Close()
+		}(conn.client)
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Errorf("关闭MCP客户端失败: %v", err)
+			}
+		case <-time.After(stdioShutdownTimeout):
+			log.Warnf("MCP服务器 %s 未在 %v 内退出，发送中断信号", conn.config.Name, stdioShutdownTimeout)
 		}
 		conn.client = nil
 	}
+	if conn.runCancel != nil {
+		conn.runCancel()
+		conn.runCancel = nil
+	}
 
 	conn.connected = false
 	conn.tools = make(map[string]tool.InvokableTool)
+	conn.resources = nil
+	conn.prompts = nil
+	forgetServerResources(conn.config.Name)
 
 	return nil
 }
 
+// handleNotification 处理服务器推送的通知：资源更新时清除缓存，列表变化时重新获取
+func (conn *MCPServerConnection) handleNotification(notification mcp.JSONRPCNotification) {
+	switch notification.Method {
+	case mcp.MethodNotificationResourceUpdated:
+		invalidateResource(conn.config.Name, notification)
+	case mcp.MethodNotificationResourcesListChanged:
+		go conn.refreshResources(context.Background())
+	case mcp.MethodNotificationPromptsListChanged:
+		go conn.refreshPrompts(context.Background())
+	case mcp.MethodNotificationToolsListChanged:
+		go func() {
+			if err := conn.refreshTools(context.Background()); err != nil {
+				log.Errorf("MCP服务器 %s 刷新工具列表失败: %v", conn.config.Name, err)
+			}
+		}()
+	}
+}
+
+// refreshResources 刷新资源列表，失败不影响连接
+func (conn *MCPServerConnection) refreshResources(ctx context.Context) {
+	conn.mu.RLock()
+	mcpClient := conn.client
+	conn.mu.RUnlock()
+
+	resources, err := listServerResources(ctx, mcpClient)
+	if err != nil {
+		log.Warnf("获取MCP服务器 %s 资源列表失败: %v", conn.config.Name, err)
+		return
+	}
+	conn.mu.Lock()
+	conn.resources = resources
+	conn.mu.Unlock()
+	if len(resources) > 0 {
+		log.Infof("MCP服务器 %s 资源列表已更新，共 %d 个资源", conn.config.Name, len(resources))
+	}
+}
+
+// refreshPrompts 刷新提示词列表，失败不影响连接
+func (conn *MCPServerConnection) refreshPrompts(ctx context.Context) {
+	conn.mu.RLock()
+	mcpClient := conn.client
+	conn.mu.RUnlock()
+
+	prompts, err := listServerPrompts(ctx, mcpClient)
+	if err != nil {
+		log.Warnf("获取MCP服务器 %s 提示词列表失败: %v", conn.config.Name, err)
+		return
+	}
+	conn.mu.Lock()
+	conn.prompts = prompts
+	conn.mu.Unlock()
+	if len(prompts) > 0 {
+		log.Infof("MCP服务器 %s 提示词列表已更新，共 %d 个提示词", conn.config.Name, len(prompts))
+	}
+}
+
+// handleProcessExit stdio 子进程意外退出时标记断开并按退避策略重启
+func (conn *MCPServerConnection) handleProcessExit(exited *client.Client, exitErr error) {
+	conn.mu.RLock()
+	current := conn.client == exited && !conn.closing
+	conn.mu.RUnlock()
+	if !current {
+		return
+	}
+	log.Warnf("MCP服务器 %s 子进程已退出: %v", conn.config.Name, exitErr)
+	if globalManager != nil {
+		globalManager.scheduleReconnect(conn.config.Name, conn, fmt.Errorf("子进程已退出: %v", exitErr))
+	}
+}
+
 // updateGlobalTools 更新全局工具列表
 func (g *GlobalMCPManager) updateGlobalTools(serverName string, tools map[string]tool.InvokableTool) {
 	g.mu.Lock()
@@ -480,6 +660,69 @@ func (g *GlobalMCPManager) GetToolByName(name string) (tool.InvokableTool, bool)
 	return nil, false
 }
 
+// serverSelected 未选择服务时使用全部全局服务
+func serverSelected(serverName string, selected map[string]struct{}) bool {
+	if len(selected) == 0 {
+		return true
+	}
+	_, ok := selected[serverName]
+	return ok
+}
+
+// getResources 获取已选全局服务器的资源列表
+func (g *GlobalMCPManager) getResources(selected map[string]struct{}) []ResourceInfo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var result []ResourceInfo
+	for name, conn := range g.servers {
+		if !serverSelected(name, selected) {
+			continue
+		}
+		conn.mu.RLock()
+		for _, resource := range conn.resources {
+			result = append(result, ResourceInfo{ServerName: name, Source: ToolSourceGlobal, Resource: resource})
+		}
+		conn.mu.RUnlock()
+	}
+	return result
+}
+
+// getPrompts 获取已选全局服务器的提示词列表
+func (g *GlobalMCPManager) getPrompts(selected map[string]struct{}) []PromptInfo {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var result []PromptInfo
+	for name, conn := range g.servers {
+		if !serverSelected(name, selected) {
+			continue
+		}
+		conn.mu.RLock()
+		for _, prompt := range conn.prompts {
+			result = append(result, PromptInfo{ServerName: name, Source: ToolSourceGlobal, Prompt: prompt})
+		}
+		conn.mu.RUnlock()
+	}
+	return result
+}
+
+// getServerClient 获取已连接的全局服务器客户端
+func (g *GlobalMCPManager) getServerClient(serverName string) *client.Client {
+	g.mu.RLock()
+	conn, ok := g.servers[serverName]
+	g.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if !conn.connected {
+		return nil
+	}
+	return conn.client
+}
+
 // isSessionClosedError 判断是否为session closed错误
 func isSessionClosedError(err error) bool {
 	if err == nil {
@@ -506,15 +749,9 @@ func (g *GlobalMCPManager) monitorConnections() {
 					defer cancel()
 
 					if err := conn.ping(ctx); err != nil {
-						log.Warnf("MCP服务器 %s ping失败，开始重连: %v", name, err)
-						// ping失败时直接标记为断开并触发重连
-						conn.mu.Lock()
-						conn.connected = false
-						conn.lastError = err
-						conn.mu.Unlock()
-
-						// 直接触发重连
-						go g.reconnectServer(name)
+						log.Warnf("MCP服务器 %s ping失败: %v", name, err)
+						// ping失败时标记为断开，按退避策略重连
+						g.scheduleReconnect(name, conn, err)
 					} else {
 						//log.Debugf("MCP服务器 %s ping成功", name)
 					}
@@ -525,18 +762,74 @@ func (g *GlobalMCPManager) monitorConnections() {
 	}
 }
 
+// reconnectBackoff 第 attempt 次失败后的等待时间，指数增长，上限为 reconnect_interval
+func (g *GlobalMCPManager) reconnectBackoff(attempt int) time.Duration {
+	maxDelay := g.reconnectConf.Interval
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMax
+	}
+	delay := reconnectBackoffBase
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// scheduleReconnect 标记连接断开并在退避期外发起重连；同一服务器同时只有一个重连，
+// 连续失败达到 max_reconnect_attempts（大于 0 时生效）后不再自动重连
+func (g *GlobalMCPManager) scheduleReconnect(name string, conn *MCPServerConnection, cause error) {
+	// 先读取管理器状态再加 conn.mu，与其他地方 g.mu -> conn.mu 的加锁顺序保持一致
+	g.mu.RLock()
+	stopped := g.ctx.Err() != nil
+	maxAttempts := g.reconnectConf.MaxAttempts
+	g.mu.RUnlock()
+
+	conn.mu.Lock()
+	conn.connected = false
+	conn.lastError = cause
+	exhausted := maxAttempts > 0 && conn.retryCount >= maxAttempts
+	if conn.reconnecting || time.Now().Before(conn.nextRetry) || stopped || exhausted {
+		conn.mu.Unlock()
+		return
+	}
+	conn.reconnecting = true
+	conn.mu.Unlock()
+
+	go func() {
+		_, err := g.reconnectServer(name)
+
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		conn.reconnecting = false
+		if err == nil {
+			log.Infof("MCP服务器 %s 重连成功", name)
+			return
+		}
+		conn.retryCount++
+		conn.lastError = err
+		if maxAttempts > 0 && conn.retryCount >= maxAttempts {
+			log.Errorf("MCP服务器 %s 已连续重连失败 %d 次，停止自动重连: %v", name, conn.retryCount, err)
+			return
+		}
+		delay := g.reconnectBackoff(conn.retryCount)
+		conn.nextRetry = time.Now().Add(delay)
+		log.Warnf("MCP服务器 %s 第 %d 次重连失败，%v 后重试: %v", name, conn.retryCount, delay, err)
+	}()
+}
+
 // reconnectServer 重连服务器并返回新的client
 func (g *GlobalMCPManager) reconnectServer(serverName string) (*client.Client, error) {
 	g.mu.RLock()
-	var conn *MCPServerConnection
-	for _, c := range g.servers {
-		if c.config.Name == serverName {
-			conn = c
-			break
-		}
-	}
+	conn := g.servers[serverName]
+	ctx := g.ctx
 	g.mu.RUnlock()
 
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("MCP管理器已停止")
+	}
 	if conn == nil {
 		return nil, fmt.Errorf("未找到服务器连接: %s", serverName)
 	}
@@ -547,24 +840,45 @@ func (g *GlobalMCPManager) reconnectServer(serverName string) (*client.Client, e
 	}
 
 	// 等待一小段时间确保资源释放
-	time.Sleep(time.Second)
+	select {
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+		return nil, fmt.Errorf("MCP管理器已停止")
+	}
 
 	// 重新连接
 	if err := conn.connect(); err != nil {
 		return nil, fmt.Errorf("重连失败: %v", err)
 	}
 
+	// 连接期间管理器已停止或该服务器已被移除：Stop 可能已经处理过这个连接，这里负责关闭新建的客户端
+	g.mu.RLock()
+	stale := ctx.Err() != nil || g.servers[serverName] != conn
+	g.mu.RUnlock()
+	if stale {
+		conn.disconnect()
+		return nil, fmt.Errorf("MCP管理器已停止")
+	}
+
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.client == nil {
+		return nil, fmt.Errorf("重连失败: 连接已断开")
+	}
 	return conn.client, nil
 }
 
 // ping 发送ping请求检测连接状态
 func (conn *MCPServerConnection) ping(ctx context.Context) error {
-	if conn.client == nil {
+	conn.mu.RLock()
+	mcpClient := conn.client
+	conn.mu.RUnlock()
+	if mcpClient == nil {
 		return fmt.Errorf("client未初始化")
 	}
 
 	// 使用空的Ping请求作为ping
-	err := conn.client.Ping(ctx)
+	err := mcpClient.Ping(ctx)
 	if err != nil {
 		return fmt.Errorf("ping失败: %v", err)
 	}
@@ -103,6 +103,7 @@ func (l *LocalMCPManager) RegisterToolFunc(name, description string, inputParams
 			Desc:        description,
 			ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(inputSchema),
 		},
+		source:       ToolSourceLocal,
 		isLocal:      true,
 		localHandler: handler,
 	}
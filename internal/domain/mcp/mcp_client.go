@@ -1,8 +1,10 @@
 package mcp
 
 import (
+	"context"
 	"strings"
 
+	"xiaozhi-esp32-server-golang/internal/domain/config/types"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/cloudwego/eino/components/tool"
@@ -61,11 +63,17 @@ func filterGlobalToolsBySelectedServices(globalTools map[string]tool.InvokableTo
 }
 
 func GetToolByName(deviceId string, agentId string, toolName string, selectedMCPServiceNames string) (tool.InvokableTool, bool) {
+	tool, _, ok := LookupTool(deviceId, agentId, toolName, selectedMCPServiceNames)
+	return tool, ok
+}
+
+// LookupTool 按 本地 > 全局 > 设备 的顺序查找工具，同时返回工具来源
+func LookupTool(deviceId string, agentId string, toolName string, selectedMCPServiceNames string) (tool.InvokableTool, string, bool) {
 	// 优先从本地管理器获取
 	localManager := GetLocalMCPManager()
 	tool, ok := localManager.GetToolByName(toolName)
 	if ok {
-		return tool, ok
+		return tool, ToolSourceLocal, ok
 	}
 
 	// 其次从全局管理器获取
@@ -73,20 +81,20 @@ func GetToolByName(deviceId string, agentId string, toolName string, selectedMCP
 	if len(selected) == 0 {
 		tool, ok = globalManager.GetToolByName(toolName)
 		if ok {
-			return tool, ok
+			return tool, ToolSourceGlobal, ok
 		}
 	} else {
 		globalTools := globalManager.GetAllTools()
 
 		// 兼容直接传入 "server_tool" 的场景
 		if invokable, exists := globalTools[toolName]; exists && isGlobalToolAllowed(toolName, selected) {
-			return invokable, true
+			return invokable, ToolSourceGlobal, true
 		}
 
 		for serviceName := range selected {
 			candidate := serviceName + "_" + toolName
 			if invokable, exists := globalTools[candidate]; exists {
-				return invokable, true
+				return invokable, ToolSourceGlobal, true
 			}
 		}
 	}
@@ -94,16 +102,16 @@ func GetToolByName(deviceId string, agentId string, toolName string, selectedMCP
 	// 最后从设备MCP客户端池获取
 	tool, ok = mcpClientPool.GetToolByDeviceId(deviceId, toolName)
 	if ok {
-		return tool, true
+		return tool, ToolSourceDevice, true
 	}
 	// 兼容 AgentID 上报的 MCP 工具
 	if agentId != "" && agentId != deviceId {
 		tool, ok = mcpClientPool.GetToolByDeviceId(agentId, toolName)
 		if ok {
-			return tool, true
+			return tool, ToolSourceDevice, true
 		}
 	}
-	return nil, false
+	return nil, "", false
 }
 
 func GetDeviceMcpClient(deviceId string) *DeviceMcpSession {
@@ -121,13 +129,45 @@ func RemoveDeviceMcpClient(deviceId string) error {
 }
 
 func GetToolsByDeviceId(deviceId string, agentId string, selectedMCPServiceNames string) (map[string]tool.InvokableTool, error) {
+	retTools, _ := getToolsWithSource(deviceId, agentId, selectedMCPServiceNames)
+	return retTools, nil
+}
+
+// GetAllowedToolsByDeviceId 获取设备可用的工具，并按智能体工具策略过滤掉被禁止的工具
+func GetAllowedToolsByDeviceId(deviceId string, agentId string, selectedMCPServiceNames string, policy types.MCPToolPolicy) (map[string]tool.InvokableTool, error) {
+	retTools, sources := getToolsWithSource(deviceId, agentId, selectedMCPServiceNames)
+	if policy.IsEmpty() {
+		return retTools, nil
+	}
+	var denied []string
+	for key, invokable := range retTools {
+		// 全局工具的 key 为 "服务名_工具名"，策略按 LLM 看到的工具名匹配
+		name := key
+		if info, err := invokable.Info(context.Background()); err == nil && info != nil && info.Name != "" {
+			name = info.Name
+		}
+		if !ToolAllowed(policy, name, sources[key]) {
+			delete(retTools, key)
+			denied = append(denied, name)
+		}
+	}
+	if len(denied) > 0 {
+		log.Infof("设备 %s 按工具策略移除 %d 个工具: %v", deviceId, len(denied), denied)
+	}
+	return retTools, nil
+}
+
+// getToolsWithSource 汇总本地、全局、设备工具，同时返回每个工具的来源
+func getToolsWithSource(deviceId string, agentId string, selectedMCPServiceNames string) (map[string]tool.InvokableTool, map[string]string) {
 	retTools := make(map[string]tool.InvokableTool)
+	sources := make(map[string]string)
 
 	// 优先从本地管理器获取
 	localManager := GetLocalMCPManager()
 	localTools := localManager.GetAllTools()
 	for toolName, tool := range localTools {
 		retTools[toolName] = tool
+		sources[toolName] = ToolSourceLocal
 	}
 	log.Infof("从本地管理器获取到 %d 个工具", len(localTools))
 
@@ -138,6 +178,7 @@ func GetToolsByDeviceId(deviceId string, agentId string, selectedMCPServiceNames
 		// 本地工具优先，如果已存在同名工具则不覆盖
 		if _, exists := retTools[toolName]; !exists {
 			retTools[toolName] = tool
+			sources[toolName] = ToolSourceGlobal
 		}
 	}
 	log.Infof("从全局管理器获取到 %d 个工具（过滤后）", len(filteredGlobalTools))
@@ -146,18 +187,19 @@ func GetToolsByDeviceId(deviceId string, agentId string, selectedMCPServiceNames
 	deviceTools, err := mcpClientPool.GetAllToolsByDeviceIdAndAgentId(deviceId, agentId)
 	if err != nil {
 		log.Errorf("获取设备 %s 的工具失败: %v", deviceId, err)
-		return retTools, nil
+		return retTools, sources
 	}
 	for toolName, tool := range deviceTools {
 		// 本地工具和全局工具优先，如果已存在同名工具则不覆盖
 		if _, exists := retTools[toolName]; !exists {
 			retTools[toolName] = tool
+			sources[toolName] = ToolSourceDevice
 		}
 	}
 	log.Infof("从设备 %s 获取到 %d 个工具", deviceId, len(deviceTools))
 	log.Infof("设备 %s 总共获取到 %d 个工具", deviceId, len(retTools))
 
-	return retTools, nil
+	return retTools, sources
 }
 
 func GetWsEndpointMcpTools(agentId string) (map[string]tool.InvokableTool, error) {
@@ -239,15 +281,11 @@ func GetReportedToolByName(deviceId string, agentId string, toolName string) (to
 	return nil, false
 }
 
-func GetAudioResourceByTool(tool McpTool, resourceLink mcp_go.ResourceLink) (mcp_go.ReadResourceResult, error) {
-	/*client := tool.GetClient()
-	resourceRequest := mcp_go.ReadResourceRequest{
-		Request: mcp_go.Request{
-			Params: mcp_go.ReadResourceParams{
-				URI: resourceLink.URL,
-			},
-		},
+// GetAudioResourceByTool 通过工具所属的 MCP 客户端读取资源链接指向的音频资源
+func GetAudioResourceByTool(tool *McpTool, resourceLink mcp_go.ResourceLink) (mcp_go.ReadResourceResult, error) {
+	result, err := tool.ReadResource(context.Background(), resourceLink.URI)
+	if err != nil {
+		return mcp_go.ReadResourceResult{}, err
 	}
-	client.ReadResource(context.Background(), resourceRequest)*/
-	return mcp_go.ReadResourceResult{}, nil
+	return *result, nil
 }
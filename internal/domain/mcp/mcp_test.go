@@ -2,9 +2,12 @@ package mcp
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -44,16 +47,13 @@ func TestGlobalMCPManager_StartStop(t *testing.T) {
 }
 
 func TestMCPTool_Info(t *testing.T) {
-	tool := &mcpTool{
-		name:        "test_tool",
-		description: "测试工具",
-		inputSchema: map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"query": map[string]interface{}{
-					"type": "string",
-				},
-			},
+	tool := &McpTool{
+		info: &schema.ToolInfo{
+			Name: "test_tool",
+			Desc: "测试工具",
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"query": {Type: schema.String},
+			}),
 		},
 		serverName: "test_server",
 		client:     nil, // 测试中不需要真实客户端
@@ -67,12 +67,10 @@ func TestMCPTool_Info(t *testing.T) {
 }
 
 func TestMCPTool_InvokableRun(t *testing.T) {
-	tool := &mcpTool{
-		name:        "test_tool",
-		description: "测试工具",
-		inputSchema: map[string]interface{}{},
-		serverName:  "test_server",
-		client:      nil, // 测试中不需要真实客户端
+	tool := &McpTool{
+		info:       &schema.ToolInfo{Name: "test_tool", Desc: "测试工具"},
+		serverName: "test_server",
+		client:     nil, // 测试中不需要真实客户端
 	}
 
 	// 这个测试会失败，因为客户端为nil
@@ -179,12 +177,8 @@ func TestMCPGoStructures(t *testing.T) {
 
 // 创建测试工具
 func TestMCPTool_InvokableRun_NewTool(t *testing.T) {
-	testTool := &mcpTool{
-		name:        "test_tool",
-		description: "测试工具",
-		inputSchema: map[string]interface{}{
-			"type": "object",
-		},
+	testTool := &McpTool{
+		info:       &schema.ToolInfo{Name: "test_tool", Desc: "测试工具"},
 		serverName: "test_server",
 		client:     nil, // 测试中不需要真实客户端
 	}
@@ -194,3 +188,92 @@ func TestMCPTool_InvokableRun_NewTool(t *testing.T) {
 	_, err := testTool.InvokableRun(context.Background(), `{"query": "test"}`)
 	assert.Error(t, err) // 预期会有网络错误
 }
+
+func TestEndpointForConfig_Stdio(t *testing.T) {
+	config := MCPServerConfig{
+		Name:    "filesystem",
+		Command: "npx",
+		Args:    []string{"-y", "@modelcontextprotocol/server-filesystem", "/data"},
+		Enabled: true,
+	}
+
+	transportType, endpoint, err := endpointForConfig(config)
+	assert.NoError(t, err)
+	assert.Equal(t, "stdio", transportType)
+	assert.Equal(t, "npx -y @modelcontextprotocol/server-filesystem /data", endpoint)
+
+	config.Command = ""
+	config.Type = "stdio"
+	_, _, err = endpointForConfig(config)
+	assert.Error(t, err)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	g := &GlobalMCPManager{reconnectConf: ReconnectConfig{Interval: 10 * time.Second}}
+
+	assert.Equal(t, 2*time.Second, g.reconnectBackoff(1))
+	assert.Equal(t, 4*time.Second, g.reconnectBackoff(2))
+	assert.Equal(t, 8*time.Second, g.reconnectBackoff(3))
+	assert.Equal(t, 10*time.Second, g.reconnectBackoff(10))
+}
+
+func TestReconnectServerAfterStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	conn := &MCPServerConnection{config: MCPServerConfig{Name: "s", Command: "true"}}
+	g := &GlobalMCPManager{servers: map[string]*MCPServerConnection{"s": conn}, ctx: ctx, cancel: cancel}
+
+	newClient, err := g.reconnectServer("s")
+	assert.Error(t, err)
+	assert.Nil(t, newClient)
+
+	g.scheduleReconnect("s", conn, assert.AnError)
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	assert.False(t, conn.reconnecting, "已停止的管理器不应发起重连")
+}
+
+func TestScheduleReconnectStopsAtMaxAttempts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 不支持的类型，每次重连都会失败
+	conn := &MCPServerConnection{config: MCPServerConfig{Name: "s", Type: "unknown"}}
+	g := &GlobalMCPManager{
+		servers:       map[string]*MCPServerConnection{"s": conn},
+		ctx:           ctx,
+		cancel:        cancel,
+		reconnectConf: ReconnectConfig{MaxAttempts: 1},
+	}
+
+	g.scheduleReconnect("s", conn, assert.AnError)
+	require.Eventually(t, func() bool {
+		conn.mu.RLock()
+		defer conn.mu.RUnlock()
+		return !conn.reconnecting && conn.retryCount == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	g.scheduleReconnect("s", conn, assert.AnError)
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	assert.False(t, conn.reconnecting, "达到最大重连次数后不应继续重连")
+	assert.Equal(t, 1, conn.retryCount)
+}
+
+func TestMcpToolClientSwapConcurrent(t *testing.T) {
+	// 多个会话并行调用同一工具时，重连替换 client 不能与读取竞争（配合 -race 运行）
+	tool := &McpTool{info: &schema.ToolInfo{Name: "test_tool"}, serverName: "test_server"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tool.setClient(&client.Client{})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = tool.GetClient()
+		}()
+	}
+	wg.Wait()
+	assert.NotNil(t, tool.GetClient())
+}
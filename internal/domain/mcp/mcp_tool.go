@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	log "xiaozhi-esp32-server-golang/logger"
 
 	"github.com/cloudwego/eino/components/tool"
@@ -20,6 +21,9 @@ type McpTool struct {
 	info       *schema.ToolInfo
 	serverName string
 	client     *client.Client
+	clientMu   sync.RWMutex // 并行调用时保护重连后的 client 替换
+	source     string       // 工具来源 local/global/device，执行工具策略时使用
+	ownerID    string       // 设备工具所属的设备ID（或上报工具的智能体ID），用于查找登记的工具策略
 
 	// 本地工具支持
 	isLocal      bool
@@ -53,8 +57,15 @@ func (t *McpTool) InvokeableLocalRun(ctx context.Context, argumentsInJSON string
 	return resultStr, nil
 }
 
-// InvokableRun 调用工具，实现InvokableTool接口
+// InvokableRun 调用工具，实现InvokableTool接口。
+// 所有来源的工具调用都经过这里，调用前统一执行智能体工具策略（见 guardToolCall）
 func (t *McpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	argumentsInJSON, err := guardToolCall(ctx, t.ownerID, t.info.Name, t.source, argumentsInJSON)
+	if err != nil {
+		log.Warnf("工具调用被策略拒绝: %s, 原因: %v", t.info.Name, err)
+		return "", err
+	}
+
 	// 如果是本地工具，直接调用本地处理函数
 	if t.isLocal {
 		return t.InvokeableLocalRun(ctx, argumentsInJSON, opts...)
@@ -64,7 +75,8 @@ func (t *McpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts
 
 	// 远程MCP工具调用逻辑
 	// 检查客户端是否可用
-	if t.client == nil {
+	mcpClient := t.GetClient()
+	if mcpClient == nil {
 		return retContent, fmt.Errorf("调用MCP工具失败: MCP客户端未初始化")
 	}
 
@@ -84,8 +96,12 @@ func (t *McpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts
 		},
 	}
 
+	// 调用期间服务器发来的 sampling/elicitation 请求交给发起调用的会话
+	unbind := bindServerRequestHandler(ctx, t.serverName)
+	defer unbind()
+
 	// 第一次尝试调用
-	result, err := t.client.CallTool(ctx, callRequest)
+	result, err := mcpClient.CallTool(ctx, callRequest)
 	if err != nil && isSessionClosedError(err) {
 		log.Warnf("工具 %s 调用失败(session closed): %v，尝试重连后重试", t.info.Name, err)
 
@@ -96,10 +112,10 @@ func (t *McpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts
 		}
 
 		// 更新工具的client引用
-		t.client = newClient
+		t.setClient(newClient)
 
 		// 重试调用
-		result, err = t.client.CallTool(ctx, callRequest)
+		result, err = newClient.CallTool(ctx, callRequest)
 		if err != nil {
 			return retContent, fmt.Errorf("重连后调用仍然失败: %v", err)
 		}
@@ -116,5 +132,19 @@ func (t *McpTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts
 }
 
 func (t *McpTool) GetClient() *client.Client {
+	t.clientMu.RLock()
+	defer t.clientMu.RUnlock()
 	return t.client
 }
+
+// setClient 重连后替换工具的 client，同一工具可能被多个会话并行调用
+func (t *McpTool) setClient(mcpClient *client.Client) {
+	t.clientMu.Lock()
+	t.client = mcpClient
+	t.clientMu.Unlock()
+}
+
+// GetServerName 工具所属的 MCP 服务器名，本地工具为空
+func (t *McpTool) GetServerName() string {
+	return t.serverName
+}
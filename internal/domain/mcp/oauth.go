@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	// oauthAuthRefPrefix 管理后台为已完成 OAuth 授权的服务下发的 auth_ref 前缀
+	oauthAuthRefPrefix = "oauth:"
+	// oauthTokenRefreshBefore 令牌剩余有效期小于该值时重新向管理后台获取
+	oauthTokenRefreshBefore = time.Minute
+	oauthTokenFetchTimeout  = 15 * time.Second
+)
+
+// OAuthToken 管理后台下发的短期访问令牌
+type OAuthToken struct {
+	AccessToken string
+	TokenType   string
+	ExpiresAt   time.Time // 零值表示未知有效期
+}
+
+// OAuthTokenSource 按 auth_ref 获取访问令牌，由配置提供者注册（令牌的授权、加密保存与刷新都在管理后台完成）
+type OAuthTokenSource func(ctx context.Context, authRef string) (*OAuthToken, error)
+
+var (
+	oauthTokenSourceMu sync.RWMutex
+	oauthTokenSource   OAuthTokenSource
+
+	oauthTokenCacheMu sync.Mutex
+	oauthTokenCache   = make(map[string]*OAuthToken)
+)
+
+// SetOAuthTokenSource 注册访问令牌来源
+func SetOAuthTokenSource(source OAuthTokenSource) {
+	oauthTokenSourceMu.Lock()
+	oauthTokenSource = source
+	oauthTokenSourceMu.Unlock()
+}
+
+func isOAuthAuthRef(authRef string) bool {
+	return strings.HasPrefix(strings.TrimSpace(authRef), oauthAuthRefPrefix)
+}
+
+// oauthAuthorizationHeader 返回 Authorization 头，缓存的令牌即将过期时重新获取
+func oauthAuthorizationHeader(ctx context.Context, authRef string) (string, error) {
+	oauthTokenCacheMu.Lock()
+	defer oauthTokenCacheMu.Unlock()
+
+	token := oauthTokenCache[authRef]
+	if token == nil || (!token.ExpiresAt.IsZero() && time.Until(token.ExpiresAt) < oauthTokenRefreshBefore) {
+		oauthTokenSourceMu.RLock()
+		source := oauthTokenSource
+		oauthTokenSourceMu.RUnlock()
+		if source == nil {
+			return "", fmt.Errorf("未配置 OAuth 令牌来源，无法连接需要授权的MCP服务")
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, oauthTokenFetchTimeout)
+		defer cancel()
+		fetched, err := source(fetchCtx, authRef)
+		if err != nil {
+			return "", err
+		}
+		token = fetched
+		oauthTokenCache[authRef] = token
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + token.AccessToken, nil
+}
+
+// invalidateOAuthToken 连接失败（如令牌被吊销）后丢弃缓存，下次连接重新获取
+func invalidateOAuthToken(authRef string) {
+	oauthTokenCacheMu.Lock()
+	delete(oauthTokenCache, authRef)
+	oauthTokenCacheMu.Unlock()
+}
+
+// oauthHeaderFunc 每次 HTTP 请求时附加访问令牌，令牌过期前自动换新
+func oauthHeaderFunc(serverName string, authRef string) transport.HTTPHeaderFunc {
+	return func(ctx context.Context) map[string]string {
+		header, err := oauthAuthorizationHeader(ctx, authRef)
+		if err != nil {
+			log.Warnf("获取MCP服务 %s 的 OAuth 令牌失败: %v", serverName, err)
+			return nil
+		}
+		return map[string]string{"Authorization": header}
+	}
+}
@@ -0,0 +1,296 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	// resourceReadTimeout 单次读取资源的超时时间
+	resourceReadTimeout = 30 * time.Second
+	// resourceListTimeout 获取资源/提示词列表的超时时间
+	resourceListTimeout = 10 * time.Second
+)
+
+// 已订阅资源的读取结果缓存，收到 notifications/resources/updated 时失效；
+// 未订阅的资源每次都重新读取，避免拍照等实时资源返回旧内容
+var (
+	resourceCache      sync.Map // key: resourceKey(serverName, uri) -> *mcp.ReadResourceResult
+	resourceSubscribed sync.Map // key: resourceKey(serverName, uri) -> struct{}
+)
+
+// ResourceContent 解码后的资源内容，文本资源填充 Text，二进制资源填充 Blob
+type ResourceContent struct {
+	URI      string
+	MIMEType string
+	Text     string
+	Blob     []byte
+}
+
+// IsText 是否为文本资源
+func (c ResourceContent) IsText() bool {
+	return c.Blob == nil
+}
+
+// IsImage 是否为图片资源
+func (c ResourceContent) IsImage() bool {
+	return strings.HasPrefix(strings.ToLower(c.MIMEType), "image/")
+}
+
+// ResourceInfo 带服务器名的资源描述
+type ResourceInfo struct {
+	ServerName string       `json:"server_name"`
+	Source     string       `json:"source"`
+	Resource   mcp.Resource `json:"resource"`
+}
+
+// PromptInfo 带服务器名的提示词描述
+type PromptInfo struct {
+	ServerName string     `json:"server_name"`
+	Source     string     `json:"source"`
+	Prompt     mcp.Prompt `json:"prompt"`
+}
+
+func resourceKey(serverName, uri string) string {
+	return serverName + "\x00" + uri
+}
+
+// IsAudioResourceLink 音频资源链接走流式播放；未声明 MIME 类型的链接沿用原有的音乐播放逻辑
+func IsAudioResourceLink(link mcp.ResourceLink) bool {
+	mimeType := strings.ToLower(strings.TrimSpace(link.MIMEType))
+	return mimeType == "" || strings.HasPrefix(mimeType, "audio/")
+}
+
+// ReadResource 通过工具所属的 MCP 客户端读取资源，服务器支持订阅时订阅该资源并缓存结果
+func (t *McpTool) ReadResource(ctx context.Context, uri string) (*mcp.ReadResourceResult, error) {
+	if t.isLocal {
+		return nil, fmt.Errorf("本地工具 %s 不支持读取资源", t.info.Name)
+	}
+	mcpClient := t.GetClient()
+	if mcpClient == nil {
+		return nil, fmt.Errorf("读取资源失败: MCP客户端未初始化")
+	}
+
+	key := resourceKey(t.serverName, uri)
+	if cached, ok := resourceCache.Load(key); ok {
+		return cached.(*mcp.ReadResourceResult), nil
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, resourceReadTimeout)
+	defer cancel()
+	request := mcp.ReadResourceRequest{Params: mcp.ReadResourceParams{URI: uri}}
+	result, err := mcpClient.ReadResource(readCtx, request)
+	if err != nil && isSessionClosedError(err) {
+		log.Warnf("读取资源 %s 失败(session closed): %v，尝试重连后重试", uri, err)
+		newClient, reconnectErr := GetGlobalMCPManager().reconnectServer(t.serverName)
+		if reconnectErr != nil {
+			return nil, fmt.Errorf("重连服务器失败: %v", reconnectErr)
+		}
+		t.setClient(newClient)
+		mcpClient = newClient
+		result, err = mcpClient.ReadResource(readCtx, request)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取资源失败: %v", err)
+	}
+
+	if subscribeResource(ctx, t.serverName, mcpClient, uri) {
+		resourceCache.Store(key, result)
+	}
+	return result, nil
+}
+
+// subscribeResource 服务器支持订阅时订阅资源更新，返回是否已订阅
+func subscribeResource(ctx context.Context, serverName string, mcpClient *client.Client, uri string) bool {
+	key := resourceKey(serverName, uri)
+	if _, ok := resourceSubscribed.Load(key); ok {
+		return true
+	}
+	capabilities := mcpClient.GetServerCapabilities()
+	if capabilities.Resources == nil || !capabilities.Resources.Subscribe {
+		return false
+	}
+	subCtx, cancel := context.WithTimeout(ctx, resourceListTimeout)
+	defer cancel()
+	if err := mcpClient.Subscribe(subCtx, mcp.SubscribeRequest{Params: mcp.SubscribeParams{URI: uri}}); err != nil {
+		log.Warnf("订阅MCP资源失败, server: %s, uri: %s, err: %v", serverName, uri, err)
+		return false
+	}
+	resourceSubscribed.Store(key, struct{}{})
+	log.Infof("已订阅MCP资源, server: %s, uri: %s", serverName, uri)
+	return true
+}
+
+// invalidateResource 资源更新后清除缓存，下次读取时重新获取
+func invalidateResource(serverName string, notification mcp.JSONRPCNotification) {
+	uri, _ := notification.Params.AdditionalFields["uri"].(string)
+	if uri == "" {
+		return
+	}
+	resourceCache.Delete(resourceKey(serverName, uri))
+	log.Infof("MCP资源已更新, server: %s, uri: %s", serverName, uri)
+}
+
+// forgetServerResources 连接断开后清除该服务器的订阅和缓存，重连后需重新订阅
+func forgetServerResources(serverName string) {
+	prefix := serverName + "\x00"
+	for _, m := range []*sync.Map{&resourceCache, &resourceSubscribed} {
+		m.Range(func(key, _ interface{}) bool {
+			if strings.HasPrefix(key.(string), prefix) {
+				m.Delete(key)
+			}
+			return true
+		})
+	}
+}
+
+// DecodeResourceContents 将资源内容解码为文本或二进制数据
+func DecodeResourceContents(contents []mcp.ResourceContents) []ResourceContent {
+	result := make([]ResourceContent, 0, len(contents))
+	for _, content := range contents {
+		switch c := content.(type) {
+		case mcp.TextResourceContents:
+			result = append(result, ResourceContent{URI: c.URI, MIMEType: c.MIMEType, Text: c.Text})
+		case mcp.BlobResourceContents:
+			data, err := base64.StdEncoding.DecodeString(c.Blob)
+			if err != nil {
+				log.Warnf("解码MCP资源失败, uri: %s, err: %v", c.URI, err)
+				continue
+			}
+			result = append(result, ResourceContent{URI: c.URI, MIMEType: c.MIMEType, Blob: data})
+		}
+	}
+	return result
+}
+
+// ReadResourceLink 读取工具结果中的资源链接
+func ReadResourceLink(ctx context.Context, mcpTool *McpTool, link mcp.ResourceLink) ([]ResourceContent, error) {
+	result, err := mcpTool.ReadResource(ctx, link.URI)
+	if err != nil {
+		return nil, err
+	}
+	contents := DecodeResourceContents(result.Contents)
+	for i := range contents {
+		if contents[i].MIMEType == "" {
+			contents[i].MIMEType = link.MIMEType
+		}
+	}
+	return contents, nil
+}
+
+// listServerResources 获取服务器的资源列表，服务器未声明 resources 能力时返回空
+func listServerResources(ctx context.Context, mcpClient *client.Client) ([]mcp.Resource, error) {
+	if mcpClient == nil || mcpClient.GetServerCapabilities().Resources == nil {
+		return nil, nil
+	}
+	listCtx, cancel := context.WithTimeout(ctx, resourceListTimeout)
+	defer cancel()
+	result, err := mcpClient.ListResources(listCtx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Resources, nil
+}
+
+// listServerPrompts 获取服务器的提示词列表，服务器未声明 prompts 能力时返回空
+func listServerPrompts(ctx context.Context, mcpClient *client.Client) ([]mcp.Prompt, error) {
+	if mcpClient == nil || mcpClient.GetServerCapabilities().Prompts == nil {
+		return nil, nil
+	}
+	listCtx, cancel := context.WithTimeout(ctx, resourceListTimeout)
+	defer cancel()
+	result, err := mcpClient.ListPrompts(listCtx, mcp.ListPromptsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Prompts, nil
+}
+
+func getServerPrompt(ctx context.Context, mcpClient *client.Client, name string, arguments map[string]string) (*mcp.GetPromptResult, error) {
+	if mcpClient == nil {
+		return nil, fmt.Errorf("MCP客户端未初始化")
+	}
+	getCtx, cancel := context.WithTimeout(ctx, resourceListTimeout)
+	defer cancel()
+	return mcpClient.GetPrompt(getCtx, mcp.GetPromptRequest{
+		Params: mcp.GetPromptParams{Name: name, Arguments: arguments},
+	})
+}
+
+// GetResources 汇总全局服务器（按智能体选择的服务过滤）与设备/智能体接入点上报的资源
+func GetResources(deviceId string, agentId string, selectedMCPServiceNames string) []ResourceInfo {
+	resources := globalManager.getResources(parseSelectedMCPServiceNames(selectedMCPServiceNames))
+	for _, id := range uniqueIDs(deviceId, agentId) {
+		if session := mcpClientPool.GetMcpClient(id); session != nil {
+			resources = append(resources, session.GetResources()...)
+		}
+	}
+	return resources
+}
+
+// GetPrompts 汇总全局服务器（按智能体选择的服务过滤）与设备/智能体接入点上报的提示词
+func GetPrompts(deviceId string, agentId string, selectedMCPServiceNames string) []PromptInfo {
+	prompts := globalManager.getPrompts(parseSelectedMCPServiceNames(selectedMCPServiceNames))
+	for _, id := range uniqueIDs(deviceId, agentId) {
+		if session := mcpClientPool.GetMcpClient(id); session != nil {
+			prompts = append(prompts, session.GetPrompts()...)
+		}
+	}
+	return prompts
+}
+
+// GetPrompt 按服务器名获取提示词内容，服务器可以是全局服务器或设备/智能体接入点
+func GetPrompt(ctx context.Context, deviceId string, agentId string, serverName string, name string, arguments map[string]string) (*mcp.GetPromptResult, error) {
+	if mcpClient := globalManager.getServerClient(serverName); mcpClient != nil {
+		return getServerPrompt(ctx, mcpClient, name, arguments)
+	}
+	for _, id := range uniqueIDs(deviceId, agentId) {
+		session := mcpClientPool.GetMcpClient(id)
+		if session == nil {
+			continue
+		}
+		if instance := session.getInstance(serverName); instance != nil {
+			return getServerPrompt(ctx, instance.mcpClient, name, arguments)
+		}
+	}
+	return nil, fmt.Errorf("未找到MCP服务器: %s", serverName)
+}
+
+// PromptResultText 将提示词消息拼接为文本，非文本内容忽略
+func PromptResultText(result *mcp.GetPromptResult) string {
+	if result == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(result.Messages))
+	for _, message := range result.Messages {
+		switch content := message.Content.(type) {
+		case mcp.TextContent:
+			parts = append(parts, content.Text)
+		case mcp.EmbeddedResource:
+			if text, ok := content.Resource.(mcp.TextResourceContents); ok {
+				parts = append(parts, text.Text)
+			}
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+func uniqueIDs(deviceId string, agentId string) []string {
+	ids := make([]string, 0, 2)
+	if deviceId != "" {
+		ids = append(ids, deviceId)
+	}
+	if agentId != "" && agentId != deviceId {
+		ids = append(ids, agentId)
+	}
+	return ids
+}
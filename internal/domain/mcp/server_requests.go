@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+)
+
+// 服务器发起的请求（sampling/createMessage、elicitation/create）由正在调用该服务器工具的会话应答：
+// 工具调用期间将会话的处理器绑定到工具所属服务器，服务器此时发来的请求交给该处理器。
+// mcp-go v0.36 中只有 stdio 传输能接收服务器请求，SSE/streamable-HTTP 服务器发来的请求不会到达这里；
+// 设备/智能体接入点的 websocket 连接由 WebsocketTransport 自行分发。
+
+// MethodElicitationCreate 服务器向用户询问信息的请求方法
+const MethodElicitationCreate = "elicitation/create"
+
+// elicitation 回答的动作
+const (
+	ElicitationAccept  = "accept"
+	ElicitationDecline = "decline"
+	ElicitationCancel  = "cancel"
+)
+
+const (
+	defaultSamplingMaxTokens = 1024
+	defaultSamplingTimeout   = 60 * time.Second
+)
+
+// ElicitationParams elicitation/create 请求参数，RequestedSchema 为只含一层属性的 JSON Schema
+type ElicitationParams struct {
+	Message         string         `json:"message"`
+	RequestedSchema map[string]any `json:"requestedSchema,omitempty"`
+}
+
+// ElicitationResult elicitation/create 的回答，Action 为 accept 时 Content 按 RequestedSchema 填写
+type ElicitationResult struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
+// ServerRequestHandler 应答服务器请求的会话，sampling 使用会话智能体的 LLM，elicitation 向用户语音询问
+type ServerRequestHandler interface {
+	CreateMessage(ctx context.Context, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+	Elicit(ctx context.Context, params ElicitationParams) (*ElicitationResult, error)
+}
+
+// SamplingPolicy 服务器通过 sampling 调用智能体 LLM 的限制
+type SamplingPolicy struct {
+	Enable    bool
+	MaxTokens int
+	// AllowedModels 非空时只有智能体使用的模型在列表中才允许 sampling
+	AllowedModels []string
+	Timeout       time.Duration
+}
+
+// GetSamplingPolicy 从配置读取 sampling 策略，未配置时默认开启
+func GetSamplingPolicy() SamplingPolicy {
+	policy := SamplingPolicy{
+		Enable:        true,
+		MaxTokens:     defaultSamplingMaxTokens,
+		AllowedModels: viper.GetStringSlice("mcp.sampling.allowed_models"),
+		Timeout:       defaultSamplingTimeout,
+	}
+	if viper.IsSet("mcp.sampling.enable") {
+		policy.Enable = viper.GetBool("mcp.sampling.enable")
+	}
+	if maxTokens := viper.GetInt("mcp.sampling.max_tokens"); maxTokens > 0 {
+		policy.MaxTokens = maxTokens
+	}
+	if seconds := viper.GetInt("mcp.sampling.timeout"); seconds > 0 {
+		policy.Timeout = time.Duration(seconds) * time.Second
+	}
+	return policy
+}
+
+// ModelAllowed 模型是否允许用于 sampling
+func (p SamplingPolicy) ModelAllowed(model string) bool {
+	if len(p.AllowedModels) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedModels {
+		if allowed == model {
+			return true
+		}
+	}
+	return false
+}
+
+type serverRequestHandlerKey struct{}
+
+// WithServerRequestHandler 在工具调用 ctx 中携带应答服务器请求的会话
+func WithServerRequestHandler(ctx context.Context, handler ServerRequestHandler) context.Context {
+	return context.WithValue(ctx, serverRequestHandlerKey{}, handler)
+}
+
+func serverRequestHandlerFromContext(ctx context.Context) ServerRequestHandler {
+	handler, _ := ctx.Value(serverRequestHandlerKey{}).(ServerRequestHandler)
+	return handler
+}
+
+// serverRequestBinding 正在调用某服务器工具的会话及其工具调用 ctx，打断或会话结束时 ctx 随之取消
+type serverRequestBinding struct {
+	ctx     context.Context
+	handler ServerRequestHandler
+}
+
+var (
+	serverRequestBindingsMu sync.Mutex
+	serverRequestBindings   = make(map[string][]*serverRequestBinding) // key: serverName
+)
+
+// bindServerRequestHandler 工具调用期间绑定会话，返回解绑函数；同一服务器被多个会话同时调用时由最近的调用方应答
+func bindServerRequestHandler(ctx context.Context, serverName string) func() {
+	handler := serverRequestHandlerFromContext(ctx)
+	if handler == nil || serverName == "" {
+		return func() {}
+	}
+	binding := &serverRequestBinding{ctx: ctx, handler: handler}
+	serverRequestBindingsMu.Lock()
+	serverRequestBindings[serverName] = append(serverRequestBindings[serverName], binding)
+	serverRequestBindingsMu.Unlock()
+
+	return func() {
+		serverRequestBindingsMu.Lock()
+		defer serverRequestBindingsMu.Unlock()
+		bindings := serverRequestBindings[serverName]
+		for i, b := range bindings {
+			if b == binding {
+				bindings = append(bindings[:i], bindings[i+1:]...)
+				break
+			}
+		}
+		if len(bindings) == 0 {
+			delete(serverRequestBindings, serverName)
+		} else {
+			serverRequestBindings[serverName] = bindings
+		}
+	}
+}
+
+func activeServerRequestBinding(serverName string) *serverRequestBinding {
+	serverRequestBindingsMu.Lock()
+	defer serverRequestBindingsMu.Unlock()
+	bindings := serverRequestBindings[serverName]
+	if len(bindings) == 0 {
+		return nil
+	}
+	return bindings[len(bindings)-1]
+}
+
+// serverRequestDispatcher 处理服务器发来的请求，交给正在调用该服务器工具的会话
+func serverRequestDispatcher(serverName string) transport.RequestHandler {
+	return func(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+		binding := activeServerRequestBinding(serverName)
+		if binding == nil {
+			return nil, fmt.Errorf("当前没有会话在调用服务器 %s 的工具，无法处理 %s 请求", serverName, request.Method)
+		}
+
+		var result any
+		switch request.Method {
+		case string(mcp.MethodSamplingCreateMessage):
+			var params mcp.CreateMessageParams
+			if err := decodeRequestParams(request.Params, &params); err != nil {
+				return nil, err
+			}
+			createResult, err := binding.handler.CreateMessage(binding.ctx, mcp.CreateMessageRequest{
+				Request:             mcp.Request{Method: request.Method},
+				CreateMessageParams: params,
+			})
+			if err != nil {
+				return nil, err
+			}
+			result = createResult
+		case MethodElicitationCreate:
+			var params ElicitationParams
+			if err := decodeRequestParams(request.Params, &params); err != nil {
+				return nil, err
+			}
+			elicitResult, err := binding.handler.Elicit(binding.ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			result = elicitResult
+		default:
+			return nil, fmt.Errorf("unsupported request method: %s", request.Method)
+		}
+
+		resultBytes, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return &transport.JSONRPCResponse{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      request.ID,
+			Result:  json.RawMessage(resultBytes),
+		}, nil
+	}
+}
+
+func decodeRequestParams(raw any, params any) error {
+	if raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+	if err := json.Unmarshal(data, params); err != nil {
+		return fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	return nil
+}
+
+// installServerRequestHandler 在支持双向通信的传输上安装服务器请求分发，需在 client.Start 之后调用以覆盖 mcp-go 默认的处理
+func installServerRequestHandler(serverName string, t transport.Interface) {
+	if bidirectional, ok := t.(transport.BidirectionalInterface); ok {
+		bidirectional.SetRequestHandler(serverRequestDispatcher(serverName))
+	}
+}
+
+// clientCapabilities 初始化时声明的客户端能力；mcp-go v0.36 的 ClientCapabilities 没有 elicitation 字段，放在 experimental 中声明
+func clientCapabilities() mcp.ClientCapabilities {
+	capabilities := mcp.ClientCapabilities{
+		Experimental: map[string]any{
+			"elicitation": map[string]any{},
+		},
+	}
+	if GetSamplingPolicy().Enable {
+		capabilities.Sampling = &struct{}{}
+	}
+	return capabilities
+}
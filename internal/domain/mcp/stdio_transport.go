@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	// stdioShutdownTimeout 关闭 stdin 后等待子进程自行退出的时间，超时后发送中断信号
+	stdioShutdownTimeout = 5 * time.Second
+	// stdioKillDelay 发送中断信号后等待的时间，超时后强制结束子进程
+	stdioKillDelay = 5 * time.Second
+	// stdioMaxLogLine stderr 单行日志的最大长度
+	stdioMaxLogLine = 64 * 1024
+)
+
+// stdioCommandLine 用于日志展示的命令行
+func stdioCommandLine(config MCPServerConfig) string {
+	parts := append([]string{strings.TrimSpace(config.Command)}, config.Args...)
+	return strings.Join(parts, " ")
+}
+
+// stdioEnv 将 env 配置转换为 KEY=VALUE 列表，按 key 排序保证启动参数稳定
+func stdioEnv(config MCPServerConfig) []string {
+	keys := make([]string, 0, len(config.Env))
+	for k := range config.Env {
+		if strings.TrimSpace(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", strings.TrimSpace(k), config.Env[k]))
+	}
+	return env
+}
+
+// newStdioTransport 创建以子进程方式启动的 MCP 传输层。
+// 子进程随 Start 传入的 ctx 结束：ctx 取消时先发送中断信号，stdioKillDelay 后仍未退出则强制结束。
+func newStdioTransport(config MCPServerConfig) *transport.Stdio {
+	workDir := strings.TrimSpace(config.WorkDir)
+	commandFunc := func(ctx context.Context, command string, env []string, args []string) (*exec.Cmd, error) {
+		cmd := exec.CommandContext(ctx, command, args...)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Dir = workDir
+		cmd.Cancel = func() error {
+			if runtime.GOOS == "windows" {
+				return cmd.Process.Kill()
+			}
+			return cmd.Process.Signal(os.Interrupt)
+		}
+		cmd.WaitDelay = stdioKillDelay
+		return cmd, nil
+	}
+	return transport.NewStdioWithOptions(
+		strings.TrimSpace(config.Command),
+		stdioEnv(config),
+		config.Args,
+		transport.WithCommandFunc(commandFunc),
+	)
+}
+
+// watchStdioProcess 将子进程 stderr 逐行写入日志；stderr 结束即子进程退出，
+// 若不是主动关闭则调用 onExit 触发重启
+func watchStdioProcess(serverName string, mcpClient *client.Client, onExit func(error)) {
+	stderr, ok := client.GetStderr(mcpClient)
+	if !ok || stderr == nil {
+		return
+	}
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 0, 4096), stdioMaxLogLine)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" {
+				log.Infof("MCP服务器 %s stderr: %s", serverName, line)
+			}
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		onExit(err)
+	}()
+}
@@ -0,0 +1,277 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-esp32-server-golang/internal/domain/config/types"
+)
+
+// 工具来源，工具策略的 glob 可以用 "<来源>:" 前缀限定来源
+const (
+	ToolSourceLocal  = "local"
+	ToolSourceGlobal = "global"
+	ToolSourceDevice = "device"
+	// ToolSourceAgentMCP 外部 MCP 客户端经智能体 MCP 服务器调用设备工具，"device:" 前缀同样匹配
+	ToolSourceAgentMCP = "agent_mcp"
+)
+
+// toolRateLimiter 按 智能体+规则+工具 统计滑动窗口内的调用次数
+type toolRateLimiter struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+var rateLimiter = &toolRateLimiter{hits: make(map[string][]time.Time)}
+
+// allow 窗口内未超过限额时记录本次调用并返回 true
+func (l *toolRateLimiter) allow(key string, limit types.MCPToolRateLimit, now time.Time) bool {
+	window := time.Duration(limit.WindowSeconds) * time.Second
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hits := l.hits[key]
+	kept := hits[:0]
+	for _, ts := range hits {
+		if now.Sub(ts) < window {
+			kept = append(kept, ts)
+		}
+	}
+	if len(kept) >= limit.Max {
+		l.hits[key] = kept
+		return false
+	}
+	l.hits[key] = append(kept, now)
+	return true
+}
+
+// matchToolPattern 判断工具是否匹配 glob，pattern 可带 local:/global:/device:/agent_mcp: 前缀
+func matchToolPattern(pattern string, toolName string, source string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	if idx := strings.Index(pattern, ":"); idx > 0 {
+		switch prefix := pattern[:idx]; prefix {
+		case ToolSourceLocal, ToolSourceGlobal, ToolSourceDevice, ToolSourceAgentMCP:
+			// 经智能体 MCP 服务器调用的仍是设备工具，device: 规则对其同样生效
+			if prefix != source && !(prefix == ToolSourceDevice && source == ToolSourceAgentMCP) {
+				return false
+			}
+			pattern = pattern[idx+1:]
+		}
+	}
+	matched, err := path.Match(pattern, toolName)
+	return err == nil && matched
+}
+
+func matchAnyToolPattern(patterns []string, toolName string, source string) bool {
+	for _, pattern := range patterns {
+		if matchToolPattern(pattern, toolName, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolAllowed 判断工具是否允许使用：deny 优先，allow 为空时默认允许
+func ToolAllowed(policy types.MCPToolPolicy, toolName string, source string) bool {
+	if matchAnyToolPattern(policy.Deny, toolName, source) {
+		return false
+	}
+	if len(policy.Allow) == 0 {
+		return true
+	}
+	return matchAnyToolPattern(policy.Allow, toolName, source)
+}
+
+// ToolNeedsConfirm 判断工具调用前是否需要用户口头确认
+func ToolNeedsConfirm(policy types.MCPToolPolicy, toolName string, source string) bool {
+	return matchAnyToolPattern(policy.Confirm, toolName, source)
+}
+
+// ToolPolicyError 工具调用被智能体工具策略拒绝，错误信息会作为工具结果交给调用方
+type ToolPolicyError struct {
+	Err error
+}
+
+func (e *ToolPolicyError) Error() string { return e.Err.Error() }
+
+func (e *ToolPolicyError) Unwrap() error { return e.Err }
+
+// toolPolicyBinding 一次工具调用使用的智能体及其工具策略
+type toolPolicyBinding struct {
+	agentID string
+	policy  types.MCPToolPolicy
+}
+
+type toolPolicyKey struct{}
+
+type toolSourceKey struct{}
+
+// WithToolCallSource 指定本次调用在工具策略中使用的来源，如智能体 MCP 服务器转发的设备工具调用使用 ToolSourceAgentMCP
+func WithToolCallSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, toolSourceKey{}, source)
+}
+
+// WithToolPolicy 绑定本次调用使用的智能体工具策略，优先于 SetToolPolicy 登记的策略
+func WithToolPolicy(ctx context.Context, agentID string, policy types.MCPToolPolicy) context.Context {
+	return context.WithValue(ctx, toolPolicyKey{}, toolPolicyBinding{agentID: agentID, policy: policy})
+}
+
+// toolPolicies 按设备ID和智能体ID登记的工具策略，供不经过对话发起的调用（如管理后台回放）使用
+var toolPolicies sync.Map // map[string]toolPolicyBinding
+
+// SetToolPolicy 登记设备及其所属智能体的工具策略，设备配置加载或刷新后调用
+func SetToolPolicy(deviceID string, agentID string, policy types.MCPToolPolicy) {
+	binding := toolPolicyBinding{agentID: agentID, policy: policy}
+	if deviceID != "" {
+		toolPolicies.Store(deviceID, binding)
+	}
+	if agentID != "" {
+		toolPolicies.Store(agentID, binding)
+	}
+}
+
+// guardToolCall 工具调用的统一策略检查：优先使用 ctx 绑定的策略，其次按工具所属设备查找登记的策略，
+// 都没有时不做限制；ctx 通过 WithToolCallSource 指定来源时按该来源匹配规则。返回实际用于调用的参数，拒绝时返回 *ToolPolicyError
+func guardToolCall(ctx context.Context, ownerID string, toolName string, source string, arguments string) (string, error) {
+	if override, ok := ctx.Value(toolSourceKey{}).(string); ok && override != "" {
+		source = override
+	}
+	binding, ok := ctx.Value(toolPolicyKey{}).(toolPolicyBinding)
+	if !ok && ownerID != "" {
+		var value interface{}
+		if value, ok = toolPolicies.Load(ownerID); ok {
+			binding = value.(toolPolicyBinding)
+		}
+	}
+	if !ok {
+		return arguments, nil
+	}
+	checked, err := CheckToolCall(binding.agentID, binding.policy, toolName, source, arguments)
+	if err != nil {
+		return arguments, &ToolPolicyError{Err: err}
+	}
+	return checked, nil
+}
+
+// CheckToolCall 执行工具策略：是否允许、参数约束（clamp 时截断参数）、限流。
+// 由 McpTool.InvokableRun 在调用前统一执行，返回实际用于调用的参数；拒绝时返回的错误信息会作为工具结果交给 LLM。
+func CheckToolCall(agentID string, policy types.MCPToolPolicy, toolName string, source string, arguments string) (string, error) {
+	if policy.IsEmpty() {
+		return arguments, nil
+	}
+	if !ToolAllowed(policy, toolName, source) {
+		return arguments, fmt.Errorf("工具 %s 已被智能体策略禁止使用", toolName)
+	}
+
+	var rules []types.MCPToolRule
+	for _, rule := range policy.Rules {
+		if matchToolPattern(rule.Tool, toolName, source) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return arguments, nil
+	}
+
+	var args map[string]interface{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return arguments, fmt.Errorf("解析工具参数失败: %v", err)
+		}
+	}
+	changed := false
+	for _, rule := range rules {
+		for name, limit := range rule.Args {
+			value, ok := args[name]
+			if !ok {
+				continue
+			}
+			newValue, clamped, err := checkToolArg(name, value, limit)
+			if err != nil {
+				return arguments, err
+			}
+			if clamped {
+				args[name] = newValue
+				changed = true
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		if rule.RateLimit == nil || rule.RateLimit.Max <= 0 || rule.RateLimit.WindowSeconds <= 0 {
+			continue
+		}
+		key := agentID + "|" + rule.Tool + "|" + toolName
+		if !rateLimiter.allow(key, *rule.RateLimit, now) {
+			return arguments, fmt.Errorf("工具 %s 调用过于频繁，%d 秒内最多调用 %d 次", toolName, rule.RateLimit.WindowSeconds, rule.RateLimit.Max)
+		}
+	}
+
+	if !changed {
+		return arguments, nil
+	}
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return arguments, fmt.Errorf("工具参数序列化失败: %v", err)
+	}
+	return string(argsJSON), nil
+}
+
+// checkToolArg 校验单个参数，返回（可能被截断后的）参数值以及是否发生截断
+func checkToolArg(name string, value interface{}, limit types.MCPToolArgLimit) (interface{}, bool, error) {
+	clamped := false
+	if limit.Min != nil || limit.Max != nil {
+		number, ok := value.(float64)
+		if !ok {
+			return value, false, fmt.Errorf("参数 %s 必须是数字", name)
+		}
+		if limit.Min != nil && number < *limit.Min {
+			if !limit.Clamp {
+				return value, false, fmt.Errorf("参数 %s 不能小于 %v", name, *limit.Min)
+			}
+			value, clamped = *limit.Min, true
+		}
+		if limit.Max != nil && number > *limit.Max {
+			if !limit.Clamp {
+				return value, false, fmt.Errorf("参数 %s 不能大于 %v", name, *limit.Max)
+			}
+			value, clamped = *limit.Max, true
+		}
+	}
+	if len(limit.Enum) > 0 {
+		allowed := false
+		for _, candidate := range limit.Enum {
+			if fmt.Sprint(candidate) == fmt.Sprint(value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return value, false, fmt.Errorf("参数 %s 只能是 %v 之一", name, limit.Enum)
+		}
+	}
+	if limit.Pattern != "" {
+		str, ok := value.(string)
+		if !ok {
+			return value, false, fmt.Errorf("参数 %s 必须是字符串", name)
+		}
+		re, err := regexp.Compile(limit.Pattern)
+		if err != nil {
+			return value, false, fmt.Errorf("参数 %s 的约束配置错误: %v", name, err)
+		}
+		if !re.MatchString(str) {
+			return value, false, fmt.Errorf("参数 %s 的取值不被允许", name)
+		}
+	}
+	return value, clamped, nil
+}
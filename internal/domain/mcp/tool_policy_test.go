@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"xiaozhi-esp32-server-golang/internal/domain/config/types"
+)
+
+func TestCheckToolCall(t *testing.T) {
+	maxVolume := 60.0
+	policy := types.MCPToolPolicy{
+		Deny: []string{"global:HassTurn*"},
+		Rules: []types.MCPToolRule{
+			{
+				Tool: "device:self.audio_speaker.set_volume",
+				Args: map[string]types.MCPToolArgLimit{
+					"volume": {Max: &maxVolume, Clamp: true},
+				},
+			},
+			{
+				Tool:      "play_music",
+				Args:      map[string]types.MCPToolArgLimit{"source": {Enum: []interface{}{"local", "radio"}}},
+				RateLimit: &types.MCPToolRateLimit{Max: 2, WindowSeconds: 60},
+			},
+		},
+	}
+
+	if _, err := CheckToolCall("agent1", policy, "HassTurnOn", ToolSourceGlobal, `{}`); err == nil {
+		t.Error("expected denied global tool")
+	}
+	if _, err := CheckToolCall("agent1", policy, "HassTurnOn", ToolSourceDevice, `{}`); err != nil {
+		t.Errorf("deny rule with global: prefix should not match device tool: %v", err)
+	}
+
+	args, err := CheckToolCall("agent1", policy, "self.audio_speaker.set_volume", ToolSourceDevice, `{"volume":90}`)
+	if err != nil || args != `{"volume":60}` {
+		t.Errorf("expected clamped volume, got %s, %v", args, err)
+	}
+
+	if _, err := CheckToolCall("agent1", policy, "play_music", ToolSourceLocal, `{"source":"web"}`); err == nil {
+		t.Error("expected enum violation")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := CheckToolCall("agent1", policy, "play_music", ToolSourceLocal, `{"source":"radio"}`); err != nil {
+			t.Fatalf("call %d should pass: %v", i, err)
+		}
+	}
+	if _, err := CheckToolCall("agent1", policy, "play_music", ToolSourceLocal, `{"source":"radio"}`); err == nil {
+		t.Error("expected rate limit")
+	}
+	if _, err := CheckToolCall("agent2", policy, "play_music", ToolSourceLocal, `{"source":"radio"}`); err != nil {
+		t.Errorf("rate limit should be per agent: %v", err)
+	}
+
+	allowOnly := types.MCPToolPolicy{Allow: []string{"local:*", "get_*"}}
+	if !ToolAllowed(allowOnly, "exit_conversation", ToolSourceLocal) || !ToolAllowed(allowOnly, "get_weather", ToolSourceGlobal) {
+		t.Error("expected allowed tools")
+	}
+	if ToolAllowed(allowOnly, "HassTurnOn", ToolSourceGlobal) {
+		t.Error("expected tool outside allow list to be denied")
+	}
+}
+
+// TestMcpToolEnforcesPolicy 工具策略在 McpTool.InvokableRun 中统一执行，不依赖调用方
+func TestMcpToolEnforcesPolicy(t *testing.T) {
+	var received []string
+	newTool := func(name string, source string, ownerID string) *McpTool {
+		return &McpTool{
+			info:    &schema.ToolInfo{Name: name},
+			source:  source,
+			ownerID: ownerID,
+			isLocal: true,
+			localHandler: func(ctx context.Context, args string) (string, error) {
+				received = append(received, args)
+				return "ok", nil
+			},
+		}
+	}
+	maxVolume := 50.0
+	policy := types.MCPToolPolicy{
+		Deny: []string{"device:reboot"},
+		Rules: []types.MCPToolRule{{
+			Tool: "set_volume",
+			Args: map[string]types.MCPToolArgLimit{"volume": {Max: &maxVolume, Clamp: true}},
+		}},
+	}
+
+	// ctx 绑定的策略
+	ctx := WithToolPolicy(context.Background(), "agent-ctx", policy)
+	if _, err := newTool("set_volume", ToolSourceGlobal, "").InvokableRun(ctx, `{"volume":80}`); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != `{"volume":50}` {
+		t.Fatalf("handler received %v, want clamped volume", received)
+	}
+
+	// 未绑定策略时按工具所属设备查找登记的策略（如管理后台回放、智能体 MCP 服务）
+	SetToolPolicy("policy-test-device", "policy-test-agent", policy)
+	_, err := newTool("reboot", ToolSourceDevice, "policy-test-device").InvokableRun(context.Background(), `{}`)
+	var policyErr *ToolPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("err = %v, want *ToolPolicyError", err)
+	}
+	if _, err := newTool("reboot", ToolSourceDevice, "policy-test-agent").InvokableRun(context.Background(), `{}`); err == nil {
+		t.Error("policy registered by agent ID not applied")
+	}
+	if len(received) != 1 {
+		t.Errorf("denied tool was invoked: %v", received)
+	}
+
+	// 没有任何策略时不做限制
+	if _, err := newTool("reboot", ToolSourceDevice, "unknown-device").InvokableRun(context.Background(), `{}`); err != nil {
+		t.Errorf("tool without policy denied: %v", err)
+	}
+}
+
+// TestAgentMCPToolSource 经智能体 MCP 服务器转发的设备工具调用按 agent_mcp 来源匹配，device: 规则同样生效
+func TestAgentMCPToolSource(t *testing.T) {
+	invoked := 0
+	deviceTool := &McpTool{
+		info:    &schema.ToolInfo{Name: "open_door"},
+		source:  ToolSourceDevice,
+		ownerID: "agent-mcp-test-device",
+		isLocal: true,
+		localHandler: func(ctx context.Context, args string) (string, error) {
+			invoked++
+			return "ok", nil
+		},
+	}
+	ctx := WithToolCallSource(context.Background(), ToolSourceAgentMCP)
+
+	SetToolPolicy("agent-mcp-test-device", "agent-mcp-test-agent", types.MCPToolPolicy{Deny: []string{"agent_mcp:open_*"}})
+	if _, err := deviceTool.InvokableRun(ctx, `{}`); err == nil {
+		t.Error("agent_mcp rule not applied to agent MCP call")
+	}
+	if _, err := deviceTool.InvokableRun(context.Background(), `{}`); err != nil {
+		t.Errorf("agent_mcp rule applied to device call: %v", err)
+	}
+
+	SetToolPolicy("agent-mcp-test-device", "agent-mcp-test-agent", types.MCPToolPolicy{Deny: []string{"device:open_door"}})
+	if _, err := deviceTool.InvokableRun(ctx, `{}`); err == nil {
+		t.Error("device rule not applied to agent MCP call")
+	}
+	if invoked != 1 {
+		t.Errorf("invoked = %d, want 1", invoked)
+	}
+}
@@ -0,0 +1,39 @@
+package mcp
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultToolCallTimeout = 30 * time.Second
+
+// ToolCallTimeout 单次工具调用的超时时间
+// 优先级：全局服务器配置的 tool_timeout > mcp.tool_timeouts.<服务名> > mcp.tool_timeout（默认30秒）
+func ToolCallTimeout(serverName string) time.Duration {
+	if serverName != "" {
+		if seconds := GetGlobalMCPManager().serverToolTimeout(serverName); seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if seconds := viper.GetInt("mcp.tool_timeouts." + serverName); seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if seconds := viper.GetInt("mcp.tool_timeout"); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultToolCallTimeout
+}
+
+// serverToolTimeout 全局服务器配置的工具调用超时（秒），未配置返回0
+func (g *GlobalMCPManager) serverToolTimeout(serverName string) int {
+	g.mu.RLock()
+	conn, ok := g.servers[serverName]
+	g.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	return conn.config.ToolTimeout
+}
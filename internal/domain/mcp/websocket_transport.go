@@ -47,6 +47,9 @@ type WebsocketTransport struct {
 	conn *websocket.Conn
 
 	notifyHandler func(notification mcp.JSONRPCNotification)
+	// 服务器发起的请求（sampling、elicitation）
+	requestHandler    transport.RequestHandler
+	requestHandlerMux sync.RWMutex
 	// 添加关闭回调
 	onCloseHandler func(reason string)
 
@@ -148,6 +151,13 @@ func (t *WebsocketTransport) readMessages() {
 
 // handleMessage 处理接收到的消息
 func (t *WebsocketTransport) handleMessage(message []byte) {
+	// 同时带有 id 和 method 的是服务器发起的请求，需先于响应判断
+	var request transport.JSONRPCRequest
+	if err := json.Unmarshal(message, &request); err == nil && request.Method != "" && !request.ID.IsNil() {
+		go t.handleRequest(request)
+		return
+	}
+
 	// 尝试解析为 JSON-RPC 响应
 	var response transport.JSONRPCResponse
 	if err := json.Unmarshal(message, &response); err == nil {
@@ -195,6 +205,41 @@ func (t *WebsocketTransport) handleResponse(response *transport.JSONRPCResponse)
 	}
 }
 
+// handleRequest 处理服务器发起的请求并回复，处理过程可能等待用户回答，因此在独立协程中执行
+func (t *WebsocketTransport) handleRequest(request transport.JSONRPCRequest) {
+	t.requestHandlerMux.RLock()
+	handler := t.requestHandler
+	t.requestHandlerMux.RUnlock()
+
+	response := &transport.JSONRPCResponse{JSONRPC: mcp.JSONRPC_VERSION, ID: request.ID}
+	if handler == nil {
+		response.Error = jsonRPCResponseError(mcp.METHOD_NOT_FOUND, "No request handler configured")
+	} else if result, err := handler(t.ctx, request); err != nil {
+		response.Error = jsonRPCResponseError(mcp.INTERNAL_ERROR, err.Error())
+	} else if result != nil {
+		response = result
+	}
+
+	t.writeMux.Lock()
+	err := t.conn.WriteJSON(response)
+	t.writeMux.Unlock()
+	if err != nil {
+		log.Warnf("websocket mcp 回复服务器请求失败, method: %s, err: %v", request.Method, err)
+	}
+}
+
+func jsonRPCResponseError(code int, message string) *struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+} {
+	return &struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}{Code: code, Message: message}
+}
+
 // handleNotification 处理 JSON-RPC 通知
 func (t *WebsocketTransport) handleNotification(notification *mcp.JSONRPCNotification) {
 	if t.notifyHandler != nil {
@@ -275,6 +320,13 @@ func (t *WebsocketTransport) SetNotificationHandler(handler func(notification mc
 	t.notifyHandler = handler
 }
 
+// SetRequestHandler 设置服务器请求的处理函数，实现 transport.BidirectionalInterface
+func (t *WebsocketTransport) SetRequestHandler(handler transport.RequestHandler) {
+	t.requestHandlerMux.Lock()
+	t.requestHandler = handler
+	t.requestHandlerMux.Unlock()
+}
+
 // SetOnCloseHandler 设置连接关闭回调
 func (t *WebsocketTransport) SetOnCloseHandler(handler func(reason string)) {
 	t.onCloseHandler = handler
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 
+	"xiaozhi-esp32-server-golang/internal/domain/memory/local"
 	"xiaozhi-esp32-server-golang/internal/domain/memory/mem0"
 	"xiaozhi-esp32-server-golang/internal/domain/memory/memobase"
 	"xiaozhi-esp32-server-golang/internal/domain/memory/memos"
 	"xiaozhi-esp32-server-golang/internal/domain/memory/nomemo"
+	memory_types "xiaozhi-esp32-server-golang/internal/domain/memory/types"
 
 	"github.com/cloudwego/eino/schema"
 )
@@ -34,6 +36,35 @@ type MemoryProvider interface {
 	ResetMemory(ctx context.Context, agentId string) error
 }
 
+// MemoryItem 单条长期记忆
+type MemoryItem = memory_types.MemoryItem
+
+var (
+	ErrNotSupported = memory_types.ErrNotSupported
+	ErrItemNotFound = memory_types.ErrItemNotFound
+)
+
+// ItemManager 支持逐条查看、编辑和删除记忆的提供者实现该接口，供管理后台使用
+type ItemManager interface {
+	// ListItems 列出 agent 的全部记忆
+	ListItems(ctx context.Context, agentID string) ([]MemoryItem, error)
+
+	// SearchItems 检索 agent 的相关记忆
+	SearchItems(ctx context.Context, agentID string, query string, topK int) ([]MemoryItem, error)
+
+	// UpdateItem 修改单条记忆的内容
+	UpdateItem(ctx context.Context, agentID string, itemID string, content string) error
+
+	// DeleteItem 删除单条记忆
+	DeleteItem(ctx context.Context, agentID string, itemID string) error
+}
+
+// AsItemManager 判断提供者是否支持逐条管理记忆
+func AsItemManager(provider MemoryProvider) (ItemManager, bool) {
+	manager, ok := provider.(ItemManager)
+	return manager, ok
+}
+
 // MemoryType 记忆类型
 type MemoryType string
 
@@ -42,6 +73,7 @@ const (
 	MemoryTypeMemobase MemoryType = "memobase" // Memobase 长期记忆
 	MemoryTypeMem0     MemoryType = "mem0"     // Mem0 记忆服务
 	MemoryTypeMemOS    MemoryType = "memos"    // MemOS（兼容 Mem0 API）
+	MemoryTypeLocal    MemoryType = "local"    // 本地记忆（SQLite/Redis + LLM 事实抽取）
 )
 
 // GetProvider 获取指定类型的记忆提供者
@@ -63,7 +95,26 @@ func GetProviderByType(memoryType MemoryType, config map[string]interface{}) (Me
 		return mem0.GetMem0ClientWithConfig(config)
 	case MemoryTypeMemOS:
 		return memos.GetWithConfig(config)
+	case MemoryTypeLocal:
+		return local.GetWithConfig(config)
 	default:
 		return nil, fmt.Errorf("unsupported memory type: %v", memoryType)
 	}
 }
+
+// SpeakerScopeID 生成按说话人分区的记忆 ID
+// speakerGroupID 为 0（未识别的说话人）时返回共享分区，即 agentID 本身，兼容未分区前写入的记忆
+func SpeakerScopeID(agentID string, speakerGroupID uint) string {
+	if speakerGroupID == 0 {
+		return agentID
+	}
+	return fmt.Sprintf("%s:speaker:%d", agentID, speakerGroupID)
+}
+
+// 确保各提供者实现了逐条管理接口
+var (
+	_ ItemManager = (*local.Provider)(nil)
+	_ ItemManager = (*mem0.Mem0Client)(nil)
+	_ ItemManager = (*memobase.MemobaseClient)(nil)
+	_ ItemManager = (*memos.Client)(nil)
+)
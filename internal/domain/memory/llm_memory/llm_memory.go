@@ -156,6 +156,11 @@ func (m *Memory) getSystemPromptKey(deviceID string) string {
 	return fmt.Sprintf("%s:llm:system:%s", m.keyPrefix, deviceID)
 }
 
+// getSummaryKey 生成设备对应的滚动摘要的 Redis key
+func (m *Memory) getSummaryKey(deviceID string) string {
+	return fmt.Sprintf("%s:llm:summary:%s", m.keyPrefix, deviceID)
+}
+
 // AddMessage 添加一条新的对话消息到记忆体
 func (m *Memory) AddMessage(ctx context.Context, deviceID string, agentID string, msg schema.Message) error {
 	if m.redisClient == nil {
@@ -280,6 +285,11 @@ func (m *Memory) ResetMemory(ctx context.Context, deviceID string) error {
 		return fmt.Errorf("delete history failed: %w", err)
 	}
 
+	// 删除滚动摘要
+	if err := m.redisClient.Del(ctx, m.getSummaryKey(deviceID)).Err(); err != nil {
+		return fmt.Errorf("delete summary failed: %w", err)
+	}
+
 	return nil
 }
 
@@ -323,14 +333,49 @@ func (m *Memory) RemoveOldMessages(ctx context.Context, deviceID string, before
 	return m.redisClient.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("%f", score)).Err()
 }
 
-// Summary 获取对话的摘要
-func (m *Memory) GetSummary(ctx context.Context, deviceID string) (string, error) {
-	return "", nil
+// storedSummary Redis 中保存的滚动摘要，Boundary 为已并入摘要的消息指纹（见 client.SummaryBoundary）
+type storedSummary struct {
+	Summary  string `json:"summary"`
+	Boundary string `json:"boundary"`
 }
 
-// SetSummary 设置对话的摘要
-func (m *Memory) SetSummary(ctx context.Context, deviceID string, summary string) error {
-	return nil
+// GetSummary 获取对话的滚动摘要及其覆盖到的消息边界；旧版本保存的纯文本摘要没有边界
+func (m *Memory) GetSummary(ctx context.Context, deviceID string) (summary string, boundary string, err error) {
+	if m.redisClient == nil {
+		log.Log().Warn("redis client is nil")
+		return "", "", nil
+	}
+
+	result, err := m.redisClient.Get(ctx, m.getSummaryKey(deviceID)).Result()
+	if err == redis.Nil {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("get summary failed: %w", err)
+	}
+	var stored storedSummary
+	if json.Unmarshal([]byte(result), &stored) != nil {
+		return result, "", nil
+	}
+	return stored.Summary, stored.Boundary, nil
+}
+
+// SetSummary 设置对话的滚动摘要及其覆盖到的消息边界，空摘要会删除已有记录
+func (m *Memory) SetSummary(ctx context.Context, deviceID string, summary string, boundary string) error {
+	if m.redisClient == nil {
+		log.Log().Warn("redis client is nil")
+		return nil
+	}
+
+	key := m.getSummaryKey(deviceID)
+	if summary == "" {
+		return m.redisClient.Del(ctx, key).Err()
+	}
+	data, err := json.Marshal(storedSummary{Summary: summary, Boundary: boundary})
+	if err != nil {
+		return err
+	}
+	return m.redisClient.Set(ctx, key, data, 0).Err()
 }
 
 // 进行总结
@@ -0,0 +1,88 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// embedder OpenAI 兼容的 /embeddings 接口客户端
+type embedder struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// newEmbedder 未配置 embedding.base_url 时返回 nil，检索退化为关键词匹配
+func newEmbedder(config map[string]interface{}) *embedder {
+	raw, ok := config["embedding"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(getString(raw, "base_url", "")), "/")
+	if baseURL == "" {
+		return nil
+	}
+	timeoutMS := getInt(raw, "timeout_ms", 5000)
+	return &embedder{
+		baseURL:    baseURL,
+		apiKey:     strings.TrimSpace(getString(raw, "api_key", "")),
+		model:      getString(raw, "model", "text-embedding-3-small"),
+		httpClient: &http.Client{Timeout: time.Duration(timeoutMS) * time.Millisecond},
+	}
+}
+
+func (e *embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model": e.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status=%d, body=%s", resp.StatusCode, string(respBytes))
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBytes, &out); err != nil {
+		return nil, fmt.Errorf("invalid embedding response: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, item := range out.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,70 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// factExtractionPrompt 事实抽取提示词，要求 LLM 以 JSON 返回新增、更新和删除的事实
+const factExtractionPrompt = `你是一个记忆整理助手，负责从用户与助手的对话中提取关于用户的长期事实。
+
+## 提取规则
+1. 只记录关于用户本人的稳定信息：姓名、年龄、家庭成员、喜好、习惯、计划、重要事件等
+2. 每条事实是一句独立、简短的陈述句，使用第三人称"用户"
+3. 忽略寒暄、助手自身的信息以及一次性的提问
+4. 若新信息与已有事实冲突，更新对应 id 的事实；若已有事实被用户否定，删除该 id
+5. 已有事实中已经包含的信息不要重复新增
+
+## 输出格式
+只输出可解析的 JSON，不要解释：
+{"add": ["事实"], "update": [{"id": "已有事实id", "content": "更新后的事实"}], "delete": ["已有事实id"]}
+没有任何变化时输出 {"add": [], "update": [], "delete": []}`
+
+type factUpdate struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+type extractionResult struct {
+	Add    []string     `json:"add"`
+	Update []factUpdate `json:"update"`
+	Delete []string     `json:"delete"`
+}
+
+// buildExtractionDialogue 组装抽取请求：已有事实与待处理对话
+func buildExtractionDialogue(existing []*Fact, messages []schema.Message) []*schema.Message {
+	var builder strings.Builder
+	builder.WriteString("## 已有事实\n")
+	if len(existing) == 0 {
+		builder.WriteString("（无）\n")
+	}
+	for _, fact := range existing {
+		builder.WriteString(fmt.Sprintf("- [%s] %s\n", fact.ID, fact.Content))
+	}
+	builder.WriteString("\n## 对话\n")
+	for _, msg := range messages {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+	}
+
+	return []*schema.Message{
+		schema.SystemMessage(factExtractionPrompt),
+		schema.UserMessage(builder.String()),
+	}
+}
+
+// parseExtractionResult 解析 LLM 输出，兼容 markdown 代码块包裹
+func parseExtractionResult(text string) (*extractionResult, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("no json object in llm output: %s", text)
+	}
+	var result extractionResult
+	if err := json.Unmarshal([]byte(text[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("invalid extraction json: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,82 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	memory_types "xiaozhi-esp32-server-golang/internal/domain/memory/types"
+)
+
+// ListItems 列出 agent 的全部事实，按更新时间倒序
+func (p *Provider) ListItems(ctx context.Context, agentID string) ([]memory_types.MemoryItem, error) {
+	facts, err := p.store.List(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("local memory list failed: %w", err)
+	}
+	items := make([]memory_types.MemoryItem, 0, len(facts))
+	for _, fact := range facts {
+		items = append(items, factToItem(fact, 0))
+	}
+	return items, nil
+}
+
+// SearchItems 检索相关事实，不受 search_top_k 限制
+func (p *Provider) SearchItems(ctx context.Context, agentID string, query string, topK int) ([]memory_types.MemoryItem, error) {
+	ranked, err := p.rankByQuery(ctx, agentID, query, 0)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]memory_types.MemoryItem, 0, len(ranked))
+	for _, item := range ranked {
+		if topK > 0 && len(items) >= topK {
+			break
+		}
+		items = append(items, factToItem(item.fact, item.relevance))
+	}
+	return items, nil
+}
+
+// UpdateItem 修改事实内容并重建索引
+func (p *Provider) UpdateItem(ctx context.Context, agentID string, itemID string, content string) error {
+	fact, err := p.findFact(ctx, agentID, itemID)
+	if err != nil {
+		return err
+	}
+	fact.Content = strings.TrimSpace(content)
+	return p.saveFact(ctx, fact)
+}
+
+// DeleteItem 删除单条事实
+func (p *Provider) DeleteItem(ctx context.Context, agentID string, itemID string) error {
+	if _, err := p.findFact(ctx, agentID, itemID); err != nil {
+		return err
+	}
+	if err := p.store.Delete(ctx, agentID, itemID); err != nil {
+		return fmt.Errorf("local memory delete failed: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) findFact(ctx context.Context, agentID string, itemID string) (*Fact, error) {
+	facts, err := p.store.List(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("local memory list failed: %w", err)
+	}
+	for _, fact := range facts {
+		if fact.ID == itemID {
+			return fact, nil
+		}
+	}
+	return nil, memory_types.ErrItemNotFound
+}
+
+func factToItem(fact *Fact, score float64) memory_types.MemoryItem {
+	return memory_types.MemoryItem{
+		ID:        fact.ID,
+		Content:   fact.Content,
+		Score:     score,
+		CreatedAt: fact.CreatedAt,
+		UpdatedAt: fact.UpdatedAt,
+	}
+}
@@ -0,0 +1,505 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+
+	"xiaozhi-esp32-server-golang/internal/domain/llm"
+	llm_common "xiaozhi-esp32-server-golang/internal/domain/llm/common"
+	log "xiaozhi-esp32-server-golang/logger"
+)
+
+const (
+	defaultExtractEvery     = 6
+	defaultExtractTimeoutMS = 30000
+	defaultMaxFacts         = 200
+	defaultMaxPending       = 100
+	defaultSearchTopK       = 3
+	defaultSearchThreshold  = 0.2
+	defaultHalfLifeDays     = 30.0
+	defaultRelevanceWeight  = 0.7
+	duplicateSimilarity     = 0.95
+
+	// llmRetryInterval LLM 初始化或抽取失败后，间隔多久再自动重试
+	llmRetryInterval = time.Minute
+)
+
+// Provider 本地长期记忆：使用 agent 自身的 LLM 从对话中抽取用户事实，
+// 存储于 SQLite 或 Redis，检索时按相关度（向量或关键词）与新鲜度综合打分
+type Provider struct {
+	store    Store
+	embedder *embedder
+
+	llmConfig   map[string]interface{}
+	llmMu       sync.Mutex
+	llmProvider llm.LLMProvider
+	llmErr      error
+	llmFailedAt time.Time
+
+	enableSearch    bool
+	searchTopK      int
+	searchThreshold float64
+	relevanceWeight float64
+	halfLifeDays    float64
+	extractEvery    int
+	extractTimeout  time.Duration
+	maxFacts        int
+	maxPending      int
+
+	mu         sync.Mutex
+	pending    map[string][]schema.Message
+	extracting map[string]bool      // 正在抽取的 agent，每个 agent 同时只有一个抽取
+	retryAt    map[string]time.Time // 抽取失败后，到该时间前不再由 AddMessage 触发抽取
+}
+
+// GetWithConfig 使用配置创建本地记忆提供者
+// config["llm"] 为 agent 的 LLM 配置，由会话在创建时注入
+func GetWithConfig(config map[string]interface{}) (*Provider, error) {
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	store, err := getStore(getString(config, "store", StoreTypeSQLite), config)
+	if err != nil {
+		return nil, err
+	}
+
+	llmConfig, _ := config["llm"].(map[string]interface{})
+
+	p := &Provider{
+		store:           store,
+		embedder:        newEmbedder(config),
+		llmConfig:       llmConfig,
+		enableSearch:    getBool(config, "enable_search", true),
+		searchTopK:      getInt(config, "search_top_k", defaultSearchTopK),
+		searchThreshold: getFloat(config, "search_threshold", defaultSearchThreshold),
+		relevanceWeight: getFloat(config, "relevance_weight", defaultRelevanceWeight),
+		halfLifeDays:    getFloat(config, "recency_half_life_days", defaultHalfLifeDays),
+		extractEvery:    getInt(config, "extract_every", defaultExtractEvery),
+		extractTimeout:  time.Duration(getInt(config, "extract_timeout_ms", defaultExtractTimeoutMS)) * time.Millisecond,
+		maxFacts:        getInt(config, "max_facts", defaultMaxFacts),
+		maxPending:      getInt(config, "max_pending", defaultMaxPending),
+		pending:         make(map[string][]schema.Message),
+		extracting:      make(map[string]bool),
+		retryAt:         make(map[string]time.Time),
+	}
+	if p.searchTopK <= 0 {
+		p.searchTopK = defaultSearchTopK
+	}
+	if p.relevanceWeight < 0 || p.relevanceWeight > 1 {
+		p.relevanceWeight = defaultRelevanceWeight
+	}
+	if p.extractEvery <= 0 {
+		p.extractEvery = defaultExtractEvery
+	}
+	if p.extractTimeout <= 0 {
+		p.extractTimeout = time.Duration(defaultExtractTimeoutMS) * time.Millisecond
+	}
+	if p.maxPending < p.extractEvery {
+		p.maxPending = p.extractEvery
+	}
+	return p, nil
+}
+
+// getLLM 懒加载抽取用的 LLM；初始化失败时在 llmRetryInterval 内直接返回上次的错误，之后重新初始化
+func (p *Provider) getLLM() (llm.LLMProvider, error) {
+	p.llmMu.Lock()
+	defer p.llmMu.Unlock()
+
+	if p.llmProvider != nil {
+		return p.llmProvider, nil
+	}
+	if p.llmErr != nil && time.Since(p.llmFailedAt) < llmRetryInterval {
+		return nil, p.llmErr
+	}
+
+	var provider llm.LLMProvider
+	var err error
+	if len(p.llmConfig) == 0 {
+		err = fmt.Errorf("local memory: agent llm config is empty")
+	} else {
+		llmType, _ := p.llmConfig["type"].(string)
+		provider, err = llm.GetLLMProvider(llmType, p.llmConfig)
+	}
+	if err != nil {
+		p.llmErr = err
+		p.llmFailedAt = time.Now()
+		return nil, err
+	}
+	p.llmProvider, p.llmErr = provider, nil
+	return provider, nil
+}
+
+// AddMessage 缓存对话消息，累计到 extract_every 条后异步抽取事实；
+// 缓存最多保留 max_pending 条，超出时丢弃最旧的消息
+func (p *Provider) AddMessage(ctx context.Context, agentID string, msg schema.Message) error {
+	if msg.Role != schema.User && msg.Role != schema.Assistant {
+		return nil
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	p.mu.Lock()
+	p.setPendingLocked(agentID, append(p.pending[agentID], schema.Message{Role: msg.Role, Content: msg.Content}))
+	ready := len(p.pending[agentID]) >= p.extractEvery &&
+		!p.extracting[agentID] && time.Now().After(p.retryAt[agentID])
+	p.mu.Unlock()
+
+	if ready {
+		go func() {
+			if err := p.extract(context.WithoutCancel(ctx), agentID); err != nil {
+				log.Warnf("本地记忆抽取失败, agentID: %s, err: %v", agentID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// GetMessages 返回尚未抽取为事实的最近消息
+func (p *Provider) GetMessages(ctx context.Context, agentID string, count int) ([]*schema.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	msgs := p.pending[agentID]
+	if count > 0 && len(msgs) > count {
+		msgs = msgs[len(msgs)-count:]
+	}
+	result := make([]*schema.Message, 0, len(msgs))
+	for i := range msgs {
+		msg := msgs[i]
+		result = append(result, &msg)
+	}
+	return result, nil
+}
+
+// GetContext 按新鲜度返回事实列表，总 token 数不超过 maxToken
+func (p *Provider) GetContext(ctx context.Context, agentID string, maxToken int) (string, error) {
+	if !p.enableSearch {
+		return "", nil
+	}
+	facts, err := p.store.List(ctx, agentID)
+	if err != nil {
+		return "", fmt.Errorf("local memory list failed: %w", err)
+	}
+	ranked := rankFacts(facts, "", nil, time.Now(), p.scoreOptions(0))
+	return formatFacts(ranked, 0, maxToken), nil
+}
+
+// Search 检索与 query 相关的事实
+func (p *Provider) Search(ctx context.Context, agentID string, query string, topK int, timeRangeDays int64) (string, error) {
+	if !p.enableSearch {
+		return "", nil
+	}
+	if topK <= 0 || topK > p.searchTopK {
+		topK = p.searchTopK
+	}
+	ranked, err := p.rankByQuery(ctx, agentID, query, timeRangeDays)
+	if err != nil {
+		return "", err
+	}
+	return formatFacts(ranked, topK, 0), nil
+}
+
+// rankByQuery 按 query 对 agent 的事实排序，配置了 embedding 时优先使用向量相似度
+func (p *Provider) rankByQuery(ctx context.Context, agentID string, query string, timeRangeDays int64) ([]scoredFact, error) {
+	facts, err := p.store.List(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("local memory list failed: %w", err)
+	}
+	if len(facts) == 0 {
+		return nil, nil
+	}
+
+	var queryEmbedding []float32
+	if p.embedder != nil && strings.TrimSpace(query) != "" {
+		vectors, err := p.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			log.Warnf("本地记忆 query embedding 失败，退化为关键词检索: %v", err)
+		} else if len(vectors) > 0 {
+			queryEmbedding = vectors[0]
+		}
+	}
+
+	return rankFacts(facts, query, queryEmbedding, time.Now(), p.scoreOptions(timeRangeDays)), nil
+}
+
+// Flush 立即抽取缓存中的消息
+func (p *Provider) Flush(ctx context.Context, agentID string) error {
+	return p.extract(context.WithoutCancel(ctx), agentID)
+}
+
+// ResetMemory 删除 agent 的全部事实与缓存消息
+func (p *Provider) ResetMemory(ctx context.Context, agentID string) error {
+	p.mu.Lock()
+	delete(p.pending, agentID)
+	delete(p.retryAt, agentID)
+	p.mu.Unlock()
+
+	if err := p.store.DeleteAll(ctx, agentID); err != nil {
+		return fmt.Errorf("local memory reset failed: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) scoreOptions(timeRangeDays int64) scoreOptions {
+	return scoreOptions{
+		threshold:       p.searchThreshold,
+		relevanceWeight: p.relevanceWeight,
+		halfLifeDays:    p.halfLifeDays,
+		timeRangeDays:   timeRangeDays,
+	}
+}
+
+// extract 取出缓存消息交给 LLM 抽取事实并落库；失败时消息放回缓存，llmRetryInterval 后再由 AddMessage 触发重试。
+// 同一 agent 已有抽取进行中时直接返回，新消息留给下一次抽取
+func (p *Provider) extract(ctx context.Context, agentID string) error {
+	p.mu.Lock()
+	if p.extracting[agentID] {
+		p.mu.Unlock()
+		return nil
+	}
+	messages := p.pending[agentID]
+	delete(p.pending, agentID)
+	if len(messages) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	p.extracting[agentID] = true
+	p.mu.Unlock()
+
+	err := p.extractMessages(ctx, agentID, messages)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.extracting, agentID)
+	if err != nil {
+		p.setPendingLocked(agentID, append(messages, p.pending[agentID]...))
+		p.retryAt[agentID] = time.Now().Add(llmRetryInterval)
+		return err
+	}
+	delete(p.retryAt, agentID)
+	return nil
+}
+
+// setPendingLocked 更新缓存消息，超过 maxPending 时丢弃最旧的，调用方需持有 p.mu
+func (p *Provider) setPendingLocked(agentID string, messages []schema.Message) {
+	if len(messages) > p.maxPending {
+		dropped := len(messages) - p.maxPending
+		log.Warnf("本地记忆待抽取消息超过上限 %d, 丢弃最旧的 %d 条, agentID: %s", p.maxPending, dropped, agentID)
+		messages = append([]schema.Message(nil), messages[dropped:]...)
+	}
+	p.pending[agentID] = messages
+}
+
+func (p *Provider) extractMessages(ctx context.Context, agentID string, messages []schema.Message) error {
+	llmProvider, err := p.getLLM()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.extractTimeout)
+	defer cancel()
+
+	existing, err := p.store.List(ctx, agentID)
+	if err != nil {
+		return fmt.Errorf("local memory list failed: %w", err)
+	}
+
+	text, err := llm.ResponseText(ctx, llmProvider, "local_memory_"+agentID, buildExtractionDialogue(existing, messages))
+	if err != nil {
+		return err
+	}
+	result, err := parseExtractionResult(text)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*Fact, len(existing))
+	for _, fact := range existing {
+		byID[fact.ID] = fact
+	}
+
+	for _, id := range result.Delete {
+		if _, ok := byID[id]; !ok {
+			continue
+		}
+		if err := p.store.Delete(ctx, agentID, id); err != nil {
+			return fmt.Errorf("local memory delete failed: %w", err)
+		}
+		delete(byID, id)
+	}
+
+	for _, update := range result.Update {
+		fact, ok := byID[update.ID]
+		if !ok || strings.TrimSpace(update.Content) == "" {
+			continue
+		}
+		fact.Content = strings.TrimSpace(update.Content)
+		fact.Hits++
+		if err := p.saveFact(ctx, fact); err != nil {
+			return err
+		}
+	}
+
+	for _, content := range result.Add {
+		content = strings.TrimSpace(content)
+		if content == "" {
+			continue
+		}
+		fact := &Fact{AgentID: agentID, Content: content}
+		if err := p.indexFact(ctx, fact); err != nil {
+			log.Warnf("本地记忆 embedding 失败，仅保存关键词索引: %v", err)
+		}
+		if dup := findDuplicate(byID, fact); dup != nil {
+			dup.Hits++
+			if err := p.saveFact(ctx, dup); err != nil {
+				return err
+			}
+			continue
+		}
+		fact.ID = uuid.New().String()
+		fact.Hits = 1
+		if err := p.store.Save(ctx, fact); err != nil {
+			return fmt.Errorf("local memory save failed: %w", err)
+		}
+		byID[fact.ID] = fact
+	}
+
+	log.Debugf("本地记忆抽取完成, agentID: %s, add: %d, update: %d, delete: %d",
+		agentID, len(result.Add), len(result.Update), len(result.Delete))
+	return p.prune(ctx, agentID)
+}
+
+// saveFact 重建索引后保存
+func (p *Provider) saveFact(ctx context.Context, fact *Fact) error {
+	if err := p.indexFact(ctx, fact); err != nil {
+		log.Warnf("本地记忆 embedding 失败，仅保存关键词索引: %v", err)
+	}
+	if err := p.store.Save(ctx, fact); err != nil {
+		return fmt.Errorf("local memory save failed: %w", err)
+	}
+	return nil
+}
+
+// indexFact 计算关键词与（若配置）向量索引
+func (p *Provider) indexFact(ctx context.Context, fact *Fact) error {
+	fact.Keywords = tokenize(fact.Content)
+	if p.embedder == nil {
+		return nil
+	}
+	vectors, err := p.embedder.Embed(ctx, []string{fact.Content})
+	if err != nil {
+		return err
+	}
+	if len(vectors) > 0 {
+		fact.Embedding = vectors[0]
+	}
+	return nil
+}
+
+// prune 超过 max_facts 时淘汰最旧的事实
+func (p *Provider) prune(ctx context.Context, agentID string) error {
+	if p.maxFacts <= 0 {
+		return nil
+	}
+	facts, err := p.store.List(ctx, agentID)
+	if err != nil {
+		return fmt.Errorf("local memory list failed: %w", err)
+	}
+	for i := p.maxFacts; i < len(facts); i++ {
+		if err := p.store.Delete(ctx, agentID, facts[i].ID); err != nil {
+			return fmt.Errorf("local memory delete failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// findDuplicate 查找与新事实内容相同或向量高度相似的已有事实
+func findDuplicate(existing map[string]*Fact, fact *Fact) *Fact {
+	normalized := strings.ToLower(strings.TrimSpace(fact.Content))
+	for _, candidate := range existing {
+		if strings.ToLower(strings.TrimSpace(candidate.Content)) == normalized {
+			return candidate
+		}
+		if cosine(candidate.Embedding, fact.Embedding) >= duplicateSimilarity {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// formatFacts 输出 "- 事实 [日期]" 列表，topK/maxTokens 为 0 时不限制
+func formatFacts(ranked []scoredFact, topK int, maxTokens int) string {
+	lines := make([]string, 0, len(ranked))
+	total := 0
+	for _, item := range ranked {
+		if topK > 0 && len(lines) >= topK {
+			break
+		}
+		line := fmt.Sprintf("- %s [%s]", item.fact.Content, item.fact.UpdatedAt.Format("2006-01-02"))
+		size := llm_common.EstimateTokens(line) + 1 // 换行
+		if maxTokens > 0 && total+size > maxTokens {
+			break
+		}
+		total += size
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func getString(config map[string]interface{}, key, defaultValue string) string {
+	if v, ok := config[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return defaultValue
+}
+
+func getInt(config map[string]interface{}, key string, defaultValue int) int {
+	if v, ok := config[key]; ok {
+		switch value := v.(type) {
+		case int:
+			return value
+		case int32:
+			return int(value)
+		case int64:
+			return int(value)
+		case float64:
+			return int(value)
+		}
+	}
+	return defaultValue
+}
+
+func getFloat(config map[string]interface{}, key string, defaultValue float64) float64 {
+	if v, ok := config[key]; ok {
+		switch value := v.(type) {
+		case float64:
+			return value
+		case float32:
+			return float64(value)
+		case int:
+			return float64(value)
+		case int64:
+			return float64(value)
+		}
+	}
+	return defaultValue
+}
+
+func getBool(config map[string]interface{}, key string, defaultValue bool) bool {
+	if v, ok := config[key]; ok {
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	}
+	return defaultValue
+}
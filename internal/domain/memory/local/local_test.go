@@ -0,0 +1,204 @@
+package local
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	memory_types "xiaozhi-esp32-server-golang/internal/domain/memory/types"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+type fakeLLM struct {
+	output string
+}
+
+func (f *fakeLLM) ResponseWithContext(ctx context.Context, sessionID string, dialogue []*schema.Message, functions []*schema.ToolInfo) chan *schema.Message {
+	ch := make(chan *schema.Message, 1)
+	ch <- schema.AssistantMessage(f.output, nil)
+	close(ch)
+	return ch
+}
+
+func (f *fakeLLM) ResponseWithVllm(ctx context.Context, file []byte, text string, mimeType string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeLLM) GetModelInfo() map[string]interface{} { return nil }
+func (f *fakeLLM) Close() error                         { return nil }
+func (f *fakeLLM) IsValid() bool                        { return true }
+
+func newTestProvider(t *testing.T, output string) *Provider {
+	t.Helper()
+	p, err := GetWithConfig(map[string]interface{}{
+		"sqlite_path":   filepath.Join(t.TempDir(), "memory.db"),
+		"extract_every": 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.llmProvider = &fakeLLM{output: output}
+	return p
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("我喜欢吃苹果, likes Go!")
+	want := []string{"我喜", "喜欢", "欢吃", "吃苹", "苹果", "likes", "go"}
+	if strings.Join(tokens, ",") != strings.Join(want, ",") {
+		t.Fatalf("tokenize = %v, want %v", tokens, want)
+	}
+}
+
+func TestRankFactsPrefersRelevantThenRecent(t *testing.T) {
+	now := time.Now()
+	facts := []*Fact{
+		{ID: "old", Content: "用户喜欢吃苹果", Keywords: tokenize("用户喜欢吃苹果"), UpdatedAt: now.AddDate(0, 0, -60)},
+		{ID: "new", Content: "用户喜欢吃苹果派", Keywords: tokenize("用户喜欢吃苹果派"), UpdatedAt: now},
+		{ID: "other", Content: "用户住在北京", Keywords: tokenize("用户住在北京"), UpdatedAt: now},
+	}
+	ranked := rankFacts(facts, "苹果", nil, now, scoreOptions{threshold: 0.1, relevanceWeight: 0.7, halfLifeDays: 30})
+	if len(ranked) != 2 {
+		t.Fatalf("expected 2 relevant facts, got %d", len(ranked))
+	}
+	if ranked[0].fact.ID != "new" {
+		t.Fatalf("expected recent fact first, got %s", ranked[0].fact.ID)
+	}
+
+	ranked = rankFacts(facts, "苹果", nil, now, scoreOptions{threshold: 0.1, relevanceWeight: 0.7, halfLifeDays: 30, timeRangeDays: 7})
+	if len(ranked) != 1 {
+		t.Fatalf("expected time range to drop old fact, got %d", len(ranked))
+	}
+}
+
+func TestFlushExtractsAndSearches(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProvider(t, "```json\n{\"add\": [\"用户的女儿叫小美\", \"用户喜欢爵士乐\"], \"update\": [], \"delete\": []}\n```")
+
+	if err := p.AddMessage(ctx, "agent1", schema.Message{Role: schema.User, Content: "我女儿叫小美，我平时爱听爵士乐"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(ctx, "agent1"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := p.Search(ctx, "agent1", "放点爵士乐", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "爵士乐") || strings.Contains(result, "小美") {
+		t.Fatalf("unexpected search result: %q", result)
+	}
+
+	memoryContext, err := p.GetContext(ctx, "agent1", 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(memoryContext, "小美") {
+		t.Fatalf("context should contain all facts: %q", memoryContext)
+	}
+
+	// 重复抽取相同事实不会新增
+	p.llmProvider = &fakeLLM{output: `{"add": ["用户喜欢爵士乐"]}`}
+	_ = p.AddMessage(ctx, "agent1", schema.Message{Role: schema.User, Content: "我真的很喜欢爵士乐"})
+	if err := p.Flush(ctx, "agent1"); err != nil {
+		t.Fatal(err)
+	}
+	facts, _ := p.store.List(ctx, "agent1")
+	if len(facts) != 2 {
+		t.Fatalf("expected duplicate fact merged, got %d facts", len(facts))
+	}
+
+	if err := p.ResetMemory(ctx, "agent1"); err != nil {
+		t.Fatal(err)
+	}
+	facts, _ = p.store.List(ctx, "agent1")
+	if len(facts) != 0 {
+		t.Fatalf("expected no facts after reset, got %d", len(facts))
+	}
+}
+
+func TestItemManagement(t *testing.T) {
+	ctx := context.Background()
+	p := newTestProvider(t, `{"add": ["用户喜欢爵士乐"]}`)
+	_ = p.AddMessage(ctx, "agent1", schema.Message{Role: schema.User, Content: "我爱听爵士乐"})
+	if err := p.Flush(ctx, "agent1"); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := p.ListItems(ctx, "agent1")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("ListItems = %v, %v", items, err)
+	}
+	itemID := items[0].ID
+
+	if err := p.UpdateItem(ctx, "agent1", itemID, "用户喜欢古典乐"); err != nil {
+		t.Fatal(err)
+	}
+	found, err := p.SearchItems(ctx, "agent1", "古典乐", 5)
+	if err != nil || len(found) != 1 || found[0].Content != "用户喜欢古典乐" {
+		t.Fatalf("SearchItems after update = %v, %v", found, err)
+	}
+
+	// 其他 agent 不能操作该条目
+	if err := p.DeleteItem(ctx, "agent2", itemID); err != memory_types.ErrItemNotFound {
+		t.Fatalf("expected ErrItemNotFound, got %v", err)
+	}
+	if err := p.DeleteItem(ctx, "agent1", itemID); err != nil {
+		t.Fatal(err)
+	}
+	items, _ = p.ListItems(ctx, "agent1")
+	if len(items) != 0 {
+		t.Fatalf("expected no items after delete, got %d", len(items))
+	}
+}
+
+func TestExtractFailureKeepsBoundedPending(t *testing.T) {
+	ctx := context.Background()
+	p, err := GetWithConfig(map[string]interface{}{
+		"sqlite_path":   filepath.Join(t.TempDir(), "memory.db"),
+		"extract_every": 2,
+		"max_pending":   5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未配置 LLM：抽取失败，消息放回缓存但不超过上限
+	for i := 0; i < 20; i++ {
+		_ = p.AddMessage(ctx, "agent1", schema.Message{Role: schema.User, Content: "消息" + string(rune('a'+i))})
+		if err := p.Flush(ctx, "agent1"); err == nil {
+			t.Fatal("expected extraction to fail without llm config")
+		}
+	}
+	msgs, _ := p.GetMessages(ctx, "agent1", 0)
+	if len(msgs) != 5 || msgs[4].Content != "消息t" {
+		t.Fatalf("pending = %d messages, last %q; want the newest 5", len(msgs), msgs[len(msgs)-1].Content)
+	}
+	p.mu.Lock()
+	retryAt := p.retryAt["agent1"]
+	p.mu.Unlock()
+	if !retryAt.After(time.Now()) {
+		t.Fatal("failed extraction should delay the next automatic retry")
+	}
+
+	// LLM 初始化失败不会被永久缓存：超过重试间隔后重新初始化
+	p.llmConfig = map[string]interface{}{"type": "unknown"}
+	p.llmFailedAt = time.Now().Add(-2 * llmRetryInterval)
+	if _, err := p.getLLM(); err == nil || strings.Contains(err.Error(), "config is empty") {
+		t.Fatalf("getLLM should retry with the new config, got %v", err)
+	}
+
+	// 同一 agent 正在抽取时不会重复抽取
+	p.mu.Lock()
+	p.extracting["agent1"] = true
+	p.mu.Unlock()
+	if err := p.Flush(ctx, "agent1"); err != nil {
+		t.Fatalf("Flush during extraction = %v", err)
+	}
+	if msgs, _ := p.GetMessages(ctx, "agent1", 0); len(msgs) != 5 {
+		t.Fatalf("pending changed during in-flight extraction: %d", len(msgs))
+	}
+}
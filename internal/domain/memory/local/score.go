@@ -0,0 +1,155 @@
+package local
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// tokenize 生成关键词索引：英文/数字按词切分，中日韩文字按相邻二元组切分
+func tokenize(text string) []string {
+	seen := make(map[string]struct{})
+	tokens := make([]string, 0)
+	add := func(token string) {
+		if token == "" {
+			return
+		}
+		if _, ok := seen[token]; ok {
+			return
+		}
+		seen[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) >= 2 {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			add(string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			add(string(han[i : i+2]))
+		}
+		han = han[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// keywordRelevance 查询词与事实关键词的重合度，范围 [0,1]
+func keywordRelevance(queryTokens []string, factTokens []string) float64 {
+	if len(queryTokens) == 0 || len(factTokens) == 0 {
+		return 0
+	}
+	index := make(map[string]struct{}, len(factTokens))
+	for _, token := range factTokens {
+		index[token] = struct{}{}
+	}
+	matched := 0
+	for _, token := range queryTokens {
+		if _, ok := index[token]; ok {
+			matched++
+		}
+	}
+	return float64(matched) / math.Sqrt(float64(len(queryTokens))*float64(len(factTokens)))
+}
+
+// cosine 余弦相似度，维度不一致时返回 0
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// recency 按半衰期计算的新鲜度，范围 (0,1]
+func recency(updatedAt time.Time, now time.Time, halfLifeDays float64) float64 {
+	if halfLifeDays <= 0 {
+		return 1
+	}
+	ageDays := now.Sub(updatedAt).Hours() / 24
+	if ageDays <= 0 {
+		return 1
+	}
+	return math.Exp(-math.Ln2 * ageDays / halfLifeDays)
+}
+
+type scoredFact struct {
+	fact      *Fact
+	relevance float64
+	score     float64
+}
+
+// rankFacts 按相关度与新鲜度加权排序，relevance 低于 threshold 的事实被丢弃
+func rankFacts(facts []*Fact, query string, queryEmbedding []float32, now time.Time, opts scoreOptions) []scoredFact {
+	queryTokens := tokenize(query)
+	ranked := make([]scoredFact, 0, len(facts))
+	for _, fact := range facts {
+		if opts.timeRangeDays > 0 && now.Sub(fact.UpdatedAt) > time.Duration(opts.timeRangeDays)*24*time.Hour {
+			continue
+		}
+		fresh := recency(fact.UpdatedAt, now, opts.halfLifeDays)
+		if strings.TrimSpace(query) == "" {
+			ranked = append(ranked, scoredFact{fact: fact, score: fresh})
+			continue
+		}
+
+		relevance := 0.0
+		if len(queryEmbedding) > 0 && len(fact.Embedding) > 0 {
+			relevance = cosine(queryEmbedding, fact.Embedding)
+		} else {
+			relevance = keywordRelevance(queryTokens, fact.Keywords)
+		}
+		if relevance < opts.threshold {
+			continue
+		}
+		ranked = append(ranked, scoredFact{
+			fact:      fact,
+			relevance: relevance,
+			score:     opts.relevanceWeight*relevance + (1-opts.relevanceWeight)*fresh,
+		})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	return ranked
+}
+
+type scoreOptions struct {
+	threshold       float64
+	relevanceWeight float64
+	halfLifeDays    float64
+	timeRangeDays   int64
+}
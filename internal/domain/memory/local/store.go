@@ -0,0 +1,178 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	i_redis "xiaozhi-esp32-server-golang/internal/db/redis"
+)
+
+const (
+	StoreTypeSQLite = "sqlite"
+	StoreTypeRedis  = "redis"
+
+	defaultSQLitePath  = "data/local_memory.db"
+	defaultRedisPrefix = "xiaozhi"
+)
+
+// Fact 一条从对话中抽取出的用户事实
+type Fact struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"id"`
+	AgentID   string    `gorm:"size:191;index" json:"agent_id"`
+	Content   string    `gorm:"type:text" json:"content"`
+	Keywords  []string  `gorm:"serializer:json;type:text" json:"keywords,omitempty"`
+	Embedding []float32 `gorm:"serializer:json;type:text" json:"embedding,omitempty"`
+	Hits      int       `json:"hits"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Fact) TableName() string {
+	return "local_memory_facts"
+}
+
+// Store 事实存储后端
+type Store interface {
+	// List 返回 agent 的全部事实，按更新时间倒序
+	List(ctx context.Context, agentID string) ([]*Fact, error)
+	// Save 新增或覆盖一条事实
+	Save(ctx context.Context, fact *Fact) error
+	// Delete 删除一条事实
+	Delete(ctx context.Context, agentID string, id string) error
+	// DeleteAll 删除 agent 的全部事实
+	DeleteAll(ctx context.Context, agentID string) error
+}
+
+var stores sync.Map // key: store type + 位置, value: Store
+
+// getStore 按配置获取存储后端，同一位置的存储在进程内复用
+func getStore(storeType string, config map[string]interface{}) (Store, error) {
+	switch storeType {
+	case "", StoreTypeSQLite:
+		path := getString(config, "sqlite_path", defaultSQLitePath)
+		key := StoreTypeSQLite + ":" + path
+		if s, ok := stores.Load(key); ok {
+			return s.(Store), nil
+		}
+		s, err := newSQLiteStore(path)
+		if err != nil {
+			return nil, err
+		}
+		actual, _ := stores.LoadOrStore(key, s)
+		return actual.(Store), nil
+	case StoreTypeRedis:
+		prefix := getString(config, "redis_key_prefix", defaultRedisPrefix)
+		key := StoreTypeRedis + ":" + prefix
+		if s, ok := stores.Load(key); ok {
+			return s.(Store), nil
+		}
+		client := i_redis.GetClient()
+		if client == nil {
+			return nil, fmt.Errorf("无法获取 Redis 客户端")
+		}
+		actual, _ := stores.LoadOrStore(key, &redisStore{client: client, keyPrefix: prefix})
+		return actual.(Store), nil
+	default:
+		return nil, fmt.Errorf("unsupported local memory store: %s", storeType)
+	}
+}
+
+type sqliteStore struct {
+	db *gorm.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建本地记忆目录失败: %w", err)
+		}
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("打开本地记忆数据库失败: %w", err)
+	}
+	if err := db.AutoMigrate(&Fact{}); err != nil {
+		return nil, fmt.Errorf("本地记忆表迁移失败: %w", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) List(ctx context.Context, agentID string) ([]*Fact, error) {
+	var facts []*Fact
+	err := s.db.WithContext(ctx).Where("agent_id = ?", agentID).Order("updated_at DESC").Find(&facts).Error
+	return facts, err
+}
+
+func (s *sqliteStore) Save(ctx context.Context, fact *Fact) error {
+	return s.db.WithContext(ctx).Save(fact).Error
+}
+
+func (s *sqliteStore) Delete(ctx context.Context, agentID string, id string) error {
+	return s.db.WithContext(ctx).Where("agent_id = ? AND id = ?", agentID, id).Delete(&Fact{}).Error
+}
+
+func (s *sqliteStore) DeleteAll(ctx context.Context, agentID string) error {
+	return s.db.WithContext(ctx).Where("agent_id = ?", agentID).Delete(&Fact{}).Error
+}
+
+// redisStore 每个 agent 一个 hash，field 为事实 ID，value 为事实 JSON
+type redisStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func (s *redisStore) key(agentID string) string {
+	return fmt.Sprintf("%s:memory:local:%s", s.keyPrefix, agentID)
+}
+
+func (s *redisStore) List(ctx context.Context, agentID string) ([]*Fact, error) {
+	values, err := s.client.HGetAll(ctx, s.key(agentID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	facts := make([]*Fact, 0, len(values))
+	for _, value := range values {
+		var fact Fact
+		if err := json.Unmarshal([]byte(value), &fact); err != nil {
+			continue
+		}
+		facts = append(facts, &fact)
+	}
+	sort.Slice(facts, func(i, j int) bool {
+		return facts[i].UpdatedAt.After(facts[j].UpdatedAt)
+	})
+	return facts, nil
+}
+
+func (s *redisStore) Save(ctx context.Context, fact *Fact) error {
+	// 与 gorm 的自动时间戳行为保持一致
+	now := time.Now()
+	if fact.CreatedAt.IsZero() {
+		fact.CreatedAt = now
+	}
+	fact.UpdatedAt = now
+	data, err := json.Marshal(fact)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key(fact.AgentID), fact.ID, data).Err()
+}
+
+func (s *redisStore) Delete(ctx context.Context, agentID string, id string) error {
+	return s.client.HDel(ctx, s.key(agentID), id).Err()
+}
+
+func (s *redisStore) DeleteAll(ctx context.Context, agentID string) error {
+	return s.client.Del(ctx, s.key(agentID)).Err()
+}
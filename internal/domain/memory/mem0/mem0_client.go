@@ -219,8 +219,10 @@ func (m *Mem0Client) GetMessages(ctx context.Context, agentID string, count int)
 // ResetMemory 重置用户记忆
 func (m *Mem0Client) ResetMemory(ctx context.Context, userID string) error {
 
-	// 删除用户的所有记忆
-	err := m.client.DeleteUser(userID)
+	// 删除 agent 的所有记忆（AddMessage 以 agent_id 写入）
+	err := m.client.DeleteAll(types.MemoryOptions{
+		AgentID: userID,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to reset memory for user %s: %w", userID, err)
 	}
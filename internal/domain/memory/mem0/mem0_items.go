@@ -0,0 +1,164 @@
+package mem0
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	memory_types "xiaozhi-esp32-server-golang/internal/domain/memory/types"
+
+	"github.com/hackers365/mem0-go/types"
+)
+
+const (
+	// listItemsPageSize 分页列出记忆时每页条数
+	listItemsPageSize = 100
+	// listItemsMaxPages 分页上限，防止服务端分页异常时无限请求
+	listItemsMaxPages = 1000
+)
+
+var listHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// memoryPage GET /v1/memories/ 携带 page 参数时的分页响应
+type memoryPage struct {
+	Next    *string        `json:"next"`
+	Results []types.Memory `json:"results"`
+}
+
+// ListItems 按 agent_id 分页列出 agent 的全部记忆，直到没有更多结果
+func (m *Mem0Client) ListItems(ctx context.Context, agentID string) ([]memory_types.MemoryItem, error) {
+	var all []types.Memory
+	for page := 1; page <= listItemsMaxPages; page++ {
+		results, more, err := m.listMemoriesPage(ctx, agentID, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list memories for agent %s: %w", agentID, err)
+		}
+		all = append(all, results...)
+		if !more {
+			break
+		}
+	}
+	// 服务端已按 agent_id 过滤，这里再严格过滤一次，避免越权返回其他 agent 的记忆
+	owned := make([]types.Memory, 0, len(all))
+	for _, result := range all {
+		if result.AgentID == agentID {
+			owned = append(owned, result)
+		}
+	}
+	return toMemoryItems(agentID, owned), nil
+}
+
+// listMemoriesPage 读取一页记忆。mem0-go 的 GetAll 不会把 agent_id 和分页参数带到查询串，这里直接请求接口
+func (m *Mem0Client) listMemoriesPage(ctx context.Context, agentID string, page int) ([]types.Memory, bool, error) {
+	query := url.Values{}
+	query.Set("agent_id", agentID)
+	query.Set("page", strconv.Itoa(page))
+	query.Set("page_size", strconv.Itoa(listItemsPageSize))
+	endpoint := strings.TrimRight(m.config.BaseUrl, "/") + "/v1/memories/?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Authorization", "Token "+m.config.APIKey)
+	resp, err := listHTTPClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+	}
+
+	// 未启用分页的服务端直接返回数组，视为全部结果
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		var results []types.Memory
+		if err := json.Unmarshal(body, &results); err != nil {
+			return nil, false, err
+		}
+		return results, false, nil
+	}
+	var result memoryPage
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, false, err
+	}
+	more := len(result.Results) > 0 && (result.Next != nil && *result.Next != "" || len(result.Results) >= listItemsPageSize)
+	return result.Results, more, nil
+}
+
+// SearchItems 检索 agent 的相关记忆，不受 enable_search 与 search_topk 配置限制
+func (m *Mem0Client) SearchItems(ctx context.Context, agentID string, query string, topK int) ([]memory_types.MemoryItem, error) {
+	results, err := m.actionSearch(ctx, agentID, query, topK, 0)
+	if err != nil {
+		return nil, err
+	}
+	return toMemoryItems(agentID, results), nil
+}
+
+// UpdateItem 修改单条记忆
+func (m *Mem0Client) UpdateItem(ctx context.Context, agentID string, itemID string, content string) error {
+	if err := m.checkOwner(agentID, itemID); err != nil {
+		return err
+	}
+	if _, err := m.client.Update(itemID, content); err != nil {
+		return fmt.Errorf("failed to update memory %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// DeleteItem 删除单条记忆
+func (m *Mem0Client) DeleteItem(ctx context.Context, agentID string, itemID string) error {
+	if err := m.checkOwner(agentID, itemID); err != nil {
+		return err
+	}
+	if err := m.client.Delete(itemID); err != nil {
+		return fmt.Errorf("failed to delete memory %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// checkOwner 确认记忆属于该 agent，避免跨 agent 修改
+func (m *Mem0Client) checkOwner(agentID string, itemID string) error {
+	memory, err := m.client.Get(itemID)
+	if err != nil || memory == nil || memory.AgentID != agentID {
+		return memory_types.ErrItemNotFound
+	}
+	return nil
+}
+
+// toMemoryItems 转换为统一格式，排除明确属于其他 agent 的记忆
+func toMemoryItems(agentID string, results []types.Memory) []memory_types.MemoryItem {
+	items := make([]memory_types.MemoryItem, 0, len(results))
+	for _, result := range results {
+		if result.AgentID != "" && result.AgentID != agentID {
+			continue
+		}
+		content := result.Memory
+		if content == "" && result.Data != nil {
+			content = result.Data.Memory
+		}
+		category := ""
+		if len(result.Categories) > 0 {
+			category = result.Categories[0]
+		}
+		items = append(items, memory_types.MemoryItem{
+			ID:        result.ID,
+			Content:   content,
+			Category:  category,
+			Score:     result.Score,
+			CreatedAt: result.CreatedAt,
+			UpdatedAt: result.UpdatedAt,
+		})
+	}
+	return items
+}
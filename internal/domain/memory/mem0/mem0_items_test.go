@@ -0,0 +1,51 @@
+package mem0
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/hackers365/mem0-go/types"
+)
+
+func TestListItemsPaginatesWithAgentFilter(t *testing.T) {
+	const total = 250
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/memories/" || q.Get("agent_id") != "agent-1" || r.Header.Get("Authorization") != "Token key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		pages = append(pages, q.Get("page"))
+		page, _ := strconv.Atoi(q.Get("page"))
+		size, _ := strconv.Atoi(q.Get("page_size"))
+		var results []types.Memory
+		for i := (page - 1) * size; i < total && i < page*size; i++ {
+			results = append(results, types.Memory{ID: fmt.Sprintf("m%d", i), Memory: "fact", AgentID: "agent-1"})
+		}
+		var next *string
+		if page*size < total {
+			link := fmt.Sprintf("/v1/memories/?page=%d", page+1)
+			next = &link
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": total, "next": next, "results": results})
+	}))
+	defer server.Close()
+
+	m := &Mem0Client{config: Mem0Config{APIKey: "key", BaseUrl: server.URL}}
+	items, err := m.ListItems(context.Background(), "agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != total {
+		t.Fatalf("ListItems returned %d items, want %d (pages requested: %v)", len(items), total, pages)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("requested pages %v, want 3 pages", pages)
+	}
+}
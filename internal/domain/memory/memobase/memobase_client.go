@@ -120,13 +120,19 @@ func deviceIDToUUID(deviceID string) string {
 	return uuid.NewSHA1(deviceNamespace, []byte(deviceID)).String()
 }
 
+// agentMemobaseUserID 智能体在 Memobase 中的用户ID，读写和删除都必须使用这一个映射。
+// 早期版本在调用方和 getUser 中各转换了一次，已有数据都保存在两次转换后的ID下，为兼容保留
+func agentMemobaseUserID(agentID string) string {
+	return deviceIDToUUID(deviceIDToUUID(agentID))
+}
+
 func IsEnableSearch() bool {
 	return clientInstance.EnableSearch
 }
 
 // AddMessage 添加消息到Memobase
 func (m *MemobaseClient) AddMessage(ctx context.Context, agentID string, msg schema.Message) error {
-	memobaseUserID := deviceIDToUUID(agentID)
+	memobaseUserID := agentMemobaseUserID(agentID)
 	// 构建消息
 	messages := []blob.OpenAICompatibleMessage{
 		{
@@ -155,7 +161,7 @@ func (m *MemobaseClient) AddMessage(ctx context.Context, agentID string, msg sch
 	}
 
 	// 获取或创建用户实例（使用UUID格式的userID）
-	user, err := m.getUser(memobaseUserID)
+	user, err := m.getUser(agentID)
 	if err != nil {
 		log.Log().Errorf("获取或创建用户失败, agentID: %s, memobaseUserID: %s, error: %v", agentID, memobaseUserID, err)
 		return fmt.Errorf("获取或创建用户失败: %v", err)
@@ -175,8 +181,8 @@ func (m *MemobaseClient) AddMessage(ctx context.Context, agentID string, msg sch
 }
 
 func (m *MemobaseClient) Flush(ctx context.Context, agentID string) error {
-	memobaseUserID := deviceIDToUUID(agentID)
-	user, err := m.getUser(memobaseUserID)
+	memobaseUserID := agentMemobaseUserID(agentID)
+	user, err := m.getUser(agentID)
 	if err != nil {
 		log.Log().Errorf("刷新用户记忆失败, agentID: %s, memobaseUserID: %s, error: %v", agentID, memobaseUserID, err)
 		return fmt.Errorf("刷新用户记忆失败: %v", err)
@@ -189,10 +195,10 @@ func (m *MemobaseClient) Flush(ctx context.Context, agentID string) error {
 func (m *MemobaseClient) GetContext(ctx context.Context, agentID string, maxToken int) (string, error) {
 
 	// 将设备ID转换为UUID格式（Memobase要求）
-	memobaseUserID := deviceIDToUUID(agentID)
+	memobaseUserID := agentMemobaseUserID(agentID)
 
 	// 获取用户实例（不执行 HTTP GET 请求，只创建实例）
-	user, err := m.getUser(memobaseUserID)
+	user, err := m.getUser(agentID)
 	if err != nil {
 		log.Log().Errorf("获取用户实例失败, agentID: %s, memobaseUserID: %s, error: %v", agentID, memobaseUserID, err)
 		return "", fmt.Errorf("获取用户实例失败: %v", err)
@@ -217,10 +223,10 @@ func (m *MemobaseClient) Search(ctx context.Context, agentID string, query strin
 	}
 	topK = m.SearchTopk
 	// 将设备ID转换为UUID格式（Memobase要求）
-	memobaseUserID := deviceIDToUUID(agentID)
+	memobaseUserID := agentMemobaseUserID(agentID)
 
 	// 获取用户实例（不执行 HTTP GET 请求，只创建实例）
-	user, err := m.getUser(memobaseUserID)
+	user, err := m.getUser(agentID)
 	if err != nil {
 		log.Log().Errorf("获取用户实例失败, agentID: %s, memobaseUserID: %s, error: %v", agentID, memobaseUserID, err)
 		return "", fmt.Errorf("获取用户实例失败: %v", err)
@@ -274,7 +280,7 @@ func (m *MemobaseClient) AddBatchMessages(ctx context.Context, userID string, me
 	}
 
 	// 将设备ID转换为UUID格式（Memobase要求）
-	memobaseUserID := deviceIDToUUID(userID)
+	memobaseUserID := agentMemobaseUserID(userID)
 
 	// 获取或创建用户实例（使用UUID格式的userID）
 	user, err := m.getUser(userID)
@@ -303,11 +309,15 @@ func (m *MemobaseClient) GetMessages(ctx context.Context, agentID string, count
 
 // ResetMemory 重置用户的记忆
 // 实现 MemoryProvider 接口
-// 注意：Memobase 的记忆重置需要通过 API 删除用户数据
+// 通过删除 Memobase 用户清除其全部画像与事件，下次写入时会重新创建用户
 func (m *MemobaseClient) ResetMemory(ctx context.Context, userID string) error {
-	// TODO: 如果 Memobase SDK 提供了删除用户数据的接口，在这里调用
-	// 目前返回 nil 表示操作成功（即使没有实际删除）
-	log.Log().Infof("Memobase 重置记忆请求: userID=%s (注意: Memobase 不支持直接重置)", userID)
+	memobaseUserID := agentMemobaseUserID(userID)
+	if err := m.client.DeleteUser(memobaseUserID); err != nil {
+		log.Log().Errorf("Memobase 重置记忆失败, userID: %s, error: %v", userID, err)
+		return fmt.Errorf("Memobase 重置记忆失败: %v", err)
+	}
+	m.users.Delete(userID)
+	log.Log().Infof("Memobase 重置记忆成功: userID=%s", userID)
 	return nil
 }
 
@@ -317,18 +327,17 @@ func (m *MemobaseClient) Close() error {
 	return nil
 }
 
-// todo 加user对象缓存
-func (m *MemobaseClient) getUser(userID string) (*core.User, error) {
-	if user, ok := m.users.Load(userID); ok {
+// getUser 获取或创建智能体对应的 Memobase 用户，按 agentID 缓存
+func (m *MemobaseClient) getUser(agentID string) (*core.User, error) {
+	if user, ok := m.users.Load(agentID); ok {
 		return user.(*core.User), nil
 	}
 
-	memobaseUserID := deviceIDToUUID(userID)
-	user, err := m.client.GetOrCreateUser(memobaseUserID)
+	user, err := m.client.GetOrCreateUser(agentMemobaseUserID(agentID))
 	if err != nil {
 		return nil, fmt.Errorf("获取用户实例失败: %v", err)
 	}
 
-	m.users.Store(userID, user)
+	m.users.Store(agentID, user)
 	return user, nil
 }
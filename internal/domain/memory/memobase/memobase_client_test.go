@@ -0,0 +1,55 @@
+package memobase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/memodb-io/memobase/src/client/memobase-go/core"
+)
+
+func TestResetMemoryDeletesTheUserThatStoresMemory(t *testing.T) {
+	var mu sync.Mutex
+	var created, deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := strings.TrimPrefix(r.URL.Path, "/api/v1/users/")
+		mu.Lock()
+		switch r.Method {
+		case http.MethodGet:
+			created = append(created, userID)
+		case http.MethodDelete:
+			deleted = append(deleted, userID)
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {}, "errno": 0, "errmsg": ""}`))
+	}))
+	defer server.Close()
+
+	client, err := core.NewMemoBaseClient(server.URL, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &MemobaseClient{client: client}
+
+	if _, err := m.getUser("agent-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ResetMemory(context.Background(), "agent-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := agentMemobaseUserID("agent-1")
+	if len(created) != 1 || created[0] != want {
+		t.Fatalf("user fetched as %v, want %s", created, want)
+	}
+	if len(deleted) != 1 || deleted[0] != want {
+		t.Fatalf("DeleteUser called with %v, want %s", deleted, want)
+	}
+	if _, ok := m.users.Load("agent-1"); ok {
+		t.Fatal("user cache not cleared after reset")
+	}
+}
@@ -0,0 +1,115 @@
+package memobase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	memory_types "xiaozhi-esp32-server-golang/internal/domain/memory/types"
+
+	"github.com/memodb-io/memobase/src/client/memobase-go/core"
+)
+
+// 列出用户画像时的最大 token 数，足够覆盖全部画像条目
+const listProfileMaxTokens = 100000
+
+// ListItems 列出用户画像（profile）条目，每条画像对应一条记忆
+func (m *MemobaseClient) ListItems(ctx context.Context, agentID string) ([]memory_types.MemoryItem, error) {
+	profiles, err := m.listProfiles(agentID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]memory_types.MemoryItem, 0, len(profiles))
+	for _, profile := range profiles {
+		items = append(items, profileToItem(profile))
+	}
+	return items, nil
+}
+
+// SearchItems 按关键词过滤画像条目（Memobase 仅支持对事件做语义检索）
+func (m *MemobaseClient) SearchItems(ctx context.Context, agentID string, query string, topK int) ([]memory_types.MemoryItem, error) {
+	profiles, err := m.listProfiles(agentID)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	items := make([]memory_types.MemoryItem, 0)
+	for _, profile := range profiles {
+		if topK > 0 && len(items) >= topK {
+			break
+		}
+		text := strings.ToLower(profile.Content + " " + profile.Attributes.Topic + " " + profile.Attributes.SubTopic)
+		if query == "" || strings.Contains(text, query) {
+			items = append(items, profileToItem(profile))
+		}
+	}
+	return items, nil
+}
+
+// UpdateItem 修改画像内容，保留原有主题
+func (m *MemobaseClient) UpdateItem(ctx context.Context, agentID string, itemID string, content string) error {
+	profile, user, err := m.findProfile(agentID, itemID)
+	if err != nil {
+		return err
+	}
+	if err := user.UpdateProfile(itemID, content, profile.Attributes.Topic, profile.Attributes.SubTopic); err != nil {
+		return fmt.Errorf("更新Memobase画像失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteItem 删除画像条目
+func (m *MemobaseClient) DeleteItem(ctx context.Context, agentID string, itemID string) error {
+	_, user, err := m.findProfile(agentID, itemID)
+	if err != nil {
+		return err
+	}
+	if err := user.DeleteProfile(itemID); err != nil {
+		return fmt.Errorf("删除Memobase画像失败: %v", err)
+	}
+	return nil
+}
+
+func (m *MemobaseClient) listProfiles(agentID string) ([]core.UserProfileData, error) {
+	user, err := m.getUser(agentID)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户实例失败: %v", err)
+	}
+	profiles, err := user.Profile(&core.ProfileOptions{MaxTokenSize: listProfileMaxTokens})
+	if err != nil {
+		return nil, fmt.Errorf("获取Memobase画像失败: %v", err)
+	}
+	return profiles, nil
+}
+
+// findProfile 在 agent 的画像中查找条目，确保不会操作其他用户的数据
+func (m *MemobaseClient) findProfile(agentID string, itemID string) (*core.UserProfileData, *core.User, error) {
+	user, err := m.getUser(agentID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取用户实例失败: %v", err)
+	}
+	profiles, err := user.Profile(&core.ProfileOptions{MaxTokenSize: listProfileMaxTokens})
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取Memobase画像失败: %v", err)
+	}
+	for i := range profiles {
+		if profiles[i].ID.String() == itemID {
+			return &profiles[i], user, nil
+		}
+	}
+	return nil, nil, memory_types.ErrItemNotFound
+}
+
+func profileToItem(profile core.UserProfileData) memory_types.MemoryItem {
+	category := profile.Attributes.Topic
+	if profile.Attributes.SubTopic != "" {
+		category += "/" + profile.Attributes.SubTopic
+	}
+	return memory_types.MemoryItem{
+		ID:        profile.ID.String(),
+		Content:   profile.Content,
+		Category:  category,
+		CreatedAt: profile.CreatedAt,
+		UpdatedAt: profile.UpdatedAt,
+	}
+}
@@ -0,0 +1,101 @@
+package memos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	memory_types "xiaozhi-esp32-server-golang/internal/domain/memory/types"
+)
+
+// 列出记忆时的最大召回条数（MemOS 没有分页列表接口，使用空 query 检索代替）
+const listItemsLimit = 100
+
+// ListItems 列出 agent 的记忆
+func (c *Client) ListItems(ctx context.Context, agentID string) ([]memory_types.MemoryItem, error) {
+	return c.searchItems(ctx, agentID, "", listItemsLimit, 0)
+}
+
+// SearchItems 检索 agent 的相关记忆，不受 enable_search 配置限制
+func (c *Client) SearchItems(ctx context.Context, agentID string, query string, topK int) ([]memory_types.MemoryItem, error) {
+	if topK <= 0 {
+		topK = listItemsLimit
+	}
+	return c.searchItems(ctx, agentID, query, topK, c.searchThreshold)
+}
+
+// UpdateItem MemOS 文档未提供修改单条记忆的接口
+func (c *Client) UpdateItem(ctx context.Context, agentID string, itemID string, content string) error {
+	return memory_types.ErrNotSupported
+}
+
+// DeleteItem 删除单条记忆
+func (c *Client) DeleteItem(ctx context.Context, agentID string, itemID string) error {
+	payload, err := c.newIdentityPayload(agentID)
+	if err != nil {
+		return err
+	}
+	payload["memory_ids"] = []string{itemID}
+	if _, err := c.requestJSON(ctx, http.MethodPost, "/delete/memory", payload); err != nil {
+		return fmt.Errorf("memos delete_memory failed: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) searchItems(ctx context.Context, agentID string, query string, topK int, threshold float64) ([]memory_types.MemoryItem, error) {
+	payload, err := c.newIdentityPayload(agentID)
+	if err != nil {
+		return nil, err
+	}
+	payload["query"] = query
+	payload["memory_limit_number"] = topK
+	payload["relativity"] = threshold
+	data, err := c.requestJSON(ctx, http.MethodPost, "/search/memory", payload)
+	if err != nil {
+		return nil, fmt.Errorf("memos search failed: %w", err)
+	}
+
+	items := make([]memory_types.MemoryItem, 0)
+	for _, raw := range getSearchItems(data) {
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		content := getStringFromMap(obj, "memory_value", "content", "memory", "text")
+		if content == "" {
+			continue
+		}
+		item := memory_types.MemoryItem{
+			ID:       getStringFromMap(obj, "id", "memory_id"),
+			Content:  content,
+			Category: getStringFromMap(obj, "memory_type", "memory_key"),
+		}
+		if score, ok := obj["relativity"].(float64); ok {
+			item.Score = score
+		}
+		item.CreatedAt = parseTime(obj, "create_time", "created_at")
+		item.UpdatedAt = parseTime(obj, "update_time", "updated_at")
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseTime 兼容毫秒时间戳与 RFC3339 字符串
+func parseTime(data map[string]interface{}, keys ...string) time.Time {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case float64:
+			if v > 1e12 {
+				return time.UnixMilli(int64(v))
+			}
+			return time.Unix(int64(v), 0)
+		case string:
+			if t, err := time.Parse(time.RFC3339, strings.TrimSpace(v)); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotSupported 记忆提供者不支持该操作
+var ErrNotSupported = errors.New("memory provider does not support this operation")
+
+// ErrItemNotFound 记忆条目不存在或不属于该 agent
+var ErrItemNotFound = errors.New("memory item not found")
+
+// MemoryItem 单条长期记忆，供管理后台查看、编辑和删除
+type MemoryItem struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	Category  string    `json:"category,omitempty"` // 分类/主题（如 memobase 的 topic）
+	Score     float64   `json:"score,omitempty"`    // 检索相关度，仅 SearchItems 返回
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
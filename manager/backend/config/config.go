@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 type Config struct {
 	Server         ServerConfig         `json:"server"`
 	Database       DatabaseConfig       `json:"database"`
 	JWT            JWTConfig            `json:"jwt"`
+	OIDC           OIDCConfig           `json:"oidc"`
+	TwoFactor      TwoFactorConfig      `json:"two_factor"`
+	Audit          AuditConfig          `json:"audit"`
 	SpeakerService SpeakerServiceConfig `json:"speaker_service"`
 	Storage        StorageConfig        `json:"storage"`
 	History        HistoryConfig        `json:"history"`
@@ -22,15 +26,22 @@ type ServerConfig struct {
 }
 
 type DatabaseConfig struct {
-	Type   string        `json:"type"`             // "mysql" 或 "sqlite"，决定使用哪种数据库
-	MySQL  *MySQLConfig  `json:"mysql,omitempty"`
-	SQLite *SQLiteConfig `json:"sqlite,omitempty"`
+	Type     string          `json:"type"` // "mysql"、"sqlite" 或 "postgres"，决定使用哪种数据库
+	MySQL    *MySQLConfig    `json:"mysql,omitempty"`
+	SQLite   *SQLiteConfig   `json:"sqlite,omitempty"`
+	Postgres *PostgresConfig `json:"postgres,omitempty"`
+	// ManualMigrate 为 true 时启动不自动执行数据库迁移，有未执行的迁移时拒绝连接数据库，
+	// 需要先用 migrate up 子命令升级
+	ManualMigrate bool `json:"manual_migrate,omitempty"`
 }
 
 // GetStorageType 获取当前配置的存储类型
 func (c *DatabaseConfig) GetStorageType() string {
-	if c.Type == "sqlite" || c.Type == "mysql" {
+	switch c.Type {
+	case "sqlite", "mysql", "postgres":
 		return c.Type
+	case "postgresql", "pgsql":
+		return "postgres"
 	}
 	// 未设置 type 时，根据已有配置推断
 	if c.SQLite != nil {
@@ -51,14 +62,119 @@ type MySQLConfig struct {
 	Database string `json:"database"`
 }
 
+// PostgresConfig PostgreSQL 数据库配置
+type PostgresConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+	SSLMode  string `json:"sslmode,omitempty"`  // disable / require / verify-full 等，默认 disable
+	Schema   string `json:"schema,omitempty"`   // 表所在的 schema，默认 public
+	TimeZone string `json:"timezone,omitempty"` // 会话时区，默认 Asia/Shanghai
+}
+
+// DSN 生成 PostgreSQL 连接串（key=value 形式，值中的空格和引号会被转义）
+func (c *PostgresConfig) DSN() string {
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	timeZone := c.TimeZone
+	if timeZone == "" {
+		timeZone = "Asia/Shanghai"
+	}
+	port := c.Port
+	if port == 0 {
+		port = 5432
+	}
+	parts := []string{
+		"host=" + quoteDSNValue(c.Host),
+		fmt.Sprintf("port=%d", port),
+		"user=" + quoteDSNValue(c.Username),
+		"password=" + quoteDSNValue(c.Password),
+		"dbname=" + quoteDSNValue(c.Database),
+		"sslmode=" + quoteDSNValue(sslMode),
+		"TimeZone=" + quoteDSNValue(timeZone),
+	}
+	if c.Schema != "" {
+		parts = append(parts, "search_path="+quoteDSNValue(c.Schema))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " '\\") {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
 // SQLiteConfig SQLite 数据库配置
 type SQLiteConfig struct {
 	FilePath string `json:"file_path"` // 数据库文件路径，如 ./data/xiaozhi.db
 }
 
 type JWTConfig struct {
-	Secret     string `json:"secret"`
-	ExpireHour int    `json:"expire_hour"`
+	Secret     string `json:"secret"`      // HS256 签名密钥，未配置 keys 时使用（可用环境变量 JWT_SECRET 覆盖），都未配置时自动生成并保存到数据库
+	ExpireHour int    `json:"expire_hour"` // 兼容旧配置：未配置 access_token_minutes 时作为访问令牌有效期
+
+	// 多密钥与轮换：active_kid 用于签发新令牌，其余密钥仅用于校验未过期的旧令牌
+	Keys      []JWTKeyConfig `json:"keys,omitempty"`
+	ActiveKid string         `json:"active_kid,omitempty"`
+
+	AccessTokenMinutes int `json:"access_token_minutes,omitempty"` // 访问令牌有效期（分钟），默认15
+	RefreshTokenHours  int `json:"refresh_token_hours,omitempty"`  // 刷新令牌有效期（小时），默认720
+}
+
+// JWTKeyConfig 单把签名密钥，通过 JWT 头部的 kid 区分
+type JWTKeyConfig struct {
+	Kid            string `json:"kid"`
+	Algorithm      string `json:"algorithm"`                  // HS256 / RS256 / ES256
+	Secret         string `json:"secret,omitempty"`           // HS256 使用
+	PrivateKeyFile string `json:"private_key_file,omitempty"` // RS256/ES256 私钥（PEM），仅校验的旧密钥可不配置
+	PublicKeyFile  string `json:"public_key_file,omitempty"`  // RS256/ES256 公钥（PEM），未配置时由私钥推导
+}
+
+// OIDCConfig 控制台 OpenID Connect 单点登录配置（授权码 + PKCE）
+type OIDCConfig struct {
+	Enabled      bool     `json:"enabled"`
+	ProviderName string   `json:"provider_name,omitempty"` // 登录页按钮显示的名称
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"` // 可用环境变量 OIDC_CLIENT_SECRET 覆盖
+	RedirectURL  string   `json:"redirect_url"`            // 需在 IdP 注册，形如 https://manager.example.com/api/auth/oidc/callback
+	Scopes       []string `json:"scopes,omitempty"`        // 默认 openid profile email
+
+	UsernameClaim string   `json:"username_claim,omitempty"` // 默认 preferred_username，缺失时用 email
+	EmailClaim    string   `json:"email_claim,omitempty"`    // 默认 email
+	GroupsClaim   string   `json:"groups_claim,omitempty"`   // 默认 groups
+	AdminGroups   []string `json:"admin_groups,omitempty"`   // 属于这些组的用户为管理员，其余为普通用户
+	AllowedGroups []string `json:"allowed_groups,omitempty"` // 非空时只允许这些组的用户登录
+	// OrgRoleGroups 组织内角色（org_owner/operator/viewer）对应的组，每次登录同步；
+	// 未配置的角色不由单点登录管理，管理员在控制台分配的该角色登录后保持不变
+	OrgRoleGroups map[string][]string `json:"org_role_groups,omitempty"`
+
+	AutoProvision     *bool `json:"auto_provision,omitempty"`      // 首次登录自动创建用户，默认 true
+	DisableLocalLogin bool  `json:"disable_local_login,omitempty"` // 禁用用户名密码登录与注册
+}
+
+// AutoProvisionEnabled 是否自动创建首次登录的用户
+func (c OIDCConfig) AutoProvisionEnabled() bool {
+	return c.AutoProvision == nil || *c.AutoProvision
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	RetentionDays int `json:"retention_days"` // 审计日志保留天数，0 表示永久保留
+}
+
+// TwoFactorConfig TOTP 两步验证配置
+type TwoFactorConfig struct {
+	Issuer       string `json:"issuer,omitempty"` // 验证器 App 中显示的名称，默认 Xiaozhi
+	EnforceAdmin bool   `json:"enforce_admin"`    // 管理员必须启用两步验证，未启用前只能访问绑定页面
 }
 
 type SpeakerServiceConfig struct {
@@ -107,6 +223,42 @@ func LoadWithPath(configPath string) *Config {
 		}
 	}
 
+	// 使用 PostgreSQL 时同样支持 DB_* 环境变量覆盖
+	if config.Database.GetStorageType() == "postgres" {
+		if config.Database.Postgres == nil {
+			config.Database.Postgres = &PostgresConfig{}
+		}
+		if host := os.Getenv("DB_HOST"); host != "" {
+			config.Database.Postgres.Host = host
+		}
+		if port := os.Getenv("DB_PORT"); port != "" {
+			var p int
+			fmt.Sscanf(port, "%d", &p)
+			config.Database.Postgres.Port = p
+		}
+		if username := os.Getenv("DB_USER"); username != "" {
+			config.Database.Postgres.Username = username
+		}
+		if password := os.Getenv("DB_PASSWORD"); password != "" {
+			config.Database.Postgres.Password = password
+		}
+		if database := os.Getenv("DB_NAME"); database != "" {
+			config.Database.Postgres.Database = database
+		}
+		if sslMode := os.Getenv("DB_SSLMODE"); sslMode != "" {
+			config.Database.Postgres.SSLMode = sslMode
+		}
+	}
+
+	// 优先使用环境变量覆盖 JWT 密钥，避免密钥写在配置文件中
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		config.JWT.Secret = secret
+	}
+
+	if secret := os.Getenv("OIDC_CLIENT_SECRET"); secret != "" {
+		config.OIDC.ClientSecret = secret
+	}
+
 	// 优先使用环境变量覆盖声纹服务配置
 	if serviceURL := os.Getenv("SPEAKER_SERVICE_URL"); serviceURL != "" {
 		config.SpeakerService.URL = serviceURL
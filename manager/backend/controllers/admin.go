@@ -21,12 +21,12 @@ import (
 	"strings"
 	"time"
 
+	"xiaozhi/manager/backend/middleware"
 	"xiaozhi/manager/backend/models"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
@@ -104,6 +104,7 @@ func (ac *AdminController) GetDeviceConfigs(c *gin.Context) {
 		MemoryMode      string                      `json:"memory_mode"`
 		MCPServiceNames string                      `json:"mcp_service_names"`
 		OpenClaw        OpenClawConfigResponse      `json:"openclaw"`
+		MCPToolPolicy   MCPToolPolicyConfig         `json:"mcp_tool_policy"`
 		ConfigSource    string                      `json:"config_source"` // 新增：配置来源
 	}
 
@@ -114,6 +115,7 @@ func (ac *AdminController) GetDeviceConfigs(c *gin.Context) {
 		EnterKeywords: []string{},
 		ExitKeywords:  []string{},
 	}
+	response.MCPToolPolicy = emptyMCPToolPolicy()
 	var configSource string // 记录配置来源
 
 	// 查找设备
@@ -154,6 +156,7 @@ func (ac *AdminController) GetDeviceConfigs(c *gin.Context) {
 		response.MemoryMode = normalizeAgentMemoryMode(agent.MemoryMode)
 		response.MCPServiceNames = normalizeMCPServiceNamesCSV(agent.MCPServiceNames)
 		response.OpenClaw = buildOpenClawConfigFromAgent(agent)
+		response.MCPToolPolicy = buildMCPToolPolicyFromAgent(agent)
 	}
 
 	cloneVoiceCache := make(map[string]bool)
@@ -1940,7 +1943,7 @@ func generateMQTTPassword(deviceID, signatureKey string) string {
 // GetConfigs 获取所有配置列表
 func (ac *AdminController) GetConfigs(c *gin.Context) {
 	var configs []models.Config
-	if err := ac.DB.Find(&configs).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).Find(&configs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取配置列表失败"})
 		return
 	}
@@ -1951,7 +1954,7 @@ func (ac *AdminController) GetConfigs(c *gin.Context) {
 func (ac *AdminController) GetConfig(c *gin.Context) {
 	id := c.Param("id")
 	var config models.Config
-	if err := ac.DB.First(&config, id).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).First(&config, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
 		} else {
@@ -1988,6 +1991,10 @@ func (ac *AdminController) CreateConfig(c *gin.Context) {
 	if existingCount == 0 {
 		config.IsDefault = true
 	}
+	if err := assignConfigTenant(c, &config); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 如果设置为默认配置，先取消其他同类型的默认配置
 	if config.IsDefault {
@@ -1998,6 +2005,7 @@ func (ac *AdminController) CreateConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, "", &config, "创建")
 
 	ac.notifySystemConfigChanged()
 	c.JSON(http.StatusCreated, gin.H{"data": config})
@@ -2007,16 +2015,21 @@ func (ac *AdminController) UpdateConfig(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var config models.Config
 
-	if err := ac.DB.First(&config, id).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).First(&config, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		return
 	}
+	before := versionSnapshot(&config)
 
 	var updateData models.Config
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 默认配置是平台级的，组织配置不能设为默认
+	if config.OrgID != nil {
+		updateData.IsDefault = false
+	}
 
 	// 如果设置为默认配置，先取消其他同类型的默认配置
 	if updateData.IsDefault {
@@ -2034,6 +2047,7 @@ func (ac *AdminController) UpdateConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, before, &config, "")
 
 	ac.notifySystemConfigChanged()
 	c.JSON(http.StatusOK, gin.H{"data": config})
@@ -2041,7 +2055,7 @@ func (ac *AdminController) UpdateConfig(c *gin.Context) {
 
 func (ac *AdminController) DeleteConfig(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	if err := ac.DB.Delete(&models.Config{}, id).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).Delete(&models.Config{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除配置失败"})
 		return
 	}
@@ -2145,7 +2159,7 @@ func (ac *AdminController) DeleteGlobalRole(c *gin.Context) {
 // 用户管理
 func (ac *AdminController) GetUsers(c *gin.Context) {
 	var users []models.User
-	if err := ac.DB.Find(&users).Error; err != nil {
+	if err := ac.DB.Scopes(usersInTenant(c)).Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户列表失败"})
 		return
 	}
@@ -2186,6 +2200,18 @@ func (ac *AdminController) CreateUser(c *gin.Context) {
 	requestData.Password = password
 	requestData.Role = role
 
+	// 角色和所属组织：组织管理员创建的用户固定属于本组织
+	orgID, err := orgIDFromJSON(rawMap["org_id"])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requestData.Role, orgID, err = resolveUserTenant(c, ac.DB, requestData.Role, orgID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 验证必要字段
 	if requestData.Username == "" || requestData.Email == "" || requestData.Password == "" {
 		log.Printf("[CreateUser] 缺少必要字段: username=%s, email=%s, password长度=%d",
@@ -2200,7 +2226,7 @@ func (ac *AdminController) CreateUser(c *gin.Context) {
 
 	// 检查用户名是否已存在
 	var existingUser models.User
-	err := ac.DB.Where("username = ?", requestData.Username).First(&existingUser).Error
+	err = ac.DB.Where("username = ?", requestData.Username).First(&existingUser).Error
 	if err == nil {
 		// 用户名已存在
 		log.Printf("[CreateUser] 用户名 %s 已存在", requestData.Username)
@@ -2219,6 +2245,7 @@ func (ac *AdminController) CreateUser(c *gin.Context) {
 	user.Username = requestData.Username
 	user.Email = requestData.Email
 	user.Role = requestData.Role
+	user.OrgID = orgID
 
 	// 加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(requestData.Password), bcrypt.DefaultCost)
@@ -2247,7 +2274,7 @@ func (ac *AdminController) UpdateUser(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var user models.User
 
-	if err := ac.DB.First(&user, id).Error; err != nil {
+	if err := ac.DB.Scopes(usersInTenant(c)).First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		return
 	}
@@ -2258,7 +2285,45 @@ func (ac *AdminController) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// 如果更新密码，需要加密
+	// 组织管理员只能修改基本信息、密码和组织内角色
+	if _, platform := tenantOf(c); !platform {
+		for key := range updateData {
+			if key != "username" && key != "email" && key != "password" && key != "role" {
+				delete(updateData, key)
+			}
+		}
+	}
+	_, hasRole := updateData["role"]
+	rawOrgID, hasOrgID := updateData["org_id"]
+	if hasRole || hasOrgID {
+		role := user.Role
+		if v, ok := updateData["role"].(string); ok {
+			role = v
+		}
+		orgID := user.OrgID
+		if hasOrgID {
+			parsed, err := orgIDFromJSON(rawOrgID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			orgID = parsed
+		}
+		role, orgID, err := resolveUserTenant(c, ac.DB, role, orgID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updateData["role"] = role
+		if orgID != nil {
+			updateData["org_id"] = *orgID
+		} else {
+			updateData["org_id"] = nil
+		}
+	}
+
+	// 如果更新密码，需要加密，并使该用户已登录的会话失效
+	passwordChanged := false
 	if password, ok := updateData["password"]; ok && password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password.(string)), bcrypt.DefaultCost)
 		if err != nil {
@@ -2266,12 +2331,20 @@ func (ac *AdminController) UpdateUser(c *gin.Context) {
 			return
 		}
 		updateData["password"] = string(hashedPassword)
+		passwordChanged = true
 	}
+	// token_version 只能通过注销会话递增
+	delete(updateData, "token_version")
 
 	if err := ac.DB.Model(&user).Updates(updateData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户失败"})
 		return
 	}
+	if passwordChanged {
+		if err := middleware.RevokeUserSessions(ac.DB, user.ID); err != nil {
+			log.Printf("[UpdateUser] 注销用户会话失败: %v", err)
+		}
+	}
 
 	// 重新查询用户信息（不包含密码）
 	ac.DB.First(&user, id)
@@ -2281,10 +2354,15 @@ func (ac *AdminController) UpdateUser(c *gin.Context) {
 
 func (ac *AdminController) DeleteUser(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	if !ensureTenantUser(c, ac.DB, uint(id)) {
+		return
+	}
 	if err := ac.DB.Delete(&models.User{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
 		return
 	}
+	ac.DB.Model(&models.UserSession{}).Where("user_id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	ac.DB.Where("user_id = ?", id).Delete(&models.APIKey{})
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
@@ -2303,7 +2381,7 @@ func (ac *AdminController) ResetUserPassword(c *gin.Context) {
 
 	// 查找用户
 	var user models.User
-	if err := ac.DB.First(&user, id).Error; err != nil {
+	if err := ac.DB.Scopes(usersInTenant(c)).First(&user, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		} else {
@@ -2327,6 +2405,11 @@ func (ac *AdminController) ResetUserPassword(c *gin.Context) {
 		return
 	}
 
+	// 重置密码后该用户已登录的会话全部失效
+	if err := middleware.RevokeUserSessions(ac.DB, user.ID); err != nil {
+		log.Printf("[ResetUserPassword] 注销用户会话失败: %v", err)
+	}
+
 	log.Printf("[ResetUserPassword] 管理员重置用户密码成功 - 用户ID: %d, 用户名: %s", user.ID, user.Username)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "密码重置成功",
@@ -2337,6 +2420,43 @@ func (ac *AdminController) ResetUserPassword(c *gin.Context) {
 	})
 }
 
+// 注销用户全部会话（强制重新登录）
+func (ac *AdminController) RevokeUserSessions(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var user models.User
+	if err := ac.DB.Scopes(usersInTenant(c)).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if err := middleware.RevokeUserSessions(ac.DB, user.ID); err != nil {
+		log.Printf("[RevokeUserSessions] 注销用户会话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+		return
+	}
+	log.Printf("[RevokeUserSessions] 管理员注销用户会话 - 用户ID: %d, 用户名: %s", user.ID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "已注销该用户的全部会话"})
+}
+
+// ResetUserTwoFactor 重置用户的两步验证（用户丢失验证器时使用），并注销其全部会话
+func (ac *AdminController) ResetUserTwoFactor(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var user models.User
+	if err := ac.DB.Scopes(usersInTenant(c)).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if err := clearTwoFactor(ac.DB, user.ID); err != nil {
+		log.Printf("[ResetUserTwoFactor] 重置两步验证失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置两步验证失败"})
+		return
+	}
+	if err := middleware.RevokeUserSessions(ac.DB, user.ID); err != nil {
+		log.Printf("[ResetUserTwoFactor] 注销用户会话失败: %v", err)
+	}
+	log.Printf("[ResetUserTwoFactor] 管理员重置两步验证 - 用户ID: %d, 用户名: %s", user.ID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "已重置该用户的两步验证"})
+}
+
 // GetUserVoiceCloneQuotas 获取用户声音复刻额度（按 tts_config_id 维度）
 func (ac *AdminController) GetUserVoiceCloneQuotas(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -2608,7 +2728,7 @@ func (ac *AdminController) UpdateUserVoiceCloneQuotas(c *gin.Context) {
 // 设备管理
 func (ac *AdminController) GetDevices(c *gin.Context) {
 	var devices []models.Device
-	if err := ac.DB.Find(&devices).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Find(&devices).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备列表失败"})
 		return
 	}
@@ -2625,6 +2745,11 @@ func (ac *AdminController) ValidateDeviceCode(c *gin.Context) {
 
 	var device models.Device
 	err := ac.DB.Where("device_code = ?", deviceCode).First(&device).Error
+	// 其他组织的设备对组织内角色只返回存在与否
+	if err == nil && device.UserID != 0 && !userInTenant(c, ac.DB, device.UserID) {
+		c.JSON(http.StatusOK, gin.H{"exists": true})
+		return
+	}
 
 	if err == gorm.ErrRecordNotFound {
 		c.JSON(http.StatusOK, gin.H{"exists": false})
@@ -2656,15 +2781,24 @@ func (ac *AdminController) CreateDevice(c *gin.Context) {
 
 	// 检查用户是否存在
 	var user models.User
-	if err := ac.DB.First(&user, req.UserID).Error; err != nil {
+	if err := ac.DB.Scopes(usersInTenant(c)).First(&user, req.UserID).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的用户不存在"})
 		return
 	}
+	if req.AgentID != 0 && !agentInTenant(c, ac.DB, req.AgentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的智能体不存在"})
+		return
+	}
 
 	// 如果提供了激活码，先查找现有设备
 	if req.DeviceCode != "" {
 		var existingDevice models.Device
 		if err := ac.DB.Where("device_code = ?", req.DeviceCode).First(&existingDevice).Error; err == nil {
+			// 不能接管其他组织已绑定的设备
+			if existingDevice.UserID != 0 && !userInTenant(c, ac.DB, existingDevice.UserID) {
+				c.JSON(http.StatusConflict, gin.H{"error": "该激活码已被其他组织的设备使用"})
+				return
+			}
 			// 设备代码已存在，更新设备信息
 			existingDevice.UserID = req.UserID
 			if req.DeviceName != "" {
@@ -2715,7 +2849,7 @@ func (ac *AdminController) UpdateDevice(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var device models.Device
 
-	if err := ac.DB.First(&device, id).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).First(&device, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
 		return
 	}
@@ -2732,6 +2866,14 @@ func (ac *AdminController) UpdateDevice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !userInTenant(c, ac.DB, updateData.UserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的用户不存在"})
+		return
+	}
+	if updateData.AgentID != 0 && !agentInTenant(c, ac.DB, updateData.AgentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的智能体不存在"})
+		return
+	}
 
 	// 更新设备信息
 	device.UserID = updateData.UserID
@@ -2750,7 +2892,7 @@ func (ac *AdminController) UpdateDevice(c *gin.Context) {
 
 func (ac *AdminController) DeleteDevice(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	if err := ac.DB.Delete(&models.Device{}, id).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Delete(&models.Device{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除设备失败"})
 		return
 	}
@@ -2760,7 +2902,7 @@ func (ac *AdminController) DeleteDevice(c *gin.Context) {
 // 智能体管理
 func (ac *AdminController) GetAgents(c *gin.Context) {
 	var agents []models.Agent
-	if err := ac.DB.Find(&agents).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Find(&agents).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取智能体列表失败"})
 		return
 	}
@@ -2807,7 +2949,7 @@ func (ac *AdminController) GetDeviceMcpTools(c *gin.Context) {
 	}
 
 	var device models.Device
-	if err := ac.DB.Where("id = ?", deviceID).First(&device).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Where("id = ?", deviceID).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
 		return
 	}
@@ -2834,7 +2976,7 @@ func (ac *AdminController) CallAgentMcpTool(c *gin.Context) {
 	}
 
 	var agent models.Agent
-	if err := ac.DB.Where("id = ?", agentID).First(&agent).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Where("id = ?", agentID).First(&agent).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
 		return
 	}
@@ -2866,7 +3008,7 @@ func (ac *AdminController) CallDeviceMcpTool(c *gin.Context) {
 	}
 
 	var device models.Device
-	if err := ac.DB.Where("id = ?", deviceID).First(&device).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Where("id = ?", deviceID).First(&device).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
 		return
 	}
@@ -2892,6 +3034,10 @@ func (ac *AdminController) GetAgentMCPEndpoint(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id parameter is required"})
 		return
 	}
+	if !agentInTenant(c, ac.DB, agentID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return
+	}
 
 	// 从JWT中间件获取当前用户ID
 	userIDInterface, exists := c.Get("user_id")
@@ -2911,9 +3057,13 @@ func (ac *AdminController) GetAgentMCPEndpoint(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	serverEndpoint, err := GenerateAgentMCPServerEndpoint(ac.DB, agentID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	// 返回单个endpoint字符串
-	c.JSON(http.StatusOK, gin.H{"data": gin.H{"endpoint": endpoint}})
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"endpoint": endpoint, "server_endpoint": serverEndpoint}})
 }
 
 // GetAgentOpenClawEndpoint 获取智能体的OpenClaw接入点URL
@@ -2923,6 +3073,10 @@ func (ac *AdminController) GetAgentOpenClawEndpoint(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id parameter is required"})
 		return
 	}
+	if !agentInTenant(c, ac.DB, agentID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return
+	}
 
 	// 从JWT中间件获取当前用户ID
 	userIDInterface, exists := c.Get("user_id")
@@ -3007,7 +3161,7 @@ func (ac *AdminController) CallAgentOpenClawChatTest(c *gin.Context) {
 	}
 
 	var agent models.Agent
-	if err := ac.DB.Where("id = ?", agentID).First(&agent).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Where("id = ?", agentID).First(&agent).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
 		return
 	}
@@ -3045,10 +3199,10 @@ func (ac *AdminController) CallAgentOpenClawChatTest(c *gin.Context) {
 func (ac *AdminController) GetAgentMcpTools(c *gin.Context) {
 	agentID := c.Param("id")
 
-	// 管理员验证函数：验证智能体是否存在（管理员可以查看任意用户的智能体）
+	// 管理员验证函数：验证智能体是否存在（平台管理员可以查看任意用户的智能体，组织内角色限本组织）
 	adminAgentValidator := func(agentID string) error {
 		var agent models.Agent
-		if err := ac.DB.Where("id = ?", agentID).First(&agent).Error; err != nil {
+		if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Where("id = ?", agentID).First(&agent).Error; err != nil {
 			return fmt.Errorf("智能体不存在")
 		}
 		return nil
@@ -3068,6 +3222,7 @@ func (ac *AdminController) CreateAgent(c *gin.Context) {
 	var openClawPayload struct {
 		OpenClaw       *OpenClawConfigResponse `json:"openclaw"`
 		OpenClawConfig *string                 `json:"openclaw_config"`
+		MCPToolPolicy  *MCPToolPolicyConfig    `json:"mcp_tool_policy"`
 	}
 	if err := c.ShouldBindBodyWith(&openClawPayload, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -3096,10 +3251,29 @@ func (ac *AdminController) CreateAgent(c *gin.Context) {
 	}
 	applyOpenClawConfigToAgent(&agent, openClawCfg)
 
+	toolPolicy := parseMCPToolPolicy(agent.MCPToolPolicyConfig)
+	if openClawPayload.MCPToolPolicy != nil {
+		toolPolicy = *openClawPayload.MCPToolPolicy
+	}
+	if err := applyMCPToolPolicyToAgent(&agent, toolPolicy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !userInTenant(c, ac.DB, agent.UserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的用户不存在"})
+		return
+	}
+	if err := validateAgentConfigRefs(ac.DB, agent.UserID, agent.LLMConfigID, agent.TTSConfigID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := ac.DB.Create(&agent).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建智能体失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityAgent, agent.ID, "", &agent, "创建")
 
 	c.JSON(http.StatusCreated, gin.H{"data": agent})
 }
@@ -3108,10 +3282,11 @@ func (ac *AdminController) UpdateAgent(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var agent models.Agent
 
-	if err := ac.DB.First(&agent, id).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).First(&agent, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
 		return
 	}
+	before := versionSnapshot(&agent)
 	currentOpenClawCfg := buildOpenClawConfigFromAgent(agent)
 
 	if err := c.ShouldBindBodyWith(&agent, binding.JSON); err != nil {
@@ -3122,6 +3297,7 @@ func (ac *AdminController) UpdateAgent(c *gin.Context) {
 	var openClawPayload struct {
 		OpenClaw       *OpenClawConfigResponse `json:"openclaw"`
 		OpenClawConfig *string                 `json:"openclaw_config"`
+		MCPToolPolicy  *MCPToolPolicyConfig    `json:"mcp_tool_policy"`
 	}
 	if err := c.ShouldBindBodyWith(&openClawPayload, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -3150,17 +3326,34 @@ func (ac *AdminController) UpdateAgent(c *gin.Context) {
 	}
 	applyOpenClawConfigToAgent(&agent, openClawCfg)
 
+	if openClawPayload.MCPToolPolicy != nil {
+		if err := applyMCPToolPolicyToAgent(&agent, *openClawPayload.MCPToolPolicy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !userInTenant(c, ac.DB, agent.UserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的用户不存在"})
+		return
+	}
+	if err := validateAgentConfigRefs(ac.DB, agent.UserID, agent.LLMConfigID, agent.TTSConfigID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := ac.DB.Save(&agent).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新智能体失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityAgent, agent.ID, before, &agent, "")
 
 	c.JSON(http.StatusOK, gin.H{"data": agent})
 }
 
 func (ac *AdminController) DeleteAgent(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	if err := ac.DB.Delete(&models.Agent{}, id).Error; err != nil {
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Delete(&models.Agent{}, id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除智能体失败"})
 		return
 	}
@@ -3226,7 +3419,7 @@ func (ac *AdminController) DeleteASRConfig(c *gin.Context) {
 // LLM配置管理（兼容前端）
 func (ac *AdminController) GetLLMConfigs(c *gin.Context) {
 	var configs []models.Config
-	if err := ac.DB.Where("type = ?", "llm").Find(&configs).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).Where("type = ?", "llm").Find(&configs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get LLM configs"})
 		return
 	}
@@ -3254,7 +3447,7 @@ func (ac *AdminController) DeleteLLMConfig(c *gin.Context) {
 // TTS配置管理（兼容前端）
 func (ac *AdminController) GetTTSConfigs(c *gin.Context) {
 	var configs []models.Config
-	if err := ac.DB.Where("type = ?", "tts").Find(&configs).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).Where("type = ?", "tts").Find(&configs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get TTS configs"})
 		return
 	}
@@ -3311,6 +3504,7 @@ func (ac *AdminController) UpdateSpeakerConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		return
 	}
+	before := versionSnapshot(&config)
 
 	var updateData models.Config
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -3337,6 +3531,7 @@ func (ac *AdminController) UpdateSpeakerConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, before, &config, "")
 
 	c.JSON(http.StatusOK, gin.H{"data": config})
 }
@@ -3732,7 +3927,7 @@ func (ac *AdminController) ToggleConfigEnable(c *gin.Context) {
 	}
 
 	var config models.Config
-	if err := ac.DB.First(&config, id).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).First(&config, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		} else {
@@ -3769,6 +3964,10 @@ func (ac *AdminController) createConfigWithType(c *gin.Context, config *models.C
 		safeName := strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(config.Name, " ", "_"), "-", "_"))
 		config.ConfigID = fmt.Sprintf("%s_%s_%d", config.Type, safeName, timestamp)
 	}
+	if err := assignConfigTenant(c, config); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 如果设置为默认配置，先取消其他同类型的默认配置
 	if config.IsDefault {
@@ -3779,6 +3978,7 @@ func (ac *AdminController) createConfigWithType(c *gin.Context, config *models.C
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, "", config, "创建")
 
 	ac.notifySystemConfigChanged()
 	c.JSON(http.StatusCreated, gin.H{"data": *config})
@@ -3798,16 +3998,21 @@ func (ac *AdminController) updateConfigWithType(c *gin.Context, configType strin
 	id, _ := strconv.Atoi(c.Param("id"))
 	var config models.Config
 
-	if err := ac.DB.Where("id = ? AND type = ?", id, configType).First(&config).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).Where("id = ? AND type = ?", id, configType).First(&config).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
 		return
 	}
+	before := versionSnapshot(&config)
 
 	var updateData configUpdateBody
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 默认配置是平台级的，组织配置不能设为默认
+	if config.OrgID != nil {
+		updateData.IsDefault = false
+	}
 
 	// 如果设置为默认配置，先取消其他同类型的默认配置
 	if updateData.IsDefault {
@@ -3844,6 +4049,7 @@ func (ac *AdminController) updateConfigWithType(c *gin.Context, configType strin
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新配置失败: " + err.Error()})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, before, &config, "")
 
 	ac.notifySystemConfigChanged()
 	c.JSON(http.StatusOK, gin.H{"data": config})
@@ -3851,7 +4057,7 @@ func (ac *AdminController) updateConfigWithType(c *gin.Context, configType strin
 
 func (ac *AdminController) deleteConfigWithType(c *gin.Context, configType string) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	if err := ac.DB.Where("id = ? AND type = ?", id, configType).Delete(&models.Config{}).Error; err != nil {
+	if err := ac.DB.Scopes(configsInTenant(c)).Where("id = ? AND type = ?", id, configType).Delete(&models.Config{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除配置失败"})
 		return
 	}
@@ -4626,6 +4832,7 @@ func (ac *AdminController) CreateMCPConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建MCP配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, "", &config, "创建")
 	ac.notifySystemConfigChanged()
 	c.JSON(http.StatusCreated, gin.H{"data": config})
 }
@@ -4638,6 +4845,7 @@ func (ac *AdminController) UpdateMCPConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "MCP配置不存在"})
 		return
 	}
+	before := versionSnapshot(&config)
 
 	var updateData models.Config
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -4655,6 +4863,10 @@ func (ac *AdminController) UpdateMCPConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新MCP配置失败"})
 		return
 	}
+	var saved models.Config
+	if ac.DB.First(&saved, config.ID).Error == nil {
+		recordVersion(ac.DB, c, versionEntityConfig, saved.ID, before, &saved, "")
+	}
 	ac.notifySystemConfigChanged()
 	c.JSON(http.StatusOK, gin.H{"data": config})
 }
@@ -4769,6 +4981,57 @@ func GenerateAgentOpenClawEndpoint(db *gorm.DB, agentID string, userID uint) (st
 	return endpointWithToken, nil
 }
 
+// GenerateAgentMCPServerEndpoint 生成智能体MCP服务器（streamable-HTTP）的接入点，供外部MCP客户端调用智能体和设备
+func GenerateAgentMCPServerEndpoint(db *gorm.DB, agentID string, userID uint) (string, error) {
+	var otaConfig models.Config
+	if err := db.Where("type = ? AND is_default = ?", "ota", true).First(&otaConfig).Error; err != nil {
+		return "", fmt.Errorf("failed to get OTA config: %v", err)
+	}
+
+	var otaData map[string]interface{}
+	if err := json.Unmarshal([]byte(otaConfig.JsonData), &otaData); err != nil {
+		return "", fmt.Errorf("failed to parse OTA config: %v", err)
+	}
+
+	externalURL, ok := otaData["external"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("external config not found in OTA config")
+	}
+
+	websocketConfig, ok := externalURL["websocket"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("websocket config not found in external config")
+	}
+
+	wsURL, ok := websocketConfig["url"].(string)
+	if !ok || wsURL == "" {
+		return "", fmt.Errorf("websocket URL not found in external config")
+	}
+
+	parsedURL, err := url.Parse(wsURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse WebSocket URL: %v", err)
+	}
+
+	// MCP服务器使用HTTP协议，ws对应http，wss对应https
+	scheme := "http"
+	if parsedURL.Scheme == "wss" || parsedURL.Scheme == "https" {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s", scheme, parsedURL.Host)
+
+	var agent models.Agent
+	if err := db.Select("id", "mcp_server_token_version").Where("id = ?", agentID).First(&agent).Error; err != nil {
+		return "", fmt.Errorf("failed to get agent: %v", err)
+	}
+	token, err := generateAgentMCPServerToken(agentID, userID, agent.MCPServerTokenVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate agent MCP server token: %v", err)
+	}
+
+	return fmt.Sprintf("%s/xiaozhi/agent/mcp?token=%s", baseURL, token), nil
+}
+
 // Memory配置管理
 func (ac *AdminController) GetMemoryConfigs(c *gin.Context) {
 	var configs []models.Config
@@ -4790,8 +5053,8 @@ func (ac *AdminController) CreateMemoryConfig(c *gin.Context) {
 	config.Type = "memory"
 
 	// 验证provider字段
-	if config.Provider != "memobase" && config.Provider != "mem0" && config.Provider != "memos" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provider必须是memobase、mem0或memos"})
+	if config.Provider != "memobase" && config.Provider != "mem0" && config.Provider != "memos" && config.Provider != "local" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provider必须是memobase、mem0、memos或local"})
 		return
 	}
 
@@ -4804,6 +5067,7 @@ func (ac *AdminController) CreateMemoryConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建Memory配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, "", &config, "创建")
 
 	c.JSON(http.StatusCreated, gin.H{"data": config})
 }
@@ -4816,6 +5080,7 @@ func (ac *AdminController) UpdateMemoryConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Memory配置不存在"})
 		return
 	}
+	before := versionSnapshot(&config)
 
 	var updateData models.Config
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -4824,8 +5089,8 @@ func (ac *AdminController) UpdateMemoryConfig(c *gin.Context) {
 	}
 
 	// 验证provider字段
-	if updateData.Provider != "memobase" && updateData.Provider != "mem0" && updateData.Provider != "memos" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Provider必须是memobase、mem0或memos"})
+	if updateData.Provider != "memobase" && updateData.Provider != "mem0" && updateData.Provider != "memos" && updateData.Provider != "local" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provider必须是memobase、mem0、memos或local"})
 		return
 	}
 
@@ -4845,6 +5110,7 @@ func (ac *AdminController) UpdateMemoryConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新Memory配置失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityConfig, config.ID, before, &config, "")
 
 	c.JSON(http.StatusOK, gin.H{"data": config})
 }
@@ -4881,70 +5147,37 @@ func (ac *AdminController) SetDefaultMemoryConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "设置默认Memory配置成功", "data": config})
 }
 
-// generateMCPToken 生成稳定的MCP JWT Token（同一agentID+userID下保持不变）
+// generateMCPToken 生成设备MCP接入点的JWT Token，使用管理后台的签名密钥，主程序向管理后台校验。
+// 接入点URL通常长期配置在外部MCP服务中，因此不设置过期时间
 func generateMCPToken(agentID string, userID uint) (string, error) {
-	// 创建自定义的JWT Claims
-	type MCPClaims struct {
-		UserID     uint   `json:"userId"`
-		AgentID    string `json:"agentId"`
-		EndpointID string `json:"endpointId"`
-		Purpose    string `json:"purpose"`
-		jwt.RegisteredClaims
-	}
-
-	// 构建endpointId
-	endpointID := fmt.Sprintf("agent_%s", agentID)
-
-	// 创建JWT claims。
-	// 不设置iat/exp，保证token长期有效且同一agentID+userID生成结果稳定一致。
-	claims := MCPClaims{
-		UserID:           userID,
-		AgentID:          agentID,
-		EndpointID:       endpointID,
-		Purpose:          "mcp-endpoint",
-		RegisteredClaims: jwt.RegisteredClaims{},
-	}
-
-	// 使用HS256算法生成JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// 使用与middleware相同的密钥
-	jwtSecret := []byte("xiaozhi_admin_secret_key")
-	tokenString, err := token.SignedString(jwtSecret)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	return middleware.SignEndpointToken(middleware.EndpointClaims{
+		UserID:     userID,
+		AgentID:    agentID,
+		EndpointID: fmt.Sprintf("agent_%s", agentID),
+		Purpose:    mcpEndpointTokenPurpose,
+	}, 0)
 }
 
-// generateOpenClawToken 生成稳定的OpenClaw JWT Token（同一agentID+userID下保持不变）
+// generateOpenClawToken 生成OpenClaw接入点的JWT Token，使用管理后台的签名密钥，主程序向管理后台校验
 func generateOpenClawToken(agentID string, userID uint) (string, error) {
-	type OpenClawClaims struct {
-		UserID     uint   `json:"user_id"`
-		AgentID    string `json:"agent_id"`
-		EndpointID string `json:"endpoint_id"`
-		Purpose    string `json:"purpose"`
-		jwt.RegisteredClaims
-	}
-
-	endpointID := fmt.Sprintf("agent_%s", agentID)
-	claims := OpenClawClaims{
-		UserID:           userID,
-		AgentID:          agentID,
-		EndpointID:       endpointID,
-		Purpose:          "openclaw-endpoint",
-		RegisteredClaims: jwt.RegisteredClaims{},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	jwtSecret := []byte("xiaozhi_admin_secret_key")
-	tokenString, err := token.SignedString(jwtSecret)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	return middleware.SignEndpointToken(middleware.EndpointClaims{
+		UserID:     userID,
+		AgentID:    agentID,
+		EndpointID: fmt.Sprintf("agent_%s", agentID),
+		Purpose:    openClawEndpointTokenPurpose,
+	}, 0)
+}
+
+// generateAgentMCPServerToken 生成智能体MCP服务器的JWT Token，使用管理后台的签名密钥，
+// 带有效期和智能体当前的令牌版本，重置接入点后旧令牌由主程序向管理后台校验时拒绝
+func generateAgentMCPServerToken(agentID string, userID uint, version int) (string, error) {
+	return middleware.SignEndpointToken(middleware.EndpointClaims{
+		UserID:     userID,
+		AgentID:    agentID,
+		EndpointID: fmt.Sprintf("agent_%s", agentID),
+		Purpose:    agentMCPServerTokenPurpose,
+		Version:    version,
+	}, agentMCPServerTokenTTL)
 }
 
 // ==================== 新角色管理 API ====================
@@ -5102,6 +5335,7 @@ func (ac *AdminController) CreateRoleNew(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityRole, role.ID, "", &role, "创建")
 
 	c.JSON(http.StatusCreated, gin.H{"data": role})
 }
@@ -5136,6 +5370,7 @@ func (ac *AdminController) UpdateRoleNew(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权修改此角色"})
 		return
 	}
+	before := versionSnapshot(&role)
 
 	var updateData models.Role
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -5179,6 +5414,7 @@ func (ac *AdminController) UpdateRoleNew(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
 		return
 	}
+	recordVersion(ac.DB, c, versionEntityRole, role.ID, before, &role, "")
 
 	c.JSON(http.StatusOK, gin.H{"data": role})
 }
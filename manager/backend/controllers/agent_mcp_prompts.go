@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MCPPromptWebSocketInterface MCP提示词请求所需的WebSocket能力
+type MCPPromptWebSocketInterface interface {
+	RequestMcpPromptsFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+	GetMcpPromptFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+}
+
+// agentMCPPromptHandler 智能体可选的MCP提示词片段，管理员与普通用户共用，权限由调用方校验
+type agentMCPPromptHandler struct {
+	ws MCPPromptWebSocketInterface
+}
+
+// list 列出智能体已选全局MCP服务与接入点提供的提示词；主程序不可用时返回空列表
+func (h agentMCPPromptHandler) list(c *gin.Context, agent models.Agent) {
+	if h.ws == nil {
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"prompts": []interface{}{}}})
+		return
+	}
+	result, err := h.ws.RequestMcpPromptsFromClient(context.Background(), map[string]interface{}{
+		"agent_id":          fmt.Sprintf("%d", agent.ID),
+		"mcp_service_names": normalizeMCPServiceNamesCSV(agent.MCPServiceNames),
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"prompts": []interface{}{}}})
+		return
+	}
+	prompts, ok := result["prompts"]
+	if !ok || prompts == nil {
+		prompts = []interface{}{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"prompts": prompts}})
+}
+
+// render 按参数获取提示词内容，供前端插入到智能体提示词中
+func (h agentMCPPromptHandler) render(c *gin.Context, agent models.Agent) {
+	var req struct {
+		ServerName string            `json:"server_name" binding:"required"`
+		Name       string            `json:"name" binding:"required"`
+		Arguments  map[string]string `json:"arguments"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if h.ws == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "websocket controller unavailable"})
+		return
+	}
+
+	arguments := make(map[string]interface{}, len(req.Arguments))
+	for key, value := range req.Arguments {
+		arguments[key] = value
+	}
+	result, err := h.ws.GetMcpPromptFromClient(context.Background(), map[string]interface{}{
+		"agent_id":    fmt.Sprintf("%d", agent.ID),
+		"server_name": strings.TrimSpace(req.ServerName),
+		"name":        strings.TrimSpace(req.Name),
+		"arguments":   arguments,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "获取MCP提示词失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ==================== 管理员版本 ====================
+
+func (ac *AdminController) mcpPromptHandler() agentMCPPromptHandler {
+	h := agentMCPPromptHandler{}
+	if ac.WebSocketController != nil {
+		h.ws = ac.WebSocketController
+	}
+	return h
+}
+
+// GetAgentMCPPrompts 智能体可选的MCP提示词（管理员版本）
+func (ac *AdminController) GetAgentMCPPrompts(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.mcpPromptHandler().list(c, agent)
+	}
+}
+
+// RenderAgentMCPPrompt 获取MCP提示词内容（管理员版本）
+func (ac *AdminController) RenderAgentMCPPrompt(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.mcpPromptHandler().render(c, agent)
+	}
+}
+
+// ==================== 用户版本 ====================
+
+func (uc *UserController) mcpPromptHandler() agentMCPPromptHandler {
+	h := agentMCPPromptHandler{}
+	if uc.WebSocketController != nil {
+		h.ws = uc.WebSocketController
+	}
+	return h
+}
+
+// GetAgentMCPPrompts 智能体可选的MCP提示词（用户版本）
+func (uc *UserController) GetAgentMCPPrompts(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.mcpPromptHandler().list(c, agent)
+	}
+}
+
+// RenderAgentMCPPrompt 获取MCP提示词内容（用户版本）
+func (uc *UserController) RenderAgentMCPPrompt(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.mcpPromptHandler().render(c, agent)
+	}
+}
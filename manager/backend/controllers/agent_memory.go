@@ -0,0 +1,462 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 共享记忆分区：未识别到说话人时使用
+const sharedMemorySpeakerName = "共享（未识别说话人）"
+
+// 记忆审计动作
+const (
+	MemoryAuditActionUpdate    = "update"
+	MemoryAuditActionDelete    = "delete"
+	MemoryAuditActionExport    = "export"
+	MemoryAuditActionForgetAll = "forget_all"
+)
+
+// MemoryWebSocketInterface 长期记忆请求所需的WebSocket能力
+type MemoryWebSocketInterface interface {
+	RequestMemoryFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+}
+
+// memorySpeaker 记忆分区：共享分区（ID 为 0）或一个声纹组
+type memorySpeaker struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// agentMemoryHandler 智能体长期记忆管理，管理员与普通用户共用，权限由调用方校验
+type agentMemoryHandler struct {
+	db *gorm.DB
+	ws MemoryWebSocketInterface
+}
+
+// loadDefaultMemoryConfig 加载默认记忆配置，与下发给主程序的设备配置保持一致
+func loadDefaultMemoryConfig(db *gorm.DB) (string, map[string]interface{}) {
+	var config models.Config
+	if err := db.Where("type = ? AND is_default = ? AND enabled = ?", "memory", true, true).First(&config).Error; err != nil {
+		return "nomemo", map[string]interface{}{}
+	}
+	configData := map[string]interface{}{}
+	if strings.TrimSpace(config.JsonData) != "" {
+		if err := json.Unmarshal([]byte(config.JsonData), &configData); err != nil {
+			log.Printf("解析Memory配置失败: %v", err)
+		}
+	}
+	return config.Provider, configData
+}
+
+// memorySpeakers 智能体的记忆分区列表：共享分区 + 声纹组；activeOnly 为 false 时包含已停用的声纹组
+func (h agentMemoryHandler) memorySpeakers(agentID uint, activeOnly bool) []memorySpeaker {
+	speakers := []memorySpeaker{{ID: 0, Name: sharedMemorySpeakerName}}
+	query := h.db.Where("agent_id = ?", agentID)
+	if activeOnly {
+		query = query.Where("status = ?", "active")
+	}
+	var speakerGroups []models.SpeakerGroup
+	if err := query.Order("created_at ASC").Find(&speakerGroups).Error; err != nil {
+		log.Printf("查询声纹组失败: %v", err)
+		return speakers
+	}
+	for _, group := range speakerGroups {
+		speakers = append(speakers, memorySpeaker{ID: group.ID, Name: group.Name})
+	}
+	return speakers
+}
+
+// parseSpeakerGroupID 解析并校验声纹组属于该智能体，0 表示共享分区
+func (h agentMemoryHandler) parseSpeakerGroupID(c *gin.Context, agentID uint, raw string) (uint, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, true
+	}
+	parsed, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "speaker_group_id 参数错误"})
+		return 0, false
+	}
+	if parsed == 0 {
+		return 0, true
+	}
+	var count int64
+	h.db.Model(&models.SpeakerGroup{}).Where("id = ? AND agent_id = ?", parsed, agentID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "声纹组不存在或不属于该智能体"})
+		return 0, false
+	}
+	return uint(parsed), true
+}
+
+// request 向主程序发送记忆请求
+func (h agentMemoryHandler) request(agentID uint, speakerGroupID uint, action string, extra map[string]interface{}) (map[string]interface{}, error) {
+	if h.ws == nil {
+		return nil, fmt.Errorf("websocket controller unavailable")
+	}
+	provider, memoryConfig := loadDefaultMemoryConfig(h.db)
+	body := map[string]interface{}{
+		"action":           action,
+		"agent_id":         fmt.Sprintf("%d", agentID),
+		"speaker_group_id": speakerGroupID,
+		"memory": map[string]interface{}{
+			"provider": provider,
+			"config":   memoryConfig,
+		},
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return h.ws.RequestMemoryFromClient(context.Background(), body)
+}
+
+// audit 记录审计日志，失败只打印日志不影响主流程
+func (h agentMemoryHandler) audit(c *gin.Context, agent models.Agent, speakerGroupID *uint, action, itemID, detail string) {
+	userID, _ := c.Get("user_id")
+	username, _ := c.Get("username")
+	provider, _ := loadDefaultMemoryConfig(h.db)
+	entry := models.MemoryAuditLog{
+		AgentID:        agent.ID,
+		SpeakerGroupID: speakerGroupID,
+		Provider:       provider,
+		Action:         action,
+		ItemID:         itemID,
+		Detail:         detail,
+		IP:             c.ClientIP(),
+	}
+	if id, ok := userID.(uint); ok {
+		entry.UserID = id
+	}
+	if name, ok := username.(string); ok {
+		entry.Username = name
+	}
+	if err := h.db.Create(&entry).Error; err != nil {
+		log.Printf("记录记忆审计日志失败: %v", err)
+	}
+}
+
+func isNoMemoryProvider(provider string) bool {
+	return provider == "" || provider == "nomemo"
+}
+
+// list 按说话人分区列出或检索记忆
+// 查询参数：speaker_group_id（0 或空为共享分区），query（为空时列出全部，否则检索）
+func (h agentMemoryHandler) list(c *gin.Context, agent models.Agent) {
+	speakerGroupID, ok := h.parseSpeakerGroupID(c, agent.ID, c.Query("speaker_group_id"))
+	if !ok {
+		return
+	}
+
+	provider, _ := loadDefaultMemoryConfig(h.db)
+	data := gin.H{
+		"agent_id":         agent.ID,
+		"memory_mode":      normalizeAgentMemoryMode(agent.MemoryMode),
+		"provider":         provider,
+		"speakers":         h.memorySpeakers(agent.ID, true),
+		"speaker_group_id": speakerGroupID,
+		"supports_items":   false,
+		"items":            []interface{}{},
+	}
+	if isNoMemoryProvider(provider) {
+		c.JSON(http.StatusOK, gin.H{"data": data})
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("query"))
+	action := "list"
+	if query != "" {
+		action = "search"
+	}
+	result, err := h.request(agent.ID, speakerGroupID, action, map[string]interface{}{"query": query})
+	if err != nil {
+		log.Printf("查询智能体 %d 的记忆失败: %v", agent.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "查询记忆失败: " + err.Error()})
+		return
+	}
+
+	data["scope"] = result["scope"]
+	data["supports_items"] = result["supports_items"]
+	if items, ok := result["items"].([]interface{}); ok {
+		data["items"] = items
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// update 修改单条记忆
+func (h agentMemoryHandler) update(c *gin.Context, agent models.Agent) {
+	var req struct {
+		SpeakerGroupID uint   `json:"speaker_group_id"`
+		Content        string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content 不能为空"})
+		return
+	}
+	speakerGroupID, ok := h.parseSpeakerGroupID(c, agent.ID, strconv.FormatUint(uint64(req.SpeakerGroupID), 10))
+	if !ok {
+		return
+	}
+
+	itemID := c.Param("memory_id")
+	if _, err := h.request(agent.ID, speakerGroupID, "update", map[string]interface{}{
+		"item_id": itemID,
+		"content": req.Content,
+	}); err != nil {
+		respondMemoryError(c, "修改记忆失败", err)
+		return
+	}
+	h.audit(c, agent, &speakerGroupID, MemoryAuditActionUpdate, itemID, "")
+	c.JSON(http.StatusOK, gin.H{"message": "记忆已更新"})
+}
+
+// delete 删除单条记忆
+func (h agentMemoryHandler) delete(c *gin.Context, agent models.Agent) {
+	speakerGroupID, ok := h.parseSpeakerGroupID(c, agent.ID, c.Query("speaker_group_id"))
+	if !ok {
+		return
+	}
+
+	itemID := c.Param("memory_id")
+	if _, err := h.request(agent.ID, speakerGroupID, "delete", map[string]interface{}{"item_id": itemID}); err != nil {
+		respondMemoryError(c, "删除记忆失败", err)
+		return
+	}
+	h.audit(c, agent, &speakerGroupID, MemoryAuditActionDelete, itemID, "")
+	c.JSON(http.StatusOK, gin.H{"message": "记忆已删除"})
+}
+
+// export 导出智能体全部记忆分区的记忆（JSON 附件）
+func (h agentMemoryHandler) export(c *gin.Context, agent models.Agent) {
+	provider, _ := loadDefaultMemoryConfig(h.db)
+	scopes := make([]gin.H, 0)
+	total := 0
+	if !isNoMemoryProvider(provider) {
+		for _, speaker := range h.memorySpeakers(agent.ID, false) {
+			result, err := h.request(agent.ID, speaker.ID, "list", nil)
+			if err != nil {
+				respondMemoryError(c, "导出记忆失败", err)
+				return
+			}
+			items, _ := result["items"].([]interface{})
+			if items == nil {
+				items = []interface{}{}
+			}
+			total += len(items)
+			scopes = append(scopes, gin.H{
+				"speaker_group_id": speaker.ID,
+				"speaker_name":     speaker.Name,
+				"items":            items,
+			})
+		}
+	}
+
+	h.audit(c, agent, nil, MemoryAuditActionExport, "", fmt.Sprintf("scopes=%d, items=%d", len(scopes), total))
+
+	filename := fmt.Sprintf("agent_%d_memories_%s.json", agent.ID, time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.JSON(http.StatusOK, gin.H{
+		"agent_id":    agent.ID,
+		"agent_name":  agent.Name,
+		"provider":    provider,
+		"exported_at": time.Now(),
+		"scopes":      scopes,
+	})
+}
+
+// forgetAll 清空智能体全部记忆分区，需要请求体携带 confirm=true
+func (h agentMemoryHandler) forgetAll(c *gin.Context, agent models.Agent) {
+	var req struct {
+		Confirm bool `json:"confirm"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请确认清空全部记忆（confirm=true）"})
+		return
+	}
+
+	provider, _ := loadDefaultMemoryConfig(h.db)
+	cleared := 0
+	if !isNoMemoryProvider(provider) {
+		for _, speaker := range h.memorySpeakers(agent.ID, false) {
+			if _, err := h.request(agent.ID, speaker.ID, "reset", nil); err != nil {
+				h.audit(c, agent, nil, MemoryAuditActionForgetAll, "", fmt.Sprintf("failed after %d scopes: %v", cleared, err))
+				respondMemoryError(c, "清空记忆失败", err)
+				return
+			}
+			cleared++
+		}
+	}
+
+	h.audit(c, agent, nil, MemoryAuditActionForgetAll, "", fmt.Sprintf("scopes=%d", cleared))
+	c.JSON(http.StatusOK, gin.H{"message": "已清空全部记忆", "data": gin.H{"scopes": cleared}})
+}
+
+// auditLogs 查询智能体的记忆审计日志
+func (h agentMemoryHandler) auditLogs(c *gin.Context, agent models.Agent) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	var logs []models.MemoryAuditLog
+	if err := h.db.Where("agent_id = ?", agent.ID).Order("id DESC").Limit(limit).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取审计日志失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": logs})
+}
+
+// respondMemoryError 将主程序返回的错误映射为 HTTP 状态码
+func respondMemoryError(c *gin.Context, prefix string, err error) {
+	msg := err.Error()
+	log.Printf("%s: %v", prefix, err)
+	switch {
+	case strings.Contains(msg, "does not support"):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": prefix + ": 当前记忆服务不支持该操作"})
+	case strings.Contains(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": prefix + ": 记忆不存在"})
+	case strings.Contains(msg, "websocket controller unavailable"), strings.Contains(msg, "没有连接的客户端"):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": prefix + ": " + msg})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": prefix + ": " + msg})
+	}
+}
+
+// ==================== 管理员版本 ====================
+
+func (ac *AdminController) memoryHandler() agentMemoryHandler {
+	h := agentMemoryHandler{db: ac.DB}
+	if ac.WebSocketController != nil {
+		h.ws = ac.WebSocketController
+	}
+	return h
+}
+
+func (ac *AdminController) memoryAgent(c *gin.Context) (models.Agent, bool) {
+	var agent models.Agent
+	if err := ac.DB.Scopes(ownedByTenant(c, ac.DB)).Where("id = ?", c.Param("id")).First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return agent, false
+	}
+	return agent, true
+}
+
+// GetAgentMemories 按说话人浏览智能体长期记忆（管理员版本）
+func (ac *AdminController) GetAgentMemories(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.memoryHandler().list(c, agent)
+	}
+}
+
+// UpdateAgentMemory 修改单条记忆（管理员版本）
+func (ac *AdminController) UpdateAgentMemory(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.memoryHandler().update(c, agent)
+	}
+}
+
+// DeleteAgentMemory 删除单条记忆（管理员版本）
+func (ac *AdminController) DeleteAgentMemory(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.memoryHandler().delete(c, agent)
+	}
+}
+
+// ExportAgentMemories 导出全部记忆（管理员版本）
+func (ac *AdminController) ExportAgentMemories(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.memoryHandler().export(c, agent)
+	}
+}
+
+// ForgetAgentMemories 清空全部记忆（管理员版本）
+func (ac *AdminController) ForgetAgentMemories(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.memoryHandler().forgetAll(c, agent)
+	}
+}
+
+// GetAgentMemoryAuditLogs 记忆审计日志（管理员版本）
+func (ac *AdminController) GetAgentMemoryAuditLogs(c *gin.Context) {
+	if agent, ok := ac.memoryAgent(c); ok {
+		ac.memoryHandler().auditLogs(c, agent)
+	}
+}
+
+// ==================== 用户版本 ====================
+
+func (uc *UserController) memoryHandler() agentMemoryHandler {
+	h := agentMemoryHandler{db: uc.DB}
+	if uc.WebSocketController != nil {
+		h.ws = uc.WebSocketController
+	}
+	return h
+}
+
+func (uc *UserController) memoryAgent(c *gin.Context) (models.Agent, bool) {
+	userID, _ := c.Get("user_id")
+	var agent models.Agent
+	if err := uc.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在或不属于当前用户"})
+		return agent, false
+	}
+	return agent, true
+}
+
+// GetAgentMemories 按说话人浏览智能体长期记忆（用户版本）
+func (uc *UserController) GetAgentMemories(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.memoryHandler().list(c, agent)
+	}
+}
+
+// UpdateAgentMemory 修改单条记忆（用户版本）
+func (uc *UserController) UpdateAgentMemory(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.memoryHandler().update(c, agent)
+	}
+}
+
+// DeleteAgentMemory 删除单条记忆（用户版本）
+func (uc *UserController) DeleteAgentMemory(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.memoryHandler().delete(c, agent)
+	}
+}
+
+// ExportAgentMemories 导出全部记忆（用户版本）
+func (uc *UserController) ExportAgentMemories(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.memoryHandler().export(c, agent)
+	}
+}
+
+// ForgetAgentMemories 清空全部记忆（用户版本）
+func (uc *UserController) ForgetAgentMemories(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.memoryHandler().forgetAll(c, agent)
+	}
+}
+
+// GetAgentMemoryAuditLogs 记忆审计日志（用户版本）
+func (uc *UserController) GetAgentMemoryAuditLogs(c *gin.Context) {
+	if agent, ok := uc.memoryAgent(c); ok {
+		uc.memoryHandler().auditLogs(c, agent)
+	}
+}
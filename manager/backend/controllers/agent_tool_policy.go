@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"xiaozhi/manager/backend/models"
+)
+
+// MCPToolPolicyConfig 智能体的工具调用策略，下发到主程序后在工具调用前执行
+type MCPToolPolicyConfig struct {
+	Allow []string                `json:"allow"`
+	Deny  []string                `json:"deny"`
+	Rules []MCPToolPolicyRuleItem `json:"rules"`
+	// Confirm 需要用户口头确认后才执行的工具
+	Confirm []string `json:"confirm"`
+}
+
+type MCPToolPolicyRuleItem struct {
+	Tool      string                          `json:"tool"`
+	Args      map[string]MCPToolArgConstraint `json:"args,omitempty"`
+	RateLimit *MCPToolRateLimitConfig         `json:"rate_limit,omitempty"`
+}
+
+type MCPToolArgConstraint struct {
+	Min     *float64      `json:"min,omitempty"`
+	Max     *float64      `json:"max,omitempty"`
+	Enum    []interface{} `json:"enum,omitempty"`
+	Pattern string        `json:"pattern,omitempty"`
+	Clamp   bool          `json:"clamp,omitempty"`
+}
+
+type MCPToolRateLimitConfig struct {
+	Max           int `json:"max"`
+	WindowSeconds int `json:"window_seconds"`
+}
+
+func emptyMCPToolPolicy() MCPToolPolicyConfig {
+	return MCPToolPolicyConfig{Allow: []string{}, Deny: []string{}, Rules: []MCPToolPolicyRuleItem{}, Confirm: []string{}}
+}
+
+// validateToolPattern 校验工具 glob，允许 local:/global:/device:/agent_mcp: 前缀
+func validateToolPattern(pattern string) error {
+	glob := pattern
+	if idx := strings.Index(glob, ":"); idx > 0 {
+		switch glob[:idx] {
+		case "local", "global", "device", "agent_mcp":
+			glob = glob[idx+1:]
+		}
+	}
+	if glob == "" {
+		return fmt.Errorf("工具匹配规则 %q 不能为空", pattern)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("工具匹配规则 %q 格式错误", pattern)
+	}
+	return nil
+}
+
+func normalizeToolPatterns(patterns []string) ([]string, error) {
+	result := make([]string, 0, len(patterns))
+	seen := make(map[string]struct{}, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, exists := seen[pattern]; exists {
+			continue
+		}
+		if err := validateToolPattern(pattern); err != nil {
+			return nil, err
+		}
+		seen[pattern] = struct{}{}
+		result = append(result, pattern)
+	}
+	return result, nil
+}
+
+// normalizeMCPToolPolicy 去除空项并校验 glob、正则、数值范围和限流配置
+func normalizeMCPToolPolicy(cfg MCPToolPolicyConfig) (MCPToolPolicyConfig, error) {
+	result := emptyMCPToolPolicy()
+	var err error
+	if result.Allow, err = normalizeToolPatterns(cfg.Allow); err != nil {
+		return result, err
+	}
+	if result.Deny, err = normalizeToolPatterns(cfg.Deny); err != nil {
+		return result, err
+	}
+	if result.Confirm, err = normalizeToolPatterns(cfg.Confirm); err != nil {
+		return result, err
+	}
+
+	for _, rule := range cfg.Rules {
+		rule.Tool = strings.TrimSpace(rule.Tool)
+		if rule.Tool == "" {
+			continue
+		}
+		if err := validateToolPattern(rule.Tool); err != nil {
+			return result, err
+		}
+		for name, constraint := range rule.Args {
+			if strings.TrimSpace(name) == "" {
+				return result, fmt.Errorf("工具 %s 的参数名不能为空", rule.Tool)
+			}
+			if constraint.Min != nil && constraint.Max != nil && *constraint.Min > *constraint.Max {
+				return result, fmt.Errorf("工具 %s 参数 %s 的最小值大于最大值", rule.Tool, name)
+			}
+			if constraint.Pattern != "" {
+				if _, err := regexp.Compile(constraint.Pattern); err != nil {
+					return result, fmt.Errorf("工具 %s 参数 %s 的正则格式错误: %v", rule.Tool, name, err)
+				}
+			}
+		}
+		if rule.RateLimit != nil {
+			if rule.RateLimit.Max <= 0 || rule.RateLimit.WindowSeconds <= 0 {
+				return result, fmt.Errorf("工具 %s 的限流次数和时间窗口必须大于0", rule.Tool)
+			}
+		}
+		if len(rule.Args) == 0 && rule.RateLimit == nil {
+			continue
+		}
+		result.Rules = append(result.Rules, rule)
+	}
+	return result, nil
+}
+
+func parseMCPToolPolicy(raw string) MCPToolPolicyConfig {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return emptyMCPToolPolicy()
+	}
+	var parsed MCPToolPolicyConfig
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return emptyMCPToolPolicy()
+	}
+	normalized, err := normalizeMCPToolPolicy(parsed)
+	if err != nil {
+		return emptyMCPToolPolicy()
+	}
+	return normalized
+}
+
+func buildMCPToolPolicyFromAgent(agent models.Agent) MCPToolPolicyConfig {
+	return parseMCPToolPolicy(agent.MCPToolPolicyConfig)
+}
+
+// applyMCPToolPolicyToAgent 校验策略并保存到智能体，空策略保存为空字符串
+func applyMCPToolPolicyToAgent(agent *models.Agent, cfg MCPToolPolicyConfig) error {
+	if agent == nil {
+		return nil
+	}
+	normalized, err := normalizeMCPToolPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	if len(normalized.Allow) == 0 && len(normalized.Deny) == 0 && len(normalized.Rules) == 0 && len(normalized.Confirm) == 0 {
+		agent.MCPToolPolicyConfig = ""
+		return nil
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	agent.MCPToolPolicyConfig = string(data)
+	return nil
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxAPIKeysPerUser 每个用户最多持有的 API Key 数量
+const maxAPIKeysPerUser = 20
+
+// APIKeyController 个人 API Key 管理，供脚本、CI 和家庭自动化调用管理接口
+type APIKeyController struct {
+	DB *gorm.DB
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 表示永不过期
+}
+
+// 获取可授予的权限列表
+func (kc *APIKeyController) GetAPIKeyScopes(c *gin.Context) {
+	isAdmin := c.GetString("role") == "admin"
+	scopes := make([]middleware.APIKeyScope, 0, len(middleware.APIKeyScopes))
+	for _, scope := range middleware.APIKeyScopes {
+		if isAdmin || !scope.AdminOnly {
+			scopes = append(scopes, scope)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": scopes})
+}
+
+// 获取当前用户的 API Key 列表
+func (kc *APIKeyController) ListAPIKeys(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var keys []models.APIKey
+	if err := kc.DB.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取 API Key 失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// 创建 API Key，明文只在本次返回
+func (kc *APIKeyController) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.GetUint("user_id")
+	isAdmin := c.GetString("role") == "admin"
+
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		if seen[scope] {
+			continue
+		}
+		if !middleware.ValidAPIKeyScope(scope, isAdmin) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效或无权授予的权限: " + scope})
+			return
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > 3650 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效期应在 0-3650 天之间"})
+		return
+	}
+
+	var count int64
+	kc.DB.Model(&models.APIKey{}).Where("user_id = ?", userID).Count(&count)
+	if count >= maxAPIKeysPerUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API Key 数量已达上限"})
+		return
+	}
+
+	key, prefix, hash, err := middleware.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成 API Key 失败"})
+		return
+	}
+	apiKey := models.APIKey{
+		UserID:  userID,
+		Name:    strings.TrimSpace(req.Name),
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  strings.Join(scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+	if err := kc.DB.Create(&apiKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存 API Key 失败"})
+		return
+	}
+
+	log.Printf("[APIKey] 用户创建 API Key - 用户ID: %d, 名称: %s, 权限: %s", userID, apiKey.Name, apiKey.Scopes)
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"api_key": apiKey,
+		"key":     key,
+	}})
+}
+
+// 删除（吊销）API Key
+func (kc *APIKeyController) DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API Key ID格式错误"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	result := kc.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIKey{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除 API Key 失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API Key 不存在"})
+		return
+	}
+	log.Printf("[APIKey] 用户吊销 API Key - 用户ID: %d, ID: %d", userID, id)
+	c.JSON(http.StatusOK, gin.H{"message": "已吊销"})
+}
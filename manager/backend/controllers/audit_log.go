@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	auditLogDefaultPageSize = 20
+	auditLogMaxPageSize     = 200
+)
+
+// scopedAuditLogs 平台管理员查看全部审计日志，组织管理员只能查看本组织成员的操作
+func (ac *AdminController) scopedAuditLogs(c *gin.Context) *gorm.DB {
+	query := ac.DB.Model(&models.AuditLog{})
+	orgID, platform := tenantOf(c)
+	if platform {
+		return query
+	}
+	if orgID == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where("org_id = ?", orgID)
+}
+
+// GetAuditLogs 分页查询审计日志，支持按操作人、动作、实体、请求方法、结果和时间范围过滤
+func (ac *AdminController) GetAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(auditLogDefaultPageSize)))
+	if pageSize < 1 || pageSize > auditLogMaxPageSize {
+		pageSize = auditLogDefaultPageSize
+	}
+
+	query := ac.scopedAuditLogs(c)
+	for _, field := range []string{"actor_id", "action", "entity_type", "entity_id", "method", "ip"} {
+		if value := strings.TrimSpace(c.Query(field)); value != "" {
+			query = query.Where(field+" = ?", value)
+		}
+	}
+	if actor := strings.TrimSpace(c.Query("actor")); actor != "" {
+		query = query.Where("actor_name LIKE ?", "%"+actor+"%")
+	}
+	switch c.Query("status") {
+	case "success":
+		query = query.Where("status_code < ?", http.StatusBadRequest)
+	case "failed":
+		query = query.Where("status_code >= ?", http.StatusBadRequest)
+	}
+	if start, ok := parseToolCallLogTime(c.Query("start_time")); ok {
+		query = query.Where("created_at >= ?", start)
+	}
+	if end, ok := parseToolCallLogTime(c.Query("end_time")); ok {
+		query = query.Where("created_at <= ?", end)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询审计日志失败"})
+		return
+	}
+	// 列表不返回快照内容，详情接口再取
+	var logs []models.AuditLog
+	if err := query.Omit("before", "after").Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询审计日志失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":          total,
+		"page":           page,
+		"page_size":      pageSize,
+		"retention_days": middleware.AuditRetentionDays(),
+		"data":           logs,
+	})
+}
+
+// GetAuditLog 获取单条审计日志，包含变更前后快照
+func (ac *AdminController) GetAuditLog(c *gin.Context) {
+	var entry models.AuditLog
+	if err := ac.scopedAuditLogs(c).Where("id = ?", c.Param("id")).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "审计日志不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
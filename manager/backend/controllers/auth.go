@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"xiaozhi/manager/backend/config"
 	"xiaozhi/manager/backend/middleware"
 	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/oidc"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -12,12 +15,17 @@ import (
 )
 
 type AuthController struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	OIDC      config.OIDCConfig
+	TwoFactor config.TwoFactorConfig
+
+	oidcProvider *oidc.Provider
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username      string `json:"username" binding:"required"`
+	Password      string `json:"password" binding:"required"`
+	TwoFactorCode string `json:"two_factor_code"` // 启用两步验证后必填，可为 TOTP 验证码或恢复码
 }
 
 type RegisterRequest struct {
@@ -33,6 +41,10 @@ func (ac *AuthController) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if ac.localLoginDisabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "已禁用本地账号登录，请使用单点登录"})
+		return
+	}
 
 	// 添加登录调试日志
 	log.Printf("[Login] 尝试登录用户: %s, 客户端IP: %s", req.Username, c.ClientIP())
@@ -44,12 +56,18 @@ func (ac *AuthController) Login(c *gin.Context) {
 		var user models.User
 		if err := ac.DB.Where("username = ?", req.Username).First(&user).Error; err == nil {
 			log.Printf("[Login] 找到用户: ID=%d, Username=%s, Role=%s, Email=%s", user.ID, user.Username, user.Role, user.Email)
-			log.Printf("[Login] 数据库中密码哈希长度: %d, 哈希前缀: %s", len(user.Password), user.Password[:10])
 			log.Printf("[Login] 开始bcrypt密码比较验证")
 			
 			if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err == nil {
 				log.Printf("[Login] ✅ 密码验证成功 - 用户: %s", req.Username)
-				token, err := middleware.GenerateToken(user.ID, user.Username, user.Role)
+				if user.TOTPEnabled {
+					if err := verifySecondFactor(ac.DB, &user, req.TwoFactorCode); err != nil {
+						log.Printf("[Login] 两步验证未通过 - 用户: %s, 原因: %v", req.Username, err)
+						c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "two_factor_required": true})
+						return
+					}
+				}
+				tokens, err := middleware.IssueSession(ac.DB, user, c.Request.UserAgent(), c.ClientIP())
 				if err != nil {
 					log.Printf("[Login] ❌ 生成token失败: %v", err)
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
@@ -58,18 +76,21 @@ func (ac *AuthController) Login(c *gin.Context) {
 
 				log.Printf("[Login] ✅ 登录成功，返回token - 用户: %s, 角色: %s", user.Username, user.Role)
 				c.JSON(http.StatusOK, gin.H{
-					"token": token,
+					"token":         tokens.AccessToken,
+					"refresh_token": tokens.RefreshToken,
+					"expires_in":    tokens.ExpiresIn,
 					"user": gin.H{
-						"id":       user.ID,
-						"username": user.Username,
-						"email":    user.Email,
-						"role":     user.Role,
+						"id":           user.ID,
+						"username":     user.Username,
+						"email":        user.Email,
+						"role":         user.Role,
+						"totp_enabled": user.TOTPEnabled,
 					},
+					"two_factor_setup_required": middleware.TwoFactorRequired(user) && !user.TOTPEnabled,
 				})
 				return
 			} else {
 				log.Printf("[Login] ❌ 密码验证失败 - 用户: %s, bcrypt错误: %v", req.Username, err)
-				log.Printf("[Login] 调试信息 - 输入密码: '%s', 哈希: '%s'", req.Password, user.Password)
 			}
 		} else {
 			log.Printf("[Login] ❌ 用户不存在 - 用户名: %s, 数据库错误: %v", req.Username, err)
@@ -78,9 +99,9 @@ func (ac *AuthController) Login(c *gin.Context) {
 		log.Printf("[Login] ❌ 数据库连接不可用")
 	}
 
-	// Fallback: 硬编码的admin用户验证（当数据库不可用时）
-	if req.Username == "admin" && req.Password == "admin123" {
-		token, err := middleware.GenerateToken(1, "admin", "admin")
+	// Fallback: 硬编码的admin用户验证（仅当数据库不可用时，数据库可用时不接受默认密码）
+	if ac.DB == nil && req.Username == "admin" && req.Password == "admin123" {
+		token, err := middleware.GenerateToken(1, "admin", "admin", 0, "")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
 			return
@@ -108,6 +129,10 @@ func (ac *AuthController) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if ac.localLoginDisabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "已禁用本地账号登录，请使用单点登录"})
+		return
+	}
 
 	// 检查用户名是否已存在
 	var existingUser models.User
@@ -168,6 +193,44 @@ func (ac *AuthController) GetProfile(c *gin.Context) {
 
 	log.Printf("[GetProfile] ✅ 成功获取用户信息 - ID: %d, 用户名: %s, 角色: %s", user.ID, user.Username, user.Role)
 	c.JSON(http.StatusOK, gin.H{
+		"user": gin.H{
+			"id":           user.ID,
+			"username":     user.Username,
+			"email":        user.Email,
+			"role":         user.Role,
+			"totp_enabled": user.TOTPEnabled,
+		},
+		"two_factor_setup_required": middleware.TwoFactorRequired(user) && !user.TOTPEnabled,
+	})
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// 刷新访问令牌（刷新令牌同时轮换）
+func (ac *AuthController) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, user, err := middleware.RefreshSession(ac.DB, req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, middleware.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录"})
+			return
+		}
+		log.Printf("[RefreshToken] ❌ 刷新token失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新token失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
@@ -176,3 +239,31 @@ func (ac *AuthController) GetProfile(c *gin.Context) {
 		},
 	})
 }
+
+// 退出登录：注销当前访问令牌和所属会话
+func (ac *AuthController) Logout(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*middleware.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息缺失"})
+		return
+	}
+	if err := middleware.RevokeToken(ac.DB, claims); err != nil {
+		log.Printf("[Logout] ❌ 注销token失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "退出登录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// 退出所有设备：当前用户的全部令牌立即失效
+func (ac *AuthController) RevokeAllSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if err := middleware.RevokeUserSessions(ac.DB, userID); err != nil {
+		log.Printf("[RevokeAllSessions] ❌ 注销会话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+		return
+	}
+	log.Printf("[RevokeAllSessions] ✅ 用户 %d 已注销全部会话", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "已退出所有设备，请重新登录"})
+}
@@ -32,7 +32,7 @@ type SaveMessageRequest struct {
 	Role          string                 `json:"role" binding:"required,oneof=user assistant system tool"`
 	Content       string                 `json:"content" binding:"required"`
 	ToolCallID    string                 `json:"tool_call_id,omitempty"`    // 工具调用ID（Tool角色使用）
-	ToolCallsJSON *string                `json:"tool_calls_json,omitempty"` // 工具调用列表JSON（Assistant角色使用），nil 表示 NULL
+	ToolCallsJSON *models.JSONText       `json:"tool_calls_json,omitempty"` // 工具调用列表JSON（Assistant角色使用），nil 表示 NULL
 	AudioData     string                 `json:"audio_data,omitempty"`      // base64编码
 	AudioFormat   string                 `json:"audio_format,omitempty"`    // 音频格式（客户端传入，后端固定使用wav）
 	AudioDuration int                    `json:"audio_duration,omitempty"`
@@ -175,6 +175,15 @@ func (c *ChatHistoryController) SaveMessage(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusCreated, message)
+	emitWebhookEvent(c.DB, message.UserID, webhookEventChatMessage, map[string]interface{}{
+		"message_id": message.MessageID,
+		"device_id":  message.DeviceID,
+		"agent_id":   message.AgentID,
+		"session_id": message.SessionID,
+		"role":       message.Role,
+		"content":    message.Content,
+		"created_at": message.CreatedAt,
+	})
 }
 
 // GetMessages 获取消息列表（按agentId汇总）
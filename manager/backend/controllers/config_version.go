@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/jsondiff"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 可版本化的实体类型
+const (
+	versionEntityConfig = "config"
+	versionEntityAgent  = "agent"
+	versionEntityRole   = "role"
+)
+
+// versionSnapshot 实体的 JSON 快照，去掉 updated_at，内容未变的保存不会产生新版本
+func versionSnapshot(record interface{}) string {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return ""
+	}
+	delete(m, "updated_at")
+	raw, err = json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// recordVersionAttempts 并发保存同一实体时版本号可能冲突（唯一索引 idx_config_version），冲突后重新读取最新版本重试的次数
+const recordVersionAttempts = 3
+
+// recordVersion 在实体保存成功后记录新版本。before 为修改前的快照，实体还没有任何版本时
+// （版本管理启用前创建的数据）先把它记为第一个版本，保证修改后仍能回滚到原内容。
+// 内容与最新版本相同时不产生新版本；记录失败只打日志，不影响保存结果
+func recordVersion(db *gorm.DB, c *gin.Context, entityType string, entityID uint, before string, after interface{}, comment string) *models.ConfigVersion {
+	snapshot := versionSnapshot(after)
+	if snapshot == "" {
+		return nil
+	}
+	for attempt := 1; ; attempt++ {
+		var latest models.ConfigVersion
+		hasLatest := latestVersion(db, entityType, entityID, &latest)
+		if !hasLatest && before != "" {
+			latest = models.ConfigVersion{
+				EntityType: entityType,
+				EntityID:   entityID,
+				Version:    1,
+				Snapshot:   before,
+				Comment:    "版本管理启用前的内容",
+			}
+			if err := db.Create(&latest).Error; err != nil {
+				// 其他请求已写入初始版本时重新读取
+				if !latestVersion(db, entityType, entityID, &latest) {
+					log.Printf("[Version] 记录 %s#%d 初始版本失败: %v", entityType, entityID, err)
+					return nil
+				}
+			}
+			hasLatest = true
+		}
+		if hasLatest && latest.Snapshot == snapshot {
+			return nil
+		}
+
+		version := models.ConfigVersion{
+			EntityType: entityType,
+			EntityID:   entityID,
+			Version:    latest.Version + 1,
+			Snapshot:   snapshot,
+			Comment:    comment,
+			ActorID:    c.GetUint("user_id"),
+			ActorName:  c.GetString("username"),
+		}
+		err := db.Create(&version).Error
+		if err == nil {
+			return &version
+		}
+		// 版本号已被并发的保存占用时重试，其他错误直接放弃
+		var current models.ConfigVersion
+		if attempt >= recordVersionAttempts || !latestVersion(db, entityType, entityID, &current) || current.Version < version.Version {
+			log.Printf("[Version] 记录 %s#%d 版本失败: %v", entityType, entityID, err)
+			return nil
+		}
+	}
+}
+
+// latestVersion 读取实体的最新版本，不存在时返回 false
+func latestVersion(db *gorm.DB, entityType string, entityID uint, latest *models.ConfigVersion) bool {
+	return db.Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("version DESC").First(latest).Error == nil
+}
+
+// findVersionedConfig 按调用者的组织范围读取配置
+func (ac *AdminController) findVersionedConfig(c *gin.Context) (*models.Config, bool) {
+	var config models.Config
+	if err := ac.DB.Scopes(configsInTenant(c)).First(&config, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
+		return nil, false
+	}
+	return &config, true
+}
+
+// findVersionedAgent 读取智能体：/api/user 下只能访问自己的智能体，管理接口按组织范围限定
+func (ac *AdminController) findVersionedAgent(c *gin.Context) (*models.Agent, bool) {
+	var agent models.Agent
+	query := ac.DB.Where("id = ?", c.Param("id"))
+	if strings.HasPrefix(c.FullPath(), "/api/user/") {
+		query = query.Where("user_id = ?", c.GetUint("user_id"))
+	} else {
+		query = query.Scopes(ownedByTenant(c, ac.DB))
+	}
+	if err := query.First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return nil, false
+	}
+	return &agent, true
+}
+
+// findVersionedRole 读取角色，权限规则同 UpdateRoleNew：管理员或角色所有者
+func (ac *AdminController) findVersionedRole(c *gin.Context) (*models.Role, bool) {
+	var role models.Role
+	if err := ac.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return nil, false
+	}
+	if strings.Contains(c.FullPath(), "/admin/roles/global/") && role.RoleType != "global" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该接口仅允许操作全局角色"})
+		return nil, false
+	}
+	isAdmin := c.GetString("role") == middleware.RoleAdmin
+	isOwner := role.UserID != nil && *role.UserID == c.GetUint("user_id")
+	if !isAdmin && !isOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问此角色"})
+		return nil, false
+	}
+	return &role, true
+}
+
+// listVersions 返回实体的版本列表（不含快照内容），最新的在前
+func (ac *AdminController) listVersions(c *gin.Context, entityType string, entityID uint) {
+	var versions []models.ConfigVersion
+	if err := ac.DB.Omit("snapshot").
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询版本失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// loadVersion 读取实体的指定版本；version 为 0 时取最新版本
+func (ac *AdminController) loadVersion(entityType string, entityID uint, version int) (models.ConfigVersion, error) {
+	var v models.ConfigVersion
+	query := ac.DB.Where("entity_type = ? AND entity_id = ?", entityType, entityID)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+	err := query.Order("version DESC").First(&v).Error
+	return v, err
+}
+
+// getVersion 返回指定版本，快照中的敏感字段已脱敏
+func (ac *AdminController) getVersion(c *gin.Context, entityType string, entityID uint) {
+	number, _ := strconv.Atoi(c.Param("version"))
+	if number <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "版本号无效"})
+		return
+	}
+	version, err := ac.loadVersion(entityType, entityID, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"version":  version,
+		"snapshot": jsondiff.Mask(jsondiff.Parse(version.Snapshot)),
+	}})
+}
+
+// diffVersions 比较任意两个版本：to 默认为最新版本，from 默认为 to 的上一个版本
+func (ac *AdminController) diffVersions(c *gin.Context, entityType string, entityID uint) {
+	toNumber, _ := strconv.Atoi(c.Query("to"))
+	to, err := ac.loadVersion(entityType, entityID, toNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return
+	}
+	fromNumber, _ := strconv.Atoi(c.Query("from"))
+	if fromNumber <= 0 {
+		fromNumber = to.Version - 1
+	}
+	var from models.ConfigVersion
+	if fromNumber > 0 {
+		if from, err = ac.loadVersion(entityType, entityID, fromNumber); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+			return
+		}
+	}
+
+	diff := jsondiff.Diff(jsondiff.Parse(from.Snapshot), jsondiff.Parse(to.Snapshot))
+	from.Snapshot, to.Snapshot = "", ""
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"from": from,
+		"to":   to,
+		"diff": diff,
+	}})
+}
+
+// rollbackVersion 将实体恢复到指定版本：apply 把快照中可回滚的字段写回 record，
+// 保存后记录一个新版本，并通过 WebSocket 通知已连接的主程序使用回滚后的内容
+func (ac *AdminController) rollbackVersion(c *gin.Context, entityType string, entityID uint, record interface{}, apply func(snapshot string) error) {
+	number, _ := strconv.Atoi(c.Param("version"))
+	if number <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "版本号无效"})
+		return
+	}
+	target, err := ac.loadVersion(entityType, entityID, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return
+	}
+
+	before := versionSnapshot(record)
+	if err := apply(target.Snapshot); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ac.DB.Save(record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "回滚失败"})
+		return
+	}
+	version := recordVersion(ac.DB, c, entityType, entityID, before, record, fmt.Sprintf("回滚到版本 %d", target.Version))
+
+	ac.notifyVersionRollback(entityType, entityID)
+	c.JSON(http.StatusOK, gin.H{"data": record, "version": version})
+}
+
+// notifyVersionRollback 配置回滚后推送系统配置；智能体、角色回滚后让使用它们的在线设备重新加载配置，
+// 会话中的提示词立即生效，不必等设备下次连接
+func (ac *AdminController) notifyVersionRollback(entityType string, entityID uint) {
+	if entityType == versionEntityConfig {
+		ac.notifySystemConfigChanged()
+		return
+	}
+	if ac.WebSocketController == nil {
+		return
+	}
+	column := "agent_id"
+	if entityType == versionEntityRole {
+		column = "role_id"
+	}
+	var deviceIDs []string
+	if err := ac.DB.Model(&models.Device{}).Where(column+" = ? AND device_name <> ''", entityID).
+		Pluck("device_name", &deviceIDs).Error; err != nil {
+		log.Printf("[Version] 查询 %s#%d 的设备失败: %v", entityType, entityID, err)
+		return
+	}
+	go ac.WebSocketController.ReloadDeviceConfigs(deviceIDs)
+}
+
+// GetConfigVersions 配置的版本列表
+func (ac *AdminController) GetConfigVersions(c *gin.Context) {
+	if config, ok := ac.findVersionedConfig(c); ok {
+		ac.listVersions(c, versionEntityConfig, config.ID)
+	}
+}
+
+// GetConfigVersion 配置的指定版本
+func (ac *AdminController) GetConfigVersion(c *gin.Context) {
+	if config, ok := ac.findVersionedConfig(c); ok {
+		ac.getVersion(c, versionEntityConfig, config.ID)
+	}
+}
+
+// DiffConfigVersions 比较配置的两个版本
+func (ac *AdminController) DiffConfigVersions(c *gin.Context) {
+	if config, ok := ac.findVersionedConfig(c); ok {
+		ac.diffVersions(c, versionEntityConfig, config.ID)
+	}
+}
+
+// RollbackConfigVersion 将配置回滚到指定版本；类型、config_id、默认标志和所属组织保持不变
+func (ac *AdminController) RollbackConfigVersion(c *gin.Context) {
+	config, ok := ac.findVersionedConfig(c)
+	if !ok {
+		return
+	}
+	ac.rollbackVersion(c, versionEntityConfig, config.ID, config, func(snapshot string) error {
+		var target models.Config
+		if err := json.Unmarshal([]byte(snapshot), &target); err != nil {
+			return fmt.Errorf("版本内容无效")
+		}
+		config.Name = target.Name
+		config.Provider = target.Provider
+		config.JsonData = target.JsonData
+		config.Enabled = target.Enabled
+		return nil
+	})
+}
+
+// GetAgentVersions 智能体的版本列表
+func (ac *AdminController) GetAgentVersions(c *gin.Context) {
+	if agent, ok := ac.findVersionedAgent(c); ok {
+		ac.listVersions(c, versionEntityAgent, agent.ID)
+	}
+}
+
+// GetAgentVersion 智能体的指定版本
+func (ac *AdminController) GetAgentVersion(c *gin.Context) {
+	if agent, ok := ac.findVersionedAgent(c); ok {
+		ac.getVersion(c, versionEntityAgent, agent.ID)
+	}
+}
+
+// DiffAgentVersions 比较智能体的两个版本
+func (ac *AdminController) DiffAgentVersions(c *gin.Context) {
+	if agent, ok := ac.findVersionedAgent(c); ok {
+		ac.diffVersions(c, versionEntityAgent, agent.ID)
+	}
+}
+
+// RollbackAgentVersion 将智能体的提示词、模型、音色和工具设置回滚到指定版本，所属用户不变
+func (ac *AdminController) RollbackAgentVersion(c *gin.Context) {
+	agent, ok := ac.findVersionedAgent(c)
+	if !ok {
+		return
+	}
+	ac.rollbackVersion(c, versionEntityAgent, agent.ID, agent, func(snapshot string) error {
+		var target models.Agent
+		if err := json.Unmarshal([]byte(snapshot), &target); err != nil {
+			return fmt.Errorf("版本内容无效")
+		}
+		// 版本中引用的配置可能已被删除或不再属于本组织
+		if err := validateAgentConfigRefs(ac.DB, agent.UserID, target.LLMConfigID, target.TTSConfigID); err != nil {
+			return err
+		}
+		agent.Name = target.Name
+		agent.CustomPrompt = target.CustomPrompt
+		agent.LLMConfigID = target.LLMConfigID
+		agent.TTSConfigID = target.TTSConfigID
+		agent.Voice = target.Voice
+		agent.ASRSpeed = target.ASRSpeed
+		agent.MemoryMode = target.MemoryMode
+		agent.MCPServiceNames = target.MCPServiceNames
+		agent.OpenClawConfig = target.OpenClawConfig
+		agent.MCPToolPolicyConfig = target.MCPToolPolicyConfig
+		agent.Status = target.Status
+		return nil
+	})
+}
+
+// GetRoleVersions 角色的版本列表
+func (ac *AdminController) GetRoleVersions(c *gin.Context) {
+	if role, ok := ac.findVersionedRole(c); ok {
+		ac.listVersions(c, versionEntityRole, role.ID)
+	}
+}
+
+// GetRoleVersion 角色的指定版本
+func (ac *AdminController) GetRoleVersion(c *gin.Context) {
+	if role, ok := ac.findVersionedRole(c); ok {
+		ac.getVersion(c, versionEntityRole, role.ID)
+	}
+}
+
+// DiffRoleVersions 比较角色的两个版本
+func (ac *AdminController) DiffRoleVersions(c *gin.Context) {
+	if role, ok := ac.findVersionedRole(c); ok {
+		ac.diffVersions(c, versionEntityRole, role.ID)
+	}
+}
+
+// RollbackRoleVersion 将角色的提示词、模型和音色回滚到指定版本；类型、所属用户和默认标志不变
+func (ac *AdminController) RollbackRoleVersion(c *gin.Context) {
+	role, ok := ac.findVersionedRole(c)
+	if !ok {
+		return
+	}
+	ac.rollbackVersion(c, versionEntityRole, role.ID, role, func(snapshot string) error {
+		var target models.Role
+		if err := json.Unmarshal([]byte(snapshot), &target); err != nil {
+			return fmt.Errorf("版本内容无效")
+		}
+		role.Name = target.Name
+		role.Description = target.Description
+		role.Prompt = target.Prompt
+		role.LLMConfigID = target.LLMConfigID
+		role.TTSConfigID = target.TTSConfigID
+		role.Voice = target.Voice
+		role.Status = target.Status
+		role.SortOrder = target.SortOrder
+		return nil
+	})
+}
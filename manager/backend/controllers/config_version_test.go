@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/jsondiff"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestConfigVersionRollback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Config{}, &models.ConfigVersion{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 版本管理启用前已存在的配置，第一次修改时补记原内容
+	config := models.Config{Type: "llm", Name: "qwen", ConfigID: "qwen", JsonData: `{"model":"qwen-plus"}`, Enabled: true}
+	db.Create(&config)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("role", "admin")
+
+	before := versionSnapshot(&config)
+	config.JsonData = `{"model":"qwen-max"}`
+	db.Save(&config)
+	if v := recordVersion(db, c, versionEntityConfig, config.ID, before, &config, ""); v == nil || v.Version != 2 {
+		t.Fatalf("recordVersion = %+v, want version 2", v)
+	}
+	// 内容未变的保存不产生新版本
+	if v := recordVersion(db, c, versionEntityConfig, config.ID, before, &config, ""); v != nil {
+		t.Fatalf("unchanged save recorded version %d", v.Version)
+	}
+
+	ac := &AdminController{DB: db}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("role", "admin") })
+	r.POST("/api/admin/configs/:id/versions/:version/rollback", ac.RollbackConfigVersion)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/configs/1/versions/1/rollback", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("rollback status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	var restored models.Config
+	db.First(&restored, config.ID)
+	if restored.JsonData != `{"model":"qwen-plus"}` {
+		t.Errorf("json_data after rollback = %s", restored.JsonData)
+	}
+	var count int64
+	db.Model(&models.ConfigVersion{}).Where("entity_id = ?", config.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("versions after rollback = %d, want 3", count)
+	}
+}
+
+func TestRecordVersionRetriesOnConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 不使用默认事务，抢先写入的版本不会随失败的插入一起回滚
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "versions.db")), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ConfigVersion{}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	db.Create(&models.ConfigVersion{EntityType: versionEntityAgent, EntityID: 1, Version: 1, Snapshot: `{"name":"a"}`})
+
+	// 模拟并发保存：第一次写入前另一个请求抢先写入了同一版本号
+	raced := false
+	db.Callback().Create().Before("gorm:create").Register("test:race", func(tx *gorm.DB) {
+		if raced {
+			return
+		}
+		raced = true
+		tx.Session(&gorm.Session{NewDB: true}).Create(&models.ConfigVersion{EntityType: versionEntityAgent, EntityID: 1, Version: 2, Snapshot: `{"name":"b"}`})
+	})
+
+	v := recordVersion(db, c, versionEntityAgent, 1, "", map[string]string{"name": "c"}, "")
+	if v == nil || v.Version != 3 {
+		t.Fatalf("recordVersion = %+v, want version 3", v)
+	}
+}
+
+func TestConfigVersionDiffShowsMaxTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Config{}, &models.ConfigVersion{}); err != nil {
+		t.Fatal(err)
+	}
+	config := models.Config{Type: "llm", Name: "qwen", ConfigID: "qwen", JsonData: `{"max_tokens":1024,"api_key":"sk-a"}`, Enabled: true}
+	db.Create(&config)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	before := versionSnapshot(&config)
+	config.JsonData = `{"max_tokens":2048,"api_key":"sk-b"}`
+	db.Save(&config)
+	recordVersion(db, c, versionEntityConfig, config.ID, before, &config, "")
+
+	ac := &AdminController{DB: db}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("role", "admin") })
+	r.GET("/api/admin/configs/:id/versions/diff", ac.DiffConfigVersions)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/configs/1/versions/diff", nil))
+	var resp struct {
+		Data struct {
+			Diff map[string]struct {
+				Before interface{} `json:"before"`
+				After  interface{} `json:"after"`
+			} `json:"diff"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if change := resp.Data.Diff["json_data.max_tokens"]; change.Before != 1024.0 || change.After != 2048.0 {
+		t.Errorf("max_tokens change = %+v", change)
+	}
+	if change := resp.Data.Diff["json_data.api_key"]; change.Before != jsondiff.Masked || change.After != jsondiff.Masked {
+		t.Errorf("api_key change = %+v", change)
+	}
+}
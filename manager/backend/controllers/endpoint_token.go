@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 接入点令牌的用途，主程序校验时需指定
+const (
+	// mcpEndpointTokenPurpose 设备MCP接入点（外部MCP服务通过 /mcp 接入）
+	mcpEndpointTokenPurpose = "mcp-endpoint"
+	// openClawEndpointTokenPurpose OpenClaw 接入点
+	openClawEndpointTokenPurpose = "openclaw-endpoint"
+	// agentMCPServerTokenPurpose 智能体MCP服务器接入点
+	agentMCPServerTokenPurpose = "agent-mcp-server"
+	// agentMCPServerTokenTTL 智能体MCP服务器接入点令牌的有效期，到期后需重新获取接入点
+	agentMCPServerTokenTTL = 365 * 24 * time.Hour
+)
+
+// verifyEndpointToken 校验接入点令牌的签名、有效期、用途以及智能体是否存在；
+// 智能体MCP服务器的令牌还需与智能体当前的令牌版本一致
+func verifyEndpointToken(db *gorm.DB, purpose string, tokenString string) (*middleware.EndpointClaims, error) {
+	tokenString = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tokenString), "Bearer "))
+	if tokenString == "" {
+		return nil, errors.New("缺少令牌")
+	}
+	switch purpose {
+	case mcpEndpointTokenPurpose, openClawEndpointTokenPurpose, agentMCPServerTokenPurpose:
+	default:
+		return nil, fmt.Errorf("不支持的令牌用途: %s", purpose)
+	}
+	claims, err := middleware.ParseEndpointToken(tokenString, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	var agent models.Agent
+	if err := db.Select("id", "mcp_server_token_version").Where("id = ?", claims.AgentID).First(&agent).Error; err != nil {
+		return nil, errors.New("智能体不存在")
+	}
+	if purpose == agentMCPServerTokenPurpose && claims.Version != agent.MCPServerTokenVersion {
+		return nil, errors.New("令牌已被重置")
+	}
+	return claims, nil
+}
+
+// handleEndpointTokenVerifyRequest 主程序收到接入点请求时向管理后台校验令牌，签名密钥只保存在管理后台
+func (client *WebSocketClient) handleEndpointTokenVerifyRequest(request *WebSocketRequest) {
+	purpose, _ := request.Body["purpose"].(string)
+	token, _ := request.Body["token"].(string)
+	claims, err := verifyEndpointToken(client.controller.DB, purpose, token)
+	if err != nil {
+		client.sendResponse(request.ID, 401, nil, err.Error())
+		return
+	}
+	body := map[string]interface{}{
+		"user_id":     claims.UserID,
+		"agent_id":    claims.AgentID,
+		"endpoint_id": claims.EndpointID,
+	}
+	if claims.ExpiresAt != nil {
+		body["expires_at"] = claims.ExpiresAt.Unix()
+	}
+	client.sendResponse(request.ID, 200, body, "")
+}
+
+// rotateAgentMCPServerToken 递增智能体的令牌版本使已发放的智能体MCP服务器接入点失效，并返回新的接入点
+func rotateAgentMCPServerToken(db *gorm.DB, agentID string, userID uint) (string, error) {
+	result := db.Model(&models.Agent{}).Where("id = ?", agentID).
+		UpdateColumn("mcp_server_token_version", gorm.Expr("mcp_server_token_version + 1"))
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", errors.New("智能体不存在")
+	}
+	return GenerateAgentMCPServerEndpoint(db, agentID, userID)
+}
+
+// RotateAgentMCPServerToken 重置智能体MCP服务器接入点，旧的接入点URL立即失效
+func (ac *AdminController) RotateAgentMCPServerToken(c *gin.Context) {
+	agentID := c.Param("id")
+	if !agentInTenant(c, ac.DB, agentID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	uid, _ := userID.(uint)
+
+	serverEndpoint, err := rotateAgentMCPServerToken(ac.DB, agentID, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"server_endpoint": serverEndpoint}})
+}
+
+// RotateAgentMCPServerToken 重置智能体MCP服务器接入点（用户版本）
+func (uc *UserController) RotateAgentMCPServerToken(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	agentID := c.Param("id")
+	var agent models.Agent
+	if err := uc.DB.Where("id = ? AND user_id = ?", agentID, userID).First(&agent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在或不属于当前用户"})
+		return
+	}
+
+	serverEndpoint, err := rotateAgentMCPServerToken(uc.DB, agentID, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"server_endpoint": serverEndpoint}})
+}
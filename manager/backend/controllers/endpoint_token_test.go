@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/url"
+	"testing"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAgentMCPServerTokenRotate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Config{}, &models.Agent{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&models.Config{Type: "ota", Name: "ota", ConfigID: "ota", IsDefault: true, Enabled: true,
+		JsonData: `{"external":{"websocket":{"url":"wss://xiaozhi.example.com/xiaozhi/v1/"}}}`})
+	agent := models.Agent{UserID: 3, Name: "客厅"}
+	db.Create(&agent)
+	agentID := "1"
+
+	tokenOf := func(endpoint string) string {
+		t.Helper()
+		parsed, err := url.Parse(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Scheme != "https" || parsed.Path != "/xiaozhi/agent/mcp" {
+			t.Fatalf("unexpected endpoint %s", endpoint)
+		}
+		return parsed.Query().Get("token")
+	}
+
+	endpoint, err := GenerateAgentMCPServerEndpoint(db, agentID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken := tokenOf(endpoint)
+	claims, err := verifyEndpointToken(db, agentMCPServerTokenPurpose, "Bearer "+oldToken)
+	if err != nil {
+		t.Fatalf("token rejected: %v", err)
+	}
+	if claims.AgentID != agentID || claims.UserID != 3 || claims.ExpiresAt == nil {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if _, err := verifyEndpointToken(db, openClawEndpointTokenPurpose, oldToken); err == nil {
+		t.Error("token accepted for another purpose")
+	}
+
+	// 设备MCP和OpenClaw接入点令牌不设置过期时间，也不受重置影响
+	mcpToken, err := generateMCPToken(agentID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyEndpointToken(db, mcpEndpointTokenPurpose, mcpToken); err != nil {
+		t.Errorf("mcp endpoint token rejected: %v", err)
+	}
+	if _, err := verifyEndpointToken(db, openClawEndpointTokenPurpose, mcpToken); err == nil {
+		t.Error("mcp endpoint token accepted as openclaw token")
+	}
+
+	// 重置后旧令牌失效，新令牌可用
+	endpoint, err = rotateAgentMCPServerToken(db, agentID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyEndpointToken(db, agentMCPServerTokenPurpose, oldToken); err == nil {
+		t.Error("token still valid after rotate")
+	}
+	if _, err := verifyEndpointToken(db, agentMCPServerTokenPurpose, tokenOf(endpoint)); err != nil {
+		t.Errorf("rotated token rejected: %v", err)
+	}
+	if _, err := verifyEndpointToken(db, mcpEndpointTokenPurpose, mcpToken); err != nil {
+		t.Errorf("mcp endpoint token rejected after rotate: %v", err)
+	}
+
+	// 智能体删除后令牌失效
+	db.Delete(&models.Agent{}, agent.ID)
+	if _, err := verifyEndpointToken(db, agentMCPServerTokenPurpose, tokenOf(endpoint)); err == nil {
+		t.Error("token valid for deleted agent")
+	}
+}
@@ -1888,6 +1888,9 @@ func (ac *AdminController) GetUserKnowledgeBasesAdmin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
 	}
+	if !ensureTenantUser(c, ac.DB, uint(userID)) {
+		return
+	}
 	var items []models.KnowledgeBase
 	if err := ac.DB.Where("user_id = ?", userID).Order("id DESC").Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取知识库列表失败"})
@@ -1905,6 +1908,9 @@ func (ac *AdminController) CreateUserKnowledgeBaseAdmin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
 	}
+	if !ensureTenantUser(c, ac.DB, uint(userID)) {
+		return
+	}
 	var req struct {
 		Name                   string   `json:"name" binding:"required,min=1,max=100"`
 		Description            string   `json:"description"`
@@ -1965,6 +1971,9 @@ func (ac *AdminController) UpdateUserKnowledgeBaseAdmin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的参数"})
 		return
 	}
+	if !ensureTenantUser(c, ac.DB, uint(userID)) {
+		return
+	}
 	var item models.KnowledgeBase
 	if err := ac.DB.Where("id = ? AND user_id = ?", kbID, userID).First(&item).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "知识库不存在"})
@@ -2025,6 +2034,9 @@ func (ac *AdminController) DeleteUserKnowledgeBaseAdmin(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的参数"})
 		return
 	}
+	if !ensureTenantUser(c, ac.DB, uint(userID)) {
+		return
+	}
 
 	var item models.KnowledgeBase
 	if err := ac.DB.Where("id = ? AND user_id = ?", kbID, userID).First(&item).Error; err != nil {
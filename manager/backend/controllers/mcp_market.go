@@ -758,15 +758,20 @@ type marketWithAuth struct {
 }
 
 type mcpServerConfig struct {
-	Name      string            `json:"name"`
-	Type      string            `json:"type"`
-	Url       string            `json:"url"`
-	SSEUrl    string            `json:"sse_url,omitempty"`
-	Enabled   bool              `json:"enabled"`
-	Provider  string            `json:"provider,omitempty"`
-	ServiceID string            `json:"service_id,omitempty"`
-	AuthRef   string            `json:"auth_ref,omitempty"`
-	Headers   map[string]string `json:"headers,omitempty"`
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Url         string            `json:"url"`
+	SSEUrl      string            `json:"sse_url,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Provider    string            `json:"provider,omitempty"`
+	ServiceID   string            `json:"service_id,omitempty"`
+	AuthRef     string            `json:"auth_ref,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Command     string            `json:"command,omitempty"`
+	Args        []string          `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	WorkDir     string            `json:"work_dir,omitempty"`
+	ToolTimeout int               `json:"tool_timeout,omitempty"`
 }
 
 func buildStoredMarketConfig(req upsertMCPMarketRequest, existing *mcpmarket.MarketConnection) (mcpmarket.MarketConnection, error) {
@@ -267,7 +267,15 @@ func (ac *AdminController) mergeMCPWithEnabledMarketServices(manualMCP map[strin
 	if err != nil {
 		return nil, nil, err
 	}
-	return mergeManualAndMarketServers(manualMCP, services)
+	merged, warnings, err := mergeManualAndMarketServers(manualMCP, services)
+	if err != nil {
+		return nil, nil, err
+	}
+	global := asMap(merged["global"])
+	if servers, ok := global["servers"].([]mcpServerConfig); ok {
+		global["servers"] = attachMCPOAuthAuthRefs(ac.DB, servers)
+	}
+	return merged, warnings, nil
 }
 
 func filterEnabledMarketServices(rows []models.MCPMarketService) []models.MCPMarketService {
@@ -0,0 +1,453 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+	mcpmarket "xiaozhi/manager/backend/services/mcp_market"
+	mcpoauth "xiaozhi/manager/backend/services/mcp_oauth"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	mcpOAuthStatusPending    = "pending"
+	mcpOAuthStatusAuthorized = "authorized"
+	mcpOAuthStatusError      = "error"
+
+	// mcpOAuthAuthRefPrefix 下发给主程序的 auth_ref 前缀，主程序据此向管理后台获取访问令牌
+	mcpOAuthAuthRefPrefix = "oauth:"
+	// mcpOAuthRefreshBefore 访问令牌剩余有效期小于该值时提前刷新
+	mcpOAuthRefreshBefore = 2 * time.Minute
+	mcpOAuthCallbackPath  = "/api/mcp-oauth/callback"
+	mcpOAuthHTTPTimeout   = 30 * time.Second
+)
+
+// 刷新令牌可能被授权服务器轮换，串行刷新避免并发请求使用已作废的刷新令牌
+var mcpOAuthRefreshMu sync.Mutex
+
+type startMCPOAuthRequest struct {
+	URL          string `json:"url" binding:"required"`
+	Scope        string `json:"scope"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURI  string `json:"redirect_uri"`
+}
+
+type mcpOAuthCredentialView struct {
+	ID                  uint   `json:"id"`
+	URL                 string `json:"url"`
+	Status              string `json:"status"`
+	AuthorizationServer string `json:"authorization_server"`
+	Scope               string `json:"scope,omitempty"`
+	ClientID            string `json:"client_id"`
+	DynamicClient       bool   `json:"dynamic_client"`
+	HasRefreshToken     bool   `json:"has_refresh_token"`
+	ExpiresAt           string `json:"expires_at,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+	UpdatedAt           string `json:"updated_at"`
+}
+
+func toMCPOAuthCredentialView(row models.MCPOAuthCredential) mcpOAuthCredentialView {
+	view := mcpOAuthCredentialView{
+		ID:                  row.ID,
+		URL:                 row.URL,
+		Status:              row.Status,
+		AuthorizationServer: row.AuthorizationServer,
+		Scope:               row.Scope,
+		ClientID:            row.ClientID,
+		DynamicClient:       row.DynamicClient,
+		HasRefreshToken:     row.RefreshTokenCiphertext != "",
+		LastError:           row.LastError,
+		UpdatedAt:           row.UpdatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if row.ExpiresAt != nil {
+		view.ExpiresAt = row.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	return view
+}
+
+// mcpOAuthRedirectURI 回调地址优先使用前端传入的地址（与浏览器访问的域名一致），否则按请求推断
+func mcpOAuthRedirectURI(c *gin.Context, requested string) (string, error) {
+	if requested = strings.TrimSpace(requested); requested != "" {
+		u, err := url.Parse(requested)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != mcpOAuthCallbackPath {
+			return "", fmt.Errorf("redirect_uri 必须是管理后台的 %s 地址", mcpOAuthCallbackPath)
+		}
+		return requested, nil
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.TrimSpace(c.GetHeader("X-Forwarded-Proto")); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + mcpOAuthCallbackPath, nil
+}
+
+// StartMCPOAuth 对远程MCP服务发起 OAuth 授权：发现授权服务器、动态注册客户端、生成 PKCE，返回授权页地址
+func (ac *AdminController) StartMCPOAuth(c *gin.Context) {
+	var req startMCPOAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	urlHash := normalizedURLHash(req.URL)
+	if urlHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url 格式不正确"})
+		return
+	}
+	redirectURI, err := mcpOAuthRedirectURI(c, req.RedirectURI)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mcpOAuthHTTPTimeout)
+	defer cancel()
+	meta, err := mcpoauth.Discover(ctx, req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "发现授权服务器失败: " + err.Error()})
+		return
+	}
+
+	var row models.MCPOAuthCredential
+	if err := ac.DB.Where("url_hash = ?", urlHash).First(&row).Error; err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询授权信息失败"})
+		return
+	}
+
+	client := mcpoauth.ClientCredentials{ClientID: strings.TrimSpace(req.ClientID), ClientSecret: strings.TrimSpace(req.ClientSecret)}
+	dynamicClient := false
+	switch {
+	case client.ClientID != "":
+	case row.ClientID != "" && row.DynamicClient && row.RedirectURI == redirectURI && row.AuthorizationServer == meta.AuthorizationServer:
+		// 复用已动态注册的客户端，避免每次授权都注册新客户端
+		client.ClientID = row.ClientID
+		dynamicClient = true
+		if client.ClientSecret, err = mcpmarket.DecryptText(row.ClientSecretCiphertext, row.ClientSecretNonce); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "解密客户端密钥失败: " + err.Error()})
+			return
+		}
+	default:
+		registered, err := mcpoauth.RegisterClient(ctx, meta, redirectURI)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		client = *registered
+		dynamicClient = true
+	}
+
+	verifier, challenge, err := mcpoauth.NewPKCE()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	state, err := mcpoauth.NewState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	scope := strings.TrimSpace(req.Scope)
+	if scope == "" && len(meta.ScopesSupported) > 0 {
+		scope = strings.Join(meta.ScopesSupported, " ")
+	}
+	authorizeURL, err := mcpoauth.AuthorizeURL(meta, client.ClientID, redirectURI, state, challenge, scope)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secretCiphertext, secretNonce, err := mcpmarket.EncryptText(client.ClientSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加密客户端密钥失败: " + err.Error()})
+		return
+	}
+	verifierCiphertext, verifierNonce, err := mcpmarket.EncryptText(verifier)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加密 code_verifier 失败: " + err.Error()})
+		return
+	}
+
+	row.URL = strings.TrimSpace(req.URL)
+	row.URLHash = urlHash
+	if row.Status != mcpOAuthStatusAuthorized {
+		// 已授权的服务重新授权期间继续使用旧令牌
+		row.Status = mcpOAuthStatusPending
+	}
+	row.Resource = meta.Resource
+	row.AuthorizationServer = meta.AuthorizationServer
+	row.AuthorizationEndpoint = meta.AuthorizationEndpoint
+	row.TokenEndpoint = meta.TokenEndpoint
+	row.Scope = scope
+	row.RedirectURI = redirectURI
+	row.ClientID = client.ClientID
+	row.ClientSecretCiphertext = secretCiphertext
+	row.ClientSecretNonce = secretNonce
+	row.DynamicClient = dynamicClient
+	row.State = state
+	row.CodeVerifierCiphertext = verifierCiphertext
+	row.CodeVerifierNonce = verifierNonce
+	row.LastError = ""
+	if err := ac.DB.Save(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存授权信息失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"id":            row.ID,
+		"authorize_url": authorizeURL,
+		"redirect_uri":  redirectURI,
+	}})
+}
+
+// MCPOAuthCallback 授权服务器回调：用授权码换取令牌并加密保存，完成后通知主程序重新加载MCP配置
+func (ac *AdminController) MCPOAuthCallback(c *gin.Context) {
+	state := strings.TrimSpace(c.Query("state"))
+	if state == "" {
+		mcpOAuthCallbackPage(c, http.StatusBadRequest, "授权失败", "缺少 state 参数")
+		return
+	}
+	var row models.MCPOAuthCredential
+	if err := ac.DB.Where("state = ?", state).First(&row).Error; err != nil {
+		mcpOAuthCallbackPage(c, http.StatusBadRequest, "授权失败", "授权请求不存在或已过期，请重新发起授权")
+		return
+	}
+
+	fail := func(message string) {
+		ac.DB.Model(&row).Updates(map[string]interface{}{"state": "", "last_error": message})
+		if row.Status != mcpOAuthStatusAuthorized {
+			ac.DB.Model(&row).Update("status", mcpOAuthStatusError)
+		}
+		mcpOAuthCallbackPage(c, http.StatusBadRequest, "授权失败", message)
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		fail(strings.TrimSpace(errCode + " " + c.Query("error_description")))
+		return
+	}
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		fail("缺少 code 参数")
+		return
+	}
+
+	verifier, err := mcpmarket.DecryptText(row.CodeVerifierCiphertext, row.CodeVerifierNonce)
+	if err != nil {
+		fail("解密 code_verifier 失败: " + err.Error())
+		return
+	}
+	client, err := mcpOAuthClient(row)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mcpOAuthHTTPTimeout)
+	defer cancel()
+	token, err := mcpoauth.ExchangeCode(ctx, row.TokenEndpoint, client, code, verifier, row.RedirectURI, row.Resource)
+	if err != nil {
+		fail("换取令牌失败: " + err.Error())
+		return
+	}
+
+	updates, err := mcpOAuthTokenUpdates(token, "")
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	updates["status"] = mcpOAuthStatusAuthorized
+	updates["state"] = ""
+	updates["code_verifier_ciphertext"] = ""
+	updates["code_verifier_nonce"] = ""
+	if err := ac.DB.Model(&row).Updates(updates).Error; err != nil {
+		mcpOAuthCallbackPage(c, http.StatusInternalServerError, "授权失败", "保存令牌失败")
+		return
+	}
+
+	log.Printf("MCP服务 OAuth 授权成功: %s", row.URL)
+	ac.notifySystemConfigChanged()
+	mcpOAuthCallbackPage(c, http.StatusOK, "授权成功", "MCP服务已完成授权，可以关闭此页面")
+}
+
+func mcpOAuthCallbackPage(c *gin.Context, status int, title, message string) {
+	c.Data(status, "text/html; charset=utf-8", []byte(fmt.Sprintf(
+		`<!DOCTYPE html><html><head><meta charset="utf-8"><title>%s</title></head><body style="font-family:sans-serif;text-align:center;padding-top:80px"><h2>%s</h2><p>%s</p></body></html>`,
+		html.EscapeString(title), html.EscapeString(title), html.EscapeString(message))))
+}
+
+func mcpOAuthClient(row models.MCPOAuthCredential) (mcpoauth.ClientCredentials, error) {
+	secret, err := mcpmarket.DecryptText(row.ClientSecretCiphertext, row.ClientSecretNonce)
+	if err != nil {
+		return mcpoauth.ClientCredentials{}, fmt.Errorf("解密客户端密钥失败: %w", err)
+	}
+	return mcpoauth.ClientCredentials{ClientID: row.ClientID, ClientSecret: secret}, nil
+}
+
+// mcpOAuthTokenUpdates 加密令牌并生成更新字段；刷新响应未返回新刷新令牌时保留原刷新令牌
+func mcpOAuthTokenUpdates(token *mcpoauth.Token, previousRefreshToken string) (map[string]interface{}, error) {
+	accessCiphertext, accessNonce, err := mcpmarket.EncryptText(token.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("加密访问令牌失败: %w", err)
+	}
+	refreshToken := token.RefreshToken
+	if refreshToken == "" {
+		refreshToken = previousRefreshToken
+	}
+	refreshCiphertext, refreshNonce, err := mcpmarket.EncryptText(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("加密刷新令牌失败: %w", err)
+	}
+	return map[string]interface{}{
+		"access_token_ciphertext":  accessCiphertext,
+		"access_token_nonce":       accessNonce,
+		"refresh_token_ciphertext": refreshCiphertext,
+		"refresh_token_nonce":      refreshNonce,
+		"token_type":               token.TokenType,
+		"expires_at":               token.ExpiresAt(time.Now()),
+		"last_error":               "",
+	}, nil
+}
+
+// issueMCPOAuthAccessToken 返回可用的访问令牌，即将过期时先用刷新令牌换新
+func issueMCPOAuthAccessToken(ctx context.Context, db *gorm.DB, id uint) (string, string, *time.Time, error) {
+	mcpOAuthRefreshMu.Lock()
+	defer mcpOAuthRefreshMu.Unlock()
+
+	var row models.MCPOAuthCredential
+	if err := db.First(&row, id).Error; err != nil {
+		return "", "", nil, fmt.Errorf("授权信息不存在")
+	}
+	if row.AccessTokenCiphertext == "" {
+		return "", "", nil, fmt.Errorf("MCP服务 %s 尚未完成 OAuth 授权", row.URL)
+	}
+	if row.ExpiresAt == nil || time.Until(*row.ExpiresAt) > mcpOAuthRefreshBefore {
+		accessToken, err := mcpmarket.DecryptText(row.AccessTokenCiphertext, row.AccessTokenNonce)
+		return accessToken, row.TokenType, row.ExpiresAt, err
+	}
+
+	refreshToken, err := mcpmarket.DecryptText(row.RefreshTokenCiphertext, row.RefreshTokenNonce)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("解密刷新令牌失败: %w", err)
+	}
+	if refreshToken == "" {
+		db.Model(&row).Updates(map[string]interface{}{"status": mcpOAuthStatusError, "last_error": "访问令牌已过期且没有刷新令牌，请重新授权"})
+		return "", "", nil, fmt.Errorf("MCP服务 %s 的访问令牌已过期，请重新授权", row.URL)
+	}
+	client, err := mcpOAuthClient(row)
+	if err != nil {
+		return "", "", nil, err
+	}
+	token, err := mcpoauth.Refresh(ctx, row.TokenEndpoint, client, refreshToken, row.Resource)
+	if err != nil {
+		db.Model(&row).Updates(map[string]interface{}{"status": mcpOAuthStatusError, "last_error": "刷新令牌失败: " + err.Error()})
+		return "", "", nil, fmt.Errorf("刷新令牌失败: %w", err)
+	}
+	updates, err := mcpOAuthTokenUpdates(token, refreshToken)
+	if err != nil {
+		return "", "", nil, err
+	}
+	updates["status"] = mcpOAuthStatusAuthorized
+	if err := db.Model(&row).Updates(updates).Error; err != nil {
+		return "", "", nil, fmt.Errorf("保存刷新后的令牌失败: %w", err)
+	}
+	log.Printf("MCP服务 OAuth 令牌已刷新: %s", row.URL)
+	return token.AccessToken, token.TokenType, token.ExpiresAt(time.Now()), nil
+}
+
+// handleMCPOAuthTokenRequest 主程序连接需要 OAuth 的MCP服务时获取短期访问令牌
+func (client *WebSocketClient) handleMCPOAuthTokenRequest(request *WebSocketRequest) {
+	authRef, _ := request.Body["auth_ref"].(string)
+	id, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(authRef), mcpOAuthAuthRefPrefix), 10, 64)
+	if !strings.HasPrefix(authRef, mcpOAuthAuthRefPrefix) || err != nil || id == 0 {
+		client.sendResponse(request.ID, 400, nil, "auth_ref 格式不正确")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mcpOAuthHTTPTimeout)
+	defer cancel()
+	accessToken, tokenType, expiresAt, err := issueMCPOAuthAccessToken(ctx, client.controller.DB, uint(id))
+	if err != nil {
+		client.sendResponse(request.ID, 500, nil, err.Error())
+		return
+	}
+	body := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   tokenType,
+	}
+	if expiresAt != nil {
+		body["expires_at"] = expiresAt.Unix()
+	}
+	client.sendResponse(request.ID, 200, body, "")
+}
+
+// attachMCPOAuthAuthRefs 为已授权且未手动配置 Authorization 头的服务设置 auth_ref，主程序连接时据此获取令牌
+func attachMCPOAuthAuthRefs(db *gorm.DB, servers []mcpServerConfig) []mcpServerConfig {
+	var rows []models.MCPOAuthCredential
+	if err := db.Select("id", "url_hash", "access_token_ciphertext").Where("access_token_ciphertext <> ''").Find(&rows).Error; err != nil || len(rows) == 0 {
+		return servers
+	}
+	byHash := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		byHash[row.URLHash] = row.ID
+	}
+	for i, server := range servers {
+		if strings.TrimSpace(server.AuthRef) != "" || hasAuthorizationHeader(server.Headers) {
+			continue
+		}
+		if id, ok := byHash[normalizedURLHash(normalizeServerURL(server))]; ok {
+			servers[i].AuthRef = fmt.Sprintf("%s%d", mcpOAuthAuthRefPrefix, id)
+		}
+	}
+	return servers
+}
+
+func hasAuthorizationHeader(headers map[string]string) bool {
+	for key := range headers {
+		if strings.EqualFold(strings.TrimSpace(key), "Authorization") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMCPOAuthCredentials 已授权/授权中的MCP服务列表
+func (ac *AdminController) GetMCPOAuthCredentials(c *gin.Context) {
+	var rows []models.MCPOAuthCredential
+	if err := ac.DB.Order("id DESC").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询授权信息失败"})
+		return
+	}
+	items := make([]mcpOAuthCredentialView, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, toMCPOAuthCredentialView(row))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// DeleteMCPOAuthCredential 删除授权信息，对应服务恢复为不带令牌连接
+func (ac *AdminController) DeleteMCPOAuthCredential(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var row models.MCPOAuthCredential
+	if err := ac.DB.First(&row, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "授权信息不存在"})
+		return
+	}
+	if err := ac.DB.Delete(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除授权信息失败"})
+		return
+	}
+	ac.notifySystemConfigChanged()
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
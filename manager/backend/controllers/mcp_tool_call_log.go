@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	toolCallLogDefaultPageSize = 20
+	toolCallLogMaxPageSize     = 200
+	// toolCallLogSourceDevice 设备/智能体接入点上报的工具，只有这类工具可以通过 mcp-call 接口重放
+	toolCallLogSourceDevice = "device"
+)
+
+// ToolCallWebSocketInterface 重放工具调用所需的WebSocket能力
+type ToolCallWebSocketInterface interface {
+	CallMcpToolFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+}
+
+// toolCallLogHandler 工具调用日志查询与重放，管理员与普通用户共用；userID 为 0 时不限制用户
+type toolCallLogHandler struct {
+	db     *gorm.DB
+	ws     ToolCallWebSocketInterface
+	userID uint
+}
+
+// handleToolCallLogRequest 保存主程序上报的工具调用记录
+func (client *WebSocketClient) handleToolCallLogRequest(request *WebSocketRequest) {
+	if request.Body == nil {
+		client.sendResponse(request.ID, 400, nil, "缺少请求体")
+		return
+	}
+	bodyString := func(key string) string {
+		value, _ := request.Body[key].(string)
+		return strings.TrimSpace(value)
+	}
+
+	entry := models.MCPToolCallLog{
+		AgentID:       bodyString("agent_id"),
+		DeviceID:      bodyString("device_id"),
+		SessionID:     bodyString("session_id"),
+		Speaker:       bodyString("speaker"),
+		ToolCallID:    bodyString("tool_call_id"),
+		ToolName:      bodyString("tool_name"),
+		Source:        bodyString("source"),
+		ServerName:    bodyString("server_name"),
+		Arguments:     bodyString("arguments"),
+		ResultSummary: bodyString("result_summary"),
+		Status:        bodyString("status"),
+		Error:         bodyString("error"),
+	}
+	if entry.ToolName == "" {
+		client.sendResponse(request.ID, 400, nil, "缺少tool_name参数")
+		return
+	}
+	if latency, ok := request.Body["latency_ms"].(float64); ok {
+		entry.LatencyMs = int64(latency)
+	}
+	if calledAt, err := time.Parse(time.RFC3339, bodyString("called_at")); err == nil {
+		entry.CreatedAt = calledAt
+	}
+	entry.UserID = client.controller.toolCallLogOwner(entry.AgentID, entry.DeviceID)
+
+	if err := client.controller.DB.Create(&entry).Error; err != nil {
+		log.Printf("保存工具调用记录失败: %v", err)
+		client.sendResponse(request.ID, 500, nil, fmt.Sprintf("保存工具调用记录失败: %v", err))
+		return
+	}
+	client.sendResponse(request.ID, 200, map[string]interface{}{"id": entry.ID}, "")
+	emitWebhookEvent(client.controller.DB, entry.UserID, webhookEventToolCall, entry)
+}
+
+// toolCallLogOwner 根据智能体或设备查找所属用户
+func (ctrl *WebSocketController) toolCallLogOwner(agentID, deviceID string) uint {
+	if id, err := strconv.ParseUint(agentID, 10, 64); err == nil && id > 0 {
+		var agent models.Agent
+		if err := ctrl.DB.Select("id", "user_id").Where("id = ?", id).First(&agent).Error; err == nil {
+			return agent.UserID
+		}
+	}
+	if deviceID != "" {
+		var device models.Device
+		if err := ctrl.DB.Select("id", "user_id").Where("device_name = ?", deviceID).First(&device).Error; err == nil {
+			return device.UserID
+		}
+	}
+	return 0
+}
+
+func (h toolCallLogHandler) scoped() *gorm.DB {
+	query := h.db.Model(&models.MCPToolCallLog{})
+	if h.userID != 0 {
+		query = query.Where("user_id = ?", h.userID)
+	}
+	return query
+}
+
+// parseToolCallLogTime 支持 RFC3339 和 "2006-01-02 15:04:05" 两种格式
+func parseToolCallLogTime(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", raw, time.Local); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// list 分页查询工具调用记录，支持按智能体、设备、工具、来源、状态、说话人和时间范围过滤
+func (h toolCallLogHandler) list(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(toolCallLogDefaultPageSize)))
+	if pageSize < 1 || pageSize > toolCallLogMaxPageSize {
+		pageSize = toolCallLogDefaultPageSize
+	}
+
+	query := h.scoped()
+	if h.userID == 0 {
+		if userID := c.Query("user_id"); userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+	for _, field := range []string{"agent_id", "device_id", "source", "status", "speaker", "session_id"} {
+		if value := strings.TrimSpace(c.Query(field)); value != "" {
+			query = query.Where(field+" = ?", value)
+		}
+	}
+	if toolName := strings.TrimSpace(c.Query("tool_name")); toolName != "" {
+		query = query.Where("tool_name LIKE ?", "%"+toolName+"%")
+	}
+	if start, ok := parseToolCallLogTime(c.Query("start_time")); ok {
+		query = query.Where("created_at >= ?", start)
+	}
+	if end, ok := parseToolCallLogTime(c.Query("end_time")); ok {
+		query = query.Where("created_at <= ?", end)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询工具调用记录失败"})
+		return
+	}
+	var logs []models.MCPToolCallLog
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询工具调用记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"data":      logs,
+	})
+}
+
+// replay 使用原参数重新调用工具：优先按设备调用，设备上不存在时按智能体接入点调用
+func (h toolCallLogHandler) replay(c *gin.Context) {
+	var entry models.MCPToolCallLog
+	if err := h.scoped().Where("id = ?", c.Param("id")).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工具调用记录不存在"})
+		return
+	}
+	if entry.Source != toolCallLogSourceDevice {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "仅支持重放设备或智能体接入点上报的MCP工具"})
+		return
+	}
+	if h.ws == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "websocket controller unavailable"})
+		return
+	}
+
+	arguments := map[string]interface{}{}
+	if strings.TrimSpace(entry.Arguments) != "" {
+		if err := json.Unmarshal([]byte(entry.Arguments), &arguments); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "记录中的参数格式错误，无法重放"})
+			return
+		}
+	}
+
+	targets := make([]map[string]interface{}, 0, 2)
+	if entry.DeviceID != "" {
+		targets = append(targets, map[string]interface{}{"device_id": entry.DeviceID})
+	}
+	if entry.AgentID != "" {
+		targets = append(targets, map[string]interface{}{"agent_id": entry.AgentID})
+	}
+
+	var lastErr error
+	for _, body := range targets {
+		body["tool_name"] = entry.ToolName
+		body["arguments"] = arguments
+		result, err := h.ws.CallMcpToolFromClient(context.Background(), body)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{"data": result})
+			return
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("记录中缺少设备和智能体信息")
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "调用MCP工具失败: " + lastErr.Error()})
+}
+
+// ==================== 管理员版本 ====================
+
+func (ac *AdminController) toolCallLogHandler() toolCallLogHandler {
+	h := toolCallLogHandler{db: ac.DB}
+	if ac.WebSocketController != nil {
+		h.ws = ac.WebSocketController
+	}
+	return h
+}
+
+// GetMCPToolCallLogs 工具调用记录（管理员版本）
+func (ac *AdminController) GetMCPToolCallLogs(c *gin.Context) {
+	ac.toolCallLogHandler().list(c)
+}
+
+// ReplayMCPToolCall 重放工具调用（管理员版本）
+func (ac *AdminController) ReplayMCPToolCall(c *gin.Context) {
+	ac.toolCallLogHandler().replay(c)
+}
+
+// ==================== 用户版本 ====================
+
+func (uc *UserController) toolCallLogHandler(c *gin.Context) (toolCallLogHandler, bool) {
+	userID, ok := c.Get("user_id")
+	id, _ := userID.(uint)
+	if !ok || id == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return toolCallLogHandler{}, false
+	}
+	h := toolCallLogHandler{db: uc.DB, userID: id}
+	if uc.WebSocketController != nil {
+		h.ws = uc.WebSocketController
+	}
+	return h, true
+}
+
+// GetMCPToolCallLogs 当前用户智能体的工具调用记录
+func (uc *UserController) GetMCPToolCallLogs(c *gin.Context) {
+	if h, ok := uc.toolCallLogHandler(c); ok {
+		h.list(c)
+	}
+}
+
+// ReplayMCPToolCall 重放当前用户的工具调用
+func (uc *UserController) ReplayMCPToolCall(c *gin.Context) {
+	if h, ok := uc.toolCallLogHandler(c); ok {
+		h.replay(c)
+	}
+}
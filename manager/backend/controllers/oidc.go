@@ -0,0 +1,411 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateTTL  = 10 * time.Minute
+	oidcTicketTTL = time.Minute
+	// oidcLoginPage 回调完成后跳回前端登录页，通过一次性票据换取令牌，避免令牌出现在URL中
+	oidcLoginPage = "/login"
+	// oidcStateCookie 保存发起登录的浏览器持有的 state 哈希，回调时必须一致，防止登录 CSRF
+	oidcStateCookie     = "xiaozhi_oidc_state"
+	oidcStateCookiePath = "/api/auth/oidc"
+
+	authProviderLocal = "local"
+	authProviderOIDC  = "oidc"
+)
+
+var oidcUsernamePattern = regexp.MustCompile(`[^a-zA-Z0-9_.@-]+`)
+
+// NewAuthController 创建认证控制器，启用 OIDC 时初始化 IdP 客户端
+func NewAuthController(db *gorm.DB, cfg *config.Config) *AuthController {
+	ac := &AuthController{DB: db}
+	if cfg == nil {
+		return ac
+	}
+	ac.TwoFactor = cfg.TwoFactor
+	if cfg.OIDC.Enabled {
+		ac.OIDC = cfg.OIDC
+		ac.oidcProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		})
+	}
+	return ac
+}
+
+func (ac *AuthController) oidcEnabled() bool {
+	return ac.oidcProvider != nil
+}
+
+// localLoginDisabled 启用 OIDC 且配置了禁用本地密码时，不允许用户名密码登录和注册
+func (ac *AuthController) localLoginDisabled() bool {
+	return ac.oidcEnabled() && ac.OIDC.DisableLocalLogin
+}
+
+// 获取登录方式（公开）
+func (ac *AuthController) GetOIDCConfig(c *gin.Context) {
+	providerName := ac.OIDC.ProviderName
+	if providerName == "" {
+		providerName = "SSO"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":             ac.oidcEnabled(),
+		"provider_name":       providerName,
+		"local_login_enabled": !ac.localLoginDisabled(),
+	})
+}
+
+// 发起 OIDC 登录：保存 state/nonce/PKCE 后跳转到 IdP
+func (ac *AuthController) OIDCLogin(c *gin.Context) {
+	if !ac.oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用单点登录"})
+		return
+	}
+
+	state, err := oidc.RandomString(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成登录状态失败"})
+		return
+	}
+	nonce, err := oidc.RandomString(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成登录状态失败"})
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成登录状态失败"})
+		return
+	}
+
+	authURL, err := ac.oidcProvider.AuthCodeURL(c.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("[OIDCLogin] ❌ 构造授权地址失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "单点登录服务不可用: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	ac.DB.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{})
+	if err := ac.DB.Create(&models.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存登录状态失败"})
+		return
+	}
+	setOIDCStateCookie(c, hashOIDCTicket(state), int(oidcStateTTL/time.Second))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDC 回调：校验 id_token、创建或更新用户，然后带一次性票据跳回前端
+func (ac *AuthController) OIDCCallback(c *gin.Context) {
+	if !ac.oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用单点登录"})
+		return
+	}
+	fail := func(message string) {
+		c.Redirect(http.StatusFound, oidcLoginPage+"?oidc_error="+url.QueryEscape(message))
+	}
+
+	if idpErr := c.Query("error"); idpErr != "" {
+		log.Printf("[OIDCCallback] IdP 返回错误: %s %s", idpErr, c.Query("error_description"))
+		fail("单点登录被拒绝: " + idpErr)
+		return
+	}
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		fail("缺少 state 或 code")
+		return
+	}
+	// state 必须由当前浏览器发起，否则可能是攻击者诱导受害者登录攻击者的账号
+	stateCookie, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if subtle.ConstantTimeCompare([]byte(stateCookie), []byte(hashOIDCTicket(state))) != 1 {
+		fail("登录状态不匹配，请重试")
+		return
+	}
+
+	var loginState models.OIDCLoginState
+	if err := ac.DB.Where("state = ? AND ticket_hash IS NULL", state).First(&loginState).Error; err != nil || time.Now().After(loginState.ExpiresAt) {
+		fail("登录请求已过期，请重试")
+		return
+	}
+
+	claims, err := ac.oidcProvider.Exchange(c.Request.Context(), code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("[OIDCCallback] ❌ 校验 id_token 失败: %v", err)
+		ac.DB.Delete(&loginState)
+		fail("单点登录校验失败")
+		return
+	}
+
+	user, err := ac.provisionOIDCUser(claims)
+	if err != nil {
+		log.Printf("[OIDCCallback] ❌ 用户开通失败: %v", err)
+		ac.DB.Delete(&loginState)
+		fail(err.Error())
+		return
+	}
+
+	ticket, err := oidc.RandomString(32)
+	if err != nil {
+		fail("生成登录票据失败")
+		return
+	}
+	ticketHash := hashOIDCTicket(ticket)
+	if err := ac.DB.Model(&loginState).Updates(map[string]interface{}{
+		"ticket_hash": ticketHash,
+		"user_id":     user.ID,
+		"expires_at":  time.Now().Add(oidcTicketTTL),
+	}).Error; err != nil {
+		fail("保存登录票据失败")
+		return
+	}
+
+	log.Printf("[OIDCCallback] ✅ 单点登录成功 - 用户: %s, 角色: %s", user.Username, user.Role)
+	c.Redirect(http.StatusFound, oidcLoginPage+"?oidc_ticket="+url.QueryEscape(ticket))
+}
+
+type OIDCExchangeRequest struct {
+	Ticket string `json:"ticket" binding:"required"`
+}
+
+// 前端用一次性票据换取访问令牌和刷新令牌
+func (ac *AuthController) OIDCExchange(c *gin.Context) {
+	if !ac.oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用单点登录"})
+		return
+	}
+	var req OIDCExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var loginState models.OIDCLoginState
+	if err := ac.DB.Where("ticket_hash = ?", hashOIDCTicket(req.Ticket)).First(&loginState).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录票据无效"})
+		return
+	}
+	// 票据只能使用一次
+	if result := ac.DB.Delete(&loginState); result.Error != nil || result.RowsAffected == 0 || time.Now().After(loginState.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录票据已过期"})
+		return
+	}
+
+	var user models.User
+	if err := ac.DB.First(&user, loginState.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+	tokens, err := middleware.IssueSession(ac.DB, user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user": gin.H{
+			"id":       user.ID,
+			"username": user.Username,
+			"email":    user.Email,
+			"role":     user.Role,
+		},
+	})
+}
+
+// setOIDCStateCookie 写入或清除（maxAge<0）登录状态 Cookie；
+// SameSite=Lax 保证 IdP 跳转回来的顶层 GET 请求仍会带上
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcStateCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func hashOIDCTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// oidcOrgRoles 组织内角色按权限从高到低排列，用户属于多个角色的组时取最高的
+var oidcOrgRoles = []string{middleware.RoleOrgOwner, middleware.RoleOperator, middleware.RoleViewer}
+
+// oidcRole 按组映射角色：属于 admin_groups 的为管理员，其次按 org_role_groups 映射组织内角色。
+// 不属于任何映射的组时，由单点登录管理的角色（管理员、已配置组的组织角色）降为普通用户，
+// 其余在控制台分配的组织角色保持不变
+func oidcRole(groups []string, cfg config.OIDCConfig, current string) string {
+	if groupsIntersect(groups, cfg.AdminGroups) {
+		return middleware.RoleAdmin
+	}
+	for _, role := range oidcOrgRoles {
+		if groupsIntersect(groups, cfg.OrgRoleGroups[role]) {
+			return role
+		}
+	}
+	for _, role := range oidcOrgRoles {
+		if current == role && len(cfg.OrgRoleGroups[role]) == 0 {
+			return current
+		}
+	}
+	return middleware.RoleUser
+}
+
+// oidcMappedGroups 参与角色映射的全部组，allowed_groups 非空时这些组的用户同样允许登录
+func oidcMappedGroups(cfg config.OIDCConfig) []string {
+	groups := append([]string{}, cfg.AdminGroups...)
+	for _, role := range oidcOrgRoles {
+		groups = append(groups, cfg.OrgRoleGroups[role]...)
+	}
+	return groups
+}
+
+func groupsIntersect(groups []string, targets []string) bool {
+	for _, group := range groups {
+		for _, target := range targets {
+			if group == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func claimOrDefault(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// provisionOIDCUser 按 issuer|sub 查找用户，不存在时自动创建；每次登录按 IdP 的组同步角色
+func (ac *AuthController) provisionOIDCUser(claims jwt.MapClaims) (*models.User, error) {
+	cfg := ac.OIDC
+	groups := oidc.StringsClaim(claims, claimOrDefault(cfg.GroupsClaim, "groups"))
+	if len(cfg.AllowedGroups) > 0 && !groupsIntersect(groups, cfg.AllowedGroups) && !groupsIntersect(groups, oidcMappedGroups(cfg)) {
+		return nil, errors.New("当前账号无权访问控制台")
+	}
+	email := oidc.StringClaim(claims, claimOrDefault(cfg.EmailClaim, "email"))
+	externalID := oidc.StringClaim(claims, "iss") + "|" + oidc.StringClaim(claims, "sub")
+
+	var user models.User
+	err := ac.DB.Where("external_id = ?", externalID).First(&user).Error
+	if err == nil {
+		updates := map[string]interface{}{}
+		if role := oidcRole(groups, cfg, user.Role); user.Role != role {
+			updates["role"] = role
+			user.Role = role
+		}
+		if email != "" && email != user.Email && !ac.emailTaken(email, user.ID) {
+			updates["email"] = email
+		}
+		if len(updates) > 0 {
+			if err := ac.DB.Model(&user).Updates(updates).Error; err != nil {
+				return nil, fmt.Errorf("更新用户失败")
+			}
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询用户失败")
+	}
+	if !cfg.AutoProvisionEnabled() {
+		return nil, errors.New("账号未开通，请联系管理员")
+	}
+
+	username := oidc.StringClaim(claims, claimOrDefault(cfg.UsernameClaim, "preferred_username"))
+	if username == "" {
+		username = strings.SplitN(email, "@", 2)[0]
+	}
+	username = oidcUsernamePattern.ReplaceAllString(username, "")
+	if username == "" {
+		username = "sso"
+	}
+	username = ac.uniqueUsername(username)
+	if email == "" || ac.emailTaken(email, 0) {
+		email = username + "@sso.invalid"
+	}
+
+	// 单点登录用户不使用本地密码，写入随机密码的哈希
+	randomPassword, err := oidc.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user = models.User{
+		Username:     username,
+		Password:     string(hashedPassword),
+		Email:        email,
+		Role:         oidcRole(groups, cfg, ""),
+		AuthProvider: authProviderOIDC,
+		ExternalID:   &externalID,
+	}
+	if err := ac.DB.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("创建用户失败")
+	}
+	log.Printf("[OIDC] 自动创建用户 - 用户名: %s, 角色: %s", user.Username, user.Role)
+	return &user, nil
+}
+
+func (ac *AuthController) emailTaken(email string, exceptUserID uint) bool {
+	var count int64
+	ac.DB.Model(&models.User{}).Where("email = ? AND id <> ?", email, exceptUserID).Count(&count)
+	return count > 0
+}
+
+// uniqueUsername 用户名已被本地用户占用时追加序号
+func (ac *AuthController) uniqueUsername(base string) string {
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	candidate := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		ac.DB.Model(&models.User{}).Where("username = ?", candidate).Count(&count)
+		if count == 0 {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	suffix, _ := oidc.RandomString(4)
+	return base + "-" + suffix
+}
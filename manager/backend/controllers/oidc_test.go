@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.OIDCLoginState{}); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasTable("oidc_login_states") {
+		t.Fatal("table oidc_login_states not created")
+	}
+	ac := NewAuthController(db, &config.Config{OIDC: config.OIDCConfig{Enabled: true, Issuer: "http://127.0.0.1:1"}})
+	db.Create(&models.OIDCLoginState{State: "s1", Nonce: "n", CodeVerifier: "v", ExpiresAt: time.Now().Add(time.Minute)})
+
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{name: "缺少Cookie"},
+		{name: "Cookie不匹配", cookie: hashOIDCTicket("s2")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=s1&code=x", nil)
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			ac.OIDCCallback(c)
+			if w.Code != http.StatusFound || !strings.Contains(w.Header().Get("Location"), "oidc_error=") {
+				t.Fatalf("status=%d location=%s", w.Code, w.Header().Get("Location"))
+			}
+			setCookie := w.Header().Get("Set-Cookie")
+			if !strings.Contains(setCookie, oidcStateCookie+"=;") || !strings.Contains(setCookie, "HttpOnly") || !strings.Contains(setCookie, "SameSite=Lax") {
+				t.Errorf("state cookie not cleared: %s", setCookie)
+			}
+		})
+	}
+}
+
+func TestProvisionOIDCUserRoles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatal(err)
+	}
+	orgID := uint(1)
+	externalID := "https://idp.example.com|u1"
+	user := models.User{Username: "alice", Password: "x", Email: "alice@example.com", Role: middleware.RoleOrgOwner, OrgID: &orgID,
+		AuthProvider: authProviderOIDC, ExternalID: &externalID}
+	db.Create(&user)
+
+	tests := []struct {
+		name     string
+		current  string
+		groups   []interface{}
+		orgRoles map[string][]string
+		want     string
+	}{
+		{name: "未配置组织角色映射时保留控制台分配的角色", current: middleware.RoleOrgOwner, groups: []interface{}{"staff"}, want: middleware.RoleOrgOwner},
+		{name: "管理员组", current: middleware.RoleOrgOwner, groups: []interface{}{"xiaozhi-admins"}, want: middleware.RoleAdmin},
+		{name: "移出管理员组降为普通用户", current: middleware.RoleAdmin, groups: []interface{}{"staff"}, want: middleware.RoleUser},
+		{name: "按组映射组织角色", current: middleware.RoleViewer, groups: []interface{}{"ops"},
+			orgRoles: map[string][]string{middleware.RoleOperator: {"ops"}}, want: middleware.RoleOperator},
+		{name: "已配置映射的组织角色移出组后降为普通用户", current: middleware.RoleOrgOwner, groups: []interface{}{"staff"},
+			orgRoles: map[string][]string{middleware.RoleOrgOwner: {"owners"}}, want: middleware.RoleUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.Model(&user).Update("role", tt.current)
+			ac := &AuthController{DB: db, OIDC: config.OIDCConfig{AdminGroups: []string{"xiaozhi-admins"}, OrgRoleGroups: tt.orgRoles}}
+			got, err := ac.provisionOIDCUser(jwt.MapClaims{"iss": "https://idp.example.com", "sub": "u1", "groups": tt.groups})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stored models.User
+			db.First(&stored, user.ID)
+			if got.Role != tt.want || stored.Role != tt.want {
+				t.Errorf("role = %s (stored %s), want %s", got.Role, stored.Role, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// organizationSlugPattern 组织标识：小写字母、数字和短横线
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,63}$`)
+
+type organizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required"`
+}
+
+func (r *organizationRequest) normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Slug = strings.ToLower(strings.TrimSpace(r.Slug))
+	if r.Name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	if !organizationSlugPattern.MatchString(r.Slug) {
+		return errors.New("组织标识只能包含小写字母、数字和短横线，长度 2-64")
+	}
+	return nil
+}
+
+// GetOrganizations 组织列表（含成员数）
+func (ac *AdminController) GetOrganizations(c *gin.Context) {
+	var orgs []models.Organization
+	if err := ac.DB.Order("id ASC").Find(&orgs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取组织列表失败"})
+		return
+	}
+
+	type memberCount struct {
+		OrgID uint
+		Count int64
+	}
+	var counts []memberCount
+	ac.DB.Model(&models.User{}).Select("org_id, COUNT(1) AS count").Where("org_id IS NOT NULL").Group("org_id").Scan(&counts)
+	countByOrg := make(map[uint]int64, len(counts))
+	for _, row := range counts {
+		countByOrg[row.OrgID] = row.Count
+	}
+
+	result := make([]gin.H, 0, len(orgs))
+	for _, org := range orgs {
+		result = append(result, gin.H{
+			"id":           org.ID,
+			"name":         org.Name,
+			"slug":         org.Slug,
+			"member_count": countByOrg[org.ID],
+			"created_at":   org.CreatedAt,
+			"updated_at":   org.UpdatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// CreateOrganization 创建组织
+func (ac *AdminController) CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	ac.DB.Model(&models.Organization{}).Where("slug = ?", req.Slug).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "组织标识已存在"})
+		return
+	}
+
+	org := models.Organization{Name: req.Name, Slug: req.Slug}
+	if err := ac.DB.Create(&org).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建组织失败"})
+		return
+	}
+	log.Printf("[Organization] 创建组织 - ID: %d, 标识: %s", org.ID, org.Slug)
+	c.JSON(http.StatusCreated, gin.H{"data": org})
+}
+
+// UpdateOrganization 修改组织名称和标识
+func (ac *AdminController) UpdateOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var org models.Organization
+	if err := ac.DB.First(&org, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "组织不存在"})
+		return
+	}
+
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	ac.DB.Model(&models.Organization{}).Where("slug = ? AND id != ?", req.Slug, org.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "组织标识已存在"})
+		return
+	}
+
+	org.Name = req.Name
+	org.Slug = req.Slug
+	if err := ac.DB.Save(&org).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新组织失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": org})
+}
+
+// DeleteOrganization 删除组织及其自有配置；组织下仍有成员时拒绝删除
+func (ac *AdminController) DeleteOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var org models.Organization
+	if err := ac.DB.First(&org, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "组织不存在"})
+		return
+	}
+
+	var members int64
+	ac.DB.Model(&models.User{}).Where("org_id = ?", org.ID).Count(&members)
+	if members > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "组织下仍有成员，请先移除或删除成员"})
+		return
+	}
+
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", org.ID).Delete(&models.Config{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&org).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除组织失败"})
+		return
+	}
+	ac.notifySystemConfigChanged()
+	log.Printf("[Organization] 删除组织 - ID: %d, 标识: %s", org.ID, org.Slug)
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"xiaozhi/manager/backend/database"
 	"xiaozhi/manager/backend/models"
 
 	"github.com/gin-gonic/gin"
@@ -83,26 +85,20 @@ func (sc *SetupController) InitializeDatabase(c *gin.Context) {
 		}
 	}()
 
-	// 1. 自动迁移表结构
-	log.Println("开始自动迁移数据库表结构...")
-	err := tx.AutoMigrate(
-		&models.User{},
-		&models.Device{},
-		&models.Agent{},
-		&models.Config{},
-		&models.MCPMarketService{},
-		&models.GlobalRole{},
-		&models.SpeakerGroup{},
-		&models.SpeakerSample{},
-		&models.ChatMessage{},
-	)
+	// 1. 表结构在启动时由版本化迁移创建，这里确认没有未执行的迁移
+	migrator, err := database.NewMigrator(sc.DB)
+	if err == nil {
+		var pending []database.Migration
+		if pending, err = migrator.Pending(); err == nil && len(pending) > 0 {
+			err = fmt.Errorf("有 %d 个未执行的迁移", len(pending))
+		}
+	}
 	if err != nil {
 		tx.Rollback()
-		log.Printf("数据库表结构迁移失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库表结构迁移失败: " + err.Error()})
+		log.Printf("数据库表结构检查失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库表结构检查失败: " + err.Error()})
 		return
 	}
-	log.Println("数据库表结构迁移成功")
 
 	// 2. 检查是否已存在管理员用户
 	var existingAdmin models.User
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenantConfigTypes 组织可以自建的配置类型（智能体直接引用的配置），其余配置为平台级
+var tenantConfigTypes = []string{"llm", "tts"}
+
+// tenantOf 返回当前请求的数据范围：platform 为 true 表示平台管理员，可访问全部组织；
+// 否则只能访问 orgID 组织，orgID 为 0 表示未加入组织，看不到任何组织数据
+func tenantOf(c *gin.Context) (orgID uint, platform bool) {
+	if c.GetString("role") == middleware.RoleAdmin {
+		return 0, true
+	}
+	return c.GetUint("org_id"), false
+}
+
+// tenantMembers 组织成员ID子查询
+func tenantMembers(db *gorm.DB, orgID uint) *gorm.DB {
+	return db.Model(&models.User{}).Select("id").Where("org_id = ?", orgID)
+}
+
+// ownedByTenant 限定按 user_id 归属的资源（设备、智能体、知识库）在当前组织内
+func ownedByTenant(c *gin.Context, db *gorm.DB) func(*gorm.DB) *gorm.DB {
+	orgID, platform := tenantOf(c)
+	return func(tx *gorm.DB) *gorm.DB {
+		if platform {
+			return tx
+		}
+		if orgID == 0 {
+			return tx.Where("1 = 0")
+		}
+		return tx.Where("user_id IN (?)", tenantMembers(db, orgID))
+	}
+}
+
+// usersInTenant 限定用户在当前组织内
+func usersInTenant(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	orgID, platform := tenantOf(c)
+	return func(tx *gorm.DB) *gorm.DB {
+		if platform {
+			return tx
+		}
+		if orgID == 0 {
+			return tx.Where("1 = 0")
+		}
+		return tx.Where("org_id = ?", orgID)
+	}
+}
+
+// configsInTenant 组织内角色只能管理本组织的配置，平台共享配置只有平台管理员可见
+func configsInTenant(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	orgID, platform := tenantOf(c)
+	return func(tx *gorm.DB) *gorm.DB {
+		if platform {
+			return tx
+		}
+		if orgID == 0 {
+			return tx.Where("1 = 0")
+		}
+		return tx.Where("org_id = ? AND type IN ?", orgID, tenantConfigTypes)
+	}
+}
+
+// configsForUser 用户可选用的配置：平台共享配置和所属组织的配置
+func configsForUser(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	orgID := c.GetUint("org_id")
+	return func(tx *gorm.DB) *gorm.DB {
+		if orgID == 0 {
+			return tx.Where("org_id IS NULL")
+		}
+		return tx.Where("org_id IS NULL OR org_id = ?", orgID)
+	}
+}
+
+// assignConfigTenant 组织内角色创建的配置归属本组织，且不能设为默认配置（默认配置是平台级的）
+func assignConfigTenant(c *gin.Context, config *models.Config) error {
+	orgID, platform := tenantOf(c)
+	if platform {
+		return nil
+	}
+	if orgID == 0 {
+		return errors.New("当前账号未加入组织")
+	}
+	allowed := false
+	for _, t := range tenantConfigTypes {
+		if config.Type == t {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("组织不能创建 %s 类型的配置", config.Type)
+	}
+	config.OrgID = &orgID
+	config.IsDefault = false
+	return nil
+}
+
+// userInTenant 用户是否属于当前组织（平台管理员不受限制）
+func userInTenant(c *gin.Context, db *gorm.DB, userID uint) bool {
+	var count int64
+	db.Model(&models.User{}).Scopes(usersInTenant(c)).Where("id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// ensureTenantUser 路径中的用户不在当前组织内时返回 404
+func ensureTenantUser(c *gin.Context, db *gorm.DB, userID uint) bool {
+	if !userInTenant(c, db, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return false
+	}
+	return true
+}
+
+// agentInTenant 智能体是否属于当前组织成员
+func agentInTenant(c *gin.Context, db *gorm.DB, agentID interface{}) bool {
+	var count int64
+	db.Model(&models.Agent{}).Scopes(ownedByTenant(c, db)).Where("id = ?", agentID).Count(&count)
+	return count > 0
+}
+
+// validateAgentConfigRefs 检查智能体引用的 LLM/TTS 配置对其所属用户可见，禁止引用其他组织的配置
+func validateAgentConfigRefs(db *gorm.DB, ownerID uint, llmConfigID, ttsConfigID *string) error {
+	var owner models.User
+	if err := db.Select("id", "org_id").First(&owner, ownerID).Error; err != nil {
+		return errors.New("智能体所属用户不存在")
+	}
+	refs := []struct {
+		configType string
+		configID   *string
+		label      string
+	}{
+		{"llm", llmConfigID, "LLM"},
+		{"tts", ttsConfigID, "TTS"},
+	}
+	for _, ref := range refs {
+		if ref.configID == nil || *ref.configID == "" {
+			continue
+		}
+		var config models.Config
+		if err := db.Select("id", "org_id").Where("type = ? AND config_id = ?", ref.configType, *ref.configID).First(&config).Error; err != nil {
+			continue
+		}
+		if config.OrgID != nil && (owner.OrgID == nil || *owner.OrgID != *config.OrgID) {
+			return fmt.Errorf("所选%s配置属于其他组织", ref.label)
+		}
+	}
+	return nil
+}
+
+// orgIDFromJSON 解析请求中的 org_id，null 或 0 表示不属于任何组织
+func orgIDFromJSON(v interface{}) (*uint, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if val < 0 || val != float64(uint(val)) {
+			return nil, errors.New("org_id 格式错误")
+		}
+		if val == 0 {
+			return nil, nil
+		}
+		id := uint(val)
+		return &id, nil
+	}
+	return nil, errors.New("org_id 格式错误")
+}
+
+// resolveUserTenant 校验要设置的角色和所属组织：组织管理员只能在本组织内分配组织角色和普通用户，
+// 平台管理员可以指定任意组织，平台管理员本身不属于任何组织
+func resolveUserTenant(c *gin.Context, db *gorm.DB, role string, orgID *uint) (string, *uint, error) {
+	if role == "" {
+		role = middleware.RoleUser
+	}
+	if !middleware.ValidRole(role) {
+		return "", nil, fmt.Errorf("未知角色: %s", role)
+	}
+
+	callerOrg, platform := tenantOf(c)
+	if !platform {
+		if role == middleware.RoleAdmin {
+			return "", nil, errors.New("无权分配平台管理员角色")
+		}
+		if callerOrg == 0 {
+			return "", nil, errors.New("当前账号未加入组织")
+		}
+		return role, &callerOrg, nil
+	}
+
+	if role == middleware.RoleAdmin {
+		return role, nil, nil
+	}
+	if orgID != nil {
+		var count int64
+		db.Model(&models.Organization{}).Where("id = ?", *orgID).Count(&count)
+		if count == 0 {
+			return "", nil, errors.New("组织不存在")
+		}
+	}
+	if middleware.IsOrgRole(role) && orgID == nil {
+		return "", nil, errors.New("组织内角色必须指定所属组织")
+	}
+	return role, orgID, nil
+}
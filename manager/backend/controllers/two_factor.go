@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"xiaozhi/manager/backend/middleware"
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount = 10
+	// 连续输错验证码达到次数后锁定一段时间，防止暴力尝试
+	twoFactorMaxFailures = 5
+	twoFactorLockout     = 5 * time.Minute
+	defaultTOTPIssuer    = "Xiaozhi"
+)
+
+var (
+	errTwoFactorCodeRequired = errors.New("请输入两步验证码")
+	errTwoFactorCodeInvalid  = errors.New("两步验证码错误")
+	errTwoFactorLocked       = errors.New("验证码错误次数过多，请稍后再试")
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码；TOTP 同一时间步只能使用一次，恢复码用后作废
+func verifySecondFactor(db *gorm.DB, user *models.User, code string) error {
+	now := time.Now()
+	if user.TwoFactorLockUntil != nil && now.Before(*user.TwoFactorLockUntil) {
+		return errTwoFactorLocked
+	}
+	if code == "" {
+		return errTwoFactorCodeRequired
+	}
+
+	if counter, ok := totp.Validate(user.TOTPSecret, code, now); ok {
+		// 以上次使用的时间步为条件更新，拒绝重放
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Updates(map[string]interface{}{"totp_last_counter": counter, "two_factor_failures": 0, "two_factor_lock_until": nil})
+		if result.Error == nil && result.RowsAffected == 1 {
+			return nil
+		}
+	} else {
+		result := db.Model(&models.UserRecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, totp.HashRecoveryCode(code)).
+			Update("used_at", now)
+		if result.Error == nil && result.RowsAffected == 1 {
+			db.Model(&models.User{}).Where("id = ?", user.ID).
+				Updates(map[string]interface{}{"two_factor_failures": 0, "two_factor_lock_until": nil})
+			log.Printf("[TwoFactor] 用户使用恢复码登录 - 用户ID: %d", user.ID)
+			return nil
+		}
+	}
+
+	updates := map[string]interface{}{"two_factor_failures": gorm.Expr("two_factor_failures + 1")}
+	if user.TwoFactorFailures+1 >= twoFactorMaxFailures {
+		updates = map[string]interface{}{"two_factor_failures": 0, "two_factor_lock_until": now.Add(twoFactorLockout)}
+		log.Printf("[TwoFactor] ⚠️ 验证码连续错误，锁定 %s - 用户ID: %d", twoFactorLockout, user.ID)
+	}
+	db.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates)
+	return errTwoFactorCodeInvalid
+}
+
+// replaceRecoveryCodes 生成新的恢复码并作废旧的，明文只在本次返回
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if err := tx.Create(&models.UserRecoveryCode{UserID: userID, CodeHash: totp.HashRecoveryCode(code)}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// clearTwoFactor 关闭两步验证并删除恢复码
+func clearTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":          false,
+			"totp_secret":           "",
+			"totp_last_counter":     0,
+			"two_factor_failures":   0,
+			"two_factor_lock_until": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error
+	})
+}
+
+func (ac *AuthController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := ac.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// 获取两步验证状态
+func (ac *AuthController) GetTwoFactorStatus(c *gin.Context) {
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+	var remaining int64
+	ac.DB.Model(&models.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"enabled":                  user.TOTPEnabled,
+		"required":                 middleware.TwoFactorRequired(*user),
+		"recovery_codes_remaining": remaining,
+		"auth_provider":            user.AuthProvider,
+	}})
+}
+
+// 生成待绑定的 TOTP 密钥和二维码，验证通过后才真正启用
+func (ac *AuthController) SetupTwoFactor(c *gin.Context) {
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已启用两步验证"})
+		return
+	}
+	if user.AuthProvider == authProviderOIDC {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "单点登录账号的多因素认证由身份提供方负责"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+		return
+	}
+	issuer := ac.TwoFactor.Issuer
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+	uri := totp.ProvisioningURI(issuer, user.Username, secret)
+	qr, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成二维码失败"})
+		return
+	}
+	if err := ac.DB.Model(user).Update("totp_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存密钥失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"secret":      secret,
+		"otpauth_url": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr),
+	}})
+}
+
+// 输入验证器 App 中的验证码确认绑定，返回恢复码
+func (ac *AuthController) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已启用两步验证"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先生成绑定二维码"})
+		return
+	}
+	counter, valid := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errTwoFactorCodeInvalid.Error()})
+		return
+	}
+
+	var codes []string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":      true,
+			"totp_last_counter": counter,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("[EnableTwoFactor] 启用两步验证失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+		return
+	}
+	log.Printf("[EnableTwoFactor] ✅ 用户启用两步验证 - 用户ID: %d, 用户名: %s", user.ID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"recovery_codes": codes}})
+}
+
+// 关闭两步验证，需要密码和当前验证码（或恢复码）
+func (ac *AuthController) DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用两步验证"})
+		return
+	}
+	if middleware.TwoFactorRequired(*user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "管理员账号必须启用两步验证"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码错误"})
+		return
+	}
+	if err := verifySecondFactor(ac.DB, user, req.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := clearTwoFactor(ac.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭两步验证失败"})
+		return
+	}
+	log.Printf("[DisableTwoFactor] 用户关闭两步验证 - 用户ID: %d, 用户名: %s", user.ID, user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": "已关闭两步验证"})
+}
+
+// 重新生成恢复码，旧恢复码全部作废
+func (ac *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := ac.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用两步验证"})
+		return
+	}
+	if err := verifySecondFactor(ac.DB, user, req.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var codes []string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"recovery_codes": codes}})
+}
@@ -27,6 +27,9 @@ type UserController struct {
 		RequestOpenClawStatusFromClient(ctx context.Context, agentID string) (map[string]interface{}, error)
 		CallOpenClawChatFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
 		InjectMessageToDevice(ctx context.Context, deviceID, message string, skipLlm bool) error
+		RequestMemoryFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+		RequestMcpPromptsFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+		GetMcpPromptFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
 	}
 }
 
@@ -301,6 +304,7 @@ func (uc *UserController) CreateAgent(c *gin.Context) {
 		MemoryMode       string                  `json:"memory_mode"`
 		MCPServiceNames  string                  `json:"mcp_service_names"`
 		OpenClaw         *OpenClawConfigResponse `json:"openclaw"`
+		MCPToolPolicy    *MCPToolPolicyConfig    `json:"mcp_tool_policy"`
 		KnowledgeBaseIDs []uint                  `json:"knowledge_base_ids"`
 	}
 
@@ -342,11 +346,23 @@ func (uc *UserController) CreateAgent(c *gin.Context) {
 		req.OpenClaw,
 	)
 	applyOpenClawConfigToAgent(&agent, openClawCfg)
+	if req.MCPToolPolicy != nil {
+		if err := applyMCPToolPolicyToAgent(&agent, *req.MCPToolPolicy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := validateAgentConfigRefs(uc.DB, agent.UserID, agent.LLMConfigID, agent.TTSConfigID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := uc.DB.Create(&agent).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建智能体失败"})
 		return
 	}
+	recordVersion(uc.DB, c, versionEntityAgent, agent.ID, "", &agent, "创建")
 	if err := uc.updateAgentKnowledgeBaseLinks(agent.ID, req.KnowledgeBaseIDs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新智能体知识库关联失败"})
 		return
@@ -406,6 +422,7 @@ func (uc *UserController) UpdateAgent(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "智能体不存在"})
 		return
 	}
+	before := versionSnapshot(&agent)
 
 	var req struct {
 		Name             string                  `json:"name" binding:"required,min=2,max=50"`
@@ -417,6 +434,7 @@ func (uc *UserController) UpdateAgent(c *gin.Context) {
 		MemoryMode       *string                 `json:"memory_mode"`
 		MCPServiceNames  string                  `json:"mcp_service_names"`
 		OpenClaw         *OpenClawConfigResponse `json:"openclaw"`
+		MCPToolPolicy    *MCPToolPolicyConfig    `json:"mcp_tool_policy"`
 		KnowledgeBaseIDs []uint                  `json:"knowledge_base_ids"`
 	}
 
@@ -453,11 +471,23 @@ func (uc *UserController) UpdateAgent(c *gin.Context) {
 		req.OpenClaw,
 	)
 	applyOpenClawConfigToAgent(&agent, openClawCfg)
+	if req.MCPToolPolicy != nil {
+		if err := applyMCPToolPolicyToAgent(&agent, *req.MCPToolPolicy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := validateAgentConfigRefs(uc.DB, agent.UserID, agent.LLMConfigID, agent.TTSConfigID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := uc.DB.Save(&agent).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新智能体失败"})
 		return
 	}
+	recordVersion(uc.DB, c, versionEntityAgent, agent.ID, before, &agent, "")
 	if err := uc.validateKnowledgeBaseOwnership(userID.(uint), req.KnowledgeBaseIDs); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -783,8 +813,8 @@ func (uc *UserController) GetVoiceOptions(c *gin.Context) {
 // 获取LLM配置列表
 func (uc *UserController) GetLLMConfigs(c *gin.Context) {
 	var configs []models.Config
-	// 从全局配置中获取所有启用的LLM配置，默认配置排在前面
-	if err := uc.DB.Where("type = ? AND enabled = ?", "llm", true).Order("is_default DESC, name ASC").Find(&configs).Error; err != nil {
+	// 获取平台共享和所属组织中启用的LLM配置，默认配置排在前面
+	if err := uc.DB.Scopes(configsForUser(c)).Where("type = ? AND enabled = ?", "llm", true).Order("is_default DESC, name ASC").Find(&configs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取LLM配置失败"})
 		return
 	}
@@ -795,8 +825,8 @@ func (uc *UserController) GetLLMConfigs(c *gin.Context) {
 // 获取TTS配置列表
 func (uc *UserController) GetTTSConfigs(c *gin.Context) {
 	var configs []models.Config
-	// 从全局配置中获取所有启用的TTS配置，默认配置排在前面
-	if err := uc.DB.Where("type = ? AND enabled = ?", "tts", true).Order("is_default DESC, name ASC").Find(&configs).Error; err != nil {
+	// 获取平台共享和所属组织中启用的TTS配置，默认配置排在前面
+	if err := uc.DB.Scopes(configsForUser(c)).Where("type = ? AND enabled = ?", "tts", true).Order("is_default DESC, name ASC").Find(&configs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取TTS配置失败"})
 		return
 	}
@@ -942,9 +972,13 @@ func (uc *UserController) GetAgentMCPEndpoint(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	serverEndpoint, err := GenerateAgentMCPServerEndpoint(uc.DB, agentID, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	// 返回单个endpoint字符串
-	c.JSON(http.StatusOK, gin.H{"data": gin.H{"endpoint": endpoint}})
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"endpoint": endpoint, "server_endpoint": serverEndpoint}})
 }
 
 // GetAgentOpenClawEndpoint 获取智能体的OpenClaw接入点URL（用户版本）
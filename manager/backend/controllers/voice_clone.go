@@ -249,7 +249,7 @@ func (vcc *VoiceCloneController) CreateVoiceClone(c *gin.Context) {
 		TTSConfigID:        ttsConfigID,
 		Status:             voiceCloneStatusProcessing,
 		TranscriptRequired: capability.RequiresTranscript,
-		MetaJSON:           string(pendingMetaJSON),
+		MetaJSON:           models.JSONText(pendingMetaJSON),
 	}
 	audio := models.VoiceCloneAudio{
 		UserID:         userID,
@@ -269,7 +269,7 @@ func (vcc *VoiceCloneController) CreateVoiceClone(c *gin.Context) {
 		Status:    voiceCloneTaskStatusQueued,
 		Attempts:  0,
 		LastError: "",
-		MetaJSON:  string(pendingMetaJSON),
+		MetaJSON:  models.JSONText(pendingMetaJSON),
 	}
 
 	err = vcc.DB.Transaction(func(tx *gorm.DB) error {
@@ -485,7 +485,7 @@ func (vcc *VoiceCloneController) RetryVoiceClone(c *gin.Context) {
 			return fmt.Errorf("当前任务状态为 %s，仅失败任务允许重新复刻", task.Status)
 		}
 
-		cloneMetaJSON := mergeJSONMeta(clone.MetaJSON, map[string]any{
+		cloneMetaJSON := mergeJSONMeta(string(clone.MetaJSON), map[string]any{
 			"task_id":     task.TaskID,
 			"task_status": voiceCloneTaskStatusQueued,
 			"queued_at":   now,
@@ -628,7 +628,7 @@ func (vcc *VoiceCloneController) AppendVoiceCloneAudio(c *gin.Context) {
 		return
 	}
 
-	metaJSON := mergeJSONMeta(clone.MetaJSON, map[string]any{
+	metaJSON := mergeJSONMeta(string(clone.MetaJSON), map[string]any{
 		"last_append_audio_at":  time.Now(),
 		"last_append_result":    result.RawResponse,
 		"last_append_http_code": result.ResponseCode,
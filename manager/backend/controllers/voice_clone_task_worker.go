@@ -119,6 +119,14 @@ func (vcc *VoiceCloneController) processVoiceCloneTask(taskPrimaryID uint) {
 		return
 	}
 	log.Printf("[voice_clone][task] task completed: task_primary_id=%d task_id=%s voice_clone_id=%d", taskPrimaryID, task.TaskID, task.VoiceCloneID)
+	emitWebhookEvent(vcc.DB, task.UserID, webhookEventVoiceCloneComplete, map[string]interface{}{
+		"task_id":           task.TaskID,
+		"voice_clone_id":    clone.ID,
+		"name":              clone.Name,
+		"provider":          clone.Provider,
+		"provider_voice_id": result.VoiceID,
+		"tts_config_id":     clone.TTSConfigID,
+	})
 }
 
 func (vcc *VoiceCloneController) claimVoiceCloneTask(taskPrimaryID uint) (*models.VoiceCloneTask, bool, error) {
@@ -180,7 +188,7 @@ func (vcc *VoiceCloneController) finishVoiceCloneTaskSuccess(task *models.VoiceC
 	}
 	now := time.Now()
 
-	cloneMetaJSON := mergeJSONMeta(clone.MetaJSON, map[string]any{
+	cloneMetaJSON := mergeJSONMeta(string(clone.MetaJSON), map[string]any{
 		"source_type": audio.SourceType,
 		"request_id":  result.RequestID,
 		"http_code":   result.ResponseCode,
@@ -237,7 +245,7 @@ func (vcc *VoiceCloneController) finishVoiceCloneTaskFailed(task *models.VoiceCl
 
 	cloneMetaJSON := ""
 	if clone != nil {
-		cloneMetaJSON = mergeJSONMeta(clone.MetaJSON, map[string]any{
+		cloneMetaJSON = mergeJSONMeta(string(clone.MetaJSON), map[string]any{
 			"task_id":     task.TaskID,
 			"task_status": voiceCloneTaskStatusFailed,
 			"last_error":  lastError,
@@ -276,6 +284,12 @@ func (vcc *VoiceCloneController) finishVoiceCloneTaskFailed(task *models.VoiceCl
 		return
 	}
 	log.Printf("[voice_clone][task] task failed: task_primary_id=%d task_id=%s reason=%s", task.ID, task.TaskID, lastError)
+	emitWebhookEvent(vcc.DB, task.UserID, webhookEventVoiceCloneFailed, map[string]interface{}{
+		"task_id":        task.TaskID,
+		"voice_clone_id": task.VoiceCloneID,
+		"provider":       task.Provider,
+		"error":          lastError,
+	})
 }
 
 func mergeJSONMeta(raw string, updates map[string]any) string {
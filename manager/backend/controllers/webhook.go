@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxWebhooksPerUser 每个用户最多配置的 Webhook 数量
+const maxWebhooksPerUser = 20
+
+// WebhookController 出站 Webhook 管理：订阅事件、测试推送和查看投递日志
+type WebhookController struct {
+	DB *gorm.DB
+}
+
+type WebhookRequest struct {
+	Name     string   `json:"name" binding:"required,max=100"`
+	URL      string   `json:"url" binding:"required,max=1024"`
+	Events   []string `json:"events"`
+	AllUsers bool     `json:"all_users"`
+	Enabled  *bool    `json:"enabled"`
+	// Secret 为空时创建会自动生成；更新时为空表示保持不变
+	Secret string `json:"secret" binding:"max=128"`
+}
+
+// 获取可订阅的事件类型
+func (wc *WebhookController) GetWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": webhookEventTypes})
+}
+
+// 获取当前用户的 Webhook 列表
+func (wc *WebhookController) ListWebhooks(c *gin.Context) {
+	var hooks []models.Webhook
+	if err := wc.DB.Where("user_id = ?", c.GetUint("user_id")).Order("id DESC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取 Webhook 失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": hooks})
+}
+
+// validateWebhookRequest 校验请求并规范化事件列表
+func validateWebhookRequest(c *gin.Context, req *WebhookRequest) (string, bool) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.URL = strings.TrimSpace(req.URL)
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL 无效: " + err.Error()})
+		return "", false
+	}
+	if req.AllUsers && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以接收所有用户的事件"})
+		return "", false
+	}
+	events := make([]string, 0, len(req.Events))
+	seen := make(map[string]bool)
+	for _, event := range req.Events {
+		event = strings.TrimSpace(event)
+		if seen[event] {
+			continue
+		}
+		if !validWebhookEvent(event) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知的事件类型: " + event})
+			return "", false
+		}
+		seen[event] = true
+		events = append(events, event)
+	}
+	return strings.Join(events, ","), true
+}
+
+// 创建 Webhook，签名密钥只在本次返回
+func (wc *WebhookController) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, ok := validateWebhookRequest(c, &req)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	var count int64
+	wc.DB.Model(&models.Webhook{}).Where("user_id = ?", userID).Count(&count)
+	if count >= maxWebhooksPerUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook 数量已达上限"})
+		return
+	}
+
+	secret := strings.TrimSpace(req.Secret)
+	if secret == "" {
+		generated, err := webhook.GenerateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成签名密钥失败"})
+			return
+		}
+		secret = generated
+	}
+	hook := models.Webhook{
+		UserID:   userID,
+		Name:     req.Name,
+		URL:      req.URL,
+		Secret:   secret,
+		Events:   events,
+		AllUsers: req.AllUsers,
+		Enabled:  req.Enabled == nil || *req.Enabled,
+	}
+	if err := wc.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存 Webhook 失败"})
+		return
+	}
+	// 数据库默认值为 true，显式写入停用状态
+	if !hook.Enabled {
+		wc.DB.Model(&hook).Update("enabled", false)
+	}
+
+	log.Printf("[Webhook] 用户创建 Webhook - 用户ID: %d, 名称: %s, 事件: %s", userID, hook.Name, hook.Events)
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"webhook": hook,
+		"secret":  secret,
+	}})
+}
+
+// findUserWebhook 查找当前用户的 Webhook，不存在时已写入响应
+func (wc *WebhookController) findUserWebhook(c *gin.Context) (*models.Webhook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook ID格式错误"})
+		return nil, false
+	}
+	var hook models.Webhook
+	if err := wc.DB.Where("id = ? AND user_id = ?", id, c.GetUint("user_id")).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook 不存在"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+// 更新 Webhook
+func (wc *WebhookController) UpdateWebhook(c *gin.Context) {
+	hook, ok := wc.findUserWebhook(c)
+	if !ok {
+		return
+	}
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, ok := validateWebhookRequest(c, &req)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":      req.Name,
+		"url":       req.URL,
+		"events":    events,
+		"all_users": req.AllUsers,
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if secret := strings.TrimSpace(req.Secret); secret != "" {
+		updates["secret"] = secret
+	}
+	if err := wc.DB.Model(hook).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新 Webhook 失败"})
+		return
+	}
+	wc.DB.First(hook, hook.ID)
+	c.JSON(http.StatusOK, gin.H{"data": hook})
+}
+
+// 删除 Webhook 及其投递日志
+func (wc *WebhookController) DeleteWebhook(c *gin.Context) {
+	hook, ok := wc.findUserWebhook(c)
+	if !ok {
+		return
+	}
+	err := wc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除 Webhook 失败"})
+		return
+	}
+	log.Printf("[Webhook] 用户删除 Webhook - 用户ID: %d, ID: %d", hook.UserID, hook.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
+}
+
+// 测试推送：同步发送一条 webhook.test 事件并返回投递结果，失败不重试
+func (wc *WebhookController) TestWebhook(c *gin.Context) {
+	hook, ok := wc.findUserWebhook(c)
+	if !ok {
+		return
+	}
+	deliveryID := uuid.NewString()
+	delivery := models.WebhookDelivery{
+		WebhookID:  hook.ID,
+		DeliveryID: deliveryID,
+		Event:      webhookEventTest,
+		Payload: webhookPayload(deliveryID, webhookEventTest, hook.UserID, map[string]interface{}{
+			"webhook_id": hook.ID,
+			"message":    "这是一条测试推送",
+		}),
+		Status: webhookStatusPending,
+	}
+	if err := wc.DB.Create(&delivery).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建投递记录失败"})
+		return
+	}
+	attemptWebhookDelivery(wc.DB, hook, &delivery, false)
+	hideWebhookResponse(c, &delivery)
+	c.JSON(http.StatusOK, gin.H{"data": delivery})
+}
+
+// hideWebhookResponse 非管理员看不到接收方返回的响应内容，只保留状态码，
+// 避免把 Webhook 当作读取其他服务响应的代理
+func hideWebhookResponse(c *gin.Context, delivery *models.WebhookDelivery) {
+	if c.GetString("role") != "admin" {
+		delivery.Response = ""
+	}
+}
+
+// 获取 Webhook 的投递日志
+func (wc *WebhookController) ListWebhookDeliveries(c *gin.Context) {
+	hook, ok := wc.findUserWebhook(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := wc.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	var total int64
+	query.Count(&total)
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取投递日志失败"})
+		return
+	}
+	for i := range deliveries {
+		hideWebhookResponse(c, &deliveries[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":      deliveries,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// 重新投递：把已结束的记录重置为待投递，重新计算重试次数
+func (wc *WebhookController) RedeliverWebhookDelivery(c *gin.Context) {
+	hook, ok := wc.findUserWebhook(c)
+	if !ok {
+		return
+	}
+	if !hook.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook 已停用"})
+		return
+	}
+	now := time.Now()
+	result := wc.DB.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND webhook_id = ? AND status NOT IN ?", c.Param("delivery_id"), hook.ID, []string{webhookStatusPending, webhookStatusSending}).
+		Updates(map[string]interface{}{
+			"status":          webhookStatusPending,
+			"attempts":        0,
+			"error":           "",
+			"next_attempt_at": now,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重新投递失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "投递记录不存在或正在投递中"})
+		return
+	}
+	StartWebhookDispatcher(wc.DB)
+	wakeWebhookDispatcher()
+	c.JSON(http.StatusOK, gin.H{"message": "已加入投递队列"})
+}
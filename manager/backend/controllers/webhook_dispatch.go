@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/webhook"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookStatusPending = "pending"
+	webhookStatusSending = "sending"
+	webhookStatusSuccess = "success"
+	webhookStatusFailed  = "failed"
+
+	webhookPollInterval      = 5 * time.Second
+	webhookBatchSize         = 50
+	webhookWorkerCount       = 4
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+	webhookCleanupInterval   = time.Hour
+	// webhookClaimLease 认领后的租约时长，进程在投递中退出时租约到期后由其他实例重新投递
+	webhookClaimLease = 2 * time.Minute
+)
+
+// Webhook 事件类型
+const (
+	webhookEventDeviceOnline       = "device.online"
+	webhookEventDeviceOffline      = "device.offline"
+	webhookEventChatMessage        = "chat.message"
+	webhookEventVoiceCloneComplete = "voice_clone.completed"
+	webhookEventVoiceCloneFailed   = "voice_clone.failed"
+	webhookEventToolCall           = "tool_call"
+	webhookEventTest               = "webhook.test"
+)
+
+// WebhookEventType 可订阅的事件类型
+type WebhookEventType struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var webhookEventTypes = []WebhookEventType{
+	{Name: webhookEventDeviceOnline, Description: "设备上线"},
+	{Name: webhookEventDeviceOffline, Description: "设备下线"},
+	{Name: webhookEventChatMessage, Description: "新的聊天消息"},
+	{Name: webhookEventVoiceCloneComplete, Description: "声音复刻完成"},
+	{Name: webhookEventVoiceCloneFailed, Description: "声音复刻失败"},
+	{Name: webhookEventToolCall, Description: "MCP 工具调用"},
+}
+
+func validWebhookEvent(name string) bool {
+	for _, e := range webhookEventTypes {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookSubscribed 判断 Webhook 是否订阅了该事件，未选择事件表示订阅全部
+func webhookSubscribed(hook *models.Webhook, event string) bool {
+	if strings.TrimSpace(hook.Events) == "" {
+		return true
+	}
+	for _, e := range strings.Split(hook.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookPayload 推送给接收方的请求体
+func webhookPayload(deliveryID, event string, userID uint, data interface{}) string {
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":         deliveryID,
+		"event":      event,
+		"user_id":    userID,
+		"created_at": time.Now().Format(time.RFC3339),
+		"data":       data,
+	})
+	return string(body)
+}
+
+var (
+	webhookWake       chan struct{}
+	webhookWorkerOnce sync.Once
+	// webhookClient 投递用的 HTTP 客户端，拒绝内网地址且不跟随重定向
+	webhookClient = webhook.NewClient()
+)
+
+// StartWebhookDispatcher 启动投递协程，继续投递重启前未完成的记录
+func StartWebhookDispatcher(db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	webhookWorkerOnce.Do(func() {
+		webhookWake = make(chan struct{}, 1)
+		go runWebhookDispatcher(db)
+		log.Printf("[Webhook] dispatcher started workers=%d", webhookWorkerCount)
+	})
+}
+
+func wakeWebhookDispatcher() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// emitWebhookEvent 为订阅了该事件的 Webhook 生成待投递记录；
+// 接收方为事件所属用户的 Webhook 以及管理员配置的全局 Webhook
+func emitWebhookEvent(db *gorm.DB, userID uint, event string, data interface{}) {
+	if db == nil {
+		return
+	}
+	var hooks []models.Webhook
+	query := db.Where("enabled = ?", true)
+	if userID != 0 {
+		query = query.Where("user_id = ? OR all_users = ?", userID, true)
+	} else {
+		query = query.Where("all_users = ?", true)
+	}
+	if err := query.Find(&hooks).Error; err != nil {
+		log.Printf("[Webhook] 查询订阅失败 event=%s err=%v", event, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	now := time.Now()
+	created := 0
+	for i := range hooks {
+		if !webhookSubscribed(&hooks[i], event) {
+			continue
+		}
+		deliveryID := uuid.NewString()
+		delivery := models.WebhookDelivery{
+			WebhookID:     hooks[i].ID,
+			DeliveryID:    deliveryID,
+			Event:         event,
+			Payload:       webhookPayload(deliveryID, event, userID, data),
+			Status:        webhookStatusPending,
+			NextAttemptAt: &now,
+		}
+		if err := db.Create(&delivery).Error; err != nil {
+			log.Printf("[Webhook] 创建投递记录失败 webhook_id=%d event=%s err=%v", hooks[i].ID, event, err)
+			continue
+		}
+		created++
+	}
+	if created > 0 {
+		StartWebhookDispatcher(db)
+		wakeWebhookDispatcher()
+	}
+}
+
+func runWebhookDispatcher(db *gorm.DB) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+	for {
+		// 一批处理满时说明可能还有积压，继续处理
+		for {
+			if processDueWebhookDeliveries(db) < webhookBatchSize {
+				break
+			}
+		}
+		if time.Since(lastCleanup) >= webhookCleanupInterval {
+			cleanupWebhookDeliveries(db)
+			lastCleanup = time.Now()
+		}
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+	}
+}
+
+// processDueWebhookDeliveries 并发投递到期的记录（含租约已过期的投递中记录），返回本批处理的条数
+func processDueWebhookDeliveries(db *gorm.DB) int {
+	var deliveries []models.WebhookDelivery
+	if err := db.Where("status IN ? AND next_attempt_at <= ?", []string{webhookStatusPending, webhookStatusSending}, time.Now()).
+		Order("next_attempt_at ASC, id ASC").Limit(webhookBatchSize).Find(&deliveries).Error; err != nil {
+		log.Printf("[Webhook] 查询待投递记录失败: %v", err)
+		return 0
+	}
+	if len(deliveries) == 0 {
+		return 0
+	}
+
+	hooks := make(map[uint]*models.Webhook)
+	sem := make(chan struct{}, webhookWorkerCount)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		hook, ok := hooks[delivery.WebhookID]
+		if !ok {
+			var h models.Webhook
+			if err := db.First(&h, delivery.WebhookID).Error; err == nil {
+				hook = &h
+			}
+			hooks[delivery.WebhookID] = hook
+		}
+		if hook == nil || !hook.Enabled {
+			db.Model(delivery).Updates(map[string]interface{}{
+				"status":          webhookStatusFailed,
+				"error":           "Webhook 已删除或已停用",
+				"next_attempt_at": nil,
+			})
+			continue
+		}
+		// 多实例部署时同一条记录可能被多个实例同时查到，认领成功的实例才投递
+		if !claimWebhookDelivery(db, delivery) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(hook *models.Webhook, delivery *models.WebhookDelivery) {
+			defer func() { <-sem; wg.Done() }()
+			attemptWebhookDelivery(db, hook, delivery, true)
+		}(hook, delivery)
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+// claimWebhookDelivery 以条件更新原子地将记录标记为投递中，并把 next_attempt_at 设为租约到期时间，
+// 已被其他实例认领（租约未到期）时返回 false
+func claimWebhookDelivery(db *gorm.DB, delivery *models.WebhookDelivery) bool {
+	now := time.Now()
+	lease := now.Add(webhookClaimLease)
+	result := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status IN ? AND next_attempt_at <= ?", delivery.ID, []string{webhookStatusPending, webhookStatusSending}, now).
+		Updates(map[string]interface{}{
+			"status":          webhookStatusSending,
+			"next_attempt_at": lease,
+		})
+	if result.Error != nil {
+		log.Printf("[Webhook] 认领投递记录失败 delivery_id=%s err=%v", delivery.DeliveryID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	delivery.Status = webhookStatusSending
+	delivery.NextAttemptAt = &lease
+	return true
+}
+
+// attemptWebhookDelivery 投递一次并更新记录；retry 为 false 时失败不再重试（测试推送）
+func attemptWebhookDelivery(db *gorm.DB, hook *models.Webhook, delivery *models.WebhookDelivery, retry bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhook.Timeout)
+	defer cancel()
+	result, err := webhook.Deliver(ctx, webhookClient, hook.URL, hook.Secret, delivery.Event, delivery.DeliveryID, []byte(delivery.Payload))
+
+	delivery.Attempts++
+	delivery.StatusCode = result.StatusCode
+	delivery.Response = truncateForLog(result.Response, 2000)
+	delivery.DurationMs = result.Duration.Milliseconds()
+	delivery.Error = ""
+	if err != nil {
+		delivery.Error = truncateForLog(err.Error(), 1000)
+	} else if !result.OK() {
+		delivery.Error = "接收方返回非 2xx 状态码"
+	}
+
+	switch {
+	case delivery.Error == "":
+		delivery.Status = webhookStatusSuccess
+		delivery.NextAttemptAt = nil
+	case !retry || delivery.Attempts >= webhook.MaxAttempts:
+		delivery.Status = webhookStatusFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := time.Now().Add(webhook.Backoff(delivery.Attempts))
+		delivery.Status = webhookStatusPending
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := db.Model(delivery).Select("status", "attempts", "status_code", "response", "error", "duration_ms", "next_attempt_at").
+		Updates(delivery).Error; err != nil {
+		log.Printf("[Webhook] 更新投递记录失败 delivery_id=%s err=%v", delivery.DeliveryID, err)
+	}
+	if delivery.Status == webhookStatusFailed {
+		log.Printf("[Webhook] 投递失败 webhook_id=%d event=%s attempts=%d err=%s", hook.ID, delivery.Event, delivery.Attempts, delivery.Error)
+	}
+}
+
+func cleanupWebhookDeliveries(db *gorm.DB) {
+	cutoff := time.Now().Add(-webhookDeliveryRetention)
+	result := db.Where("created_at < ? AND status NOT IN ?", cutoff, []string{webhookStatusPending, webhookStatusSending}).Delete(&models.WebhookDelivery{})
+	if result.Error != nil {
+		log.Printf("[Webhook] 清理投递日志失败: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("[Webhook] 已清理 %d 条过期投递日志", result.RowsAffected)
+	}
+}
+
+// webhookDeviceEvent 设备上线/下线事件，按 device_name 查找设备所属用户
+func webhookDeviceEvent(db *gorm.DB, event, deviceName string) {
+	var device models.Device
+	if err := db.Select("id", "user_id", "agent_id", "device_name", "last_active_at").
+		Where("device_name = ?", deviceName).First(&device).Error; err != nil {
+		return
+	}
+	emitWebhookEvent(db, device.UserID, event, map[string]interface{}{
+		"device_id":      device.DeviceName,
+		"id":             device.ID,
+		"agent_id":       device.AgentID,
+		"last_active_at": device.LastActiveAt,
+	})
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestWebhookDeliveryRetry(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 || r.Header.Get(webhook.HeaderSignature) == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	// 测试接收方在回环地址上，使用不限制地址的客户端
+	defaultClient := webhookClient
+	webhookClient = srv.Client()
+	t.Cleanup(func() { webhookClient = defaultClient })
+
+	hook := models.Webhook{UserID: 1, Name: "ha", URL: srv.URL, Secret: "whsec_x", Events: webhookEventChatMessage, Enabled: true}
+	db.Create(&hook)
+	if webhookSubscribed(&hook, webhookEventDeviceOnline) || !webhookSubscribed(&hook, webhookEventChatMessage) {
+		t.Fatalf("event filter %q mismatched", hook.Events)
+	}
+
+	now := time.Now()
+	delivery := models.WebhookDelivery{WebhookID: hook.ID, DeliveryID: "d1", Event: webhookEventChatMessage, Payload: `{}`, Status: webhookStatusPending, NextAttemptAt: &now}
+	db.Create(&delivery)
+
+	// 第一次失败后按退避时间重新排队
+	if n := processDueWebhookDeliveries(db); n != 1 {
+		t.Fatalf("processed %d deliveries, want 1", n)
+	}
+	db.First(&delivery, delivery.ID)
+	if delivery.Status != webhookStatusPending || delivery.Attempts != 1 || delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(time.Now()) {
+		t.Fatalf("after failure: %+v", delivery)
+	}
+	if n := processDueWebhookDeliveries(db); n != 0 {
+		t.Fatalf("retried before backoff elapsed")
+	}
+
+	db.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second))
+	processDueWebhookDeliveries(db)
+	db.First(&delivery, delivery.ID)
+	if delivery.Status != webhookStatusSuccess || delivery.Attempts != 2 || delivery.StatusCode != http.StatusNoContent {
+		t.Fatalf("after retry: %+v", delivery)
+	}
+}
+
+func TestWebhookDeliveryClaim(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	defaultClient := webhookClient
+	webhookClient = srv.Client()
+	t.Cleanup(func() { webhookClient = defaultClient })
+
+	hook := models.Webhook{UserID: 1, Name: "ha", URL: srv.URL, Secret: "whsec_x", Enabled: true}
+	db.Create(&hook)
+	past := time.Now().Add(-time.Second)
+	delivery := models.WebhookDelivery{WebhookID: hook.ID, DeliveryID: "d1", Event: webhookEventChatMessage, Payload: `{}`, Status: webhookStatusPending, NextAttemptAt: &past}
+	db.Create(&delivery)
+
+	// 两个实例查到同一条记录，只有一个能认领
+	other := delivery
+	if !claimWebhookDelivery(db, &delivery) {
+		t.Fatal("first claim failed")
+	}
+	if claimWebhookDelivery(db, &other) {
+		t.Fatal("second claim succeeded")
+	}
+	if n := processDueWebhookDeliveries(db); n != 0 || calls != 0 {
+		t.Fatalf("claimed delivery sent again: processed=%d calls=%d", n, calls)
+	}
+
+	// 认领后进程退出，租约到期后重新投递
+	db.Model(&delivery).Update("next_attempt_at", past)
+	if n := processDueWebhookDeliveries(db); n != 1 || calls != 1 {
+		t.Fatalf("stale delivery not resent: processed=%d calls=%d", n, calls)
+	}
+	var stored models.WebhookDelivery
+	db.First(&stored, delivery.ID)
+	if stored.Status != webhookStatusSuccess || stored.NextAttemptAt != nil {
+		t.Fatalf("after delivery: %+v", stored)
+	}
+}
+
+func TestWebhookTestHidesResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"internal":"secret"}`))
+	}))
+	defer srv.Close()
+	defaultClient := webhookClient
+	webhookClient = srv.Client()
+	t.Cleanup(func() { webhookClient = defaultClient })
+
+	hook := models.Webhook{UserID: 1, Name: "ha", URL: srv.URL, Events: webhookEventTest, Enabled: true}
+	db.Create(&hook)
+	wc := &WebhookController{DB: db}
+
+	for _, role := range []string{"user", "admin"} {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("user_id", uint(1)); c.Set("role", role) })
+		r.POST("/api/user/webhooks/:id/test", wc.TestWebhook)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/user/webhooks/1/test", nil))
+		leaked := strings.Contains(w.Body.String(), "internal")
+		if w.Code != http.StatusOK || leaked != (role == "admin") {
+			t.Errorf("role %s: status=%d body=%s", role, w.Code, w.Body.String())
+		}
+	}
+}
@@ -331,6 +331,15 @@ func (client *WebSocketClient) processRequest(request *WebSocketRequest) {
 	case "/api/device/inactive":
 		client.handleDeviceInactiveRequest(request)
 
+	case "/api/mcp/tool_call_log":
+		client.handleToolCallLogRequest(request)
+
+	case "/api/mcp/oauth/token":
+		client.handleMCPOAuthTokenRequest(request)
+
+	case "/api/endpoint_token/verify":
+		client.handleEndpointTokenVerifyRequest(request)
+
 	default:
 		log.Printf("未知的请求路径: %s", request.Path)
 		client.sendResponse(request.ID, 404, nil, "Unknown endpoint")
@@ -406,6 +415,7 @@ func (client *WebSocketClient) handleDeviceActiveRequest(request *WebSocketReque
 
 	client.sendResponse(request.ID, 200, response, "")
 	log.Printf("设备 %s 活跃时间已更新为: %s", deviceID, now.Format(time.RFC3339))
+	webhookDeviceEvent(client.controller.DB, webhookEventDeviceOnline, deviceID)
 }
 
 // 处理设备离线请求
@@ -452,6 +462,7 @@ func (client *WebSocketClient) handleDeviceInactiveRequest(request *WebSocketReq
 
 	client.sendResponse(request.ID, 200, response, "")
 	log.Printf("设备 %s 已设置为离线状态", deviceID)
+	webhookDeviceEvent(client.controller.DB, webhookEventDeviceOffline, deviceID)
 }
 
 // 发送响应
@@ -694,6 +705,30 @@ func (ctrl *WebSocketController) CallMcpToolFromClient(ctx context.Context, body
 	return response.Body, nil
 }
 
+// RequestMcpPromptsFromClient 请求客户端返回智能体可用的MCP提示词列表
+func (ctrl *WebSocketController) RequestMcpPromptsFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error) {
+	response, err := ctrl.broadcastRequestAndWaitFirstSuccess(ctx, "GET", "/api/mcp/prompts", body)
+	if err != nil {
+		return nil, err
+	}
+	if response.Body == nil {
+		return map[string]interface{}{}, nil
+	}
+	return response.Body, nil
+}
+
+// GetMcpPromptFromClient 请求客户端按参数获取MCP提示词内容
+func (ctrl *WebSocketController) GetMcpPromptFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error) {
+	response, err := ctrl.broadcastRequestAndWaitFirstSuccess(ctx, "POST", "/api/mcp/prompt", body)
+	if err != nil {
+		return nil, err
+	}
+	if response.Body == nil {
+		return map[string]interface{}{}, nil
+	}
+	return response.Body, nil
+}
+
 // RequestOpenClawStatusFromClient 请求客户端返回 OpenClaw 连接状态
 func (ctrl *WebSocketController) RequestOpenClawStatusFromClient(ctx context.Context, agentID string) (map[string]interface{}, error) {
 	body := map[string]interface{}{
@@ -731,6 +766,27 @@ func (ctrl *WebSocketController) CallOpenClawChatFromClient(ctx context.Context,
 	return response.Body, nil
 }
 
+// RequestMemoryFromClient 请求客户端查询/管理长期记忆，返回主程序处理结果
+func (ctrl *WebSocketController) RequestMemoryFromClient(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error) {
+	response, err := ctrl.broadcastRequestAndWaitFirstSuccess(ctx, "POST", "/api/memory/manage", body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	if response.Body == nil {
+		return result, nil
+	}
+	raw, _ := response.Body["result"].(string)
+	if strings.TrimSpace(raw) == "" {
+		return result, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, fmt.Errorf("解析记忆响应失败: %v", err)
+	}
+	return result, nil
+}
+
 func (ctrl *WebSocketController) broadcastRequestAndWaitFirstSuccess(ctx context.Context, method, path string, body map[string]interface{}) (*WebSocketResponse, error) {
 	return ctrl.broadcastRequestAndWaitFirstSuccessWithTimeout(ctx, method, path, body, defaultBroadcastRequestTimeout)
 }
@@ -897,6 +953,19 @@ func (ctrl *WebSocketController) InjectMessageToDevice(ctx context.Context, devi
 	return lastError
 }
 
+// ReloadDeviceConfigs 通知所有主程序重新加载指定设备的配置（广播方式），设备在线的主程序会刷新当前会话
+func (ctrl *WebSocketController) ReloadDeviceConfigs(deviceIDs []string) {
+	if len(deviceIDs) == 0 {
+		return
+	}
+	ctrl.Broadcast(WebSocketRequest{
+		ID:     uuid.New().String(),
+		Method: "POST",
+		Path:   "/api/device/config_reload",
+		Body:   map[string]interface{}{"device_ids": deviceIDs},
+	})
+}
+
 // 异步发送请求到客户端（不等待响应）
 func (ctrl *WebSocketController) SendRequestToClientAsync(uuid string, method, path string, body map[string]interface{}) error {
 	if client, exists := ctrl.clientsMap.Get(uuid); exists && client.isConnected {
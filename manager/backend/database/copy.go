@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const defaultCopyBatchSize = 500
+
+// CopyOptions 数据复制选项
+type CopyOptions struct {
+	BatchSize int  // 每批读取和写入的行数，默认 500
+	Truncate  bool // 目标表已有数据时先清空；为 false 时遇到非空目标表直接报错
+}
+
+// CopyResult 单张表的复制结果
+type CopyResult struct {
+	Table string
+	Rows  int64
+}
+
+// CopyData 把 src 中的全部管理后台数据复制到 dst（如 SQLite/MySQL → PostgreSQL）。
+// 目标库的表结构先通过版本化迁移创建；每张表在一个事务中写入，主键原样保留，
+// PostgreSQL 目标库在写入后重置自增序列。
+func CopyData(src, dst *gorm.DB, opts CopyOptions) ([]CopyResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultCopyBatchSize
+	}
+	migrator, err := NewMigrator(dst)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrator.Up(0); err != nil {
+		return nil, fmt.Errorf("创建目标库表结构失败: %w", err)
+	}
+
+	results := make([]CopyResult, 0, len(AllModels()))
+	for _, model := range AllModels() {
+		stmt := &gorm.Statement{DB: dst}
+		if err := stmt.Parse(model); err != nil {
+			return results, fmt.Errorf("解析模型 %T 失败: %w", model, err)
+		}
+		table := stmt.Schema.Table
+		if !src.Migrator().HasTable(table) {
+			log.Printf("[CopyData] 源库没有表 %s，跳过", table)
+			continue
+		}
+		rows, err := copyTable(src, dst, stmt.Schema, opts)
+		if err != nil {
+			return results, fmt.Errorf("复制表 %s 失败: %w", table, err)
+		}
+		results = append(results, CopyResult{Table: table, Rows: rows})
+		log.Printf("[CopyData] %s: %d 行", table, rows)
+	}
+	return results, nil
+}
+
+func copyTable(src, dst *gorm.DB, sch *schema.Schema, opts CopyOptions) (int64, error) {
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil {
+		return 0, fmt.Errorf("表 %s 没有主键", sch.Table)
+	}
+	// 源库可能是旧版本，只复制两边都存在的列
+	columns := make([]*schema.Field, 0, len(sch.Fields))
+	for _, field := range sch.Fields {
+		if field.DBName != "" && src.Migrator().HasColumn(sch.Table, field.DBName) {
+			columns = append(columns, field)
+		}
+	}
+
+	var copied int64
+	err := dst.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Table(sch.Table).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			if !opts.Truncate {
+				return fmt.Errorf("目标表已有 %d 行数据，如需覆盖请使用 -truncate", existing)
+			}
+			if err := tx.Exec("DELETE FROM ?", clause.Table{Name: sch.Table}).Error; err != nil {
+				return fmt.Errorf("清空目标表失败: %w", err)
+			}
+		}
+
+		reader := src.Session(&gorm.Session{SkipHooks: true, NewDB: true})
+		var lastID interface{}
+		for {
+			batch := reflect.New(reflect.SliceOf(sch.ModelType))
+			query := reader.Table(sch.Table).Order(clause.OrderByColumn{Column: clause.Column{Name: pk.DBName}}).Limit(opts.BatchSize)
+			if lastID != nil {
+				query = query.Where(clause.Gt{Column: clause.Column{Name: pk.DBName}, Value: lastID})
+			}
+			if err := query.Find(batch.Interface()).Error; err != nil {
+				return fmt.Errorf("读取源数据失败: %w", err)
+			}
+			items := batch.Elem()
+			if items.Len() == 0 {
+				break
+			}
+
+			// 使用 map 写入，避免零值被替换成模型上的默认值（如 enabled=false 变成 true）
+			rows := make([]map[string]interface{}, items.Len())
+			for i := 0; i < items.Len(); i++ {
+				item := items.Index(i)
+				row := make(map[string]interface{}, len(columns))
+				for _, field := range columns {
+					row[field.DBName], _ = field.ValueOf(context.Background(), item)
+				}
+				rows[i] = row
+			}
+			if err := tx.Table(sch.Table).Create(&rows).Error; err != nil {
+				return fmt.Errorf("写入目标库失败: %w", err)
+			}
+			copied += int64(len(rows))
+			lastID, _ = pk.ValueOf(context.Background(), items.Index(items.Len()-1))
+			if items.Len() < opts.BatchSize {
+				break
+			}
+		}
+		return resetSequence(tx, sch.Table, pk.DBName)
+	})
+	return copied, err
+}
+
+// resetSequence 显式写入主键后，PostgreSQL 的自增序列不会前移，需要对齐到当前最大值
+func resetSequence(tx *gorm.DB, table, column string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec(
+		"SELECT setval(pg_get_serial_sequence(?, ?), COALESCE((SELECT MAX(?) FROM ?), 0) + 1, false)",
+		table, column, clause.Column{Name: column}, clause.Table{Name: table},
+	).Error
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCopyData(t *testing.T) {
+	src := openTestDB(t, "src.db")
+	dst := openTestDB(t, "dst.db")
+	if err := src.AutoMigrate(AllModels()...); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := models.Webhook{UserID: 1, Name: "off", URL: "http://example.com", Enabled: true}
+	src.Create(&hook)
+	src.Model(&hook).Update("enabled", false)
+	toolCalls := models.JSONText(`[{"name":"get_weather"}]`)
+	src.Create(&models.ChatMessage{MessageID: "m1", DeviceID: "d1", AgentID: "1", Role: "user", Content: "hi"})
+	src.Create(&models.ChatMessage{MessageID: "m2", DeviceID: "d1", AgentID: "1", Role: "assistant", Content: "ok", ToolCallsJSON: &toolCalls})
+
+	results, err := CopyData(src, dst, CopyOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int64)
+	for _, r := range results {
+		counts[r.Table] = r.Rows
+	}
+	if counts["webhooks"] != 1 || counts["chat_messages"] != 2 {
+		t.Fatalf("copied rows = %v", counts)
+	}
+
+	var copied models.Webhook
+	dst.First(&copied, hook.ID)
+	if copied.Enabled {
+		t.Error("enabled=false was replaced by the column default")
+	}
+	var msg models.ChatMessage
+	dst.Where("message_id = ?", "m2").First(&msg)
+	if msg.ToolCallsJSON == nil || *msg.ToolCallsJSON != toolCalls {
+		t.Errorf("tool_calls = %v", msg.ToolCallsJSON)
+	}
+
+	// 目标表非空时默认拒绝，-truncate 时覆盖
+	if _, err := CopyData(src, dst, CopyOptions{}); err == nil {
+		t.Error("copy into non-empty target succeeded without Truncate")
+	}
+	if _, err := CopyData(src, dst, CopyOptions{Truncate: true}); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	dst.Model(&models.ChatMessage{}).Count(&total)
+	if total != 2 {
+		t.Errorf("chat_messages after truncate copy = %d", total)
+	}
+}
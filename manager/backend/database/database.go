@@ -3,46 +3,24 @@ package database
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"xiaozhi/manager/backend/config"
 	"xiaozhi/manager/backend/models"
 
-	"github.com/glebarez/sqlite"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 func Init(cfg config.DatabaseConfig) *gorm.DB {
-	var db *gorm.DB
-	var err error
-
-	storageType := cfg.GetStorageType()
-
-	if storageType == "sqlite" {
-		if cfg.SQLite == nil {
-			log.Println("SQLite配置为空，将使用fallback模式运行（硬编码用户验证）")
-			return nil
-		}
-		// 确保数据库文件所在目录存在，避免 SQLite 报 unable to open database file
-		dir := filepath.Dir(cfg.SQLite.FilePath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			log.Printf("创建数据库目录失败 %s: %v", dir, err)
-			return nil
+	switch cfg.GetStorageType() {
+	case "sqlite":
+		if cfg.SQLite != nil {
+			log.Println("使用SQLite数据库:", cfg.SQLite.FilePath)
 		}
-		log.Println("使用SQLite数据库:", cfg.SQLite.FilePath)
-		db, err = gorm.Open(sqlite.Open(cfg.SQLite.FilePath), &gorm.Config{})
-	} else {
-		if cfg.MySQL == nil {
-			log.Println("MySQL配置为空，将使用fallback模式运行（硬编码用户验证）")
-			return nil
+	case "postgres":
+		if cfg.Postgres != nil {
+			log.Printf("使用PostgreSQL数据库: %s:%d/%s", cfg.Postgres.Host, cfg.Postgres.Port, cfg.Postgres.Database)
 		}
-		// MySQL 数据库连接
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.MySQL.Username, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database)
-		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	}
-
+	db, err := Open(cfg)
 	if err != nil {
 		log.Println("数据库连接失败:", err)
 		log.Println("将使用fallback模式运行（硬编码用户验证）")
@@ -51,33 +29,12 @@ func Init(cfg config.DatabaseConfig) *gorm.DB {
 
 	log.Println("数据库连接成功")
 
-	// 自动迁移数据库表结构
-	log.Println("开始自动迁移数据库表结构...")
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Device{},
-		&models.Agent{},
-		&models.KnowledgeBase{},
-		&models.KnowledgeBaseDocument{},
-		&models.AgentKnowledgeBase{},
-		&models.Config{},
-		&models.MCPMarketService{},
-		&models.GlobalRole{},
-		&models.Role{}, // 新增：统一角色表
-		&models.ChatMessage{},
-		&models.SpeakerGroup{},
-		&models.SpeakerSample{},
-		&models.VoiceClone{},
-		&models.VoiceCloneAudio{},
-		&models.VoiceCloneTask{},
-		&models.UserVoiceCloneQuota{},
-	)
-	if err != nil {
+	// 执行版本化迁移
+	if err := migrateOnStartup(db, cfg.ManualMigrate); err != nil {
 		log.Printf("数据库表结构迁移失败: %v", err)
 		log.Println("将使用fallback模式运行（硬编码用户验证）")
 		return nil
 	}
-	log.Println("数据库表结构迁移成功")
 
 	// 迁移现有全局角色数据到新的 roles 表
 	log.Println("检查是否需要迁移全局角色数据...")
@@ -89,6 +46,33 @@ func Init(cfg config.DatabaseConfig) *gorm.DB {
 	return db
 }
 
+// migrateOnStartup 启动时执行未执行的迁移；manual 为 true 时只检查，有未执行的迁移直接报错
+func migrateOnStartup(db *gorm.DB, manual bool) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	if manual {
+		pending, err := migrator.Pending()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("有 %d 个未执行的迁移，已配置 manual_migrate，请先执行 migrate up", len(pending))
+		}
+		log.Println("数据库表结构已是最新版本")
+		return nil
+	}
+
+	log.Println("开始执行数据库迁移...")
+	applied, err := migrator.Up(0)
+	if err != nil {
+		return err
+	}
+	log.Printf("数据库迁移完成，本次执行 %d 个迁移", applied)
+	return nil
+}
+
 func Close(db *gorm.DB) {
 	sqlDB, err := db.DB()
 	if err != nil {
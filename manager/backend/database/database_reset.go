@@ -1,36 +1,16 @@
 package database
 
 import (
-	"fmt"
 	"log"
 	"xiaozhi/manager/backend/config"
 	"xiaozhi/manager/backend/models"
 
-	"github.com/glebarez/sqlite"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 // InitWithReset 初始化数据库并重置所有表（仅用于开发环境）
 func InitWithReset(cfg config.DatabaseConfig) *gorm.DB {
-	storageType := cfg.GetStorageType()
-	var db *gorm.DB
-	var err error
-
-	if storageType == "sqlite" {
-		if cfg.SQLite == nil {
-			log.Fatal("SQLite配置为空")
-		}
-		db, err = gorm.Open(sqlite.Open(cfg.SQLite.FilePath), &gorm.Config{})
-	} else {
-		if cfg.MySQL == nil {
-			log.Fatal("MySQL配置为空")
-		}
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.MySQL.Username, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database)
-		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	}
-
+	db, err := Open(cfg)
 	if err != nil {
 		log.Fatal("数据库连接失败:", err)
 	}
@@ -40,16 +20,28 @@ func InitWithReset(cfg config.DatabaseConfig) *gorm.DB {
 	// 删除所有表
 	err = db.Migrator().DropTable(
 		&models.User{},
+		&models.UserSession{},
+		&models.RevokedToken{},
+		&models.OIDCLoginState{},
+		&models.UserRecoveryCode{},
+		&models.APIKey{},
+		&models.Organization{},
+		&models.AuditLog{},
+		&models.ConfigVersion{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
 		&models.Device{},
 		&models.Agent{},
 		&models.Config{},
 		&models.MCPMarketService{},
+		&models.MCPOAuthCredential{},
 		&models.GlobalRole{},
 		&models.Role{},
 		&models.SpeakerGroup{},
 		&models.SpeakerSample{},
 		&models.VoiceClone{},
 		&models.VoiceCloneAudio{},
+		&schemaMigration{},
 	)
 	if err != nil {
 		log.Printf("删除表时出现错误（可能表不存在）: %v", err)
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"xiaozhi/manager/backend/config"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Dialector 根据数据库配置创建 GORM 驱动，三种数据库共用
+func Dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.GetStorageType() {
+	case "sqlite":
+		if cfg.SQLite == nil {
+			return nil, fmt.Errorf("SQLite配置为空")
+		}
+		// 确保数据库文件所在目录存在，避免 SQLite 报 unable to open database file
+		dir := filepath.Dir(cfg.SQLite.FilePath)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败 %s: %w", dir, err)
+		}
+		return sqlite.Open(cfg.SQLite.FilePath), nil
+	case "postgres":
+		if cfg.Postgres == nil {
+			return nil, fmt.Errorf("PostgreSQL配置为空")
+		}
+		return postgres.Open(cfg.Postgres.DSN()), nil
+	default:
+		if cfg.MySQL == nil {
+			return nil, fmt.Errorf("MySQL配置为空")
+		}
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			cfg.MySQL.Username, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database)
+		return mysql.Open(dsn), nil
+	}
+}
+
+// Open 连接配置的数据库
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(dialector, &gorm.Config{})
+}
@@ -0,0 +1,364 @@
+package database
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 迁移脚本按数据库类型放在 migrations/<sqlite|mysql|postgres>/ 下，
+// 文件名为 <版本号>_<名称>.up.sql / .down.sql，三种数据库的版本号必须一一对应。
+// 已发布的脚本不能再修改（校验和不一致时拒绝升级），表结构变更一律新增版本。
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// baselineVersion 基线版本：改用版本化迁移前由 AutoMigrate 创建的表结构
+const baselineVersion = 1
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本化迁移
+type Migration struct {
+	Version  int
+	Name     string
+	UpSQL    string
+	DownSQL  string // 为空表示不可回滚
+	Checksum string // up 脚本的 sha256
+}
+
+// MigrationStatus 迁移的执行状态
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // 已执行后脚本内容被修改
+}
+
+// schemaMigration 迁移历史表
+type schemaMigration struct {
+	Version     int       `gorm:"primaryKey;autoIncrement:false"`
+	Name        string    `gorm:"type:varchar(255);not null"`
+	Checksum    string    `gorm:"type:varchar(64);not null"`
+	AppliedAt   time.Time `gorm:"not null"`
+	ExecutionMs int64
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator 执行版本化迁移
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator 加载当前数据库类型对应的内置迁移脚本
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(db, sub)
+}
+
+func newMigrator(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := loadMigrations(fsys, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations 读取 <dialect>/ 目录下的迁移脚本，按版本号排序
+func loadMigrations(fsys fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("不支持的数据库类型 %s: %w", dialect, err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("迁移文件名不合法: %s/%s", dialect, entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// 统一换行符，避免 Windows 检出的文件校验和不同
+		content := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("迁移版本 %d 存在多个名称: %s, %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = content
+			sum := sha256.Sum256([]byte(content))
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.DownSQL = content
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return nil, fmt.Errorf("迁移 %04d_%s 缺少 up 脚本", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrations 返回全部已知迁移
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// NeedsBaseline 数据库由旧版本 AutoMigrate 创建、还没有迁移历史时返回 true，
+// 这种数据库执行 Up 时用 AutoMigrate 补齐到当前表结构并登记全部迁移，不执行迁移脚本
+func (m *Migrator) NeedsBaseline() bool {
+	return !m.db.Migrator().HasTable(&schemaMigration{}) && m.db.Migrator().HasTable("users")
+}
+
+// Status 返回每个迁移的执行状态，不修改数据库
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Migration: mig}
+		if record, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = record.AppliedAt
+			s.Modified = record.Checksum != mig.Checksum
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Pending 返回尚未执行的迁移
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up 按版本号顺序执行未执行的迁移，target 大于 0 时只执行到该版本（含），返回执行的个数
+func (m *Migrator) Up(target int) (int, error) {
+	if err := m.ensureHistory(); err != nil {
+		return 0, err
+	}
+	applied, err := m.verify()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		log.Printf("[Migrate] 执行迁移 %04d_%s", mig.Version, mig.Name)
+		start := time.Now()
+		// MySQL 的 DDL 会隐式提交，失败时可能只执行了一部分语句，需要按日志手动处理后重试
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, mig.UpSQL); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{
+				Version:     mig.Version,
+				Name:        mig.Name,
+				Checksum:    mig.Checksum,
+				AppliedAt:   time.Now(),
+				ExecutionMs: time.Since(start).Milliseconds(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("迁移 %04d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down 按版本号倒序回滚最近执行的 steps 个迁移，返回回滚的个数
+func (m *Migrator) Down(steps int) (int, error) {
+	if !m.db.Migrator().HasTable(&schemaMigration{}) {
+		return 0, nil
+	}
+	if _, err := m.verify(); err != nil {
+		return 0, err
+	}
+	var records []schemaMigration
+	if err := m.db.Order("version DESC").Limit(steps).Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("读取迁移历史失败: %w", err)
+	}
+
+	count := 0
+	for _, record := range records {
+		mig := m.find(record.Version)
+		if mig.DownSQL == "" {
+			return count, fmt.Errorf("迁移 %04d_%s 不可回滚", mig.Version, mig.Name)
+		}
+		log.Printf("[Migrate] 回滚迁移 %04d_%s", mig.Version, mig.Name)
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, mig.DownSQL); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, record.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("回滚 %04d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ensureHistory 创建迁移历史表；旧数据库先用 AutoMigrate 补齐到当前模型的表结构，再登记全部迁移。
+// AutoMigrate 已建出基线之后新增的列和索引，再执行这些迁移脚本会因重复建列失败
+func (m *Migrator) ensureHistory() error {
+	if m.db.Migrator().HasTable(&schemaMigration{}) {
+		return nil
+	}
+	legacy := m.NeedsBaseline()
+	if err := m.db.AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("创建迁移历史表失败: %w", err)
+	}
+	if !legacy {
+		return nil
+	}
+
+	baseline := m.find(baselineVersion)
+	if baseline == nil {
+		return fmt.Errorf("缺少基线迁移 %04d", baselineVersion)
+	}
+	log.Printf("[Migrate] 检测到未登记迁移历史的数据库，补齐表结构并登记 %d 个迁移", len(m.migrations))
+	if err := m.db.AutoMigrate(AllModels()...); err != nil {
+		return fmt.Errorf("补齐表结构失败: %w", err)
+	}
+	now := time.Now()
+	for _, mig := range m.migrations {
+		if err := m.db.Create(&schemaMigration{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Checksum:  mig.Checksum,
+			AppliedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify 校验已执行的迁移：程序不认识的版本说明数据库比程序新，校验和不一致说明脚本被修改，两者都拒绝继续
+func (m *Migrator) verify() (map[int]schemaMigration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	for version, record := range applied {
+		mig := m.find(version)
+		if mig == nil {
+			return nil, fmt.Errorf("数据库已执行迁移 %04d_%s，当前程序不包含该版本，请使用更新的程序", version, record.Name)
+		}
+		if record.Checksum != mig.Checksum {
+			return nil, fmt.Errorf("迁移 %04d_%s 执行后脚本已被修改（校验和不一致），请新增迁移版本而不是修改已发布的脚本", version, mig.Name)
+		}
+	}
+	return applied, nil
+}
+
+func (m *Migrator) applied() (map[int]schemaMigration, error) {
+	applied := make(map[int]schemaMigration)
+	if !m.db.Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+	var records []schemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("读取迁移历史失败: %w", err)
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// execScript 逐条执行脚本中的语句：语句以行尾分号结束，整行 -- 开头的为注释
+func execScript(tx *gorm.DB, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("%w\n语句: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(line)
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestMigrationsOnEmptyDatabase 在空库上执行全部迁移、全部回滚再重新执行，并确认迁移后的表结构覆盖所有模型。
+// 默认只测 SQLite；设置 MIGRATE_TEST_MYSQL_DSN / MIGRATE_TEST_POSTGRES_DSN 时同时测试对应的空库。
+func TestMigrationsOnEmptyDatabase(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testMigrationsOnEmptyDatabase(t, openTestDB(t, "migrate.db"))
+	})
+	if dsn := os.Getenv("MIGRATE_TEST_MYSQL_DSN"); dsn != "" {
+		t.Run("mysql", func(t *testing.T) {
+			testMigrationsOnEmptyDatabase(t, openDSN(t, mysql.Open(dsn)))
+		})
+	}
+	if dsn := os.Getenv("MIGRATE_TEST_POSTGRES_DSN"); dsn != "" {
+		t.Run("postgres", func(t *testing.T) {
+			testMigrationsOnEmptyDatabase(t, openDSN(t, postgres.Open(dsn)))
+		})
+	}
+}
+
+func openDSN(t *testing.T, dialector gorm.Dialector) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	return db
+}
+
+func testMigrationsOnEmptyDatabase(t *testing.T, db *gorm.DB) {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(migrator.Migrations())
+	if applied, err := migrator.Up(0); err != nil || applied != total {
+		t.Fatalf("Up = %d, %v; want %d", applied, err, total)
+	}
+	t.Cleanup(func() { migrator.Down(total) })
+	assertSchemaCoversModels(t, db)
+
+	if applied, err := migrator.Up(0); err != nil || applied != 0 {
+		t.Fatalf("second Up = %d, %v", applied, err)
+	}
+	if rolledBack, err := migrator.Down(total); err != nil || rolledBack != total {
+		t.Fatalf("Down = %d, %v; want %d", rolledBack, err, total)
+	}
+	for _, model := range AllModels() {
+		if db.Migrator().HasTable(model) {
+			t.Errorf("table of %T still exists after rolling back all migrations", model)
+		}
+	}
+	if applied, err := migrator.Up(0); err != nil || applied != total {
+		t.Fatalf("Up after Down = %d, %v", applied, err)
+	}
+}
+
+// assertSchemaCoversModels 模型新增了表或列却没有对应的迁移时失败
+func assertSchemaCoversModels(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	for _, model := range AllModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(stmt.Schema.Table) {
+			t.Errorf("missing table %s: add a migration", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			if !db.Migrator().HasColumn(stmt.Schema.Table, field.DBName) {
+				t.Errorf("missing column %s.%s: add a migration", stmt.Schema.Table, field.DBName)
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if !db.Migrator().HasIndex(stmt.Schema.Table, idx.Name) {
+				t.Errorf("missing index %s on %s: add a migration", idx.Name, stmt.Schema.Table)
+			}
+		}
+	}
+}
+
+// TestMigrationFilesMatchAcrossDialects 三种数据库的迁移版本必须一一对应，且都可回滚
+func TestMigrationFilesMatchAcrossDialects(t *testing.T) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reference []Migration
+	for _, dialect := range []string{"sqlite", "mysql", "postgres"} {
+		migrations, err := loadMigrations(sub, dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != baselineVersion {
+			t.Fatalf("%s: first migration must be the baseline", dialect)
+		}
+		for _, m := range migrations {
+			if m.DownSQL == "" {
+				t.Errorf("%s: %04d_%s has no down script", dialect, m.Version, m.Name)
+			}
+		}
+		if reference == nil {
+			reference = migrations
+			continue
+		}
+		if len(migrations) != len(reference) {
+			t.Fatalf("%s has %d migrations, sqlite has %d", dialect, len(migrations), len(reference))
+		}
+		for i, m := range migrations {
+			if m.Version != reference[i].Version || m.Name != reference[i].Name {
+				t.Errorf("%s: %04d_%s does not match sqlite %04d_%s", dialect, m.Version, m.Name, reference[i].Version, reference[i].Name)
+			}
+		}
+	}
+}
+
+// TestMigrateLegacyDatabase 旧版本 AutoMigrate 建出的库补齐表结构后登记全部迁移，不重复建表建列
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := openTestDB(t, "legacy.db")
+	if err := db.AutoMigrate(AllModels()...); err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !migrator.NeedsBaseline() {
+		t.Fatal("NeedsBaseline = false for a database without migration history")
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("%04d_%s not applied", s.Version, s.Name)
+		}
+	}
+	if migrator.NeedsBaseline() {
+		t.Error("NeedsBaseline = true after Up")
+	}
+}
+
+func TestMigratorChecksumAndUnknownVersion(t *testing.T) {
+	db := openTestDB(t, "checksum.db")
+	files := fstest.MapFS{
+		"sqlite/0001_baseline.up.sql":    {Data: []byte("-- 基线\nCREATE TABLE users (id integer PRIMARY KEY);\n")},
+		"sqlite/0001_baseline.down.sql":  {Data: []byte("DROP TABLE users;\n")},
+		"sqlite/0002_add_name.up.sql":    {Data: []byte("ALTER TABLE users ADD COLUMN name text;\nUPDATE users SET name = 'x';\n")},
+		"sqlite/0002_add_name.down.sql":  {Data: []byte("ALTER TABLE users DROP COLUMN name;\n")},
+		"sqlite/0003_add_email.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN email text;\n")},
+		"sqlite/0003_add_email.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN email;\n")},
+	}
+	migrator, err := newMigrator(db, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := migrator.Up(2); err != nil || applied != 2 {
+		t.Fatalf("Up(2) = %d, %v", applied, err)
+	}
+	if !db.Migrator().HasColumn("users", "name") || db.Migrator().HasColumn("users", "email") {
+		t.Fatal("Up(2) applied the wrong migrations")
+	}
+
+	// 已执行的脚本被修改后拒绝升级
+	files["sqlite/0002_add_name.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD COLUMN name varchar(10);\n")}
+	modified, err := newMigrator(db, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := modified.Up(0); err == nil {
+		t.Error("Up succeeded although an applied migration was modified")
+	}
+	statuses, _ := modified.Status()
+	if !statuses[1].Modified {
+		t.Error("status does not report the modified migration")
+	}
+
+	// 数据库版本比程序新时拒绝升级和回滚
+	older, err := newMigrator(db, fstest.MapFS{
+		"sqlite/0001_baseline.up.sql": files["sqlite/0001_baseline.up.sql"],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := older.Up(0); err == nil {
+		t.Error("Up succeeded with an unknown applied version")
+	}
+	if _, err := older.Down(1); err == nil {
+		t.Error("Down succeeded with an unknown applied version")
+	}
+
+	if rolledBack, err := migrator.Down(1); err != nil || rolledBack != 1 {
+		t.Fatalf("Down(1) = %d, %v", rolledBack, err)
+	}
+	if db.Migrator().HasColumn("users", "name") {
+		t.Error("Down(1) did not drop the column")
+	}
+}
@@ -0,0 +1,62 @@
+-- 基线：改用版本化迁移前由 AutoMigrate 创建的全部表结构
+-- 已有数据库首次升级时只登记此版本、不执行（见 database/migrate.go）
+
+CREATE TABLE `users` (`id` bigint unsigned AUTO_INCREMENT,`username` varchar(50) NOT NULL,`password` varchar(255) NOT NULL,`email` varchar(100),`role` varchar(20) NOT NULL DEFAULT 'user',`org_id` bigint unsigned,`token_version` bigint NOT NULL DEFAULT 0,`auth_provider` varchar(20) NOT NULL DEFAULT 'local',`external_id` varchar(255),`totp_enabled` boolean NOT NULL DEFAULT false,`totp_secret` varchar(64),`totp_last_counter` bigint NOT NULL DEFAULT 0,`two_factor_failures` bigint NOT NULL DEFAULT 0,`two_factor_lock_until` datetime(3) NULL,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_users_username` (`username`),UNIQUE INDEX `idx_users_email` (`email`),INDEX `idx_users_org_id` (`org_id`),UNIQUE INDEX `idx_users_external_id` (`external_id`));
+
+CREATE TABLE `user_sessions` (`id` bigint unsigned AUTO_INCREMENT,`session_id` varchar(64) NOT NULL,`user_id` bigint unsigned NOT NULL,`refresh_token_hash` varchar(64) NOT NULL,`token_version` bigint NOT NULL DEFAULT 0,`user_agent` varchar(255),`ip` varchar(64),`expires_at` datetime(3) NULL,`revoked_at` datetime(3) NULL,`last_used_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_user_sessions_session_id` (`session_id`),INDEX `idx_user_sessions_user_id` (`user_id`),UNIQUE INDEX `idx_user_sessions_refresh_token_hash` (`refresh_token_hash`),INDEX `idx_user_sessions_expires_at` (`expires_at`));
+
+CREATE TABLE `revoked_tokens` (`id` bigint unsigned AUTO_INCREMENT,`jti` varchar(64) NOT NULL,`user_id` bigint unsigned,`expires_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_revoked_tokens_jti` (`jti`),INDEX `idx_revoked_tokens_user_id` (`user_id`),INDEX `idx_revoked_tokens_expires_at` (`expires_at`));
+
+CREATE TABLE `oidc_login_states` (`id` bigint unsigned AUTO_INCREMENT,`state` varchar(64) NOT NULL,`nonce` varchar(64) NOT NULL,`code_verifier` varchar(128) NOT NULL,`ticket_hash` varchar(64),`user_id` bigint unsigned,`expires_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_oidc_login_states_state` (`state`),UNIQUE INDEX `idx_oidc_login_states_ticket_hash` (`ticket_hash`),INDEX `idx_oidc_login_states_expires_at` (`expires_at`));
+
+CREATE TABLE `user_recovery_codes` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`code_hash` varchar(64) NOT NULL,`used_at` datetime(3) NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_user_recovery_codes_user_id` (`user_id`));
+
+CREATE TABLE `api_keys` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`name` varchar(100) NOT NULL,`prefix` varchar(16) NOT NULL,`key_hash` varchar(64) NOT NULL,`scopes` varchar(500) NOT NULL,`expires_at` datetime(3) NULL,`last_used_at` datetime(3) NULL,`last_used_ip` varchar(64),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_api_keys_user_id` (`user_id`),UNIQUE INDEX `idx_api_keys_key_hash` (`key_hash`));
+
+CREATE TABLE `organizations` (`id` bigint unsigned AUTO_INCREMENT,`name` varchar(100) NOT NULL,`slug` varchar(64) NOT NULL,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_organizations_slug` (`slug`));
+
+CREATE TABLE `audit_logs` (`id` bigint unsigned AUTO_INCREMENT,`actor_id` bigint unsigned,`actor_name` varchar(100),`actor_role` varchar(20),`org_id` bigint unsigned,`api_key_id` bigint unsigned,`action` varchar(64) NOT NULL,`entity_type` varchar(64),`entity_id` varchar(64),`method` varchar(10),`path` varchar(255),`status_code` bigint,`before` text,`after` text,`diff` text,`ip` varchar(64),`user_agent` varchar(255),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_audit_logs_actor_id` (`actor_id`),INDEX `idx_audit_logs_org_id` (`org_id`),INDEX `idx_audit_logs_action` (`action`),INDEX `idx_audit_logs_entity_type` (`entity_type`),INDEX `idx_audit_logs_entity_id` (`entity_id`),INDEX `idx_audit_logs_created_at` (`created_at`));
+
+CREATE TABLE `config_versions` (`id` bigint unsigned AUTO_INCREMENT,`entity_type` varchar(20) NOT NULL,`entity_id` bigint unsigned NOT NULL,`version` bigint NOT NULL,`snapshot` text,`comment` varchar(255),`actor_id` bigint unsigned,`actor_name` varchar(100),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_config_version` (`entity_type`,`entity_id`,`version`));
+
+CREATE TABLE `webhooks` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`name` varchar(100) NOT NULL,`url` varchar(1024) NOT NULL,`secret` varchar(128),`events` text,`all_users` boolean DEFAULT false,`enabled` boolean DEFAULT true,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_webhooks_user_id` (`user_id`));
+
+CREATE TABLE `webhook_deliveries` (`id` bigint unsigned AUTO_INCREMENT,`webhook_id` bigint unsigned NOT NULL,`delivery_id` varchar(64),`event` varchar(50),`payload` text,`status` varchar(20),`attempts` bigint DEFAULT 0,`status_code` bigint,`response` text,`error` text,`duration_ms` bigint,`next_attempt_at` datetime(3) NULL,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_webhook_deliveries_webhook_id` (`webhook_id`),UNIQUE INDEX `idx_webhook_deliveries_delivery_id` (`delivery_id`),INDEX `idx_webhook_deliveries_event` (`event`),INDEX `idx_webhook_deliveries_status` (`status`),INDEX `idx_webhook_deliveries_next_attempt_at` (`next_attempt_at`),INDEX `idx_webhook_deliveries_created_at` (`created_at`));
+
+CREATE TABLE `devices` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`agent_id` bigint unsigned NOT NULL DEFAULT 0,`role_id` bigint unsigned,`device_code` varchar(100),`device_name` varchar(100),`challenge` varchar(128),`pre_secret_key` varchar(128),`activated` boolean DEFAULT false,`last_active_at` datetime(3) NULL,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_devices_role_id` (`role_id`),UNIQUE INDEX `idx_devices_device_code` (`device_code`));
+
+CREATE TABLE `agents` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`name` varchar(100) NOT NULL,`custom_prompt` text,`llm_config_id` varchar(100),`tts_config_id` varchar(100),`voice` varchar(200),`asr_speed` varchar(20) DEFAULT 'normal',`memory_mode` varchar(20) DEFAULT 'short',`mcp_service_names` text,`open_claw_config` text,`mcp_tool_policy_config` text,`status` varchar(20) DEFAULT 'active',`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`));
+
+CREATE TABLE `knowledge_bases` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`name` varchar(100) NOT NULL,`description` text,`content` text,`retrieval_threshold` double precision,`external_kb_id` varchar(255),`external_doc_id` varchar(255),`auto_dataset` boolean DEFAULT false,`sync_provider` varchar(50),`sync_status` varchar(20) DEFAULT 'pending',`sync_error` text,`last_synced_at` datetime(3) NULL,`status` varchar(20) DEFAULT 'active',`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_knowledge_bases_user_id` (`user_id`),INDEX `idx_knowledge_bases_external_kb_id` (`external_kb_id`),INDEX `idx_knowledge_bases_external_doc_id` (`external_doc_id`),INDEX `idx_knowledge_bases_sync_provider` (`sync_provider`),INDEX `idx_knowledge_bases_sync_status` (`sync_status`),INDEX `idx_knowledge_bases_status` (`status`));
+
+CREATE TABLE `knowledge_base_documents` (`id` bigint unsigned AUTO_INCREMENT,`knowledge_base_id` bigint unsigned NOT NULL,`name` varchar(200) NOT NULL,`content` text,`external_doc_id` varchar(255),`sync_status` varchar(20) DEFAULT 'pending',`sync_error` text,`last_synced_at` datetime(3) NULL,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_knowledge_base_documents_knowledge_base_id` (`knowledge_base_id`),INDEX `idx_knowledge_base_documents_external_doc_id` (`external_doc_id`),INDEX `idx_knowledge_base_documents_sync_status` (`sync_status`));
+
+CREATE TABLE `agent_knowledge_bases` (`id` bigint unsigned AUTO_INCREMENT,`agent_id` bigint unsigned NOT NULL,`knowledge_base_id` bigint unsigned NOT NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_agent_knowledge_bases_agent_id` (`agent_id`),UNIQUE INDEX `idx_agent_kb_unique` (`agent_id`,`knowledge_base_id`),INDEX `idx_agent_knowledge_bases_knowledge_base_id` (`knowledge_base_id`));
+
+CREATE TABLE `configs` (`id` bigint unsigned AUTO_INCREMENT,`type` varchar(50) NOT NULL,`name` varchar(100) NOT NULL,`config_id` varchar(100) NOT NULL,`provider` varchar(50),`json_data` text,`enabled` boolean DEFAULT true,`is_default` boolean DEFAULT false,`org_id` bigint unsigned,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `type_config_id` (`type`,`config_id`),INDEX `idx_configs_org_id` (`org_id`));
+
+CREATE TABLE `mcp_market_services` (`id` bigint unsigned AUTO_INCREMENT,`name` varchar(150) NOT NULL,`enabled` boolean DEFAULT true,`transport` varchar(32) NOT NULL,`url` text NOT NULL,`url_hash` varchar(512) NOT NULL,`headers_json` text,`market_id` bigint unsigned,`provider_id` varchar(50),`service_id` varchar(255),`service_name` varchar(255),`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_mcp_market_services_enabled` (`enabled`),UNIQUE INDEX `idx_mcp_market_services_url_hash` (`url_hash`),INDEX `idx_mcp_market_services_market_id` (`market_id`),INDEX `idx_mcp_market_services_provider_id` (`provider_id`),INDEX `idx_mcp_market_services_service_id` (`service_id`));
+
+CREATE TABLE `mcpo_auth_credentials` (`id` bigint unsigned AUTO_INCREMENT,`url` text NOT NULL,`url_hash` varchar(512) NOT NULL,`status` varchar(20) COMMENT 'pending|authorized|error',`resource` text,`authorization_server` text,`authorization_endpoint` text,`token_endpoint` text,`scope` varchar(500),`redirect_uri` text,`client_id` varchar(255),`client_secret_ciphertext` text,`client_secret_nonce` varchar(64),`dynamic_client` boolean,`access_token_ciphertext` text,`access_token_nonce` varchar(64),`refresh_token_ciphertext` text,`refresh_token_nonce` varchar(64),`token_type` varchar(32),`expires_at` datetime(3) NULL,`state` varchar(128),`code_verifier_ciphertext` text,`code_verifier_nonce` varchar(64),`last_error` text,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_mcp_oauth_credentials_url_hash` (`url_hash`),INDEX `idx_mcpo_auth_credentials_status` (`status`),INDEX `idx_mcpo_auth_credentials_state` (`state`));
+
+CREATE TABLE `global_roles` (`id` bigint unsigned AUTO_INCREMENT,`name` varchar(100) NOT NULL,`description` text,`prompt` text,`is_default` boolean DEFAULT false,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`));
+
+CREATE TABLE `roles` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned,`name` varchar(100) NOT NULL,`description` text,`prompt` text,`llm_config_id` varchar(100),`tts_config_id` varchar(100),`voice` varchar(200),`role_type` varchar(20) DEFAULT 'user',`status` varchar(20) DEFAULT 'active',`sort_order` bigint DEFAULT 0,`is_default` boolean DEFAULT false,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_roles_user_id` (`user_id`),INDEX `idx_roles_role_type` (`role_type`),INDEX `idx_roles_status` (`status`),INDEX `idx_roles_is_default` (`is_default`));
+
+CREATE TABLE `chat_messages` (`id` bigint unsigned AUTO_INCREMENT,`message_id` varchar(64) NOT NULL,`device_id` varchar(100) NOT NULL,`agent_id` varchar(64) NOT NULL,`user_id` bigint unsigned NOT NULL,`session_id` varchar(64),`role` varchar(20) NOT NULL COMMENT 'user|assistant|system|tool',`content` text NOT NULL,`tool_call_id` varchar(64) COMMENT '工具调用ID（Tool角色使用）',`tool_calls` json COMMENT '工具调用列表JSON（Assistant角色使用）',`audio_path` varchar(512) COMMENT '音频文件相对路径（两级hash打散）',`audio_duration` bigint COMMENT '毫秒',`audio_size` bigint COMMENT '字节',`audio_format` varchar(20) DEFAULT 'wav' COMMENT '音频格式（固定为wav）',`metadata` json,`is_deleted` boolean DEFAULT false,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_chat_messages_message_id` (`message_id`),INDEX `idx_device_id` (`device_id`),INDEX `idx_agent_id` (`agent_id`),INDEX `idx_user_id` (`user_id`),INDEX `idx_session_id` (`session_id`),INDEX `idx_chat_messages_role` (`role`),INDEX `idx_chat_messages_tool_call_id` (`tool_call_id`),INDEX `idx_chat_messages_is_deleted` (`is_deleted`),INDEX `idx_created_at` (`created_at`));
+
+CREATE TABLE `speaker_groups` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`agent_id` bigint unsigned NOT NULL,`name` varchar(100) NOT NULL,`prompt` text,`description` text,`tts_config_id` varchar(100),`voice` varchar(200),`status` varchar(20) DEFAULT 'active',`sample_count` bigint DEFAULT 0,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_speaker_groups_user_id` (`user_id`),UNIQUE INDEX `idx_speaker_groups_user_name` (`user_id`,`name`),INDEX `idx_speaker_groups_agent_id` (`agent_id`));
+
+CREATE TABLE `speaker_samples` (`id` bigint unsigned AUTO_INCREMENT,`speaker_group_id` bigint unsigned NOT NULL,`user_id` bigint unsigned NOT NULL,`uuid` varchar(36) NOT NULL,`file_path` varchar(500) NOT NULL,`file_name` varchar(255),`file_size` bigint,`duration` float,`status` varchar(20) DEFAULT 'active',`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_speaker_samples_speaker_group_id` (`speaker_group_id`),INDEX `idx_speaker_samples_user_id` (`user_id`),UNIQUE INDEX `idx_speaker_samples_uuid` (`uuid`));
+
+CREATE TABLE `voice_clones` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`name` varchar(100) NOT NULL,`provider` varchar(50) NOT NULL,`provider_voice_id` varchar(200) NOT NULL,`tts_config_id` varchar(100) NOT NULL,`status` varchar(20) DEFAULT 'active',`transcript_required` boolean DEFAULT false,`meta_json` json,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_voice_clones_user_id` (`user_id`),INDEX `idx_voice_clones_provider` (`provider`),INDEX `idx_voice_clones_provider_voice_id` (`provider_voice_id`),INDEX `idx_voice_clones_tts_config_id` (`tts_config_id`),INDEX `idx_voice_clones_status` (`status`));
+
+CREATE TABLE `voice_clone_audios` (`id` bigint unsigned AUTO_INCREMENT,`voice_clone_id` bigint unsigned,`user_id` bigint unsigned NOT NULL,`source_type` varchar(20) NOT NULL,`file_path` varchar(500) NOT NULL,`file_name` varchar(255),`file_size` bigint,`content_type` varchar(100),`transcript` text,`transcript_lang` varchar(20),`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_voice_clone_audios_voice_clone_id` (`voice_clone_id`),INDEX `idx_voice_clone_audios_user_id` (`user_id`));
+
+CREATE TABLE `voice_clone_tasks` (`id` bigint unsigned AUTO_INCREMENT,`task_id` varchar(64) NOT NULL,`user_id` bigint unsigned NOT NULL,`voice_clone_id` bigint unsigned NOT NULL,`provider` varchar(50) NOT NULL,`status` varchar(20) NOT NULL DEFAULT 'queued',`attempts` bigint NOT NULL DEFAULT 0,`last_error` text,`started_at` datetime(3) NULL,`finished_at` datetime(3) NULL,`meta_json` json,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_voice_clone_tasks_task_id` (`task_id`),INDEX `idx_voice_clone_tasks_user_id` (`user_id`),INDEX `idx_voice_clone_tasks_voice_clone_id` (`voice_clone_id`),INDEX `idx_voice_clone_tasks_provider` (`provider`),INDEX `idx_voice_clone_tasks_status` (`status`));
+
+CREATE TABLE `user_voice_clone_quota` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`tts_config_id` varchar(100) NOT NULL,`max_count` bigint NOT NULL DEFAULT -1,`used_count` bigint NOT NULL DEFAULT 0,`created_at` datetime(3) NULL,`updated_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_user_voice_clone_quota_user_id` (`user_id`),UNIQUE INDEX `idx_user_tts_quota` (`user_id`,`tts_config_id`),INDEX `idx_user_voice_clone_quota_tts_config_id` (`tts_config_id`));
+
+CREATE TABLE `memory_audit_logs` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`username` varchar(100),`agent_id` bigint unsigned NOT NULL,`speaker_group_id` bigint unsigned,`provider` varchar(50),`action` varchar(32) NOT NULL COMMENT 'update|delete|export|forget_all',`item_id` varchar(128),`detail` text,`ip` varchar(64),`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_memory_audit_logs_user_id` (`user_id`),INDEX `idx_memory_audit_logs_agent_id` (`agent_id`),INDEX `idx_memory_audit_logs_action` (`action`),INDEX `idx_memory_audit_logs_created_at` (`created_at`));
+
+CREATE TABLE `mcp_tool_call_logs` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned,`agent_id` varchar(64),`device_id` varchar(100),`session_id` varchar(100),`speaker` varchar(100),`tool_call_id` varchar(128),`tool_name` varchar(128),`source` varchar(20) COMMENT 'local|global|device',`server_name` varchar(128),`arguments` text,`result_summary` text,`status` varchar(20) COMMENT 'success|error|denied|not_found|pending_confirm|timeout|canceled',`error` text,`latency_ms` bigint,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),INDEX `idx_mcp_tool_call_logs_user_id` (`user_id`),INDEX `idx_mcp_tool_call_logs_agent_id` (`agent_id`),INDEX `idx_mcp_tool_call_logs_device_id` (`device_id`),INDEX `idx_mcp_tool_call_logs_tool_name` (`tool_name`),INDEX `idx_mcp_tool_call_logs_source` (`source`),INDEX `idx_mcp_tool_call_logs_status` (`status`),INDEX `idx_mcp_tool_call_logs_created_at` (`created_at`));
@@ -0,0 +1,3 @@
+-- 首次启动时生成的系统密钥（未配置 jwt.secret 时的 JWT 签名密钥）
+
+CREATE TABLE `system_secrets` (`id` bigint unsigned AUTO_INCREMENT,`name` varchar(64) NOT NULL,`value` text NOT NULL,`created_at` datetime(3) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_system_secrets_name` (`name`));
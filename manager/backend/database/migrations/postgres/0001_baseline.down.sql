@@ -0,0 +1,32 @@
+-- 回滚基线会删除管理后台的全部表和数据
+
+DROP TABLE IF EXISTS "mcp_tool_call_logs";
+DROP TABLE IF EXISTS "memory_audit_logs";
+DROP TABLE IF EXISTS "user_voice_clone_quota";
+DROP TABLE IF EXISTS "voice_clone_tasks";
+DROP TABLE IF EXISTS "voice_clone_audios";
+DROP TABLE IF EXISTS "voice_clones";
+DROP TABLE IF EXISTS "speaker_samples";
+DROP TABLE IF EXISTS "speaker_groups";
+DROP TABLE IF EXISTS "chat_messages";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "global_roles";
+DROP TABLE IF EXISTS "mcpo_auth_credentials";
+DROP TABLE IF EXISTS "mcp_market_services";
+DROP TABLE IF EXISTS "configs";
+DROP TABLE IF EXISTS "agent_knowledge_bases";
+DROP TABLE IF EXISTS "knowledge_base_documents";
+DROP TABLE IF EXISTS "knowledge_bases";
+DROP TABLE IF EXISTS "agents";
+DROP TABLE IF EXISTS "devices";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhooks";
+DROP TABLE IF EXISTS "config_versions";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "organizations";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "user_recovery_codes";
+DROP TABLE IF EXISTS "oidc_login_states";
+DROP TABLE IF EXISTS "revoked_tokens";
+DROP TABLE IF EXISTS "user_sessions";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,174 @@
+-- 基线：改用版本化迁移前由 AutoMigrate 创建的全部表结构
+-- 已有数据库首次升级时只登记此版本、不执行（见 database/migrate.go）
+
+CREATE TABLE "users" ("id" bigserial,"username" varchar(50) NOT NULL,"password" varchar(255) NOT NULL,"email" varchar(100),"role" varchar(20) NOT NULL DEFAULT 'user',"org_id" bigint,"token_version" bigint NOT NULL DEFAULT 0,"auth_provider" varchar(20) NOT NULL DEFAULT 'local',"external_id" varchar(255),"totp_enabled" boolean NOT NULL DEFAULT false,"totp_secret" varchar(64),"totp_last_counter" bigint NOT NULL DEFAULT 0,"two_factor_failures" bigint NOT NULL DEFAULT 0,"two_factor_lock_until" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_external_id" ON "users" ("external_id");
+CREATE INDEX IF NOT EXISTS "idx_users_org_id" ON "users" ("org_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+
+CREATE TABLE "user_sessions" ("id" bigserial,"session_id" varchar(64) NOT NULL,"user_id" bigint NOT NULL,"refresh_token_hash" varchar(64) NOT NULL,"token_version" bigint NOT NULL DEFAULT 0,"user_agent" varchar(255),"ip" varchar(64),"expires_at" timestamptz,"revoked_at" timestamptz,"last_used_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_user_sessions_expires_at" ON "user_sessions" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_sessions_refresh_token_hash" ON "user_sessions" ("refresh_token_hash");
+CREATE INDEX IF NOT EXISTS "idx_user_sessions_user_id" ON "user_sessions" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_sessions_session_id" ON "user_sessions" ("session_id");
+
+CREATE TABLE "revoked_tokens" ("id" bigserial,"jti" varchar(64) NOT NULL,"user_id" bigint,"expires_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_user_id" ON "revoked_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_revoked_tokens_jti" ON "revoked_tokens" ("jti");
+
+CREATE TABLE "oidc_login_states" ("id" bigserial,"state" varchar(64) NOT NULL,"nonce" varchar(64) NOT NULL,"code_verifier" varchar(128) NOT NULL,"ticket_hash" varchar(64),"user_id" bigint,"expires_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_oidc_login_states_expires_at" ON "oidc_login_states" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oidc_login_states_ticket_hash" ON "oidc_login_states" ("ticket_hash");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oidc_login_states_state" ON "oidc_login_states" ("state");
+
+CREATE TABLE "user_recovery_codes" ("id" bigserial,"user_id" bigint NOT NULL,"code_hash" varchar(64) NOT NULL,"used_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_user_recovery_codes_user_id" ON "user_recovery_codes" ("user_id");
+
+CREATE TABLE "api_keys" ("id" bigserial,"user_id" bigint NOT NULL,"name" varchar(100) NOT NULL,"prefix" varchar(16) NOT NULL,"key_hash" varchar(64) NOT NULL,"scopes" varchar(500) NOT NULL,"expires_at" timestamptz,"last_used_at" timestamptz,"last_used_ip" varchar(64),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE "organizations" ("id" bigserial,"name" varchar(100) NOT NULL,"slug" varchar(64) NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_organizations_slug" ON "organizations" ("slug");
+
+CREATE TABLE "audit_logs" ("id" bigserial,"actor_id" bigint,"actor_name" varchar(100),"actor_role" varchar(20),"org_id" bigint,"api_key_id" bigint,"action" varchar(64) NOT NULL,"entity_type" varchar(64),"entity_id" varchar(64),"method" varchar(10),"path" varchar(255),"status_code" bigint,"before" text,"after" text,"diff" text,"ip" varchar(64),"user_agent" varchar(255),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_entity_id" ON "audit_logs" ("entity_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_entity_type" ON "audit_logs" ("entity_type");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_org_id" ON "audit_logs" ("org_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+
+CREATE TABLE "config_versions" ("id" bigserial,"entity_type" varchar(20) NOT NULL,"entity_id" bigint NOT NULL,"version" bigint NOT NULL,"snapshot" text,"comment" varchar(255),"actor_id" bigint,"actor_name" varchar(100),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_config_version" ON "config_versions" ("entity_type","entity_id","version");
+
+CREATE TABLE "webhooks" ("id" bigserial,"user_id" bigint NOT NULL,"name" varchar(100) NOT NULL,"url" varchar(1024) NOT NULL,"secret" varchar(128),"events" text,"all_users" boolean DEFAULT false,"enabled" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhooks_user_id" ON "webhooks" ("user_id");
+
+CREATE TABLE "webhook_deliveries" ("id" bigserial,"webhook_id" bigint NOT NULL,"delivery_id" varchar(64),"event" varchar(50),"payload" text,"status" varchar(20),"attempts" bigint DEFAULT 0,"status_code" bigint,"response" text,"error" text,"duration_ms" bigint,"next_attempt_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_created_at" ON "webhook_deliveries" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event" ON "webhook_deliveries" ("event");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_webhook_deliveries_delivery_id" ON "webhook_deliveries" ("delivery_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries" ("webhook_id");
+
+CREATE TABLE "devices" ("id" bigserial,"user_id" bigint NOT NULL,"agent_id" bigint NOT NULL DEFAULT 0,"role_id" bigint,"device_code" varchar(100),"device_name" varchar(100),"challenge" varchar(128),"pre_secret_key" varchar(128),"activated" boolean DEFAULT false,"last_active_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_devices_device_code" ON "devices" ("device_code");
+CREATE INDEX IF NOT EXISTS "idx_devices_role_id" ON "devices" ("role_id");
+
+CREATE TABLE "agents" ("id" bigserial,"user_id" bigint NOT NULL,"name" varchar(100) NOT NULL,"custom_prompt" text,"llm_config_id" varchar(100),"tts_config_id" varchar(100),"voice" varchar(200),"asr_speed" varchar(20) DEFAULT 'normal',"memory_mode" varchar(20) DEFAULT 'short',"mcp_service_names" text,"open_claw_config" text,"mcp_tool_policy_config" text,"status" varchar(20) DEFAULT 'active',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+
+CREATE TABLE "knowledge_bases" ("id" bigserial,"user_id" bigint NOT NULL,"name" varchar(100) NOT NULL,"description" text,"content" text,"retrieval_threshold" double precision,"external_kb_id" varchar(255),"external_doc_id" varchar(255),"auto_dataset" boolean DEFAULT false,"sync_provider" varchar(50),"sync_status" varchar(20) DEFAULT 'pending',"sync_error" text,"last_synced_at" timestamptz,"status" varchar(20) DEFAULT 'active',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_status" ON "knowledge_bases" ("status");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_sync_status" ON "knowledge_bases" ("sync_status");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_sync_provider" ON "knowledge_bases" ("sync_provider");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_external_doc_id" ON "knowledge_bases" ("external_doc_id");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_external_kb_id" ON "knowledge_bases" ("external_kb_id");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_user_id" ON "knowledge_bases" ("user_id");
+
+CREATE TABLE "knowledge_base_documents" ("id" bigserial,"knowledge_base_id" bigint NOT NULL,"name" varchar(200) NOT NULL,"content" text,"external_doc_id" varchar(255),"sync_status" varchar(20) DEFAULT 'pending',"sync_error" text,"last_synced_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_knowledge_base_documents_sync_status" ON "knowledge_base_documents" ("sync_status");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_base_documents_external_doc_id" ON "knowledge_base_documents" ("external_doc_id");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_base_documents_knowledge_base_id" ON "knowledge_base_documents" ("knowledge_base_id");
+
+CREATE TABLE "agent_knowledge_bases" ("id" bigserial,"agent_id" bigint NOT NULL,"knowledge_base_id" bigint NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_agent_knowledge_bases_knowledge_base_id" ON "agent_knowledge_bases" ("knowledge_base_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_agent_kb_unique" ON "agent_knowledge_bases" ("agent_id","knowledge_base_id");
+CREATE INDEX IF NOT EXISTS "idx_agent_knowledge_bases_agent_id" ON "agent_knowledge_bases" ("agent_id");
+
+CREATE TABLE "configs" ("id" bigserial,"type" varchar(50) NOT NULL,"name" varchar(100) NOT NULL,"config_id" varchar(100) NOT NULL,"provider" varchar(50),"json_data" text,"enabled" boolean DEFAULT true,"is_default" boolean DEFAULT false,"org_id" bigint,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_configs_org_id" ON "configs" ("org_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "type_config_id" ON "configs" ("type","config_id");
+
+CREATE TABLE "mcp_market_services" ("id" bigserial,"name" varchar(150) NOT NULL,"enabled" boolean DEFAULT true,"transport" varchar(32) NOT NULL,"url" text NOT NULL,"url_hash" varchar(512) NOT NULL,"headers_json" text,"market_id" bigint,"provider_id" varchar(50),"service_id" varchar(255),"service_name" varchar(255),"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_mcp_market_services_service_id" ON "mcp_market_services" ("service_id");
+CREATE INDEX IF NOT EXISTS "idx_mcp_market_services_provider_id" ON "mcp_market_services" ("provider_id");
+CREATE INDEX IF NOT EXISTS "idx_mcp_market_services_market_id" ON "mcp_market_services" ("market_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mcp_market_services_url_hash" ON "mcp_market_services" ("url_hash");
+CREATE INDEX IF NOT EXISTS "idx_mcp_market_services_enabled" ON "mcp_market_services" ("enabled");
+
+CREATE TABLE "mcpo_auth_credentials" ("id" bigserial,"url" text NOT NULL,"url_hash" varchar(512) NOT NULL,"status" varchar(20),"resource" text,"authorization_server" text,"authorization_endpoint" text,"token_endpoint" text,"scope" varchar(500),"redirect_uri" text,"client_id" varchar(255),"client_secret_ciphertext" text,"client_secret_nonce" varchar(64),"dynamic_client" boolean,"access_token_ciphertext" text,"access_token_nonce" varchar(64),"refresh_token_ciphertext" text,"refresh_token_nonce" varchar(64),"token_type" varchar(32),"expires_at" timestamptz,"state" varchar(128),"code_verifier_ciphertext" text,"code_verifier_nonce" varchar(64),"last_error" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_mcpo_auth_credentials_state" ON "mcpo_auth_credentials" ("state");
+CREATE INDEX IF NOT EXISTS "idx_mcpo_auth_credentials_status" ON "mcpo_auth_credentials" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_mcp_oauth_credentials_url_hash" ON "mcpo_auth_credentials" ("url_hash");
+COMMENT ON COLUMN "mcpo_auth_credentials"."status" IS 'pending|authorized|error';
+
+CREATE TABLE "global_roles" ("id" bigserial,"name" varchar(100) NOT NULL,"description" text,"prompt" text,"is_default" boolean DEFAULT false,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+
+CREATE TABLE "roles" ("id" bigserial,"user_id" bigint,"name" varchar(100) NOT NULL,"description" text,"prompt" text,"llm_config_id" varchar(100),"tts_config_id" varchar(100),"voice" varchar(200),"role_type" varchar(20) DEFAULT 'user',"status" varchar(20) DEFAULT 'active',"sort_order" bigint DEFAULT 0,"is_default" boolean DEFAULT false,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_roles_is_default" ON "roles" ("is_default");
+CREATE INDEX IF NOT EXISTS "idx_roles_status" ON "roles" ("status");
+CREATE INDEX IF NOT EXISTS "idx_roles_role_type" ON "roles" ("role_type");
+CREATE INDEX IF NOT EXISTS "idx_roles_user_id" ON "roles" ("user_id");
+
+CREATE TABLE "chat_messages" ("id" bigserial,"message_id" varchar(64) NOT NULL,"device_id" varchar(100) NOT NULL,"agent_id" varchar(64) NOT NULL,"user_id" bigint NOT NULL,"session_id" varchar(64),"role" varchar(20) NOT NULL,"content" text NOT NULL,"tool_call_id" varchar(64),"tool_calls" jsonb,"audio_path" varchar(512),"audio_duration" bigint,"audio_size" bigint,"audio_format" varchar(20) DEFAULT 'wav',"metadata" jsonb,"is_deleted" boolean DEFAULT false,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_created_at" ON "chat_messages" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_chat_messages_is_deleted" ON "chat_messages" ("is_deleted");
+CREATE INDEX IF NOT EXISTS "idx_chat_messages_tool_call_id" ON "chat_messages" ("tool_call_id");
+CREATE INDEX IF NOT EXISTS "idx_chat_messages_role" ON "chat_messages" ("role");
+CREATE INDEX IF NOT EXISTS "idx_session_id" ON "chat_messages" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_user_id" ON "chat_messages" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_agent_id" ON "chat_messages" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_device_id" ON "chat_messages" ("device_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_chat_messages_message_id" ON "chat_messages" ("message_id");
+COMMENT ON COLUMN "chat_messages"."role" IS 'user|assistant|system|tool';
+COMMENT ON COLUMN "chat_messages"."tool_call_id" IS '工具调用ID（Tool角色使用）';
+COMMENT ON COLUMN "chat_messages"."tool_calls" IS '工具调用列表JSON（Assistant角色使用）';
+COMMENT ON COLUMN "chat_messages"."audio_path" IS '音频文件相对路径（两级hash打散）';
+COMMENT ON COLUMN "chat_messages"."audio_duration" IS '毫秒';
+COMMENT ON COLUMN "chat_messages"."audio_size" IS '字节';
+COMMENT ON COLUMN "chat_messages"."audio_format" IS '音频格式（固定为wav）';
+
+CREATE TABLE "speaker_groups" ("id" bigserial,"user_id" bigint NOT NULL,"agent_id" bigint NOT NULL,"name" varchar(100) NOT NULL,"prompt" text,"description" text,"tts_config_id" varchar(100),"voice" varchar(200),"status" varchar(20) DEFAULT 'active',"sample_count" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_speaker_groups_agent_id" ON "speaker_groups" ("agent_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_speaker_groups_user_name" ON "speaker_groups" ("user_id","name");
+CREATE INDEX IF NOT EXISTS "idx_speaker_groups_user_id" ON "speaker_groups" ("user_id");
+
+CREATE TABLE "speaker_samples" ("id" bigserial,"speaker_group_id" bigint NOT NULL,"user_id" bigint NOT NULL,"uuid" varchar(36) NOT NULL,"file_path" varchar(500) NOT NULL,"file_name" varchar(255),"file_size" bigint,"duration" decimal,"status" varchar(20) DEFAULT 'active',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_speaker_samples_uuid" ON "speaker_samples" ("uuid");
+CREATE INDEX IF NOT EXISTS "idx_speaker_samples_user_id" ON "speaker_samples" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_speaker_samples_speaker_group_id" ON "speaker_samples" ("speaker_group_id");
+
+CREATE TABLE "voice_clones" ("id" bigserial,"user_id" bigint NOT NULL,"name" varchar(100) NOT NULL,"provider" varchar(50) NOT NULL,"provider_voice_id" varchar(200) NOT NULL,"tts_config_id" varchar(100) NOT NULL,"status" varchar(20) DEFAULT 'active',"transcript_required" boolean DEFAULT false,"meta_json" jsonb,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_voice_clones_status" ON "voice_clones" ("status");
+CREATE INDEX IF NOT EXISTS "idx_voice_clones_tts_config_id" ON "voice_clones" ("tts_config_id");
+CREATE INDEX IF NOT EXISTS "idx_voice_clones_provider_voice_id" ON "voice_clones" ("provider_voice_id");
+CREATE INDEX IF NOT EXISTS "idx_voice_clones_provider" ON "voice_clones" ("provider");
+CREATE INDEX IF NOT EXISTS "idx_voice_clones_user_id" ON "voice_clones" ("user_id");
+
+CREATE TABLE "voice_clone_audios" ("id" bigserial,"voice_clone_id" bigint,"user_id" bigint NOT NULL,"source_type" varchar(20) NOT NULL,"file_path" varchar(500) NOT NULL,"file_name" varchar(255),"file_size" bigint,"content_type" varchar(100),"transcript" text,"transcript_lang" varchar(20),"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_voice_clone_audios_user_id" ON "voice_clone_audios" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_voice_clone_audios_voice_clone_id" ON "voice_clone_audios" ("voice_clone_id");
+
+CREATE TABLE "voice_clone_tasks" ("id" bigserial,"task_id" varchar(64) NOT NULL,"user_id" bigint NOT NULL,"voice_clone_id" bigint NOT NULL,"provider" varchar(50) NOT NULL,"status" varchar(20) NOT NULL DEFAULT 'queued',"attempts" bigint NOT NULL DEFAULT 0,"last_error" text,"started_at" timestamptz,"finished_at" timestamptz,"meta_json" jsonb,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_voice_clone_tasks_status" ON "voice_clone_tasks" ("status");
+CREATE INDEX IF NOT EXISTS "idx_voice_clone_tasks_provider" ON "voice_clone_tasks" ("provider");
+CREATE INDEX IF NOT EXISTS "idx_voice_clone_tasks_voice_clone_id" ON "voice_clone_tasks" ("voice_clone_id");
+CREATE INDEX IF NOT EXISTS "idx_voice_clone_tasks_user_id" ON "voice_clone_tasks" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_voice_clone_tasks_task_id" ON "voice_clone_tasks" ("task_id");
+
+CREATE TABLE "user_voice_clone_quota" ("id" bigserial,"user_id" bigint NOT NULL,"tts_config_id" varchar(100) NOT NULL,"max_count" bigint NOT NULL DEFAULT -1,"used_count" bigint NOT NULL DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_user_voice_clone_quota_tts_config_id" ON "user_voice_clone_quota" ("tts_config_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_tts_quota" ON "user_voice_clone_quota" ("user_id","tts_config_id");
+CREATE INDEX IF NOT EXISTS "idx_user_voice_clone_quota_user_id" ON "user_voice_clone_quota" ("user_id");
+
+CREATE TABLE "memory_audit_logs" ("id" bigserial,"user_id" bigint NOT NULL,"username" varchar(100),"agent_id" bigint NOT NULL,"speaker_group_id" bigint,"provider" varchar(50),"action" varchar(32) NOT NULL,"item_id" varchar(128),"detail" text,"ip" varchar(64),"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_memory_audit_logs_created_at" ON "memory_audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_memory_audit_logs_action" ON "memory_audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_memory_audit_logs_agent_id" ON "memory_audit_logs" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_memory_audit_logs_user_id" ON "memory_audit_logs" ("user_id");
+COMMENT ON COLUMN "memory_audit_logs"."action" IS 'update|delete|export|forget_all';
+
+CREATE TABLE "mcp_tool_call_logs" ("id" bigserial,"user_id" bigint,"agent_id" varchar(64),"device_id" varchar(100),"session_id" varchar(100),"speaker" varchar(100),"tool_call_id" varchar(128),"tool_name" varchar(128),"source" varchar(20),"server_name" varchar(128),"arguments" text,"result_summary" text,"status" varchar(20),"error" text,"latency_ms" bigint,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_created_at" ON "mcp_tool_call_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_status" ON "mcp_tool_call_logs" ("status");
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_source" ON "mcp_tool_call_logs" ("source");
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_tool_name" ON "mcp_tool_call_logs" ("tool_name");
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_device_id" ON "mcp_tool_call_logs" ("device_id");
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_agent_id" ON "mcp_tool_call_logs" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_mcp_tool_call_logs_user_id" ON "mcp_tool_call_logs" ("user_id");
+COMMENT ON COLUMN "mcp_tool_call_logs"."source" IS 'local|global|device';
+COMMENT ON COLUMN "mcp_tool_call_logs"."status" IS 'success|error|denied|not_found|pending_confirm|timeout|canceled';
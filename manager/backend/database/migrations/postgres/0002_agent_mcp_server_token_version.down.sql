@@ -0,0 +1 @@
+ALTER TABLE "agents" DROP COLUMN "mcp_server_token_version";
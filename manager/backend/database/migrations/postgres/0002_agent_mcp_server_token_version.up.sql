@@ -0,0 +1,3 @@
+-- 智能体MCP服务器接入点令牌版本，重置接入点时递增使旧令牌失效
+
+ALTER TABLE "agents" ADD COLUMN "mcp_server_token_version" bigint NOT NULL DEFAULT 1;
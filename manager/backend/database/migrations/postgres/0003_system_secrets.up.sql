@@ -0,0 +1,4 @@
+-- 首次启动时生成的系统密钥（未配置 jwt.secret 时的 JWT 签名密钥）
+
+CREATE TABLE "system_secrets" ("id" bigserial,"name" varchar(64) NOT NULL,"value" text NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_system_secrets_name" ON "system_secrets" ("name");
@@ -0,0 +1,41 @@
+package database
+
+import "xiaozhi/manager/backend/models"
+
+// AllModels 管理后台的全部表模型，旧数据库补齐表结构、数据复制和迁移测试共用这份列表；
+// 新增或修改模型时需要同时新增迁移脚本
+func AllModels() []interface{} {
+	return []interface{}{
+		&models.User{},
+		&models.UserSession{},
+		&models.RevokedToken{},
+		&models.SystemSecret{},
+		&models.OIDCLoginState{},
+		&models.UserRecoveryCode{},
+		&models.APIKey{},
+		&models.Organization{},
+		&models.AuditLog{},
+		&models.ConfigVersion{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.Device{},
+		&models.Agent{},
+		&models.KnowledgeBase{},
+		&models.KnowledgeBaseDocument{},
+		&models.AgentKnowledgeBase{},
+		&models.Config{},
+		&models.MCPMarketService{},
+		&models.MCPOAuthCredential{},
+		&models.GlobalRole{},
+		&models.Role{}, // 新增：统一角色表
+		&models.ChatMessage{},
+		&models.SpeakerGroup{},
+		&models.SpeakerSample{},
+		&models.VoiceClone{},
+		&models.VoiceCloneAudio{},
+		&models.VoiceCloneTask{},
+		&models.UserVoiceCloneQuota{},
+		&models.MemoryAuditLog{},
+		&models.MCPToolCallLog{},
+	}
+}
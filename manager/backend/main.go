@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 	"xiaozhi/manager/backend/config"
 	"xiaozhi/manager/backend/database"
 	"xiaozhi/manager/backend/router"
@@ -11,6 +12,18 @@ import (
 )
 
 func main() {
+	// 子命令：copy-data 把数据复制到另一个数据库，migrate 管理表结构版本，执行后退出
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "copy-data":
+			runCopyData(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		}
+	}
+
 	// 定义命令行参数
 	var configFile string
 	flag.StringVar(&configFile, "config", "config/config.json", "配置文件路径")
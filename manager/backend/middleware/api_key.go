@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix 个人 API Key 的固定前缀，用于和 JWT 区分
+const APIKeyPrefix = "xzk_"
+
+// apiKeyTouchInterval 最近使用时间的更新间隔，避免每个请求都写库
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyScope 一种 API Key 权限
+type APIKeyScope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	AdminOnly   bool   `json:"admin_only"` // 只有管理员可以授予
+}
+
+const (
+	ScopeDevicesRead  = "devices:read"
+	ScopeDevicesWrite = "devices:write"
+	ScopeInjectWrite  = "inject:write"
+	ScopeAgentsRead   = "agents:read"
+	ScopeAgentsWrite  = "agents:write"
+	ScopeHistoryRead  = "history:read"
+	ScopeHistoryWrite = "history:write"
+	ScopeConfigsRead  = "configs:read"
+	ScopeConfigsWrite = "configs:write"
+)
+
+// APIKeyScopes 可授予的全部权限
+var APIKeyScopes = []APIKeyScope{
+	{Name: ScopeDevicesRead, Description: "查看设备"},
+	{Name: ScopeDevicesWrite, Description: "添加、修改设备和调用设备工具"},
+	{Name: ScopeInjectWrite, Description: "向设备注入消息"},
+	{Name: ScopeAgentsRead, Description: "查看智能体"},
+	{Name: ScopeAgentsWrite, Description: "创建、修改智能体"},
+	{Name: ScopeHistoryRead, Description: "查看和导出聊天历史"},
+	{Name: ScopeHistoryWrite, Description: "删除聊天历史"},
+	{Name: ScopeConfigsRead, Description: "查看和导出系统配置", AdminOnly: true},
+	{Name: ScopeConfigsWrite, Description: "修改和导入系统配置", AdminOnly: true},
+}
+
+// apiKeyScopeRule 路由前缀需要的权限；read 用于 GET，write 用于其他方法，为空表示不允许
+type apiKeyScopeRule struct {
+	prefix string
+	read   string
+	write  string
+}
+
+// apiKeyScopeRules 按顺序匹配路由模板，更具体的前缀在前；未匹配的接口不接受 API Key
+var apiKeyScopeRules = []apiKeyScopeRule{
+	{prefix: "/api/user/devices/inject-message", write: ScopeInjectWrite},
+	{prefix: "/api/user/devices", read: ScopeDevicesRead, write: ScopeDevicesWrite},
+	{prefix: "/api/devices/:id/apply-role", write: ScopeDevicesWrite},
+	{prefix: "/api/admin/devices", read: ScopeDevicesRead, write: ScopeDevicesWrite},
+	{prefix: "/api/user/agents/:id/memories", read: ScopeHistoryRead, write: ScopeHistoryWrite},
+	{prefix: "/api/user/agents", read: ScopeAgentsRead, write: ScopeAgentsWrite},
+	{prefix: "/api/admin/agents", read: ScopeAgentsRead, write: ScopeAgentsWrite},
+	{prefix: "/api/user/history", read: ScopeHistoryRead, write: ScopeHistoryWrite},
+	{prefix: "/api/admin/configs/export", read: ScopeConfigsRead},
+	{prefix: "/api/admin/configs/import", write: ScopeConfigsWrite},
+	{prefix: "/api/admin/configs", read: ScopeConfigsRead, write: ScopeConfigsWrite},
+}
+
+// requiredAPIKeyScope 返回访问该路由需要的权限，ok 为 false 表示该接口不接受 API Key
+func requiredAPIKeyScope(method, fullPath string) (string, bool) {
+	for _, rule := range apiKeyScopeRules {
+		if fullPath != rule.prefix && !strings.HasPrefix(fullPath, rule.prefix+"/") {
+			continue
+		}
+		scope := rule.write
+		if method == http.MethodGet || method == http.MethodHead {
+			scope = rule.read
+		}
+		return scope, scope != ""
+	}
+	if fullPath == "/api/profile" && method == http.MethodGet {
+		return "", true
+	}
+	return "", false
+}
+
+// ValidAPIKeyScope 校验权限名，并检查普通用户是否申请了仅管理员可授予的权限
+func ValidAPIKeyScope(name string, isAdmin bool) bool {
+	for _, scope := range APIKeyScopes {
+		if scope.Name == name {
+			return isAdmin || !scope.AdminOnly
+		}
+	}
+	return false
+}
+
+// GenerateAPIKey 生成新的 API Key，返回明文（只展示一次）、展示用前缀和哈希
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 24)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + hex.EncodeToString(buf)
+	return key, key[:len(APIKeyPrefix)+8], HashAPIKey(key), nil
+}
+
+// HashAPIKey API Key 的存储哈希
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyFromRequest 从 Authorization: Bearer 或 X-API-Key 头读取 API Key
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// authenticateAPIKey 校验 API Key 并返回其所属用户，顺带记录最近使用时间
+func authenticateAPIKey(db *gorm.DB, key, clientIP string) (*models.APIKey, *models.User, error) {
+	if db == nil {
+		return nil, nil, errors.New("未启用 API Key")
+	}
+	var apiKey models.APIKey
+	if err := db.Where("key_hash = ?", HashAPIKey(key)).First(&apiKey).Error; err != nil {
+		return nil, nil, errors.New("无效的 API Key")
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, nil, errors.New("API Key 已过期")
+	}
+	var user models.User
+	if err := db.First(&user, apiKey.UserID).Error; err != nil {
+		return nil, nil, errors.New("用户不存在")
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		db.Model(&apiKey).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": clientIP})
+	}
+	return &apiKey, &user, nil
+}
+
+// apiKeyAllowed 检查 API Key 是否拥有访问当前路由的权限
+func apiKeyAllowed(c *gin.Context, scopes string) bool {
+	required, ok := requiredAPIKeyScope(c.Request.Method, c.FullPath())
+	if !ok {
+		return false
+	}
+	if required == "" {
+		return true
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		if strings.TrimSpace(scope) == required {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequiredAPIKeyScope(t *testing.T) {
+	cases := []struct {
+		method, path string
+		scope        string
+		ok           bool
+	}{
+		{http.MethodPost, "/api/user/devices/inject-message", ScopeInjectWrite, true},
+		{http.MethodGet, "/api/user/devices", ScopeDevicesRead, true},
+		{http.MethodPost, "/api/user/devices/:id/mcp-call", ScopeDevicesWrite, true},
+		{http.MethodGet, "/api/user/history/export", ScopeHistoryRead, true},
+		{http.MethodDelete, "/api/user/agents/:id/memories/:memory_id", ScopeHistoryWrite, true},
+		{http.MethodGet, "/api/user/agents/:id", ScopeAgentsRead, true},
+		{http.MethodGet, "/api/admin/configs/export", ScopeConfigsRead, true},
+		{http.MethodGet, "/api/profile", "", true},
+		// 导出接口只读，不能用写权限调用其他方法
+		{http.MethodPost, "/api/admin/configs/export", "", false},
+		// 未列出的接口（包括 API Key 管理本身）不接受 API Key
+		{http.MethodPost, "/api/user/api-keys", "", false},
+		{http.MethodGet, "/api/admin/users", "", false},
+		{http.MethodGet, "/api/user/devicesx", "", false},
+	}
+	for _, tc := range cases {
+		scope, ok := requiredAPIKeyScope(tc.method, tc.path)
+		if scope != tc.scope || ok != tc.ok {
+			t.Errorf("%s %s = (%q, %v), want (%q, %v)", tc.method, tc.path, scope, ok, tc.scope, tc.ok)
+		}
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix) || !strings.HasPrefix(key, prefix) {
+		t.Fatalf("unexpected key %q / prefix %q", key, prefix)
+	}
+	if hash != HashAPIKey(key) || hash == HashAPIKey(key+"x") {
+		t.Fatal("hash should be deterministic and key specific")
+	}
+	if ValidAPIKeyScope(ScopeConfigsRead, false) || !ValidAPIKeyScope(ScopeConfigsRead, true) || ValidAPIKeyScope("unknown", true) {
+		t.Fatal("admin-only scopes must not be grantable by regular users")
+	}
+}
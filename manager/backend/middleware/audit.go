@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+	"xiaozhi/manager/backend/services/jsondiff"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// auditMaxSnapshot 单个快照的最大长度，超出时只记录截断标记
+const auditMaxSnapshot = 60000
+
+// auditRetentionDays 审计日志保留天数，0 表示永久保留
+var auditRetentionDays int
+
+// auditEntityRule 路由前缀对应的实体；param 为实体ID所在的路由参数，model 用于读取变更前后的快照
+type auditEntityRule struct {
+	prefix string
+	entity string
+	param  string
+	model  func() interface{}
+}
+
+func configModel() interface{}       { return &models.Config{} }
+func agentModel() interface{}        { return &models.Agent{} }
+func deviceModel() interface{}       { return &models.Device{} }
+func userModel() interface{}         { return &models.User{} }
+func roleModel() interface{}         { return &models.Role{} }
+func globalRoleModel() interface{}   { return &models.GlobalRole{} }
+func organizationModel() interface{} { return &models.Organization{} }
+func webhookModel() interface{}      { return &models.Webhook{} }
+func knowledgeBaseModel() interface{} {
+	return &models.KnowledgeBase{}
+}
+
+// auditEntityRules 按顺序匹配路由模板，更具体的前缀在前；未匹配的接口按路径推断实体类型，不记录快照
+var auditEntityRules = []auditEntityRule{
+	{prefix: "/api/admin/users/:id/knowledge-bases/:kb_id", entity: "knowledge_base", param: "kb_id", model: knowledgeBaseModel},
+	{prefix: "/api/admin/users/:id/knowledge-bases", entity: "knowledge_base"},
+	{prefix: "/api/admin/users/:id/voice-clone-quotas", entity: "voice_clone_quota", param: "id"},
+	{prefix: "/api/admin/users/:id", entity: "user", param: "id", model: userModel},
+	{prefix: "/api/admin/users", entity: "user"},
+	{prefix: "/api/admin/organizations/:id", entity: "organization", param: "id", model: organizationModel},
+	{prefix: "/api/admin/organizations", entity: "organization"},
+	{prefix: "/api/admin/agents/:id/memories", entity: "agent_memory", param: "id"},
+	{prefix: "/api/admin/agents/:id", entity: "agent", param: "id", model: agentModel},
+	{prefix: "/api/admin/agents", entity: "agent"},
+	{prefix: "/api/admin/devices/:id", entity: "device", param: "id", model: deviceModel},
+	{prefix: "/api/admin/devices", entity: "device"},
+	{prefix: "/api/admin/global-roles/:id", entity: "global_role", param: "id", model: globalRoleModel},
+	{prefix: "/api/admin/global-roles", entity: "global_role"},
+	{prefix: "/api/admin/roles/global/:id", entity: "role", param: "id", model: roleModel},
+	{prefix: "/api/admin/roles/global", entity: "role"},
+	{prefix: "/api/admin/configs/import", entity: "config"},
+	{prefix: "/api/admin/configs/:id", entity: "config", param: "id", model: configModel},
+	{prefix: "/api/admin/configs", entity: "config"},
+	{prefix: "/api/admin/mcp-markets/:id", entity: "config", param: "id", model: configModel},
+	{prefix: "/api/admin/mcp-markets", entity: "config"},
+	{prefix: "/api/admin/chat-settings", entity: "chat_settings"},
+	{prefix: "/api/admin/vision-base-config", entity: "vision_base_config"},
+	{prefix: "/api/roles/:id", entity: "role", param: "id", model: roleModel},
+	{prefix: "/api/roles", entity: "role"},
+	{prefix: "/api/user/roles/:id", entity: "role", param: "id", model: roleModel},
+	{prefix: "/api/user/roles", entity: "role"},
+	{prefix: "/api/user/agents/:id/memories", entity: "agent_memory", param: "id"},
+	{prefix: "/api/user/agents/:id/devices", entity: "device", param: "device_id", model: deviceModel},
+	{prefix: "/api/user/agents/:id", entity: "agent", param: "id", model: agentModel},
+	{prefix: "/api/user/agents", entity: "agent"},
+	{prefix: "/api/user/knowledge-bases/:id", entity: "knowledge_base", param: "id", model: knowledgeBaseModel},
+	{prefix: "/api/user/knowledge-bases", entity: "knowledge_base"},
+	{prefix: "/api/user/webhooks/:id", entity: "webhook", param: "id", model: webhookModel},
+	{prefix: "/api/user/webhooks", entity: "webhook"},
+	{prefix: "/api/devices/:id/apply-role", entity: "device", param: "id", model: deviceModel},
+}
+
+// auditConfigTypes 类型化配置接口（/api/admin/<type>-configs/:id），实体均为 configs 表
+var auditConfigTypes = []string{"vad", "asr", "llm", "tts", "speaker", "vision", "ota", "mqtt", "mqtt-server", "udp", "mcp", "memory", "knowledge-search"}
+
+func init() {
+	rules := make([]auditEntityRule, 0, len(auditConfigTypes)*2)
+	for _, t := range auditConfigTypes {
+		rules = append(rules,
+			auditEntityRule{prefix: "/api/admin/" + t + "-configs/:id", entity: "config", param: "id", model: configModel},
+			auditEntityRule{prefix: "/api/admin/" + t + "-configs", entity: "config"},
+		)
+	}
+	auditEntityRules = append(rules, auditEntityRules...)
+}
+
+// auditSkipSuffixes 使用 POST 但不修改数据的接口（测试、预览、查询）
+var auditSkipSuffixes = []string{
+	"/test",
+	"/test-search",
+	"/mcp-prompts/render",
+	"/weknora/models",
+	"/openclaw-chat-test",
+}
+
+// SetAuditRetention 设置审计日志保留天数，启动时调用
+func SetAuditRetention(days int) {
+	if days < 0 {
+		days = 0
+	}
+	auditRetentionDays = days
+}
+
+// AuditRetentionDays 当前的审计日志保留天数
+func AuditRetentionDays() int {
+	return auditRetentionDays
+}
+
+// StartAuditRetention 启动后台清理，每天删除超过保留期的审计日志
+func StartAuditRetention(db *gorm.DB) {
+	if db == nil || auditRetentionDays == 0 {
+		return
+	}
+	go func() {
+		for {
+			cutoff := time.Now().AddDate(0, 0, -auditRetentionDays)
+			result := db.Where("created_at < ?", cutoff).Delete(&models.AuditLog{})
+			if result.Error != nil {
+				log.Printf("[Audit] 清理过期审计日志失败: %v", result.Error)
+			} else if result.RowsAffected > 0 {
+				log.Printf("[Audit] 已清理 %d 条超过 %d 天的审计日志", result.RowsAffected, auditRetentionDays)
+			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
+
+// auditBodyWriter 保存响应体，用于从创建接口的返回中取得新实体
+type auditBodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditBodyWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < auditMaxSnapshot {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Audit 审计中间件：记录所有修改类请求的操作人、动作、实体、变更前后快照和来源 IP，需放在 JWTAuth 之后
+func Audit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		fullPath := c.FullPath()
+		if db == nil || method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions || auditSkipped(fullPath) {
+			c.Next()
+			return
+		}
+
+		rule, matched := matchAuditRule(fullPath)
+		entityID := ""
+		if matched && rule.param != "" {
+			entityID = c.Param(rule.param)
+		} else if !matched {
+			entityID = c.Param("id")
+		}
+		var before map[string]interface{}
+		if matched && rule.model != nil && entityID != "" {
+			before = loadAuditSnapshot(db, rule.model, entityID)
+		}
+
+		writer := &auditBodyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := models.AuditLog{
+			ActorID:    c.GetUint("user_id"),
+			ActorName:  c.GetString("username"),
+			ActorRole:  c.GetString("role"),
+			Action:     auditAction(method, fullPath, rule, matched),
+			EntityType: auditEntityType(fullPath, rule, matched),
+			EntityID:   entityID,
+			Method:     method,
+			Path:       truncate(fullPath, 255),
+			StatusCode: status,
+			IP:         c.ClientIP(),
+			UserAgent:  truncate(c.Request.UserAgent(), 255),
+		}
+		if orgID, ok := c.Get("org_id"); ok {
+			if id, ok := orgID.(uint); ok {
+				entry.OrgID = &id
+			}
+		}
+		if keyID, ok := c.Get("api_key_id"); ok {
+			if id, ok := keyID.(uint); ok {
+				entry.APIKeyID = &id
+			}
+		}
+
+		var after map[string]interface{}
+		if status < http.StatusBadRequest && matched {
+			switch {
+			case rule.model != nil && entityID != "" && method != http.MethodDelete:
+				after = loadAuditSnapshot(db, rule.model, entityID)
+			case entityID == "" && method == http.MethodPost:
+				// 创建接口：从返回的 data 中取新实体
+				after = responseData(writer.body.Bytes())
+				if id, ok := after["id"]; ok {
+					entry.EntityID = truncate(jsonScalar(id), 64)
+				}
+			}
+		}
+		if before != nil || after != nil {
+			entry.Diff = encodeSnapshot(jsondiff.Diff(before, after, "updated_at"))
+			if before != nil {
+				entry.Before = encodeSnapshot(jsondiff.Mask(before))
+			}
+			if after != nil {
+				entry.After = encodeSnapshot(jsondiff.Mask(after))
+			}
+		}
+
+		if err := db.Create(&entry).Error; err != nil {
+			log.Printf("[Audit] 记录审计日志失败: %v", err)
+		}
+	}
+}
+
+func auditSkipped(fullPath string) bool {
+	if fullPath == "" {
+		return true
+	}
+	for _, suffix := range auditSkipSuffixes {
+		if strings.HasSuffix(fullPath, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAuditRule(fullPath string) (auditEntityRule, bool) {
+	for _, rule := range auditEntityRules {
+		if fullPath == rule.prefix || strings.HasPrefix(fullPath, rule.prefix+"/") {
+			return rule, true
+		}
+	}
+	return auditEntityRule{}, false
+}
+
+// auditAction 由请求方法和路由推断动作：PUT/PATCH 为 update，DELETE 为 delete，
+// POST 到集合为 create，其余 POST 取路由最后一段（如 toggle、reset-password）
+func auditAction(method, fullPath string, rule auditEntityRule, matched bool) string {
+	switch method {
+	case http.MethodPut, http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	}
+	if matched && fullPath == rule.prefix && rule.param == "" {
+		return "create"
+	}
+	if !matched && !strings.Contains(auditResourcePath(fullPath), "/") {
+		return "create"
+	}
+	last := fullPath[strings.LastIndex(fullPath, "/")+1:]
+	if last == "" || strings.HasPrefix(last, ":") || strings.HasPrefix(last, "*") {
+		return "create"
+	}
+	return last
+}
+
+// auditEntityType 未匹配规则时取路由中 admin/user 之后的第一段作为实体类型
+func auditEntityType(fullPath string, rule auditEntityRule, matched bool) string {
+	if matched {
+		return rule.entity
+	}
+	path := auditResourcePath(fullPath)
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.ReplaceAll(path, "-", "_")
+}
+
+// auditResourcePath 去掉 /api、/admin、/user 前缀后的路由
+func auditResourcePath(fullPath string) string {
+	path := strings.TrimPrefix(fullPath, "/api/")
+	path = strings.TrimPrefix(path, "admin/")
+	return strings.TrimPrefix(path, "user/")
+}
+
+// loadAuditSnapshot 读取实体并按其 JSON 形式转换为 map（json:"-" 的字段不会出现）
+func loadAuditSnapshot(db *gorm.DB, model func() interface{}, id string) map[string]interface{} {
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return nil
+	}
+	record := model()
+	if err := db.Where("id = ?", id).First(record).Error; err != nil {
+		return nil
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil
+	}
+	return jsondiff.Expand(snapshot)
+}
+
+// responseData 取响应中的 data 对象
+func responseData(body []byte) map[string]interface{} {
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Data == nil {
+		return nil
+	}
+	return jsondiff.Expand(resp.Data)
+}
+
+// encodeSnapshot 序列化快照，过大时只保留截断标记
+func encodeSnapshot(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	if len(raw) > auditMaxSnapshot {
+		return `{"_truncated":true}`
+	}
+	return string(raw)
+}
+
+func jsonScalar(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuditActionAndEntity(t *testing.T) {
+	cases := []struct {
+		method, path   string
+		action, entity string
+	}{
+		{http.MethodPost, "/api/admin/llm-configs", "create", "config"},
+		{http.MethodPut, "/api/admin/llm-configs/:id", "update", "config"},
+		{http.MethodDelete, "/api/admin/devices/:id", "delete", "device"},
+		{http.MethodPost, "/api/admin/users/:id/reset-password", "reset-password", "user"},
+		{http.MethodPost, "/api/admin/users/:id/knowledge-bases", "create", "knowledge_base"},
+		{http.MethodPost, "/api/user/speaker-groups", "create", "speaker_groups"},
+	}
+	for _, tc := range cases {
+		rule, matched := matchAuditRule(tc.path)
+		action := auditAction(tc.method, tc.path, rule, matched)
+		entity := auditEntityType(tc.path, rule, matched)
+		if action != tc.action || entity != tc.entity {
+			t.Errorf("%s %s = (%q, %q), want (%q, %q)", tc.method, tc.path, action, entity, tc.action, tc.entity)
+		}
+	}
+}
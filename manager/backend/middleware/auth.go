@@ -8,52 +8,60 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// min 函数用于获取两个整数的最小值
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"tv"`            // 与 users.token_version 不一致时令牌失效
+	SessionID    string `json:"sid,omitempty"` // 所属登录会话
 	jwt.RegisteredClaims
 }
 
-var jwtSecret = []byte("xiaozhi_admin_secret_key")
+// tokenDB 用于校验令牌注销状态，未设置时只校验签名和有效期
+var tokenDB *gorm.DB
+
+// SetTokenDB 设置令牌注销校验使用的数据库
+func SetTokenDB(db *gorm.DB) {
+	tokenDB = db
+}
 
-// 生成JWT Token
-func GenerateToken(userID uint, username, role string) (string, error) {
+// 生成JWT Token（访问令牌，有效期由 jwt.access_token_minutes 决定）
+func GenerateToken(userID uint, username, role string, tokenVersion int, sessionID string) (string, error) {
+	now := time.Now()
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:       userID,
+		Username:     username,
+		Role:         role,
+		TokenVersion: tokenVersion,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return signClaims(claims)
 }
 
-// 解析JWT Token
+// 解析JWT Token，按头部 kid 选择校验密钥
 func ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, lookupVerifyKey)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		// 接入点令牌与登录令牌共用签名密钥，不能用于登录
+		for _, aud := range claims.Audience {
+			if aud == endpointTokenAudience {
+				return nil, jwt.ErrInvalidKey
+			}
+		}
 		return claims, nil
 	}
 
@@ -65,10 +73,36 @@ func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 添加调试日志
 		log.Printf("[JWTAuth] 处理请求: %s %s, 客户端IP: %s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
-		
+
+		// 个人 API Key 作为另一种凭证，只能访问其权限覆盖的接口
+		if key := apiKeyFromRequest(c); key != "" {
+			apiKey, user, err := authenticateAPIKey(tokenDB, key, c.ClientIP())
+			if err != nil {
+				log.Printf("[JWTAuth] ❌ API Key 校验失败: %v", err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if !apiKeyAllowed(c, apiKey.Scopes) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API Key 无权访问该接口"})
+				c.Abort()
+				return
+			}
+			if TwoFactorRequired(*user) && !user.TOTPEnabled {
+				c.JSON(http.StatusForbidden, gin.H{"error": "请先启用两步验证", "two_factor_setup_required": true})
+				c.Abort()
+				return
+			}
+			c.Set("user_id", user.ID)
+			c.Set("username", user.Username)
+			c.Set("role", user.Role)
+			setOrgID(c, user.OrgID)
+			c.Set("api_key_id", apiKey.ID)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
-		log.Printf("[JWTAuth] Authorization头: %s", authHeader)
-		
 		if authHeader == "" {
 			log.Printf("[JWTAuth] ❌ 缺少认证头")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少认证头"})
@@ -77,8 +111,6 @@ func JWTAuth() gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		log.Printf("[JWTAuth] 提取的token长度: %d, 前缀: %s", len(tokenString), tokenString[:min(20, len(tokenString))])
-		
 		claims, err := ParseToken(tokenString)
 		if err != nil {
 			log.Printf("[JWTAuth] ❌ token解析失败: %v", err)
@@ -86,20 +118,47 @@ func JWTAuth() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		role := claims.Role
+		if tokenDB != nil {
+			user, err := checkTokenRevoked(tokenDB, claims)
+			if err != nil {
+				log.Printf("[JWTAuth] ❌ token已失效 - 用户ID: %d, 原因: %v", claims.UserID, err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			// 必须启用两步验证的账号，启用前只能访问绑定相关接口
+			if TwoFactorRequired(*user) && !user.TOTPEnabled && !twoFactorSetupAllowed(c.Request.URL.Path) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "请先启用两步验证", "two_factor_setup_required": true})
+				c.Abort()
+				return
+			}
+			// 以数据库中的角色和组织为准，调整权限后无需等待令牌过期
+			role = user.Role
+			setOrgID(c, user.OrgID)
+		}
 
-		log.Printf("[JWTAuth] ✅ token验证成功 - 用户ID: %d, 用户名: %s, 角色: %s", claims.UserID, claims.Username, claims.Role)
+		log.Printf("[JWTAuth] ✅ token验证成功 - 用户ID: %d, 用户名: %s, 角色: %s", claims.UserID, claims.Username, role)
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		c.Set("role", role)
+		c.Set("claims", claims)
 		c.Next()
 	}
 }
 
-// 管理员权限中间件
+// setOrgID 记录当前用户所属组织，未加入组织时不设置
+func setOrgID(c *gin.Context, orgID *uint) {
+	if orgID != nil {
+		c.Set("org_id", *orgID)
+	}
+}
+
+// 管理员权限中间件（仅平台管理员）
 func AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
-		if !exists || role != "admin" {
+		if !exists || role != RoleAdmin {
 			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
 			c.Abort()
 			return
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// endpointTokenAudience 接入点令牌的 aud，用于与登录令牌区分
+const endpointTokenAudience = "xiaozhi-endpoint"
+
+// EndpointClaims 接入点令牌（设备MCP、OpenClaw、智能体MCP服务器）的 claims，
+// 与登录令牌使用同一套签名密钥，但不能当作登录令牌使用
+type EndpointClaims struct {
+	UserID     uint   `json:"user_id"`
+	AgentID    string `json:"agent_id"`
+	EndpointID string `json:"endpoint_id"`
+	Purpose    string `json:"purpose"`
+	Version    int    `json:"ver,omitempty"` // 与智能体记录的令牌版本不一致时失效，用于重置接入点
+	jwt.RegisteredClaims
+}
+
+// SignEndpointToken 签发接入点令牌，ttl 为 0 时不设置过期时间
+func SignEndpointToken(claims EndpointClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:       uuid.NewString(),
+		Audience: jwt.ClaimStrings{endpointTokenAudience},
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+	if ttl > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	}
+	return signClaims(claims)
+}
+
+// ParseEndpointToken 校验接入点令牌的签名、有效期和用途
+func ParseEndpointToken(tokenString string, purpose string) (*EndpointClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &EndpointClaims{}, lookupVerifyKey)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*EndpointClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrInvalidKey
+	}
+	if !claims.VerifyAudience(endpointTokenAudience, true) {
+		return nil, fmt.Errorf("不是接入点令牌")
+	}
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("令牌用途不匹配: %s", claims.Purpose)
+	}
+	return claims, nil
+}
@@ -0,0 +1,289 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultJWTKid             = "default"
+	defaultAccessTokenMinutes = 15
+	defaultRefreshTokenHours  = 720
+	// jwtSecretName 自动生成的 JWT 签名密钥在 system_secrets 中的名称
+	jwtSecretName = "jwt_secret"
+)
+
+// knownDefaultJWTSecrets 曾随配置文件发布的默认密钥，使用它们签发的令牌任何人都能伪造
+var knownDefaultJWTSecrets = map[string]struct{}{
+	"xiaozhi_admin_secret_key": {},
+}
+
+// EnsureJWTSecret 未配置 jwt.secret 和 jwt.keys 时使用数据库中持久化的签名密钥，首次启动时生成，
+// 多个实例共用同一把密钥，重启后登录和接入点令牌仍然有效
+func EnsureJWTSecret(db *gorm.DB, cfg *config.JWTConfig) error {
+	if cfg.Secret != "" || len(cfg.Keys) > 0 {
+		return nil
+	}
+	var secret models.SystemSecret
+	err := db.Where("name = ?", jwtSecretName).First(&secret).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		secret = models.SystemSecret{Name: jwtSecretName, Value: hex.EncodeToString(buf)}
+		if err := db.Create(&secret).Error; err != nil {
+			// 其他实例同时生成了密钥时以已保存的为准
+			if err := db.Where("name = ?", jwtSecretName).First(&secret).Error; err != nil {
+				return fmt.Errorf("保存 JWT 签名密钥失败: %v", err)
+			}
+		} else {
+			log.Printf("[JWT] 未配置 jwt.secret，已生成签名密钥并保存到数据库")
+		}
+	} else if err != nil {
+		return fmt.Errorf("读取 JWT 签名密钥失败: %v", err)
+	}
+	cfg.Secret = secret.Value
+	return nil
+}
+
+// jwtKey 一把签名/校验密钥
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{} // 为 nil 时只用于校验
+	verifyKey interface{}
+}
+
+// jwtKeyring 当前生效的密钥集合
+type jwtKeyring struct {
+	keys       map[string]*jwtKey
+	active     *jwtKey
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+var (
+	keyringMu sync.RWMutex
+	keyring   *jwtKeyring
+)
+
+// InitJWT 根据配置加载签名密钥，启动时调用；配置错误时返回错误
+func InitJWT(cfg config.JWTConfig) error {
+	ring, err := buildKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	keyringMu.Lock()
+	keyring = ring
+	keyringMu.Unlock()
+	log.Printf("[JWT] 已加载 %d 把签名密钥，当前签名 kid: %s, 算法: %s", len(ring.keys), ring.active.kid, ring.active.method.Alg())
+	return nil
+}
+
+func currentKeyring() *jwtKeyring {
+	keyringMu.RLock()
+	ring := keyring
+	keyringMu.RUnlock()
+	if ring != nil {
+		return ring
+	}
+
+	// 未调用 InitJWT（如单元测试）时使用随机密钥，令牌仅在本进程内有效
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	if keyring == nil {
+		keyring, _ = buildKeyring(config.JWTConfig{})
+	}
+	return keyring
+}
+
+func buildKeyring(cfg config.JWTConfig) (*jwtKeyring, error) {
+	ring := &jwtKeyring{
+		keys:       make(map[string]*jwtKey),
+		accessTTL:  defaultAccessTokenMinutes * time.Minute,
+		refreshTTL: defaultRefreshTokenHours * time.Hour,
+	}
+	if cfg.AccessTokenMinutes > 0 {
+		ring.accessTTL = time.Duration(cfg.AccessTokenMinutes) * time.Minute
+	} else if cfg.ExpireHour > 0 && cfg.ExpireHour < 24 {
+		// 旧配置的 expire_hour 通常为24小时，只在更短时沿用，避免访问令牌过长
+		ring.accessTTL = time.Duration(cfg.ExpireHour) * time.Hour
+	}
+	if cfg.RefreshTokenHours > 0 {
+		ring.refreshTTL = time.Duration(cfg.RefreshTokenHours) * time.Hour
+	}
+
+	if _, ok := knownDefaultJWTSecrets[cfg.Secret]; ok {
+		return nil, fmt.Errorf("jwt.secret 使用了公开的默认值，请删除该配置（自动生成）或改为随机字符串")
+	}
+	for _, keyCfg := range cfg.Keys {
+		if _, ok := knownDefaultJWTSecrets[keyCfg.Secret]; ok {
+			return nil, fmt.Errorf("JWT 密钥 %s 使用了公开的默认值，请改为随机字符串", keyCfg.Kid)
+		}
+		key, err := loadJWTKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("加载 JWT 密钥 %s 失败: %v", keyCfg.Kid, err)
+		}
+		if _, exists := ring.keys[key.kid]; exists {
+			return nil, fmt.Errorf("JWT 密钥 kid 重复: %s", key.kid)
+		}
+		ring.keys[key.kid] = key
+	}
+
+	if len(ring.keys) == 0 {
+		secret := cfg.Secret
+		if secret == "" {
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				return nil, err
+			}
+			secret = hex.EncodeToString(buf)
+			log.Printf("[JWT] ⚠️ 未配置 jwt.secret，使用随机密钥，令牌仅在本进程内有效")
+		}
+		ring.keys[defaultJWTKid] = &jwtKey{
+			kid:       defaultJWTKid,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}
+		ring.active = ring.keys[defaultJWTKid]
+		return ring, nil
+	}
+
+	activeKid := cfg.ActiveKid
+	if activeKid == "" && len(cfg.Keys) == 1 {
+		activeKid = cfg.Keys[0].Kid
+	}
+	active, ok := ring.keys[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("jwt.active_kid %q 未在 jwt.keys 中配置", activeKid)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("jwt.active_kid %q 缺少签名私钥", activeKid)
+	}
+	ring.active = active
+	return ring, nil
+}
+
+func loadJWTKey(cfg config.JWTKeyConfig) (*jwtKey, error) {
+	kid := strings.TrimSpace(cfg.Kid)
+	if kid == "" {
+		return nil, fmt.Errorf("kid 不能为空")
+	}
+	key := &jwtKey{kid: kid}
+
+	switch strings.ToUpper(strings.TrimSpace(cfg.Algorithm)) {
+	case "", "HS256":
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("HS256 需要配置 secret")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			data, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = privateKey
+			key.verifyKey = &privateKey.PublicKey
+		}
+		if cfg.PublicKeyFile != "" {
+			data, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = publicKey
+		}
+	case "ES256":
+		key.method = jwt.SigningMethodES256
+		if cfg.PrivateKeyFile != "" {
+			data, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = privateKey
+			key.verifyKey = &privateKey.PublicKey
+		}
+		if cfg.PublicKeyFile != "" {
+			data, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey, err := jwt.ParseECPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = publicKey
+		}
+	default:
+		return nil, fmt.Errorf("不支持的算法: %s", cfg.Algorithm)
+	}
+
+	if key.verifyKey == nil {
+		return nil, fmt.Errorf("需要配置 private_key_file 或 public_key_file")
+	}
+	return key, nil
+}
+
+// signClaims 使用当前密钥签名，头部带 kid
+func signClaims(claims jwt.Claims) (string, error) {
+	active := currentKeyring().active
+	token := jwt.NewWithClaims(active.method, claims)
+	token.Header["kid"] = active.kid
+	return token.SignedString(active.signKey)
+}
+
+// lookupVerifyKey 按 kid 选择校验密钥，并确认算法与密钥一致
+func lookupVerifyKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token 缺少 kid")
+	}
+	key, ok := currentKeyring().keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("未知的 kid: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("token 算法 %s 与密钥 %s 不匹配", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// AccessTokenTTL 访问令牌有效期
+func AccessTokenTTL() time.Duration {
+	return currentKeyring().accessTTL
+}
+
+// RefreshTokenTTL 刷新令牌有效期
+func RefreshTokenTTL() time.Duration {
+	return currentKeyring().refreshTTL
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestJWTKeyRotation(t *testing.T) {
+	keys := []config.JWTKeyConfig{
+		{Kid: "old", Algorithm: "HS256", Secret: "old-secret"},
+		{Kid: "new", Algorithm: "HS256", Secret: "new-secret"},
+	}
+	if err := InitJWT(config.JWTConfig{Keys: keys, ActiveKid: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := GenerateToken(1, "admin", "admin", 3, "sid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 切换签名密钥后，旧密钥签发的令牌仍可校验
+	if err := InitJWT(config.JWTConfig{Keys: keys, ActiveKid: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(oldToken)
+	if err != nil {
+		t.Fatalf("rotated token rejected: %v", err)
+	}
+	if claims.TokenVersion != 3 || claims.SessionID != "sid-1" || claims.ID == "" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	// 旧密钥移除后拒绝
+	if err := InitJWT(config.JWTConfig{Keys: keys[1:]}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(oldToken); err == nil {
+		t.Fatal("token signed by removed key accepted")
+	}
+}
+
+func TestInitJWTRejectsUnknownActiveKid(t *testing.T) {
+	err := InitJWT(config.JWTConfig{
+		Keys:      []config.JWTKeyConfig{{Kid: "a", Secret: "s"}},
+		ActiveKid: "b",
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown active_kid")
+	}
+}
+
+func TestEndpointToken(t *testing.T) {
+	if err := InitJWT(config.JWTConfig{Secret: "endpoint-test-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	token, err := SignEndpointToken(EndpointClaims{UserID: 1, AgentID: "7", Purpose: "agent-mcp-server", Version: 2}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseEndpointToken(token, "agent-mcp-server")
+	if err != nil {
+		t.Fatalf("endpoint token rejected: %v", err)
+	}
+	if claims.AgentID != "7" || claims.Version != 2 || claims.ExpiresAt == nil {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if _, err := ParseEndpointToken(token, "openclaw-endpoint"); err == nil {
+		t.Error("endpoint token accepted for another purpose")
+	}
+	// 接入点令牌不能当作登录令牌
+	if _, err := ParseToken(token); err == nil {
+		t.Error("endpoint token accepted as access token")
+	}
+	// 登录令牌也不能当作接入点令牌
+	accessToken, err := GenerateToken(1, "admin", "admin", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseEndpointToken(accessToken, ""); err == nil {
+		t.Error("access token accepted as endpoint token")
+	}
+}
+
+func TestInitJWTRejectsDefaultSecret(t *testing.T) {
+	if err := InitJWT(config.JWTConfig{Secret: "xiaozhi_admin_secret_key"}); err == nil {
+		t.Error("expected error for the published default secret")
+	}
+	keys := []config.JWTKeyConfig{{Kid: "a", Secret: "xiaozhi_admin_secret_key"}}
+	if err := InitJWT(config.JWTConfig{Keys: keys}); err == nil {
+		t.Error("expected error for a key using the published default secret")
+	}
+}
+
+func TestEnsureJWTSecret(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.SystemSecret{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 首次启动生成并保存，之后启动读取同一把密钥
+	var first, second config.JWTConfig
+	if err := EnsureJWTSecret(db, &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureJWTSecret(db, &second); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Secret) != 64 || first.Secret != second.Secret {
+		t.Fatalf("secrets = %q, %q, want the same generated secret", first.Secret, second.Secret)
+	}
+
+	// 已配置密钥时不使用数据库中的密钥
+	configured := config.JWTConfig{Secret: "configured"}
+	if err := EnsureJWTSecret(db, &configured); err != nil || configured.Secret != "configured" {
+		t.Fatalf("configured secret changed to %q, err %v", configured.Secret, err)
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用户角色：admin 为平台管理员，org_* 为组织内角色，user 只能管理自己的资源
+const (
+	RoleAdmin    = "admin"
+	RoleOrgOwner = "org_owner"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+	RoleUser     = "user"
+)
+
+// 管理接口权限，组织内角色只能在本组织范围内使用
+const (
+	PermDevicesRead    = "devices:read"
+	PermDevicesWrite   = "devices:write"
+	PermAgentsRead     = "agents:read"
+	PermAgentsWrite    = "agents:write"
+	PermKnowledgeRead  = "knowledge:read"
+	PermKnowledgeWrite = "knowledge:write"
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermConfigsRead    = "configs:read"
+	PermConfigsWrite   = "configs:write"
+	PermAuditRead      = "audit:read"
+)
+
+// rolePermissions 组织内角色拥有的权限；平台管理员拥有全部权限，普通用户没有管理权限
+var rolePermissions = map[string][]string{
+	RoleOrgOwner: {
+		PermDevicesRead, PermDevicesWrite,
+		PermAgentsRead, PermAgentsWrite,
+		PermKnowledgeRead, PermKnowledgeWrite,
+		PermUsersRead, PermUsersWrite,
+		PermConfigsRead, PermConfigsWrite,
+		PermAuditRead,
+	},
+	RoleOperator: {
+		PermDevicesRead, PermDevicesWrite,
+		PermAgentsRead, PermAgentsWrite,
+		PermKnowledgeRead, PermKnowledgeWrite,
+		PermUsersRead,
+		PermConfigsRead,
+	},
+	RoleViewer: {
+		PermDevicesRead,
+		PermAgentsRead,
+		PermKnowledgeRead,
+		PermUsersRead,
+		PermConfigsRead,
+	},
+}
+
+// adminRouteRules 管理接口路由前缀需要的权限，规则同 apiKeyScopeRules；
+// 未匹配或权限为空的接口只允许平台管理员访问
+var adminRouteRules = []apiKeyScopeRule{
+	{prefix: "/api/admin/devices", read: PermDevicesRead, write: PermDevicesWrite},
+	{prefix: "/api/admin/agents", read: PermAgentsRead, write: PermAgentsWrite},
+	{prefix: "/api/admin/users/:id/voice-clone-quotas"},
+	{prefix: "/api/admin/users/:id/knowledge-bases", read: PermKnowledgeRead, write: PermKnowledgeWrite},
+	{prefix: "/api/admin/users", read: PermUsersRead, write: PermUsersWrite},
+	{prefix: "/api/admin/configs/export"},
+	{prefix: "/api/admin/configs/import"},
+	{prefix: "/api/admin/configs/test"},
+	{prefix: "/api/admin/configs", read: PermConfigsRead, write: PermConfigsWrite},
+	{prefix: "/api/admin/llm-configs", read: PermConfigsRead, write: PermConfigsWrite},
+	{prefix: "/api/admin/tts-configs", read: PermConfigsRead, write: PermConfigsWrite},
+	{prefix: "/api/admin/audit-logs", read: PermAuditRead},
+}
+
+// ValidRole 是否为已知角色
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser || IsOrgRole(role)
+}
+
+// IsOrgRole 是否为组织内角色
+func IsOrgRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission 角色是否拥有某项权限
+func RoleHasPermission(role, perm string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredAdminPermission 返回访问管理接口需要的权限，ok 为 false 表示只允许平台管理员
+func requiredAdminPermission(method, fullPath string) (string, bool) {
+	for _, rule := range adminRouteRules {
+		if fullPath != rule.prefix && !strings.HasPrefix(fullPath, rule.prefix+"/") {
+			continue
+		}
+		perm := rule.write
+		if method == http.MethodGet || method == http.MethodHead {
+			perm = rule.read
+		}
+		return perm, perm != ""
+	}
+	return "", false
+}
+
+// AdminRBAC 管理接口权限中间件：平台管理员不受限制，组织内角色按路由检查权限，
+// 数据范围由各接口按 org_id 限定在本组织内
+func AdminRBAC() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if role == RoleAdmin {
+			c.Next()
+			return
+		}
+		perm, ok := requiredAdminPermission(c.Request.Method, c.FullPath())
+		if !ok || !IsOrgRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			c.Abort()
+			return
+		}
+		if !RoleHasPermission(role, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "当前角色无权执行该操作", "permission": perm})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
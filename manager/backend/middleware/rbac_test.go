@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequiredAdminPermission(t *testing.T) {
+	cases := []struct {
+		method, path string
+		perm         string
+		ok           bool
+	}{
+		{http.MethodGet, "/api/admin/devices", PermDevicesRead, true},
+		{http.MethodPost, "/api/admin/devices/:id/mcp-call", PermDevicesWrite, true},
+		{http.MethodDelete, "/api/admin/agents/:id", PermAgentsWrite, true},
+		{http.MethodGet, "/api/admin/users/:id/knowledge-bases", PermKnowledgeRead, true},
+		{http.MethodPost, "/api/admin/users/:id/reset-2fa", PermUsersWrite, true},
+		{http.MethodPut, "/api/admin/llm-configs/:id", PermConfigsWrite, true},
+		{http.MethodGet, "/api/admin/audit-logs", PermAuditRead, true},
+		// 平台级接口只允许平台管理员
+		{http.MethodGet, "/api/admin/users/:id/voice-clone-quotas", "", false},
+		{http.MethodGet, "/api/admin/configs/export", "", false},
+		{http.MethodGet, "/api/admin/ota-configs", "", false},
+		{http.MethodGet, "/api/admin/organizations", "", false},
+	}
+	for _, tc := range cases {
+		perm, ok := requiredAdminPermission(tc.method, tc.path)
+		if perm != tc.perm || ok != tc.ok {
+			t.Errorf("%s %s = (%q, %v), want (%q, %v)", tc.method, tc.path, perm, ok, tc.perm, tc.ok)
+		}
+	}
+}
+
+func TestRoleHasPermission(t *testing.T) {
+	cases := []struct {
+		role, perm string
+		want       bool
+	}{
+		{RoleAdmin, PermUsersWrite, true},
+		{RoleOrgOwner, PermUsersWrite, true},
+		{RoleOperator, PermAgentsWrite, true},
+		{RoleOperator, PermUsersWrite, false},
+		{RoleOperator, PermConfigsWrite, false},
+		{RoleViewer, PermDevicesRead, true},
+		{RoleViewer, PermDevicesWrite, false},
+		{RoleOrgOwner, PermAuditRead, true},
+		{RoleOperator, PermAuditRead, false},
+		{RoleUser, PermDevicesRead, false},
+		{"unknown", PermDevicesRead, false},
+	}
+	for _, tc := range cases {
+		if got := RoleHasPermission(tc.role, tc.perm); got != tc.want {
+			t.Errorf("RoleHasPermission(%q, %q) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"xiaozhi/manager/backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRefreshToken 刷新令牌不存在、已过期或已注销
+var ErrInvalidRefreshToken = errors.New("无效的刷新令牌")
+
+// TokenPair 登录或刷新后返回给前端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌剩余有效期（秒）
+}
+
+func newRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func issueTokenPair(user models.User, sessionID string, refreshToken string) (*TokenPair, error) {
+	accessToken, err := GenerateToken(user.ID, user.Username, user.Role, user.TokenVersion, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// IssueSession 登录成功后创建会话，返回访问令牌和刷新令牌
+func IssueSession(db *gorm.DB, user models.User, userAgent, ip string) (*TokenPair, error) {
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := models.UserSession{
+		SessionID:        uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: refreshHash,
+		TokenVersion:     user.TokenVersion,
+		UserAgent:        truncate(userAgent, 255),
+		IP:               ip,
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+		LastUsedAt:       now,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return issueTokenPair(user, session.SessionID, refreshToken)
+}
+
+// RefreshSession 用刷新令牌换取新的访问令牌，刷新令牌同时轮换，旧令牌立即失效
+func RefreshSession(db *gorm.DB, refreshToken, userAgent, ip string) (*TokenPair, *models.User, error) {
+	if refreshToken == "" {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	var session models.UserSession
+	if err := db.Where("refresh_token_hash = ?", hashRefreshToken(refreshToken)).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := db.First(&user, session.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	// 会话创建后修改过密码或注销过全部会话
+	if user.TokenVersion != session.TokenVersion {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	// 以旧哈希为条件更新，并发刷新时只有一个请求成功
+	result := db.Model(&models.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, session.RefreshTokenHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": newHash,
+			"user_agent":         truncate(userAgent, 255),
+			"ip":                 ip,
+			"last_used_at":       now,
+		})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := issueTokenPair(user, session.SessionID, newToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// RevokeToken 注销当前访问令牌及其所属会话（退出登录）
+func RevokeToken(db *gorm.DB, claims *Claims) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if claims.ID != "" && claims.ExpiresAt != nil {
+			revoked := models.RevokedToken{
+				JTI:       claims.ID,
+				UserID:    claims.UserID,
+				ExpiresAt: claims.ExpiresAt.Time,
+			}
+			if err := tx.Where(models.RevokedToken{JTI: claims.ID}).FirstOrCreate(&revoked).Error; err != nil {
+				return err
+			}
+		}
+		if claims.SessionID != "" {
+			if err := tx.Model(&models.UserSession{}).
+				Where("session_id = ? AND revoked_at IS NULL", claims.SessionID).
+				Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+		}
+		// 顺带清理已过期的记录
+		tx.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+		tx.Where("expires_at < ?", now).Delete(&models.UserSession{})
+		return nil
+	})
+}
+
+// RevokeUserSessions 使用户的全部令牌失效：递增 token_version 并注销所有会话
+// 修改密码、管理员重置密码、用户主动“退出所有设备”时调用
+func RevokeUserSessions(db *gorm.DB, userID uint) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// checkTokenRevoked 校验访问令牌是否已注销、所属会话是否已注销或已被 token_version 作废，返回用户的当前状态
+func checkTokenRevoked(db *gorm.DB, claims *Claims) (*models.User, error) {
+	if claims.ID != "" {
+		var count int64
+		if err := db.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, errors.New("token 已注销")
+		}
+	}
+	// 退出登录只把当前 jti 加入黑名单，同一会话刷新得到的其他访问令牌按会话状态拒绝
+	if claims.SessionID != "" {
+		var count int64
+		if err := db.Model(&models.UserSession{}).
+			Where("session_id = ? AND revoked_at IS NULL", claims.SessionID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, errors.New("会话已注销，请重新登录")
+		}
+	}
+	var user models.User
+	if err := db.Select("id", "token_version", "role", "org_id", "auth_provider", "totp_enabled").First(&user, claims.UserID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return nil, errors.New("token 已失效，请重新登录")
+	}
+	return &user, nil
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
+}
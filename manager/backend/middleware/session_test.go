@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"testing"
+
+	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLogoutRevokesSessionTokens(t *testing.T) {
+	if err := InitJWT(config.JWTConfig{Secret: "test-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserSession{}, &models.RevokedToken{}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "alice", Password: "x", Email: "alice@example.com", Role: RoleUser}
+	db.Create(&user)
+
+	first, err := IssueSession(db, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一会话刷新后得到另一个访问令牌
+	second, _, err := RefreshSession(db, first.RefreshToken, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstClaims, err := ParseToken(first.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondClaims, err := ParseToken(second.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkTokenRevoked(db, secondClaims); err != nil {
+		t.Fatalf("token rejected before logout: %v", err)
+	}
+
+	if err := RevokeToken(db, firstClaims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checkTokenRevoked(db, firstClaims); err == nil {
+		t.Fatal("logged out token still accepted")
+	}
+	if _, err := checkTokenRevoked(db, secondClaims); err == nil {
+		t.Fatal("token from the logged out session still accepted")
+	}
+}
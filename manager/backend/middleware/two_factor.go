@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"strings"
+
+	"xiaozhi/manager/backend/models"
+)
+
+// enforceAdminTwoFactor 为 true 时管理员必须启用两步验证
+var enforceAdminTwoFactor bool
+
+// SetTwoFactorPolicy 设置两步验证强制策略，启动时调用
+func SetTwoFactorPolicy(enforceAdmin bool) {
+	enforceAdminTwoFactor = enforceAdmin
+}
+
+// TwoFactorRequired 用户是否必须启用两步验证；单点登录用户的多因素认证由身份提供方负责
+func TwoFactorRequired(user models.User) bool {
+	return enforceAdminTwoFactor && user.Role == "admin" && user.AuthProvider != "oidc"
+}
+
+// twoFactorSetupPaths 必须启用两步验证但尚未启用时仍可访问的接口
+var twoFactorSetupPaths = []string{
+	"/api/profile",
+	"/api/auth/2fa/",
+	"/api/auth/logout",
+	"/api/auth/revoke-all",
+}
+
+func twoFactorSetupAllowed(path string) bool {
+	for _, allowed := range twoFactorSetupPaths {
+		if path == allowed || strings.HasSuffix(allowed, "/") && strings.HasPrefix(path, allowed) {
+			return true
+		}
+	}
+	return false
+}
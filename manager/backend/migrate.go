@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"xiaozhi/manager/backend/config"
+	"xiaozhi/manager/backend/database"
+)
+
+// runMigrate 执行 migrate 子命令：查看迁移状态、升级或回滚数据库表结构。
+// 与服务启动一样读取配置文件并应用 DB_* 环境变量。
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var configFile string
+	fs.StringVar(&configFile, "config", "config/config.json", "配置文件路径")
+	fs.StringVar(&configFile, "c", "config/config.json", "配置文件路径 (简写)")
+	target := fs.Int("to", 0, "up: 只升级到该版本（含），默认全部")
+	steps := fs.Int("steps", 1, "down: 回滚最近执行的迁移个数")
+	force := fs.Bool("force", false, "down: 允许回滚基线版本（会删除全部表和数据）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: manager migrate [-c config.json] status|up|down [-to 版本] [-steps N] [-force]")
+		fs.PrintDefaults()
+	}
+
+	// 子命令名放在参数前后都可以
+	action := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if action == "" && fs.NArg() > 0 {
+		action = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+
+	cfg := config.LoadWithPath(configFile)
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+	defer database.Close(db)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("加载迁移脚本失败: %v", err)
+	}
+
+	switch action {
+	case "status":
+		printMigrationStatus(migrator)
+	case "up":
+		applied, err := migrator.Up(*target)
+		if err != nil {
+			log.Fatalf("升级失败（已执行 %d 个迁移）: %v", applied, err)
+		}
+		log.Printf("升级完成，执行了 %d 个迁移", applied)
+	case "down":
+		if *steps <= 0 {
+			log.Fatal("-steps 必须大于 0")
+		}
+		if !*force && rollsBackBaseline(migrator, *steps) {
+			log.Fatal("本次回滚包含基线版本，会删除管理后台的全部表和数据；确认无误请加 -force")
+		}
+		rolledBack, err := migrator.Down(*steps)
+		if err != nil {
+			log.Fatalf("回滚失败（已回滚 %d 个迁移）: %v", rolledBack, err)
+		}
+		log.Printf("回滚完成，回滚了 %d 个迁移", rolledBack)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func printMigrationStatus(migrator *database.Migrator) {
+	statuses, err := migrator.Status()
+	if err != nil {
+		log.Fatalf("读取迁移状态失败: %v", err)
+	}
+	if migrator.NeedsBaseline() {
+		fmt.Println("数据库由旧版本创建、尚无迁移历史，执行 up 时将补齐表结构并登记全部迁移")
+	}
+	pending := 0
+	for _, s := range statuses {
+		state := "未执行"
+		switch {
+		case s.Modified:
+			state = "已执行，脚本已被修改！ " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		case s.Applied:
+			state = "已执行 " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		default:
+			pending++
+		}
+		fmt.Printf("%04d  %-32s %s\n", s.Version, s.Name, state)
+	}
+	fmt.Printf("共 %d 个迁移，%d 个未执行\n", len(statuses), pending)
+}
+
+// rollsBackBaseline 回滚最近 steps 个已执行的迁移是否会包含基线版本
+func rollsBackBaseline(migrator *database.Migrator, steps int) bool {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return false
+	}
+	for i := len(statuses) - 1; i >= 0 && steps > 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+		if statuses[i].Version == statuses[0].Version {
+			return true
+		}
+		steps--
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// JSONText 以字符串读写的 JSON 列：PostgreSQL 建为 jsonb，MySQL/SQLite 建为 json；
+// 空字符串按 NULL 写入，避免数据库拒绝非法 JSON
+type JSONText string
+
+// GormDBDataType 按数据库类型返回列类型
+func (JSONText) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "json"
+}
+
+// Value 实现 driver.Valuer
+func (j JSONText) Value() (driver.Value, error) {
+	if j == "" {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan 实现 sql.Scanner
+func (j *JSONText) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = ""
+	case string:
+		*j = JSONText(v)
+	case []byte:
+		*j = JSONText(v)
+	default:
+		return fmt.Errorf("无法将 %T 转换为 JSONText", value)
+	}
+	return nil
+}
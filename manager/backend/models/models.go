@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -9,15 +10,109 @@ import (
 
 // 用户模型
 type User struct {
+	ID       uint   `json:"id" gorm:"primarykey"`
+	Username string `json:"username" gorm:"type:varchar(50);uniqueIndex:idx_users_username;not null"`
+	Password string `json:"-" gorm:"type:varchar(255);not null"`
+	Email    string `json:"email" gorm:"type:varchar(100);uniqueIndex:idx_users_email"`
+	Role     string `json:"role" gorm:"type:varchar(20);not null;default:'user'"` // admin, org_owner, operator, viewer, user
+	// OrgID 所属组织，为空表示不属于任何组织（平台管理员或独立用户）
+	OrgID *uint `json:"org_id" gorm:"index"`
+	// TokenVersion 修改密码或注销全部会话时递增，旧版本签发的令牌全部失效
+	TokenVersion int `json:"-" gorm:"not null;default:0"`
+	// AuthProvider 为 oidc 时用户由单点登录创建，ExternalID 为 issuer|sub
+	AuthProvider string  `json:"auth_provider" gorm:"type:varchar(20);not null;default:'local'"`
+	ExternalID   *string `json:"-" gorm:"type:varchar(255);uniqueIndex"`
+	// TOTP 两步验证：TOTPSecret 在确认启用前为待绑定密钥，TOTPLastCounter 防止同一验证码重放
+	TOTPEnabled        bool       `json:"totp_enabled" gorm:"column:totp_enabled;not null;default:false"`
+	TOTPSecret         string     `json:"-" gorm:"column:totp_secret;type:varchar(64)"`
+	TOTPLastCounter    int64      `json:"-" gorm:"column:totp_last_counter;not null;default:0"`
+	TwoFactorFailures  int        `json:"-" gorm:"not null;default:0"`
+	TwoFactorLockUntil *time.Time `json:"-"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// UserSession 登录会话，保存刷新令牌的哈希；刷新时轮换令牌
+type UserSession struct {
+	ID               uint       `json:"id" gorm:"primarykey"`
+	SessionID        string     `json:"session_id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID           uint       `json:"user_id" gorm:"index;not null"`
+	RefreshTokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	TokenVersion     int        `json:"-" gorm:"not null;default:0"`
+	UserAgent        string     `json:"user_agent" gorm:"type:varchar(255)"`
+	IP               string     `json:"ip" gorm:"type:varchar(64)"`
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// OIDCLoginState 单点登录进行中的授权请求；回调成功后换成一次性登录票据交给前端
+type OIDCLoginState struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	State        string    `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Nonce        string    `json:"-" gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `json:"-" gorm:"type:varchar(128);not null"`
+	TicketHash   *string   `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+	UserID       uint      `json:"-"`
+	ExpiresAt    time.Time `json:"-" gorm:"index"`
+	CreatedAt    time.Time `json:"-"`
+}
+
+// TableName 指定表名
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// UserRecoveryCode 两步验证恢复码（只保存哈希），每个只能使用一次
+type UserRecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Organization 组织（租户），成员的智能体、设备、知识库和组织自有配置与其他组织隔离
+type Organization struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
-	Username  string    `json:"username" gorm:"type:varchar(50);uniqueIndex:idx_users_username;not null"`
-	Password  string    `json:"-" gorm:"type:varchar(255);not null"`
-	Email     string    `json:"email" gorm:"type:varchar(100);uniqueIndex:idx_users_email"`
-	Role      string    `json:"role" gorm:"type:varchar(20);not null;default:'user'"` // admin, user
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	Slug      string    `json:"slug" gorm:"type:varchar(64);uniqueIndex;not null"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// APIKey 个人 API Key，只保存哈希；Scopes 为逗号分隔的权限列表
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16);not null"` // 明文前几位，用于列表中辨认
+	KeyHash    string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     string     `json:"scopes" gorm:"type:varchar(500);not null"`
+	ExpiresAt  *time.Time `json:"expires_at"` // 为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"type:varchar(64)"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RevokedToken 已注销的访问令牌（按 jti），过期后清理
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	JTI       string    `json:"jti" gorm:"column:jti;type:varchar(64);uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SystemSecret 管理后台首次启动时生成并持久化的密钥（如未配置 jwt.secret 时的 JWT 签名密钥），多个实例共用
+type SystemSecret struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Name      string    `json:"name" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Value     string    `json:"-" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // 设备模型
 type Device struct {
 	ID           uint       `json:"id" gorm:"primarykey"`
@@ -48,10 +143,15 @@ type Agent struct {
 	MCPServiceNames string  `json:"mcp_service_names" gorm:"type:text"`                  // 逗号分隔的MCP服务名，空=使用全部已启用全局MCP服务
 	// OpenClaw 配置，JSON字符串，结构：
 	// {"allowed":true,"enter_keywords":["进入openclaw"],"exit_keywords":["退出openclaw"]}
-	OpenClawConfig string    `json:"openclaw_config" gorm:"type:text"`
-	Status         string    `json:"status" gorm:"type:varchar(20);default:'active'"` // active, inactive
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	OpenClawConfig string `json:"openclaw_config" gorm:"type:text"`
+	// 工具调用策略，JSON字符串，结构：
+	// {"allow":["get_*"],"deny":["global:HassTurn*"],"rules":[{"tool":"self.audio_speaker.set_volume","args":{"volume":{"max":60,"clamp":true}},"rate_limit":{"max":5,"window_seconds":60}}]}
+	MCPToolPolicyConfig string `json:"mcp_tool_policy_config" gorm:"type:text"`
+	// 智能体MCP服务器接入点令牌版本，重置接入点时递增，令牌中的版本不一致即失效
+	MCPServerTokenVersion int       `json:"-" gorm:"not null;default:1"`
+	Status                string    `json:"status" gorm:"type:varchar(20);default:'active'"` // active, inactive
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 // KnowledgeBase 用户知识库（每用户独立）
@@ -61,11 +161,11 @@ type KnowledgeBase struct {
 	Name               string     `json:"name" gorm:"type:varchar(100);not null"`
 	Description        string     `json:"description" gorm:"type:text"`
 	Content            string     `json:"content" gorm:"type:text"`
-	RetrievalThreshold *float64   `json:"retrieval_threshold" gorm:"type:double"`         // 检索阈值（为空表示继承全局配置）
-	ExternalKBID       string     `json:"external_kb_id" gorm:"type:varchar(255);index"`  // 外部知识库ID（Dify dataset_id）
-	ExternalDocID      string     `json:"external_doc_id" gorm:"type:varchar(255);index"` // 外部文档ID（Dify document_id）
-	AutoDataset        bool       `json:"auto_dataset" gorm:"default:false"`              // 是否由系统自动创建dataset
-	SyncProvider       string     `json:"sync_provider" gorm:"type:varchar(50);index"`    // 同步provider（当前为dify）
+	RetrievalThreshold *float64   `json:"retrieval_threshold" gorm:"type:double precision"` // 检索阈值（为空表示继承全局配置）
+	ExternalKBID       string     `json:"external_kb_id" gorm:"type:varchar(255);index"`    // 外部知识库ID（Dify dataset_id）
+	ExternalDocID      string     `json:"external_doc_id" gorm:"type:varchar(255);index"`   // 外部文档ID（Dify document_id）
+	AutoDataset        bool       `json:"auto_dataset" gorm:"default:false"`                // 是否由系统自动创建dataset
+	SyncProvider       string     `json:"sync_provider" gorm:"type:varchar(50);index"`      // 同步provider（当前为dify）
 	SyncStatus         string     `json:"sync_status" gorm:"type:varchar(20);default:'pending';index"`
 	SyncError          string     `json:"sync_error" gorm:"type:text"`
 	LastSyncedAt       *time.Time `json:"last_synced_at"`
@@ -106,6 +206,7 @@ type Config struct {
 	JsonData  string    `json:"json_data" gorm:"type:text"`                                                        // JSON配置数据
 	Enabled   bool      `json:"enabled" gorm:"default:true"`
 	IsDefault bool      `json:"is_default" gorm:"default:false"`
+	OrgID     *uint     `json:"org_id" gorm:"index"` // 所属组织，为空表示平台共享配置
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -213,7 +314,7 @@ type VoiceClone struct {
 	TTSConfigID        string    `json:"tts_config_id" gorm:"type:varchar(100);not null;index"`
 	Status             string    `json:"status" gorm:"type:varchar(20);default:'active';index"`
 	TranscriptRequired bool      `json:"transcript_required" gorm:"default:false"`
-	MetaJSON           string    `json:"meta_json" gorm:"type:json"`
+	MetaJSON           JSONText  `json:"meta_json"`
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
 }
@@ -246,7 +347,7 @@ type VoiceCloneTask struct {
 	LastError    string     `json:"last_error" gorm:"type:text"`
 	StartedAt    *time.Time `json:"started_at"`
 	FinishedAt   *time.Time `json:"finished_at"`
-	MetaJSON     string     `json:"meta_json" gorm:"type:json"`
+	MetaJSON     JSONText   `json:"meta_json"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 }
@@ -262,6 +363,150 @@ type UserVoiceCloneQuota struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// MCPOAuthCredential 远程MCP服务的 OAuth 授权信息，按服务 URL 关联；令牌与客户端密钥加密保存
+type MCPOAuthCredential struct {
+	ID      uint   `json:"id" gorm:"primarykey"`
+	URL     string `json:"url" gorm:"type:text;not null"`
+	URLHash string `json:"url_hash" gorm:"type:varchar(512);not null;uniqueIndex:idx_mcp_oauth_credentials_url_hash"`
+	Status  string `json:"status" gorm:"type:varchar(20);index;comment:pending|authorized|error"`
+
+	Resource              string `json:"resource" gorm:"type:text"`
+	AuthorizationServer   string `json:"authorization_server" gorm:"type:text"`
+	AuthorizationEndpoint string `json:"authorization_endpoint" gorm:"type:text"`
+	TokenEndpoint         string `json:"token_endpoint" gorm:"type:text"`
+	Scope                 string `json:"scope" gorm:"type:varchar(500)"`
+	RedirectURI           string `json:"redirect_uri" gorm:"type:text"`
+
+	ClientID               string `json:"client_id" gorm:"type:varchar(255)"`
+	ClientSecretCiphertext string `json:"-" gorm:"type:text"`
+	ClientSecretNonce      string `json:"-" gorm:"type:varchar(64)"`
+	DynamicClient          bool   `json:"dynamic_client"` // 是否通过动态注册获得的客户端
+
+	AccessTokenCiphertext  string     `json:"-" gorm:"type:text"`
+	AccessTokenNonce       string     `json:"-" gorm:"type:varchar(64)"`
+	RefreshTokenCiphertext string     `json:"-" gorm:"type:text"`
+	RefreshTokenNonce      string     `json:"-" gorm:"type:varchar(64)"`
+	TokenType              string     `json:"token_type" gorm:"type:varchar(32)"`
+	ExpiresAt              *time.Time `json:"expires_at"`
+
+	// 授权进行中的 state 与 PKCE code_verifier，回调完成后清空
+	State                  string `json:"-" gorm:"type:varchar(128);index"`
+	CodeVerifierCiphertext string `json:"-" gorm:"type:text"`
+	CodeVerifierNonce      string `json:"-" gorm:"type:varchar(64)"`
+
+	LastError string    `json:"last_error" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MemoryAuditLog 长期记忆管理审计日志（编辑、删除、导出、清空）
+// 出于隐私考虑只记录操作元数据，不保存记忆内容
+type MemoryAuditLog struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	UserID         uint      `json:"user_id" gorm:"not null;index"` // 操作人
+	Username       string    `json:"username" gorm:"type:varchar(100)"`
+	AgentID        uint      `json:"agent_id" gorm:"not null;index"`
+	SpeakerGroupID *uint     `json:"speaker_group_id"` // 为空表示作用于全部记忆分区
+	Provider       string    `json:"provider" gorm:"type:varchar(50)"`
+	Action         string    `json:"action" gorm:"type:varchar(32);not null;index;comment:update|delete|export|forget_all"`
+	ItemID         string    `json:"item_id" gorm:"type:varchar(128)"`
+	Detail         string    `json:"detail" gorm:"type:text"`
+	IP             string    `json:"ip" gorm:"type:varchar(64)"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index"`
+}
+
+// AuditLog 管理操作审计日志，只允许追加；Before/After 为脱敏后的实体快照，Diff 为字段级变更
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	ActorID    uint      `json:"actor_id" gorm:"index"`
+	ActorName  string    `json:"actor_name" gorm:"type:varchar(100)"`
+	ActorRole  string    `json:"actor_role" gorm:"type:varchar(20)"`
+	OrgID      *uint     `json:"org_id" gorm:"index"`                           // 操作人所属组织，组织管理员只能查看本组织的记录
+	APIKeyID   *uint     `json:"api_key_id"`                                    // 通过 API Key 调用时记录
+	Action     string    `json:"action" gorm:"type:varchar(64);not null;index"` // create, update, delete 或子操作名（toggle、reset-password 等）
+	EntityType string    `json:"entity_type" gorm:"type:varchar(64);index"`
+	EntityID   string    `json:"entity_id" gorm:"type:varchar(64);index"`
+	Method     string    `json:"method" gorm:"type:varchar(10)"`
+	Path       string    `json:"path" gorm:"type:varchar(255)"` // 路由模板
+	StatusCode int       `json:"status_code"`
+	Before     string    `json:"before" gorm:"type:text"`
+	After      string    `json:"after" gorm:"type:text"`
+	Diff       string    `json:"diff" gorm:"type:text"`
+	IP         string    `json:"ip" gorm:"type:varchar(64)"`
+	UserAgent  string    `json:"user_agent" gorm:"type:varchar(255)"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate 审计日志不允许修改
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return errors.New("审计日志只允许追加")
+}
+
+// ConfigVersion 配置、智能体、角色每次保存后的完整快照，用于查看历史、比较差异和回滚
+type ConfigVersion struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	EntityType string    `json:"entity_type" gorm:"type:varchar(20);not null;uniqueIndex:idx_config_version,priority:1"` // config, agent, role
+	EntityID   uint      `json:"entity_id" gorm:"not null;uniqueIndex:idx_config_version,priority:2"`
+	Version    int       `json:"version" gorm:"not null;uniqueIndex:idx_config_version,priority:3"`
+	Snapshot   string    `json:"snapshot,omitempty" gorm:"type:text"` // 实体的 JSON 快照（不含 updated_at）
+	Comment    string    `json:"comment" gorm:"type:varchar(255)"`    // 如“回滚到版本 3”
+	ActorID    uint      `json:"actor_id"`
+	ActorName  string    `json:"actor_name" gorm:"type:varchar(100)"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Webhook 用户配置的出站 Webhook，事件发生时向 URL 推送带 HMAC 签名的 JSON
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	URL       string    `json:"url" gorm:"type:varchar(1024);not null"`
+	Secret    string    `json:"-" gorm:"type:varchar(128)"`     // 签名密钥，只在创建时返回
+	Events    string    `json:"events" gorm:"type:text"`        // 逗号分隔的事件类型，为空表示全部事件
+	AllUsers  bool      `json:"all_users" gorm:"default:false"` // 接收所有用户的事件，仅管理员可设置
+	Enabled   bool      `json:"enabled" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery 一次事件推送及其重试状态，作为投递日志展示
+type WebhookDelivery struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	WebhookID     uint       `json:"webhook_id" gorm:"not null;index"`
+	DeliveryID    string     `json:"delivery_id" gorm:"type:varchar(64);uniqueIndex"` // 对应 X-Xiaozhi-Delivery 请求头，重试时不变
+	Event         string     `json:"event" gorm:"type:varchar(50);index"`
+	Payload       string     `json:"payload" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"type:varchar(20);index"` // pending, sending, success, failed
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	StatusCode    int        `json:"status_code"`
+	Response      string     `json:"response" gorm:"type:text"` // 截断后的响应内容
+	Error         string     `json:"error" gorm:"type:text"`
+	DurationMs    int64      `json:"duration_ms"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// MCPToolCallLog 主程序上报的 MCP 工具调用记录，用于排查工具问题和重放调用
+type MCPToolCallLog struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	UserID        uint      `json:"user_id" gorm:"index"` // 智能体所属用户
+	AgentID       string    `json:"agent_id" gorm:"type:varchar(64);index"`
+	DeviceID      string    `json:"device_id" gorm:"type:varchar(100);index"`
+	SessionID     string    `json:"session_id" gorm:"type:varchar(100)"`
+	Speaker       string    `json:"speaker" gorm:"type:varchar(100)"` // 声纹识别到的说话人
+	ToolCallID    string    `json:"tool_call_id" gorm:"type:varchar(128)"`
+	ToolName      string    `json:"tool_name" gorm:"type:varchar(128);index"`
+	Source        string    `json:"source" gorm:"type:varchar(20);index;comment:local|global|device"`
+	ServerName    string    `json:"server_name" gorm:"type:varchar(128)"`
+	Arguments     string    `json:"arguments" gorm:"type:text"`
+	ResultSummary string    `json:"result_summary" gorm:"type:text"`
+	Status        string    `json:"status" gorm:"type:varchar(20);index;comment:success|error|denied|not_found|pending_confirm|timeout|canceled"`
+	Error         string    `json:"error" gorm:"type:text"`
+	LatencyMs     int64     `json:"latency_ms"`
+	CreatedAt     time.Time `json:"created_at" gorm:"index"`
+}
+
 // ChatMessage 聊天消息模型
 type ChatMessage struct {
 	ID        uint   `json:"id" gorm:"primarykey"`
@@ -278,8 +523,8 @@ type ChatMessage struct {
 	Content string `json:"content" gorm:"type:text;not null"`
 
 	// 工具调用信息
-	ToolCallID    string  `json:"tool_call_id,omitempty" gorm:"type:varchar(64);index;comment:工具调用ID（Tool角色使用）"`
-	ToolCallsJSON *string `json:"tool_calls_json,omitempty" gorm:"type:json;column:tool_calls;comment:工具调用列表JSON（Assistant角色使用）"`
+	ToolCallID    string    `json:"tool_call_id,omitempty" gorm:"type:varchar(64);index;comment:工具调用ID（Tool角色使用）"`
+	ToolCallsJSON *JSONText `json:"tool_calls_json,omitempty" gorm:"column:tool_calls;comment:工具调用列表JSON（Assistant角色使用）"`
 
 	// 音频文件信息 (文件系统存储，两级hash打散)
 	AudioPath     string `json:"audio_path,omitempty" gorm:"type:varchar(512);comment:音频文件相对路径（两级hash打散）"`
@@ -288,7 +533,7 @@ type ChatMessage struct {
 	AudioFormat   string `json:"audio_format,omitempty" gorm:"type:varchar(20);default:'wav';comment:音频格式（固定为wav）"`
 
 	// 元数据
-	MetadataJSON string                 `json:"-" gorm:"type:json;column:metadata"`
+	MetadataJSON JSONText               `json:"-" gorm:"column:metadata"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty" gorm:"-"`
 
 	// 状态
@@ -308,7 +553,7 @@ func (m *ChatMessage) BeforeSave(tx *gorm.DB) error {
 		if err != nil {
 			return err
 		}
-		m.MetadataJSON = string(data)
+		m.MetadataJSON = JSONText(data)
 	}
 	return nil
 }
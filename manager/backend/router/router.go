@@ -2,6 +2,7 @@ package router
 
 import (
 	"io/fs"
+	"log"
 	"net/http"
 	"xiaozhi/manager/backend/config"
 	"xiaozhi/manager/backend/controllers"
@@ -23,13 +24,28 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	corsConfig.AllowCredentials = true
 	r.Use(cors.New(corsConfig))
 
+	// 加载 JWT 签名密钥，未配置时使用数据库中持久化的密钥；令牌注销状态保存在数据库
+	if err := middleware.EnsureJWTSecret(db, &cfg.JWT); err != nil {
+		log.Fatalf("JWT 配置错误: %v", err)
+	}
+	if err := middleware.InitJWT(cfg.JWT); err != nil {
+		log.Fatalf("JWT 配置错误: %v", err)
+	}
+	middleware.SetTokenDB(db)
+	middleware.SetTwoFactorPolicy(cfg.TwoFactor.EnforceAdmin)
+	middleware.SetAuditRetention(cfg.Audit.RetentionDays)
+	middleware.StartAuditRetention(db)
+	controllers.StartWebhookDispatcher(db)
+
 	// 初始化控制器
-	authController := &controllers.AuthController{DB: db}
+	authController := controllers.NewAuthController(db, cfg)
 	webSocketController := controllers.NewWebSocketController(db)
 	adminController := &controllers.AdminController{DB: db, WebSocketController: webSocketController}
 	userController := &controllers.UserController{DB: db, WebSocketController: webSocketController}
 	deviceActivationController := &controllers.DeviceActivationController{DB: db}
 	setupController := &controllers.SetupController{DB: db}
+	apiKeyController := &controllers.APIKeyController{DB: db}
+	webhookController := &controllers.WebhookController{DB: db}
 	speakerGroupController := controllers.NewSpeakerGroupController(db, cfg)
 	voiceCloneController := controllers.NewVoiceCloneController(db, cfg)
 	poolStatsController := controllers.NewPoolStatsController()
@@ -55,6 +71,11 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		// 公开路由（无需认证）
 		api.POST("/login", authController.Login)
 		api.POST("/register", authController.Register)
+		api.POST("/auth/refresh", authController.RefreshToken)
+		api.GET("/auth/oidc/config", authController.GetOIDCConfig)
+		api.GET("/auth/oidc/login", authController.OIDCLogin)
+		api.GET("/auth/oidc/callback", authController.OIDCCallback)
+		api.POST("/auth/oidc/exchange", authController.OIDCExchange)
 
 		// 数据库初始化相关路由（无需认证）
 		api.GET("/setup/status", setupController.CheckSetupStatus)
@@ -65,6 +86,9 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		api.GET("/public/device/activation-info", deviceActivationController.GetActivationInfo)
 		api.POST("/public/device/activate", deviceActivationController.ActivateDevice)
 
+		// MCP服务 OAuth 授权回调（由授权服务器重定向浏览器访问，通过 state 关联授权请求）
+		api.GET("/mcp-oauth/callback", adminController.MCPOAuthCallback)
+
 		// 内部服务接口（无需认证）
 		api.GET("/configs", adminController.GetDeviceConfigs)
 		api.GET("/system/configs", adminController.GetSystemConfigs)
@@ -77,9 +101,16 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 
 		// 需要认证的路由
 		auth := api.Group("")
-		auth.Use(middleware.JWTAuth())
+		auth.Use(middleware.JWTAuth(), middleware.Audit(db))
 		{
 			auth.GET("/profile", authController.GetProfile)
+			auth.POST("/auth/logout", authController.Logout)
+			auth.POST("/auth/revoke-all", authController.RevokeAllSessions)
+			auth.GET("/auth/2fa/status", authController.GetTwoFactorStatus)
+			auth.POST("/auth/2fa/setup", authController.SetupTwoFactor)
+			auth.POST("/auth/2fa/enable", authController.EnableTwoFactor)
+			auth.POST("/auth/2fa/disable", authController.DisableTwoFactor)
+			auth.POST("/auth/2fa/recovery-codes", authController.RegenerateRecoveryCodes)
 			// 通用接口，获取系统中的设备信息
 			auth.GET("/dashboard/stats", userController.GetDashboardStats)
 			// 设备角色接口（管理员和普通用户均可访问，控制器内做权限校验）
@@ -103,6 +134,10 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				user.PUT("/roles/:id", adminController.UpdateRoleNew)
 				user.DELETE("/roles/:id", adminController.DeleteRoleNew)
 				user.PATCH("/roles/:id/toggle", adminController.ToggleRoleStatus)
+				user.GET("/roles/:id/versions", adminController.GetRoleVersions)
+				user.GET("/roles/:id/versions/diff", adminController.DiffRoleVersions)
+				user.GET("/roles/:id/versions/:version", adminController.GetRoleVersion)
+				user.POST("/roles/:id/versions/:version/rollback", adminController.RollbackRoleVersion)
 
 				// 设备管理
 				user.GET("/devices", userController.GetMyDevices)
@@ -114,6 +149,10 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				user.GET("/agents/:id", userController.GetAgent)
 				user.PUT("/agents/:id", userController.UpdateAgent)
 				user.DELETE("/agents/:id", userController.DeleteAgent)
+				user.GET("/agents/:id/versions", adminController.GetAgentVersions)
+				user.GET("/agents/:id/versions/diff", adminController.DiffAgentVersions)
+				user.GET("/agents/:id/versions/:version", adminController.GetAgentVersion)
+				user.POST("/agents/:id/versions/:version/rollback", adminController.RollbackAgentVersion)
 				user.GET("/agents/:id/devices", userController.GetAgentDevices)
 				user.POST("/agents/:id/devices", userController.AddDeviceToAgent)
 				user.DELETE("/agents/:id/devices/:device_id", userController.RemoveDeviceFromAgent)
@@ -162,12 +201,23 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				// MCP接入点
 				user.GET("/agents/:id/mcp-services/options", userController.GetAgentMCPServiceOptions)
 				user.GET("/agents/:id/mcp-endpoint", userController.GetAgentMCPEndpoint)
+				user.POST("/agents/:id/mcp-server-token/rotate", userController.RotateAgentMCPServerToken)
 				user.GET("/agents/:id/openclaw-endpoint", userController.GetAgentOpenClawEndpoint)
 				user.POST("/agents/:id/openclaw-chat-test", userController.CallAgentOpenClawChatTest)
 				user.GET("/agents/:id/mcp-tools", userController.GetAgentMcpTools)
+				user.GET("/agents/:id/mcp-prompts", userController.GetAgentMCPPrompts)
+				user.POST("/agents/:id/mcp-prompts/render", userController.RenderAgentMCPPrompt)
 				user.POST("/agents/:id/mcp-call", userController.CallAgentMcpTool)
+				user.GET("/agents/:id/memories", userController.GetAgentMemories)
+				user.GET("/agents/:id/memories/export", userController.ExportAgentMemories)
+				user.GET("/agents/:id/memories/audit-logs", userController.GetAgentMemoryAuditLogs)
+				user.POST("/agents/:id/memories/forget", userController.ForgetAgentMemories)
+				user.PUT("/agents/:id/memories/:memory_id", userController.UpdateAgentMemory)
+				user.DELETE("/agents/:id/memories/:memory_id", userController.DeleteAgentMemory)
 				user.GET("/devices/:id/mcp-tools", userController.GetDeviceMcpTools)
 				user.POST("/devices/:id/mcp-call", userController.CallDeviceMcpTool)
+				user.GET("/mcp-tool-calls", userController.GetMCPToolCallLogs)
+				user.POST("/mcp-tool-calls/:id/replay", userController.ReplayMCPToolCall)
 
 				// 消息注入
 				user.POST("/devices/inject-message", userController.InjectMessage)
@@ -186,6 +236,22 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				user.GET("/speaker-groups/:id/samples/:sample_id/file", speakerGroupController.GetSampleFile)
 				user.DELETE("/speaker-groups/:id/samples/:sample_id", speakerGroupController.DeleteSample)
 
+				// 个人 API Key（只能通过登录令牌管理，API Key 本身无权访问）
+				user.GET("/api-keys/scopes", apiKeyController.GetAPIKeyScopes)
+				user.GET("/api-keys", apiKeyController.ListAPIKeys)
+				user.POST("/api-keys", apiKeyController.CreateAPIKey)
+				user.DELETE("/api-keys/:id", apiKeyController.DeleteAPIKey)
+
+				// 出站 Webhook
+				user.GET("/webhooks/events", webhookController.GetWebhookEvents)
+				user.GET("/webhooks", webhookController.ListWebhooks)
+				user.POST("/webhooks", webhookController.CreateWebhook)
+				user.PUT("/webhooks/:id", webhookController.UpdateWebhook)
+				user.DELETE("/webhooks/:id", webhookController.DeleteWebhook)
+				user.POST("/webhooks/:id/test", webhookController.TestWebhook)
+				user.GET("/webhooks/:id/deliveries", webhookController.ListWebhookDeliveries)
+				user.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", webhookController.RedeliverWebhookDelivery)
+
 				// 聊天历史
 				user.GET("/history/messages", chatHistoryController.GetMessages)
 				user.DELETE("/history/messages/:id", chatHistoryController.DeleteMessage)
@@ -196,7 +262,8 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 
 			// 管理员路由
 			admin := auth.Group("/admin")
-			admin.Use(middleware.AdminAuth())
+			// 平台管理员可访问全部接口；组织内角色按权限访问本组织数据
+			admin.Use(middleware.AdminRBAC())
 			{
 				// 通用配置管理
 				admin.GET("/configs", adminController.GetConfigs)
@@ -204,6 +271,10 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				admin.GET("/configs/:id", adminController.GetConfig)
 				admin.PUT("/configs/:id", adminController.UpdateConfig)
 				admin.DELETE("/configs/:id", adminController.DeleteConfig)
+				admin.GET("/configs/:id/versions", adminController.GetConfigVersions)
+				admin.GET("/configs/:id/versions/diff", adminController.DiffConfigVersions)
+				admin.GET("/configs/:id/versions/:version", adminController.GetConfigVersion)
+				admin.POST("/configs/:id/versions/:version/rollback", adminController.RollbackConfigVersion)
 				admin.POST("/configs/:id/toggle", adminController.ToggleConfigEnable)
 
 				// 具体配置类型路由（兼容前端）
@@ -282,6 +353,9 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				admin.POST("/mcp-market/imported-services", adminController.CreateMCPMarketImportedService)
 				admin.PUT("/mcp-market/imported-services/:id", adminController.UpdateMCPMarketImportedService)
 				admin.DELETE("/mcp-market/imported-services/:id", adminController.DeleteMCPMarketImportedService)
+				admin.POST("/mcp-oauth/authorize", adminController.StartMCPOAuth)
+				admin.GET("/mcp-oauth/credentials", adminController.GetMCPOAuthCredentials)
+				admin.DELETE("/mcp-oauth/credentials/:id", adminController.DeleteMCPOAuthCredential)
 
 				// Memory配置管理
 				admin.GET("/memory-configs", adminController.GetMemoryConfigs)
@@ -309,6 +383,10 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				admin.POST("/roles/global", adminController.CreateRoleNew)
 				admin.PUT("/roles/global/:id", adminController.UpdateRoleNew)
 				admin.DELETE("/roles/global/:id", adminController.DeleteRoleNew)
+				admin.GET("/roles/global/:id/versions", adminController.GetRoleVersions)
+				admin.GET("/roles/global/:id/versions/diff", adminController.DiffRoleVersions)
+				admin.GET("/roles/global/:id/versions/:version", adminController.GetRoleVersion)
+				admin.POST("/roles/global/:id/versions/:version/rollback", adminController.RollbackRoleVersion)
 				admin.PATCH("/roles/global/:id/toggle", adminController.ToggleRoleStatus)
 				admin.PATCH("/roles/global/:id/default", adminController.SetDefaultRole)
 
@@ -324,13 +402,28 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				admin.POST("/agents", adminController.CreateAgent)
 				admin.PUT("/agents/:id", adminController.UpdateAgent)
 				admin.DELETE("/agents/:id", adminController.DeleteAgent)
+				admin.GET("/agents/:id/versions", adminController.GetAgentVersions)
+				admin.GET("/agents/:id/versions/diff", adminController.DiffAgentVersions)
+				admin.GET("/agents/:id/versions/:version", adminController.GetAgentVersion)
+				admin.POST("/agents/:id/versions/:version/rollback", adminController.RollbackAgentVersion)
 				admin.GET("/agents/:id/mcp-endpoint", adminController.GetAgentMCPEndpoint)
+				admin.POST("/agents/:id/mcp-server-token/rotate", adminController.RotateAgentMCPServerToken)
 				admin.GET("/agents/:id/openclaw-endpoint", adminController.GetAgentOpenClawEndpoint)
 				admin.POST("/agents/:id/openclaw-chat-test", adminController.CallAgentOpenClawChatTest)
 				admin.GET("/agents/:id/mcp-tools", adminController.GetAgentMcpTools)
+				admin.GET("/agents/:id/mcp-prompts", adminController.GetAgentMCPPrompts)
+				admin.POST("/agents/:id/mcp-prompts/render", adminController.RenderAgentMCPPrompt)
+				admin.GET("/agents/:id/memories", adminController.GetAgentMemories)
+				admin.GET("/agents/:id/memories/export", adminController.ExportAgentMemories)
+				admin.GET("/agents/:id/memories/audit-logs", adminController.GetAgentMemoryAuditLogs)
+				admin.POST("/agents/:id/memories/forget", adminController.ForgetAgentMemories)
+				admin.PUT("/agents/:id/memories/:memory_id", adminController.UpdateAgentMemory)
+				admin.DELETE("/agents/:id/memories/:memory_id", adminController.DeleteAgentMemory)
 				admin.POST("/agents/:id/mcp-call", adminController.CallAgentMcpTool)
 				admin.GET("/devices/:id/mcp-tools", adminController.GetDeviceMcpTools)
 				admin.POST("/devices/:id/mcp-call", adminController.CallDeviceMcpTool)
+				admin.GET("/mcp-tool-calls", adminController.GetMCPToolCallLogs)
+				admin.POST("/mcp-tool-calls/:id/replay", adminController.ReplayMCPToolCall)
 
 				// 用户管理
 				admin.GET("/users", adminController.GetUsers)
@@ -338,6 +431,8 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				admin.PUT("/users/:id", adminController.UpdateUser)
 				admin.DELETE("/users/:id", adminController.DeleteUser)
 				admin.POST("/users/:id/reset-password", adminController.ResetUserPassword)
+				admin.POST("/users/:id/revoke-sessions", adminController.RevokeUserSessions)
+				admin.POST("/users/:id/reset-2fa", adminController.ResetUserTwoFactor)
 
 				admin.GET("/users/:id/knowledge-bases", adminController.GetUserKnowledgeBasesAdmin)
 				admin.POST("/users/:id/knowledge-bases", adminController.CreateUserKnowledgeBaseAdmin)
@@ -347,6 +442,16 @@ func Setup(db *gorm.DB, cfg *config.Config) *gin.Engine {
 				admin.GET("/users/:id/voice-clone-quotas", adminController.GetUserVoiceCloneQuotas)
 				admin.PUT("/users/:id/voice-clone-quotas", adminController.UpdateUserVoiceCloneQuotas)
 
+				// 组织（租户）管理
+				admin.GET("/organizations", adminController.GetOrganizations)
+				admin.POST("/organizations", adminController.CreateOrganization)
+				admin.PUT("/organizations/:id", adminController.UpdateOrganization)
+				admin.DELETE("/organizations/:id", adminController.DeleteOrganization)
+
+				// 审计日志
+				admin.GET("/audit-logs", adminController.GetAuditLogs)
+				admin.GET("/audit-logs/:id", adminController.GetAuditLog)
+
 				// 配置导入导出
 				admin.GET("/configs/export", adminController.ExportConfigs)
 				admin.POST("/configs/import", adminController.ImportConfigs)
@@ -0,0 +1,163 @@
+// Package jsondiff 按字段比较数据库记录的 JSON 快照。
+// 嵌套的对象和数组（包括 Config.JsonData 这类内容为 JSON 的字符串字段）展开为点分路径，
+// 数组元素按下标；任意层级的敏感字段（密码、密钥、令牌、API Key）的值替换为 Masked
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Masked 敏感字段脱敏后的值
+const Masked = "******"
+
+// sensitiveSuffixes 字段名（小写，- 视为 _）以这些结尾时脱敏，
+// 如 token、access_token、client_secret、x-api-key；按后缀匹配，max_tokens 这类复数计数字段不受影响
+var sensitiveSuffixes = []string{
+	"password", "passwd", "secret", "token", "api_key", "apikey", "access_key", "accesskey",
+	"secret_key", "secretkey", "private_key", "authorization", "credential", "credentials",
+	"password_hash", "token_hash", "key_hash", "ticket_hash",
+}
+
+// nonSensitiveKeys 命中后缀但不是密钥的字段，保持原值以便追踪模型参数的变化
+var nonSensitiveKeys = map[string]bool{
+	"max_tokens":            true,
+	"max_completion_tokens": true,
+	"max_output_tokens":     true,
+	"max_new_tokens":        true,
+	"context_window_tokens": true,
+}
+
+// Change 一个字段变更前后的值
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Parse 解析 JSON 对象并展开内容为 JSON 的字符串字段，文档为空或无效时返回 nil
+func Parse(raw string) map[string]interface{} {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil
+	}
+	return Expand(m)
+}
+
+// Expand 将内容为 JSON 对象或数组的字符串字段原地替换为解析后的值，以便逐字段比较和脱敏
+func Expand(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+			continue
+		}
+		var nested interface{}
+		if json.Unmarshal([]byte(s), &nested) == nil {
+			m[k] = nested
+		}
+	}
+	return m
+}
+
+// Sensitive 判断该字段的值是否需要脱敏
+func Sensitive(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+	if nonSensitiveKeys[key] {
+		return false
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask 返回 m 的副本，其中非空的敏感字段替换为 Masked
+func Mask(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if Sensitive(k) && v != nil && v != "" {
+			out[k] = Masked
+			continue
+		}
+		out[k] = maskValue(v)
+	}
+	return out
+}
+
+func maskValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return Mask(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = maskValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// Diff 比较两个快照，返回以点分路径为键的变更字段；ignore 中的字段在任意层级都跳过，
+// 敏感字段变更时前后值均脱敏
+func Diff(before, after map[string]interface{}, ignore ...string) map[string]Change {
+	diff := map[string]Change{}
+	diffInto(diff, "", before, after, ignore)
+	return diff
+}
+
+func diffInto(diff map[string]Change, prefix string, before, after map[string]interface{}, ignore []string) {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	for _, k := range ignore {
+		delete(keys, k)
+	}
+	for k := range keys {
+		diffValue(diff, prefix+k, Sensitive(k), before[k], after[k], ignore)
+	}
+}
+
+// diffValue 记录一个值的变更：对象逐字段比较，长度相同的数组逐元素比较，
+// 长度变化的数组整体记录，其中的敏感字段脱敏
+func diffValue(diff map[string]Change, path string, sensitive bool, b, a interface{}, ignore []string) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+	if sensitive {
+		diff[path] = Change{Before: Masked, After: Masked}
+		return
+	}
+	switch bv := b.(type) {
+	case map[string]interface{}:
+		if av, ok := a.(map[string]interface{}); ok {
+			diffInto(diff, path+".", bv, av, ignore)
+			return
+		}
+	case []interface{}:
+		if av, ok := a.([]interface{}); ok && len(av) == len(bv) {
+			for i := range bv {
+				diffValue(diff, path+"."+strconv.Itoa(i), false, bv[i], av[i], ignore)
+			}
+			return
+		}
+	}
+	diff[path] = Change{Before: maskValue(b), After: maskValue(a)}
+}
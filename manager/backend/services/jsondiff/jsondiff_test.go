@@ -0,0 +1,102 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffMasksSensitiveFields(t *testing.T) {
+	before := Parse(`{"name":"qwen","updated_at":"2025-01-01","json_data":"{\"api_key\":\"sk-old\",\"model\":\"qwen-plus\"}"}`)
+	after := Parse(`{"name":"qwen","updated_at":"2025-01-02","json_data":"{\"api_key\":\"sk-new\",\"model\":\"qwen-max\"}"}`)
+
+	want := map[string]Change{
+		"json_data.api_key": {Before: Masked, After: Masked},
+		"json_data.model":   {Before: "qwen-plus", After: "qwen-max"},
+	}
+	if got := Diff(before, after, "updated_at"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+	masked := Mask(after)["json_data"].(map[string]interface{})
+	if masked["api_key"] != Masked || masked["model"] != "qwen-max" {
+		t.Errorf("Mask = %v", masked)
+	}
+}
+
+func TestDiffAddedAndRemovedFields(t *testing.T) {
+	got := Diff(map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": "x"})
+	want := map[string]Change{
+		"a": {Before: 1.0, After: nil},
+		"b": {Before: nil, After: "x"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+}
+
+func TestDiffMasksSecretsInArrays(t *testing.T) {
+	before := Parse(`{"json_data":"{\"servers\":[{\"name\":\"ha\",\"headers\":{\"Authorization\":\"Bearer old\"},\"env\":{\"HA_TOKEN\":\"t1\"}}]}"}`)
+	after := Parse(`{"json_data":"{\"servers\":[{\"name\":\"home\",\"headers\":{\"Authorization\":\"Bearer new\"},\"env\":{\"HA_TOKEN\":\"t2\"}}]}"}`)
+
+	want := map[string]Change{
+		"json_data.servers.0.name":                  {Before: "ha", After: "home"},
+		"json_data.servers.0.headers.Authorization": {Before: Masked, After: Masked},
+		"json_data.servers.0.env.HA_TOKEN":          {Before: Masked, After: Masked},
+	}
+	if got := Diff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+
+	// 数组长度变化时整体记录，元素中的敏感字段同样脱敏
+	added := Parse(`{"json_data":"{\"servers\":[{\"name\":\"ha\",\"headers\":{\"Authorization\":\"Bearer old\"}},{\"name\":\"x\",\"headers\":{\"Authorization\":\"Bearer x\"}}]}"}`)
+	change := Diff(before, added)["json_data.servers"]
+	servers, ok := change.After.([]interface{})
+	if !ok || len(servers) != 2 {
+		t.Fatalf("Diff servers = %v", change)
+	}
+	for _, server := range servers {
+		if auth := server.(map[string]interface{})["headers"].(map[string]interface{})["Authorization"]; auth != Masked {
+			t.Errorf("Authorization = %v, want masked", auth)
+		}
+	}
+
+	masked := Mask(after)["json_data"].(map[string]interface{})["servers"].([]interface{})[0].(map[string]interface{})
+	if masked["headers"].(map[string]interface{})["Authorization"] != Masked || masked["env"].(map[string]interface{})["HA_TOKEN"] != Masked {
+		t.Errorf("Mask = %v", masked)
+	}
+}
+
+func TestSensitive(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"password", true},
+		{"api_key", true},
+		{"apiKey", true},
+		{"X-Api-Key", true},
+		{"token", true},
+		{"access_token", true},
+		{"refresh_token", true},
+		{"HA_TOKEN", true},
+		{"client_secret", true},
+		{"secret_key", true},
+		{"Authorization", true},
+		{"max_tokens", false},
+		{"context_window_tokens", false},
+		{"max_completion_tokens", false},
+		{"token_count", false},
+		{"model", false},
+		{"prompt", false},
+	}
+	for _, tt := range tests {
+		if got := Sensitive(tt.key); got != tt.want {
+			t.Errorf("Sensitive(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	got := Diff(Parse(`{"json_data":"{\"max_tokens\":1024,\"api_key\":\"sk-a\"}"}`), Parse(`{"json_data":"{\"max_tokens\":2048,\"api_key\":\"sk-a\"}"}`))
+	want := map[string]Change{"json_data.max_tokens": {Before: 1024.0, After: 2048.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+}
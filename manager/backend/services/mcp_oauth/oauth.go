@@ -0,0 +1,337 @@
+package mcp_oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	httpTimeout = 15 * time.Second
+	// maxResponseBytes 从远端读取元数据和令牌响应的最大字节数
+	maxResponseBytes = 1 << 20
+
+	clientName = "xiaozhi-esp32-server"
+)
+
+// ServerMetadata 一个 MCP 服务器的授权发现结果
+type ServerMetadata struct {
+	Resource              string   `json:"resource"`
+	AuthorizationServer   string   `json:"authorization_server"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	RegistrationEndpoint  string   `json:"registration_endpoint,omitempty"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+}
+
+// ClientCredentials MCP 服务器使用的 OAuth 客户端，动态注册或手动填写
+type ClientCredentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// Token 令牌端点的响应
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// ExpiresAt 返回令牌的过期时间，服务器未返回有效期时为 nil
+func (t *Token) ExpiresAt(now time.Time) *time.Time {
+	if t.ExpiresIn <= 0 {
+		return nil
+	}
+	expiresAt := now.Add(time.Duration(t.ExpiresIn) * time.Second)
+	return &expiresAt
+}
+
+type protectedResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported"`
+}
+
+type authorizationServerMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	RegistrationEndpoint  string   `json:"registration_endpoint"`
+	ScopesSupported       []string `json:"scopes_supported"`
+}
+
+// Discover 查找 MCP 服务器的授权服务器：依次尝试未授权请求返回的 WWW-Authenticate resource_metadata、
+// RFC 9728 受保护资源元数据、RFC 8414 / OpenID 发现文档，都没有时使用授权服务器上默认的
+// /authorize、/token、/register 端点
+func Discover(ctx context.Context, serverURL string) (*ServerMetadata, error) {
+	resourceURL, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil || resourceURL.Scheme == "" || resourceURL.Host == "" {
+		return nil, fmt.Errorf("MCP服务URL格式不正确")
+	}
+
+	metadataURLs := make([]string, 0, 3)
+	if hint := probeResourceMetadataURL(ctx, resourceURL.String()); hint != "" {
+		metadataURLs = append(metadataURLs, hint)
+	}
+	origin := resourceURL.Scheme + "://" + resourceURL.Host
+	if path := strings.TrimSuffix(resourceURL.Path, "/"); path != "" {
+		metadataURLs = append(metadataURLs, origin+"/.well-known/oauth-protected-resource"+path)
+	}
+	metadataURLs = append(metadataURLs, origin+"/.well-known/oauth-protected-resource")
+
+	meta := &ServerMetadata{Resource: resourceURL.String(), AuthorizationServer: origin}
+	for _, metadataURL := range metadataURLs {
+		var prm protectedResourceMetadata
+		if err := getJSON(ctx, metadataURL, &prm); err != nil || len(prm.AuthorizationServers) == 0 {
+			continue
+		}
+		meta.AuthorizationServer = strings.TrimSuffix(prm.AuthorizationServers[0], "/")
+		if prm.Resource != "" {
+			meta.Resource = prm.Resource
+		}
+		meta.ScopesSupported = prm.ScopesSupported
+		break
+	}
+
+	asm, err := discoverAuthorizationServer(ctx, meta.AuthorizationServer)
+	if err != nil {
+		return nil, err
+	}
+	meta.AuthorizationEndpoint = asm.AuthorizationEndpoint
+	meta.TokenEndpoint = asm.TokenEndpoint
+	meta.RegistrationEndpoint = asm.RegistrationEndpoint
+	if len(meta.ScopesSupported) == 0 {
+		meta.ScopesSupported = asm.ScopesSupported
+	}
+	return meta, nil
+}
+
+// probeResourceMetadataURL 发送未授权请求，从 401 响应的认证质询中读取 resource_metadata
+func probeResourceMetadataURL(ctx context.Context, resourceURL string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := (&http.Client{Timeout: httpTimeout}).Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return ""
+	}
+	return ParseResourceMetadataHint(resp.Header.Get("WWW-Authenticate"))
+}
+
+// ParseResourceMetadataHint 从 WWW-Authenticate 头中提取 resource_metadata="..."
+func ParseResourceMetadataHint(header string) string {
+	const key = "resource_metadata="
+	idx := strings.Index(header, key)
+	if idx < 0 {
+		return ""
+	}
+	value := header[idx+len(key):]
+	if strings.HasPrefix(value, `"`) {
+		value = value[1:]
+		if end := strings.Index(value, `"`); end >= 0 {
+			return value[:end]
+		}
+		return ""
+	}
+	if end := strings.IndexAny(value, ", "); end >= 0 {
+		value = value[:end]
+	}
+	return value
+}
+
+func discoverAuthorizationServer(ctx context.Context, issuer string) (*authorizationServerMetadata, error) {
+	issuerURL, err := url.Parse(issuer)
+	if err != nil || issuerURL.Host == "" {
+		return nil, fmt.Errorf("授权服务器地址格式不正确: %s", issuer)
+	}
+	origin := issuerURL.Scheme + "://" + issuerURL.Host
+	path := strings.TrimSuffix(issuerURL.Path, "/")
+
+	candidates := []string{
+		origin + "/.well-known/oauth-authorization-server" + path,
+		origin + "/.well-known/openid-configuration" + path,
+	}
+	if path != "" {
+		candidates = append(candidates, origin+path+"/.well-known/openid-configuration")
+	}
+	for _, candidate := range candidates {
+		var asm authorizationServerMetadata
+		if err := getJSON(ctx, candidate, &asm); err == nil && asm.AuthorizationEndpoint != "" && asm.TokenEndpoint != "" {
+			return &asm, nil
+		}
+	}
+
+	// 没有元数据的授权服务器使用 MCP 规范定义的默认端点
+	return &authorizationServerMetadata{
+		Issuer:                origin,
+		AuthorizationEndpoint: origin + "/authorize",
+		TokenEndpoint:         origin + "/token",
+		RegistrationEndpoint:  origin + "/register",
+	}, nil
+}
+
+// RegisterClient 按 RFC 7591 动态注册使用 PKCE 的公开客户端
+func RegisterClient(ctx context.Context, meta *ServerMetadata, redirectURI string) (*ClientCredentials, error) {
+	if meta.RegistrationEndpoint == "" {
+		return nil, fmt.Errorf("授权服务器不支持动态注册客户端，请手动填写 client_id")
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"client_name":                clientName,
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.RegistrationEndpoint, strings.NewReader(string(payload)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var creds ClientCredentials
+	if err := doJSON(req, &creds); err != nil {
+		return nil, fmt.Errorf("动态注册客户端失败: %w", err)
+	}
+	if creds.ClientID == "" {
+		return nil, fmt.Errorf("动态注册客户端失败: 响应缺少 client_id")
+	}
+	return &creds, nil
+}
+
+// NewPKCE 生成随机 code_verifier 及其 S256 code_challenge
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewState 生成授权请求的随机 state
+func NewState() (string, error) {
+	return randomString(24)
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// AuthorizeURL 构造授权码请求地址，带 PKCE 和 RFC 8707 resource 参数
+func AuthorizeURL(meta *ServerMetadata, clientID, redirectURI, state, challenge, scope string) (string, error) {
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("授权端点格式不正确: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	q.Set("resource", meta.Resource)
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ExchangeCode 用授权码换取令牌
+func ExchangeCode(ctx context.Context, tokenEndpoint string, client ClientCredentials, code, verifier, redirectURI, resource string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("code_verifier", verifier)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("resource", resource)
+	return requestToken(ctx, tokenEndpoint, client, form)
+}
+
+// Refresh 用刷新令牌换取新的访问令牌，服务器可能同时轮换刷新令牌
+func Refresh(ctx context.Context, tokenEndpoint string, client ClientCredentials, refreshToken, resource string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	form.Set("resource", resource)
+	return requestToken(ctx, tokenEndpoint, client, form)
+}
+
+func requestToken(ctx context.Context, tokenEndpoint string, client ClientCredentials, form url.Values) (*Token, error) {
+	form.Set("client_id", client.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if client.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(client.ClientID), url.QueryEscape(client.ClientSecret))
+	}
+
+	var token Token
+	if err := doJSON(req, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("令牌响应缺少 access_token")
+	}
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	return &token, nil
+}
+
+func getJSON(ctx context.Context, endpoint string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := (&http.Client{Timeout: httpTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return fmt.Errorf("HTTP %d: %s %s", resp.StatusCode, oauthErr.Error, oauthErr.ErrorDescription)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,100 @@
+package mcp_oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseResourceMetadataHint(t *testing.T) {
+	cases := map[string]string{
+		`Bearer resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource"`: "https://mcp.example.com/.well-known/oauth-protected-resource",
+		`Bearer realm="mcp", resource_metadata=https://a.example/prm, error="invalid_token"`:      "https://a.example/prm",
+		`Bearer realm="mcp"`: "",
+	}
+	for header, want := range cases {
+		if got := ParseResourceMetadataHint(header); got != want {
+			t.Errorf("ParseResourceMetadataHint(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestDiscoverAndExchange(t *testing.T) {
+	var server *httptest.Server
+	var gotForm map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+server.URL+`/prm"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/prm", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"resource":              server.URL + "/mcp",
+			"authorization_servers": []string{server.URL + "/auth"},
+			"scopes_supported":      []string{"tools"},
+		})
+	})
+	mux.HandleFunc("/.well-known/oauth-authorization-server/auth", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 server.URL + "/auth",
+			"authorization_endpoint": server.URL + "/auth/authorize",
+			"token_endpoint":         server.URL + "/auth/token",
+			"registration_endpoint":  server.URL + "/auth/register",
+		})
+	})
+	mux.HandleFunc("/auth/register", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"client_id": "dyn-client"})
+	})
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		gotForm = map[string]string{}
+		for key := range r.PostForm {
+			gotForm[key] = r.PostForm.Get(key)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-1",
+			"refresh_token": "refresh-1",
+			"expires_in":    3600,
+		})
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	meta, err := Discover(ctx, server.URL+"/mcp")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if meta.TokenEndpoint != server.URL+"/auth/token" || meta.Resource != server.URL+"/mcp" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	client, err := RegisterClient(ctx, meta, "http://manager/api/mcp-oauth/callback")
+	if err != nil || client.ClientID != "dyn-client" {
+		t.Fatalf("RegisterClient: %+v, %v", client, err)
+	}
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Fatalf("challenge does not match verifier")
+	}
+
+	token, err := ExchangeCode(ctx, meta.TokenEndpoint, *client, "code-1", verifier, "http://manager/api/mcp-oauth/callback", meta.Resource)
+	if err != nil {
+		t.Fatalf("ExchangeCode: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.TokenType != "Bearer" || token.ExpiresIn != 3600 {
+		t.Fatalf("unexpected token: %+v", token)
+	}
+	if gotForm["code_verifier"] != verifier || gotForm["resource"] != meta.Resource || gotForm["client_id"] != "dyn-client" {
+		t.Fatalf("unexpected token request: %+v", gotForm)
+	}
+}
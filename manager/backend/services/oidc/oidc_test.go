@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// mockIdP 最简的 OpenID Provider，对任意授权码签发 id_token
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	nonce  string
+	form   url.Values
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.form = r.PostForm
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":                idp.server.URL,
+			"aud":                "console",
+			"sub":                "user-1",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              idp.nonce,
+			"preferred_username": "alice",
+			"groups":             []string{"staff", "ops-admins"},
+		})
+		token.Header["kid"] = "k1"
+		signed, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "access_token": "at"})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := NewProvider(Config{Issuer: idp.server.URL, ClientID: "console", RedirectURL: "http://manager/api/auth/oidc/callback"})
+	ctx := context.Background()
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(authURL)
+	if q := parsed.Query(); q.Get("code_challenge") != challenge || q.Get("nonce") != "nonce-1" || q.Get("scope") != "openid profile email" {
+		t.Fatalf("unexpected authorize url: %s", authURL)
+	}
+
+	idp.nonce = "nonce-1"
+	claims, err := provider.Exchange(ctx, "code-1", verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if idp.form.Get("code_verifier") != verifier {
+		t.Fatalf("code_verifier not sent")
+	}
+	if StringClaim(claims, "preferred_username") != "alice" || len(StringsClaim(claims, "groups")) != 2 {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+
+	// nonce 不匹配时拒绝
+	idp.nonce = "other"
+	if _, err := provider.Exchange(ctx, "code-2", verifier, "nonce-1"); err == nil {
+		t.Fatal("expected nonce mismatch error")
+	}
+}
@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中的 SHA1 测试密钥
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAtRFC6238(t *testing.T) {
+	// RFC 6238 给出的是8位验证码，6位验证码取其后六位
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		got, err := CodeAt(rfc6238Secret, Counter(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt: %v", err)
+		}
+		if got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, _ := CodeAt(rfc6238Secret, Counter(now))
+
+	if counter, ok := Validate(rfc6238Secret, code, now.Add(Period)); !ok || counter != Counter(now) {
+		t.Fatalf("code from the previous period should be accepted, got %d %v", counter, ok)
+	}
+	if _, ok := Validate(rfc6238Secret, code, now.Add(3*Period)); ok {
+		t.Fatal("code three periods old should be rejected")
+	}
+	if _, ok := Validate(rfc6238Secret, "12345", now); ok {
+		t.Fatal("short code should be rejected")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("unexpected format %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != 10 {
+		t.Fatal("recovery codes should be unique")
+	}
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))) {
+		t.Fatal("hash should ignore case, spaces and dashes")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Xiaozhi", "admin", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Xiaozhi:admin?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+}
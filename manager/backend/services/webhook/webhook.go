@@ -0,0 +1,192 @@
+// Package webhook 对外推送 Webhook 请求并签名。
+//
+// 每次推送都是 JSON POST，请求头带事件名、投递 ID 和 Unix 时间戳；X-Xiaozhi-Signature 为
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))，接收方可同时校验内容和时效。
+//
+// Webhook 地址由用户填写，投递时不连接回环、私有网段、链路本地和未指定地址（DNS 解析后检查），
+// 也不跟随重定向。
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Xiaozhi-Event"
+	HeaderDelivery  = "X-Xiaozhi-Delivery"
+	HeaderTimestamp = "X-Xiaozhi-Timestamp"
+	HeaderSignature = "X-Xiaozhi-Signature"
+
+	// MaxAttempts 最多尝试次数，用完后标记为失败
+	MaxAttempts = 6
+	// Timeout 单次投递的超时时间
+	Timeout = 10 * time.Second
+
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 2 * time.Hour
+	maxResponseBody = 2048
+	secretBytes     = 24
+)
+
+// ErrBlockedAddress Webhook 地址指向本机或内网时返回
+var ErrBlockedAddress = errors.New("不允许推送到本机或内网地址")
+
+// BlockedAddr 判断是否拒绝向 ip 推送：回环、私有网段、链路本地、组播和未指定地址
+func BlockedAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// dialControl 在 DNS 解析后对每个要连接的地址执行，公网域名解析到内网地址时同样拒绝
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if BlockedAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// NewClient 返回投递用的 HTTP 客户端：不使用代理、拒绝内网地址、不跟随重定向，
+// 3xx 响应按投递失败处理
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: Timeout, Control: dialControl}
+	return &http.Client{
+		Timeout: Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: Timeout,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Result 一次投递的结果
+type Result struct {
+	StatusCode int
+	Response   string // 截断后的响应内容
+	Duration   time.Duration
+}
+
+// OK 接收方是否已接收（任意 2xx 状态码）
+func (r Result) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// GenerateSecret 生成随机的签名密钥
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign 计算 body 在 timestamp 时刻发送的签名头
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 用 secret 校验签名是否与 body 和 timestamp 匹配
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff 第 attempt 次投递失败后到下次重试的间隔：30秒、1分钟、2分钟、4分钟……最长2小时
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := baseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Deliver 带签名头向 url POST body；返回错误表示请求未完成（DNS、连接失败、超时），
+// 接收方返回的错误状态码通过 Result 给出
+func Deliver(ctx context.Context, client *http.Client, url, secret, event, deliveryID string, body []byte) (Result, error) {
+	if client == nil {
+		client = NewClient()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "xiaozhi-webhook/1.0")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return Result{
+		StatusCode: resp.StatusCode,
+		Response:   strings.TrimSpace(string(data)),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// ValidateURL 校验 raw 为完整的 http(s) 地址，且主机不是 localhost 或内网 IP；
+// 域名在投递时按解析结果再次检查
+func ValidateURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		return fmt.Errorf("Webhook 地址需以 http:// 或 https:// 开头")
+	}
+	req, err := http.NewRequest(http.MethodPost, raw, nil)
+	if err != nil {
+		return err
+	}
+	host := req.URL.Hostname()
+	if host == "" {
+		return fmt.Errorf("Webhook 地址缺少主机名")
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrBlockedAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && BlockedAddr(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDeliverSignsPayload(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"event":"device.online"}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderEvent) != "device.online" || r.Header.Get(HeaderDelivery) != "d1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !Verify(secret, ts, got, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	res, err := Deliver(context.Background(), srv.Client(), srv.URL, secret, "device.online", "d1", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() || res.Response != "ok" {
+		t.Fatalf("Deliver = %+v", res)
+	}
+	if Verify("other", time.Now().Unix(), body, Sign(secret, time.Now().Unix(), body)) {
+		t.Error("signature verified with wrong secret")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		20: 2 * time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, raw := range []string{"https://example.com/hook", "http://93.184.216.34:8123/api/webhook/x"} {
+		if err := ValidateURL(raw); err != nil {
+			t.Errorf("ValidateURL(%q) = %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "ftp://example.com", "https://", "example.com",
+		"http://localhost:8080/", "http://127.0.0.1/", "http://10.0.0.2:8123/api/webhook/x",
+		"http://169.254.169.254/latest/meta-data", "http://[::1]/", "http://[::ffff:192.168.1.1]/", "http://0.0.0.0/"} {
+		if err := ValidateURL(raw); err == nil {
+			t.Errorf("ValidateURL(%q) accepted", raw)
+		}
+	}
+}
+
+func TestNewClientBlocksInternalAddresses(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// 回环地址在 DNS 解析后被拒绝，请求不会到达接收方
+	_, err := Deliver(context.Background(), nil, srv.URL, "", "device.online", "d1", []byte(`{}`))
+	if !errors.Is(err, ErrBlockedAddress) || calls != 0 {
+		t.Fatalf("Deliver to loopback: err=%v calls=%d", err, calls)
+	}
+
+	// 不跟随重定向，3xx 作为失败返回
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL, http.StatusFound)
+	}))
+	defer redirect.Close()
+	client := redirect.Client()
+	client.CheckRedirect = NewClient().CheckRedirect
+	res, err := Deliver(context.Background(), client, redirect.URL, "", "device.online", "d1", []byte(`{}`))
+	if err != nil || res.StatusCode != http.StatusFound || res.OK() || calls != 0 {
+		t.Fatalf("Deliver redirect = %+v, err=%v calls=%d", res, err, calls)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"xiaozhi/manager/backend/config"
 	"xiaozhi/manager/backend/storage/mysql"
+	"xiaozhi/manager/backend/storage/postgres"
 	"xiaozhi/manager/backend/storage/sqlite"
 )
 
@@ -12,8 +13,9 @@ import (
 type StorageType string
 
 const (
-	StorageTypeMySQL  StorageType = "mysql"
-	StorageTypeSQLite StorageType = "sqlite"
+	StorageTypeMySQL    StorageType = "mysql"
+	StorageTypeSQLite   StorageType = "sqlite"
+	StorageTypePostgres StorageType = "postgres"
 )
 
 // Factory 存储工厂
@@ -70,6 +72,21 @@ func CreateStorage(dbConfig config.DatabaseConfig) (*StorageAdapter, error) {
 		// 返回适配器
 		return NewStorageAdapter(baseStorage), nil
 
+	case StorageTypePostgres:
+		// 验证PostgreSQL配置
+		if err := postgres.ValidateConfig(dbConfig.Postgres); err != nil {
+			return nil, fmt.Errorf("invalid PostgreSQL config: %w", err)
+		}
+		// 创建PostgreSQL存储
+		postgresStorage, err := postgres.NewStorage(postgres.NewConfigFromDatabase(dbConfig.Postgres))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PostgreSQL storage: %w", err)
+		}
+		// 创建基础存储
+		baseStorage := NewGormBaseStorage(postgresStorage.DB)
+		// 返回适配器
+		return NewStorageAdapter(baseStorage), nil
+
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", storageType)
 	}
@@ -80,5 +97,6 @@ func (f *Factory) GetSupportedTypes() []StorageType {
 	return []StorageType{
 		StorageTypeMySQL,
 		StorageTypeSQLite,
+		StorageTypePostgres,
 	}
 }
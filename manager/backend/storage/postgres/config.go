@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"fmt"
+	"xiaozhi/manager/backend/config"
+)
+
+// Config PostgreSQL配置
+type Config struct {
+	Database        *config.PostgresConfig `json:"database"`
+	MaxIdleConns    int                    `json:"max_idle_conns"`
+	MaxOpenConns    int                    `json:"max_open_conns"`
+	ConnMaxLifetime int                    `json:"conn_max_lifetime"`
+}
+
+// NewConfigFromDatabase 从数据库配置创建PostgreSQL配置
+func NewConfigFromDatabase(cfg *config.PostgresConfig) *Config {
+	return &Config{
+		Database:        cfg,
+		MaxIdleConns:    10,
+		MaxOpenConns:    100,
+		ConnMaxLifetime: 3600,
+	}
+}
+
+// DSN 生成数据源名称
+func (c *Config) DSN() string {
+	return c.Database.DSN()
+}
+
+// Validate 验证配置
+func (c *Config) Validate() error {
+	return ValidateConfig(c.Database)
+}
+
+// ValidateConfig 验证PostgreSQL配置
+func ValidateConfig(cfg *config.PostgresConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("PostgreSQL config is required")
+	}
+	if cfg.Host == "" {
+		return fmt.Errorf("PostgreSQL host is required")
+	}
+	if cfg.Username == "" {
+		return fmt.Errorf("PostgreSQL username is required")
+	}
+	if cfg.Database == "" {
+		return fmt.Errorf("PostgreSQL database name is required")
+	}
+	return nil
+}
@@ -76,13 +76,13 @@ const routes = [
             path: 'llm-config',
             name: 'LLMConfig',
             component: () => import('../views/admin/LLMConfig.vue'),
-            meta: { title: 'LLM配置管理' }
+            meta: { title: 'LLM配置管理', allowOrgRoles: true }
           },
           {
             path: 'tts-config',
             name: 'TTSConfig',
             component: () => import('../views/admin/TTSConfig.vue'),
-            meta: { title: 'TTS配置管理' }
+            meta: { title: 'TTS配置管理', allowOrgRoles: true }
           },
           {
             path: 'speaker-config',
@@ -156,6 +156,12 @@ const routes = [
             component: () => import('../views/admin/PoolStats.vue'),
             meta: { title: '资源池统计' }
           },
+          {
+            path: 'mcp-tool-calls',
+            name: 'MCPToolCalls',
+            component: () => import('../views/admin/MCPToolCalls.vue'),
+            meta: { title: 'MCP工具调用记录' }
+          },
           {
             path: 'global-roles',
             name: 'GlobalRoles',
@@ -166,19 +172,31 @@ const routes = [
             path: 'users',
             name: 'Users',
             component: () => import('../views/admin/Users.vue'),
-            meta: { title: '用户管理' }
+            meta: { title: '用户管理', allowOrgRoles: true }
+          },
+          {
+            path: 'organizations',
+            name: 'Organizations',
+            component: () => import('../views/admin/Organizations.vue'),
+            meta: { title: '组织管理' }
+          },
+          {
+            path: 'audit-logs',
+            name: 'AuditLogs',
+            component: () => import('../views/admin/AuditLogs.vue'),
+            meta: { title: '审计日志', allowOrgRoles: ['org_owner'] }
           },
           {
             path: 'devices',
             name: 'AdminDevices',
             component: () => import('../views/admin/Devices.vue'),
-            meta: { title: '设备管理' }
+            meta: { title: '设备管理', allowOrgRoles: true }
           },
           {
             path: 'agents',
             name: 'AdminAgents',
             component: () => import('../views/admin/Agents.vue'),
-            meta: { title: '智能体管理' }
+            meta: { title: '智能体管理', allowOrgRoles: true }
           }
         ]
       },
@@ -255,6 +273,18 @@ const routes = [
         component: () => import('../views/user/KnowledgeBases.vue'),
         meta: { title: '我的知识库' }
       },
+      {
+        path: '/security',
+        name: 'Security',
+        component: () => import('../views/user/Security.vue'),
+        meta: { title: '账号安全' }
+      },
+      {
+        path: '/webhooks',
+        name: 'Webhooks',
+        component: () => import('../views/user/Webhooks.vue'),
+        meta: { title: 'Webhook 推送' }
+      },
       {
         path: 'user/roles',
         name: 'UserRoles',
@@ -350,8 +380,12 @@ router.beforeEach(async (to, from, next) => {
     return
   }
   
-  // 如果普通用户访问管理员页面，跳转到用户控制台
-  if (to.meta.requiresAdmin && authStore.user?.role !== 'admin') {
+  // 如果普通用户访问管理员页面，跳转到用户控制台；组织内角色只能访问标记了 allowOrgRoles 的页面，
+  // allowOrgRoles 为数组时只允许其中的角色
+  const orgRoleAllowed = Array.isArray(to.meta.allowOrgRoles)
+    ? to.meta.allowOrgRoles.includes(authStore.user?.role)
+    : to.meta.allowOrgRoles && authStore.isOrgMember
+  if (to.meta.requiresAdmin && !authStore.isAdmin && !orgRoleAllowed) {
     next('/console')
     return
   }
@@ -9,23 +9,49 @@ export const useAuthStore = defineStore('auth', () => {
 
   const isAuthenticated = computed(() => !!token.value)
   const isAdmin = computed(() => user.value?.role === 'admin')
+  // 组织内角色（组织管理员、运维人员、只读成员）可以访问本组织的管理页面
+  const isOrgMember = computed(() => ['org_owner', 'operator', 'viewer'].includes(user.value?.role))
+
+  const saveSession = (data) => {
+    const { token: newToken, refresh_token: refreshToken, user: userData } = data
+
+    token.value = newToken
+    user.value = userData
+
+    localStorage.setItem('token', newToken)
+    localStorage.setItem('refresh_token', refreshToken || '')
+    localStorage.setItem('user', JSON.stringify(userData))
+    return userData
+  }
 
   const login = async (credentials) => {
     try {
       const response = await api.post('/login', credentials)
-      const { token: newToken, user: userData } = response.data
-      
-      token.value = newToken
-      user.value = userData
-      
-      localStorage.setItem('token', newToken)
-      localStorage.setItem('user', JSON.stringify(userData))
-      
-      return { success: true, user: userData }
+      const userData = saveSession(response.data)
+      return {
+        success: true,
+        user: userData,
+        twoFactorSetupRequired: !!response.data.two_factor_setup_required
+      }
     } catch (error) {
       return { 
         success: false, 
-        message: error.response?.data?.error || '登录失败' 
+        message: error.response?.data?.error || '登录失败',
+        twoFactorRequired: !!error.response?.data?.two_factor_required
+      }
+    }
+  }
+
+  // 单点登录回调后，用一次性票据换取令牌
+  const loginWithOIDCTicket = async (ticket) => {
+    try {
+      const response = await api.post('/auth/oidc/exchange', { ticket })
+      const userData = saveSession(response.data)
+      return { success: true, user: userData }
+    } catch (error) {
+      return {
+        success: false,
+        message: error.response?.data?.error || '单点登录失败'
       }
     }
   }
@@ -42,13 +68,32 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
-  const logout = () => {
+  const clearSession = () => {
     token.value = null
     user.value = null
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     localStorage.removeItem('user')
   }
 
+  // 退出登录：通知后端注销当前令牌，失败也清除本地登录状态
+  const logout = async () => {
+    if (token.value) {
+      try {
+        await api.post('/auth/logout')
+      } catch {
+        // 忽略，令牌可能已过期
+      }
+    }
+    clearSession()
+  }
+
+  // 退出所有设备：该用户的全部令牌立即失效
+  const revokeAllSessions = async () => {
+    await api.post('/auth/revoke-all')
+    clearSession()
+  }
+
   const getProfile = async () => {
     // 如果正在验证中，避免重复调用
     if (isValidating.value) {
@@ -61,7 +106,7 @@ export const useAuthStore = defineStore('auth', () => {
       user.value = response.data.user
       localStorage.setItem('user', JSON.stringify(response.data.user))
     } catch (error) {
-      logout()
+      clearSession()
       throw error // 重新抛出错误，让路由守卫能够处理
     } finally {
       isValidating.value = false
@@ -73,10 +118,13 @@ export const useAuthStore = defineStore('auth', () => {
     user,
     isAuthenticated,
     isAdmin,
+    isOrgMember,
     isValidating,
     login,
+    loginWithOIDCTicket,
     register,
     logout,
+    revokeAllSessions,
     getProfile
   }
 })
\ No newline at end of file
@@ -20,16 +20,62 @@ api.interceptors.request.use(
   }
 )
 
+const clearAuthAndRedirect = () => {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refresh_token')
+  localStorage.removeItem('user')
+  window.location.href = '/login'
+}
+
+// 访问令牌过期时用刷新令牌换新，并发请求共用同一次刷新
+let refreshPromise = null
+const refreshAccessToken = () => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshPromise = (refreshToken
+      ? axios.post('/api/auth/refresh', { refresh_token: refreshToken })
+      : Promise.reject(new Error('no refresh token'))
+    ).then((response) => {
+      localStorage.setItem('token', response.data.token)
+      localStorage.setItem('refresh_token', response.data.refresh_token)
+      return response.data.token
+    }).finally(() => {
+      refreshPromise = null
+    })
+  }
+  return refreshPromise
+}
+
+const noRefreshUrls = ['/login', '/auth/refresh', '/auth/logout']
+
 // 响应拦截器
 api.interceptors.response.use(
   (response) => {
     return response
   },
-  (error) => {
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && original && !original._retried && !noRefreshUrls.includes(original.url)) {
+      original._retried = true
+      try {
+        const token = await refreshAccessToken()
+        original.headers.Authorization = `Bearer ${token}`
+        return api(original)
+      } catch {
+        clearAuthAndRedirect()
+        return Promise.reject(error)
+      }
+    }
     if (error.response?.status === 401) {
-      localStorage.removeItem('token')
-      localStorage.removeItem('user')
-      window.location.href = '/login'
+      if (original?.url !== '/login' && original?.url !== '/auth/logout') {
+        clearAuthAndRedirect()
+      }
+    } else if (error.response?.status === 403 && error.response?.data?.two_factor_setup_required) {
+      // 账号必须启用两步验证，先跳转到账号安全页完成绑定
+      if (window.location.pathname !== '/security') {
+        ElMessage.warning('请先启用两步验证')
+        window.location.href = '/security'
+      }
     } else {
       ElMessage.error(error.response?.data?.error || '请求失败')
     }
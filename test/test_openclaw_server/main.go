@@ -959,9 +959,12 @@ func cloneMap(src map[string]interface{}) map[string]interface{} {
 
 func main() {
 	addr := flag.String("addr", ":18080", "listen address")
-	secret := flag.String("jwt-secret", "xiaozhi_admin_secret_key", "JWT secret")
+	secret := flag.String("jwt-secret", "", "JWT secret (same as the manager jwt.secret)")
 	verbose := flag.Bool("verbose", false, "enable verbose websocket logs")
 	flag.Parse()
+	if *secret == "" {
+		log.Fatalf("-jwt-secret is required")
+	}
 
 	server := NewTestServer(*secret, *verbose)
 